@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"ctb-cli/core"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Client performs the private key operations through the key agent.
+type Client struct {
+	sync.Mutex
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// Ensure Client implements Decrypter
+var _ core.Decrypter = &Client{}
+
+// NewClient connects to the agent listening on the socket at the specified path.
+func NewClient(socketPath string) (*Client, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, ErrAgentUnreachable
+	}
+	return &Client{
+		conn:    conn,
+		scanner: bufio.NewScanner(conn),
+	}, nil
+}
+
+// Close closes the connection to the agent.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// PublicKey returns the public key of the user held by the agent.
+func (c *Client) PublicKey() (core.PublicKey, error) {
+	data, err := c.call(request{Op: opPublicKey})
+	if err != nil {
+		return core.EmptyPublicKey(), err
+	}
+	return core.NewPublicKeyFromBytes(data), nil
+}
+
+// OpenDataKey asks the agent to decrypt a data key sealed with the public key of the user.
+func (c *Client) OpenDataKey(serialized string) (*core.Key, error) {
+	data, err := c.call(request{Op: opOpenDataKey, Data: serialized})
+	if err != nil {
+		return nil, err
+	}
+	key, err := core.KeyFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// SigningPublicKey returns the signing public key of the user held by the agent.
+func (c *Client) SigningPublicKey() (ed25519.PublicKey, error) {
+	data, err := c.call(request{Op: opSigningKey})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Sign asks the agent to sign the message with the signing key of the user.
+func (c *Client) Sign(message []byte) ([]byte, error) {
+	return c.call(request{Op: opSign, Data: base64.StdEncoding.EncodeToString(message)})
+}
+
+// EncapsulationKey returns the ML-KEM encapsulation key of the user held by the agent.
+func (c *Client) EncapsulationKey() ([]byte, error) {
+	return c.call(request{Op: opEncapsulationKey})
+}
+
+// ProveIdentity asks the agent to sign the message with the X25519 private key of the user.
+func (c *Client) ProveIdentity(message []byte) ([]byte, error) {
+	return c.call(request{Op: opProveIdentity, Data: base64.StdEncoding.EncodeToString(message)})
+}
+
+// Stop asks the agent to stop.
+func (c *Client) Stop() error {
+	_, err := c.call(request{Op: opStop})
+	return err
+}
+
+// call sends the request to the agent and waits for its response.
+func (c *Client) call(req request) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	js, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(append(js, '\n')); err != nil {
+		return nil, ErrAgentUnreachable
+	}
+	if !c.scanner.Scan() {
+		return nil, ErrAgentUnreachable
+	}
+	var res response
+	if err := json.Unmarshal(c.scanner.Bytes(), &res); err != nil {
+		return nil, ErrInvalidRequest
+	}
+	if !res.Ok {
+		return nil, errors.New(res.Err)
+	}
+	return res.Data, nil
+}
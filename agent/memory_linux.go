@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package agent
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// allocLocked allocates memory outside of the Go heap and locks it, so that it is never swapped to disk.
+// The process is also made non-dumpable, so that the memory is not written to core dumps.
+func allocLocked(size int) ([]byte, error) {
+	if err := unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0); err != nil {
+		return nil, err
+	}
+	b, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Mlock(b); err != nil {
+		_ = unix.Munmap(b)
+		return nil, err
+	}
+	return b, nil
+}
+
+// freeLocked releases the memory allocated by allocLocked.
+func freeLocked(b []byte) {
+	_ = unix.Munlock(b)
+	_ = unix.Munmap(b)
+}
+
+// restrictSocket makes the socket of the agent only accessible by the user, with the mode bits.
+func restrictSocket(socketPath string, mode os.FileMode) error {
+	return os.Chmod(socketPath, mode)
+}
+
+// checkPeer makes sure that the client connected to the agent runs as the same user as the agent.
+func checkPeer(conn net.Conn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return ErrPeerNotAllowed
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err = errors.Join(err, credErr); err != nil {
+		return err
+	}
+	if cred.Uid != uint32(unix.Getuid()) {
+		return ErrPeerNotAllowed
+	}
+	return nil
+}
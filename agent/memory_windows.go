@@ -0,0 +1,60 @@
+//go:build windows
+// +build windows
+
+package agent
+
+import (
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// allocLocked allocates memory outside of the Go heap and locks it, so that it is never swapped to disk.
+func allocLocked(size int) ([]byte, error) {
+	addr, err := windows.VirtualAlloc(0, uintptr(size), windows.MEM_COMMIT|windows.MEM_RESERVE, windows.PAGE_READWRITE)
+	if err != nil {
+		return nil, err
+	}
+	if err := windows.VirtualLock(addr, uintptr(size)); err != nil {
+		_ = windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+		return nil, err
+	}
+	// The memory is not managed by the Go runtime, so its address is reinterpreted as a pointer
+	return unsafe.Slice((*byte)(*(*unsafe.Pointer)(unsafe.Pointer(&addr))), size), nil
+}
+
+// freeLocked releases the memory allocated by allocLocked.
+func freeLocked(b []byte) {
+	addr := uintptr(unsafe.Pointer(&b[0]))
+	_ = windows.VirtualUnlock(addr, uintptr(len(b)))
+	_ = windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+}
+
+// restrictSocket makes the socket of the agent only accessible by the user.
+// The mode bits are not enforced on Windows: the socket is given a protected DACL granting access to the user only,
+// and connecting to a Unix domain socket requires the write access to its file.
+func restrictSocket(socketPath string, mode os.FileMode) error {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return err
+	}
+	sd, err := windows.SecurityDescriptorFromString("D:P(A;;GA;;;" + user.User.Sid.String() + ")")
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	return windows.SetNamedSecurityInfo(socketPath, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+}
+
+// checkPeer does not check the client on Windows, since the credentials of the peer of a Unix domain socket
+// are not available. The access to the agent is enforced by the DACL of its socket (see restrictSocket),
+// and the default socket is created in the temporary directory of the user, which is not accessible by other users.
+func checkPeer(conn net.Conn) error {
+	return nil
+}
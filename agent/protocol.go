@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// SocketEnv is the environment variable holding the path of the agent socket.
+	SocketEnv = "CTB_AGENT_SOCK"
+)
+
+// Operations of the agent protocol
+const (
+	opPublicKey        = "public-key"        // returns the public key of the user
+	opOpenDataKey      = "open-data-key"     // opens a data key sealed with the public key of the user
+	opSigningKey       = "signing-key"       // returns the signing public key of the user
+	opSign             = "sign"              // signs a message (base64 encoded) with the signing key of the user
+	opEncapsulationKey = "encapsulation-key" // returns the ML-KEM encapsulation key of the user
+	opProveIdentity    = "prove-identity"    // signs a message (base64 encoded) with the X25519 private key of the user
+	opStop             = "stop"              // stops the agent
+)
+
+var (
+	ErrAgentNotRunning  = errors.New("agent is not running")
+	ErrInvalidRequest   = errors.New("invalid agent request")
+	ErrPeerNotAllowed   = errors.New("agent peer not allowed")
+	ErrAgentUnreachable = errors.New("cannot connect to the agent")
+)
+
+// request is a request sent to the agent. Each request is a JSON object on a line.
+type request struct {
+	Op   string `json:"op"`
+	Data string `json:"data,omitempty"`
+}
+
+// response is the response of the agent to a request.
+type response struct {
+	Ok   bool   `json:"ok"`
+	Err  string `json:"err,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// DefaultSocketPath returns the default path of the agent socket.
+// It is located in the runtime directory of the user if available, otherwise in a private temporary directory.
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "ctb-agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("ctb-agent-%d", os.Getuid()), "agent.sock")
+}
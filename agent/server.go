@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"bufio"
+	"ctb-cli/core"
+	"ctb-cli/services/key_service"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Server is the key agent. It holds the private key of the user in locked memory
+// and performs the private key operations for its clients over a Unix domain socket,
+// so that the private key never leaves the agent.
+type Server struct {
+	privateKey []byte
+	decrypter  core.Decrypter
+	listener   net.Listener
+	socketPath string
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+	keyLock  sync.RWMutex // held for reading while the private key is used
+}
+
+// NewServer creates a new agent holding the private key.
+// The private key is copied to locked memory, so that it is never swapped to disk.
+func NewServer(privateKey core.PrivateKey) (*Server, error) {
+	locked, err := allocLocked(len(privateKey.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	copy(locked, privateKey.Bytes())
+	return &Server{
+		privateKey: locked,
+		decrypter:  key_service.NewLocalDecrypter(core.NewPrivateKeyFromBytes(locked)),
+		stopped:    make(chan struct{}),
+	}, nil
+}
+
+// Listen creates the socket of the agent at the specified path.
+// The socket is only accessible by the user. A stale socket of a stopped agent is removed.
+func (s *Server) Listen(socketPath string) error {
+	err := os.MkdirAll(filepath.Dir(socketPath), 0700)
+	if err != nil {
+		return err
+	}
+	// Remove the socket of a stopped agent
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return errors.New("an agent is already running on " + socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return err
+		}
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	if err := restrictSocket(socketPath, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+	s.listener = listener
+	s.socketPath = socketPath
+	return nil
+}
+
+// Serve accepts the connections of the clients until the agent is stopped.
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.stopped:
+				return nil
+			default:
+				return err
+			}
+		}
+		go s.handle(conn)
+	}
+}
+
+// Stop stops the agent, removes its socket and erases the private key.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+		if s.listener != nil {
+			s.listener.Close()
+			os.Remove(s.socketPath)
+		}
+		// Erase the private key once it is not used anymore
+		s.keyLock.Lock()
+		defer s.keyLock.Unlock()
+		for i := range s.privateKey {
+			s.privateKey[i] = 0
+		}
+		freeLocked(s.privateKey)
+	})
+}
+
+// handle processes the requests of a client connection.
+// The connection is rejected if the client does not run as the same user as the agent.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	if err := checkPeer(conn); err != nil {
+		_ = json.NewEncoder(conn).Encode(response{Err: err.Error()})
+		return
+	}
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			_ = encoder.Encode(response{Err: ErrInvalidRequest.Error()})
+			return
+		}
+		res := s.process(req)
+		if err := encoder.Encode(res); err != nil {
+			return
+		}
+		if req.Op == opStop {
+			s.Stop()
+			return
+		}
+	}
+}
+
+// process performs the operation of the request.
+func (s *Server) process(req request) response {
+	s.keyLock.RLock()
+	defer s.keyLock.RUnlock()
+	select {
+	case <-s.stopped:
+		return response{Err: ErrAgentNotRunning.Error()}
+	default:
+	}
+	switch req.Op {
+	case opPublicKey:
+		publicKey, err := s.decrypter.PublicKey()
+		if err != nil {
+			return response{Err: err.Error()}
+		}
+		return response{Ok: true, Data: publicKey.Bytes()}
+	case opOpenDataKey:
+		key, err := s.decrypter.OpenDataKey(req.Data)
+		if err != nil {
+			return response{Err: err.Error()}
+		}
+		return response{Ok: true, Data: key.Bytes()}
+	case opSigningKey:
+		publicKey, err := s.decrypter.SigningPublicKey()
+		if err != nil {
+			return response{Err: err.Error()}
+		}
+		return response{Ok: true, Data: publicKey}
+	case opSign:
+		message, err := base64.StdEncoding.DecodeString(req.Data)
+		if err != nil {
+			return response{Err: ErrInvalidRequest.Error()}
+		}
+		signature, err := s.decrypter.Sign(message)
+		if err != nil {
+			return response{Err: err.Error()}
+		}
+		return response{Ok: true, Data: signature}
+	case opEncapsulationKey:
+		encapsulationKey, err := s.decrypter.EncapsulationKey()
+		if err != nil {
+			return response{Err: err.Error()}
+		}
+		return response{Ok: true, Data: encapsulationKey}
+	case opProveIdentity:
+		message, err := base64.StdEncoding.DecodeString(req.Data)
+		if err != nil {
+			return response{Err: ErrInvalidRequest.Error()}
+		}
+		proof, err := s.decrypter.ProveIdentity(message)
+		if err != nil {
+			return response{Err: err.Error()}
+		}
+		return response{Ok: true, Data: proof}
+	case opStop:
+		return response{Ok: true}
+	}
+	return response{Err: ErrInvalidRequest.Error()}
+}
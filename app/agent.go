@@ -0,0 +1,96 @@
+package app
+
+import (
+	"ctb-cli/agent"
+	"ctb-cli/core"
+)
+
+// AgentResult is the result of the agent commands.
+type AgentResult struct {
+	Socket    string `json:"socket" yaml:"socket" xml:"socket"`
+	PublicKey string `json:"public_key" yaml:"public_key" xml:"public_key"`
+}
+
+// UseAgent makes the application use the key agent listening on the socket for the private key operations,
+// when no private key is given.
+func (a *App) UseAgent(socketPath string) {
+	a.agentSocket = socketPath
+}
+
+// StartAgent creates the key agent holding the private key, listening on the socket at the specified path.
+// If socketPath is empty, the default socket path is used.
+// The agent serves the clients when ServeAgent is called.
+// The decoded private key is erased once it is copied to the locked memory of the agent.
+func (a *App) StartAgent(encodedPrivateKey string, socketPath string) core.AppResult {
+	privateKey, err := core.NewPrivateKeyFromEncoded(encodedPrivateKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	defer privateKey.Wipe()
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if socketPath == "" {
+		socketPath = agent.DefaultSocketPath()
+	}
+	// Create the agent and its socket
+	server, err := agent.NewServer(privateKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := server.Listen(socketPath); err != nil {
+		server.Stop()
+		return core.NewAppResultWithError(err)
+	}
+	a.agent = server
+	return core.NewAppResultWithValue(AgentResult{
+		Socket:    socketPath,
+		PublicKey: publicKey.String(),
+	})
+}
+
+// ServeAgent serves the clients of the agent created by StartAgent until it is stopped.
+func (a *App) ServeAgent() core.AppResult {
+	if err := a.agent.Serve(); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// StopAgent stops the agent created by StartAgent.
+func (a *App) StopAgent() {
+	if a.agent != nil {
+		a.agent.Stop()
+	}
+}
+
+// GetAgentStatus connects to the agent listening on the socket and returns its public key.
+func (a *App) GetAgentStatus(socketPath string) core.AppResult {
+	client, err := agent.NewClient(socketPath)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	defer client.Close()
+	publicKey, err := client.PublicKey()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(AgentResult{
+		Socket:    socketPath,
+		PublicKey: publicKey.String(),
+	})
+}
+
+// StopRemoteAgent asks the agent listening on the socket to stop.
+func (a *App) StopRemoteAgent(socketPath string) core.AppResult {
+	client, err := agent.NewClient(socketPath)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	defer client.Close()
+	if err := client.Stop(); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
@@ -1,19 +1,25 @@
 package app
 
 import (
+	"context"
+	"ctb-cli/agent"
 	"ctb-cli/config"
 	"ctb-cli/core"
+	"ctb-cli/crypto/stream"
 	"ctb-cli/fuse"
-	"ctb-cli/objectstorage/cloud"
 	"ctb-cli/repositories"
 	"ctb-cli/services/config_service"
+	"ctb-cli/services/directory_service"
 	"ctb-cli/services/filesystem_service"
+	"ctb-cli/services/invitation_service"
 	"ctb-cli/services/key_service"
 	"ctb-cli/services/object_service"
 	"ctb-cli/services/share_service"
 	"errors"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 )
 
 // App represents the main application struct.
@@ -21,11 +27,25 @@ type App struct {
 	keyStore      core.KeyService
 	fileSystem    *filesystem_service.FileSystem
 	shareService  *share_service.Service
+	directory     *directory_service.Service
+	invitations   *invitation_service.Service
 	configService *config_service.ConfigService
+	pathResolver  *repositories.PathResolver
+	linkRepo      *repositories.LinkRepository
+	storage       core.CloudStorage
 
 	// fuse is the fuse service used by the application
 	fuse *fuse.CtbFs
 
+	// agentSocket is the socket of the key agent used when no private key is given
+	agentSocket string
+	// agent is the key agent started by the application
+	agent *agent.Server
+
+	// ctx is the context of the transfers of the object storage, canceled on unmount or on an interrupt signal
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	// Config is the configuration of the application
 	cfg *config.Config
 }
@@ -37,6 +57,7 @@ var (
 	ErrRootFolderNotEmpty        = errors.New("root folder is not empty")
 	ErrCreatingRepositoryConfig  = errors.New("error creating repository config")
 	ErrInitRepositoryFolders     = errors.New("error initializing repository folders")
+	ErrRecoveryKeyWithHybrid     = errors.New("the recovery key must publish its encapsulation key before it is set in a repository requiring the hybrid key wrapping: create the repository, then use recovery-key publish and recovery-key set")
 )
 
 // New returns a new App
@@ -46,27 +67,89 @@ func New(cfg config.Config) App {
 	}
 }
 
-func (a *App) initServices() core.AppResult {
-	cloudClient := cloud.NewClient("http://localhost:1323", 10*1024*1024)
-	//cloudClient := objectstorage.NewDummyClient()
+// newInterruptContext returns a context canceled by the first interrupt or termination signal, which aborts the
+// transfers in progress. The default behavior of the signals is then restored, so a second signal stops the process.
+func newInterruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+		cancel()
+	}()
+	return ctx, cancel
+}
 
+func (a *App) initServices() core.AppResult {
 	// Get the root paths
 	root, _ := a.cfg.GetRepoCtbRoot()
 	cachePath, _ := a.cfg.GetCacheRoot()
 
+	// Create the path resolver and enable name encryption if the repository uses it
+	a.pathResolver = repositories.NewPathResolver(root)
+	a.configService = config_service.New(root, a.pathResolver)
+	a.pathResolver.SetEncryptNames(a.configService.IsNameEncryptionEnabled())
+
+	// Create the object storage selected in the configuration
+	if a.ctx == nil {
+		a.ctx, a.cancel = newInterruptContext()
+	}
+	storage, err := a.newStorageClient(root)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	a.storage = storage
+
 	// Create the repositories
-	keyRepository := repositories.NewKeyRepositoryFile(root)
+	keyRepository := repositories.NewKeyRepositoryFile(root, a.pathResolver)
 	objectCacheRepository := repositories.NewObjectCacheRepository(cachePath)
-	objectRepository := repositories.NewObjectRepository(root)
-	linkRepository := repositories.NewLinkRepository(root)
-	vaultRepository := repositories.NewVaultRepositoryFile(root)
+	objectRepository := repositories.NewObjectRepository(root, a.pathResolver)
+	a.linkRepo = repositories.NewLinkRepository(root, a.pathResolver)
+	a.linkRepo.SetEncryptLinks(a.configService.IsLinkEncryptionEnabled())
+	a.linkRepo.SetLinksMigrated(a.configService.IsLinksMigrated())
+	vaultRepository := repositories.NewVaultRepositoryFile(root, a.pathResolver)
+	userRepository := repositories.NewUserRepositoryFile(root)
+	groupRepository := repositories.NewGroupRepositoryFile(root)
+	directoryRepository := repositories.NewDirectoryRepositoryFile(root)
+	invitationRepository := repositories.NewInvitationRepositoryFile(root)
+	signerRepository := repositories.NewSignerRepositoryFile(root)
+	policyRepository := repositories.NewPolicyRepositoryFile(root)
 
 	// Create the services
-	a.keyStore = key_service.NewKeyStore(keyRepository, vaultRepository)
-	objectService := object_service.NewService(&objectCacheRepository, &objectRepository, cloudClient)
-	a.shareService = share_service.NewService(a.keyStore, linkRepository, vaultRepository, &objectService)
-	a.configService = config_service.New(root)
-	a.fileSystem = filesystem_service.NewFileSystem(a.keyStore, objectService, linkRepository, vaultRepository, *a.configService)
+	keyStore := key_service.NewKeyStore(keyRepository, vaultRepository, userRepository, groupRepository, signerRepository)
+	// The root and top-level vault keys are sealed with the recovery key of the policy of the repository, if any
+	keyStore.SetPolicyRepository(policyRepository)
+	a.keyStore = keyStore
+	objectService := object_service.NewService(&objectCacheRepository, &objectRepository, a.storage, a.keyStore)
+	a.shareService = share_service.NewService(a.keyStore, a.linkRepo, vaultRepository, &objectService)
+	a.directory = directory_service.NewService(directoryRepository, vaultRepository, a.keyStore)
+	a.invitations = invitation_service.NewService(invitationRepository, a.keyStore)
+	a.fileSystem = filesystem_service.NewFileSystem(a.keyStore, objectService, a.linkRepo, vaultRepository, *a.configService)
+
+	// The objects, vaults, shares and groups are signed by their writer, and the unsigned records are refused
+	// unless the user accepts the legacy records explicitly in the user configuration, which the writers of the
+	// repository cannot change
+	keyRepository.SetRecordSigner(a.keyStore)
+	vaultRepository.SetRecordSigner(a.keyStore)
+	groupRepository.SetRecordSigner(keyStore)
+	policyRepository.SetRecordSigner(a.keyStore)
+	a.keyStore.SetAcceptUnsignedRecords(a.cfg.IsUnsignedRecordsAllowed())
+	// The data keys are sealed with the hybrid X25519 + ML-KEM-768 scheme if the repository or the user requires it.
+	// The configuration of the repository can only add the requirement, since a writer of the repository can change it
+	a.keyStore.SetRequireHybridKeyWrapping(a.configService.IsHybridKeyWrappingRequired() || a.cfg.IsHybridKeyWrappingRequired())
+
+	// The names and links are encrypted using the vault keys of the directories
+	a.pathResolver.SetDirKeyProvider(func(dirPath string) (*core.Key, error) {
+		keyInfo, err := a.keyStore.GetVaultKeyByPath(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		return &keyInfo.Key, nil
+	})
 
 	return core.NewAppResult()
 }
@@ -74,8 +157,18 @@ func (a *App) initServices() core.AppResult {
 // SetPrivateKey sets the private key used by the application.
 // It takes an encoded private key as input and returns an AppResult.
 // If the private key is successfully decoded and its size is valid, it is set in the keyStore.
+// If no private key is given and a key agent is set, the private key operations are delegated to the agent.
 // Otherwise, an error result is returned.
 func (a *App) SetPrivateKey(encodedPrivateKey string) core.AppResult {
+	// Use the key agent
+	if encodedPrivateKey == "" && a.agentSocket != "" {
+		client, err := agent.NewClient(a.agentSocket)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		a.keyStore.SetDecrypter(client)
+		return core.NewAppResult()
+	}
 	// Decode the private key
 	privateKey, err := core.NewPrivateKeyFromEncoded(encodedPrivateKey)
 	if err != nil {
@@ -105,11 +198,32 @@ func (a *App) SetAndCheckPrivateKey(encodedPrivateKey string) core.AppResult {
 // InitRepo initializes the repository by creating the necessary folders, setting the private key,
 // and joining the user. It also creates a vault in the root path.
 // The encryptedPrivateKey parameter is the encrypted private key used for authentication.
+// If padSizes is true, the objects are padded to hide the exact file sizes.
+// The objects are encrypted with the algorithm ("chacha20-poly1305" or "aes-256-gcm").
+// If recoveryKey is not empty, it is recorded as the recovery public key of the repository and the root vault key is sealed with it.
+// If hybrid is true, the repository requires the data keys to be sealed with the hybrid X25519 + ML-KEM-768 scheme.
 // It returns an AppResult indicating the success or failure of the initialization.
-func (a *App) InitRepo(encryptedPrivateKey string) core.AppResult {
+func (a *App) InitRepo(encryptedPrivateKey string, padSizes bool, algorithm string, recoveryKey string, hybrid bool) core.AppResult {
 	// Get the root and temp paths
 	root, _ := a.cfg.GetRepoCtbRoot()
 
+	// Check the algorithm before creating anything
+	if _, err := stream.ParseAlgorithm(algorithm); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// Check the recovery key before creating anything
+	var recoveryPublicKey *core.PublicKey
+	if recoveryKey != "" && hybrid {
+		return core.NewAppResultWithError(ErrRecoveryKeyWithHybrid)
+	}
+	if recoveryKey != "" {
+		publicKey, err := core.NewPublicKeyFromEncoded(recoveryKey)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		recoveryPublicKey = &publicKey
+	}
+
 	// Check if the root folder is empty
 	rootFiles, err := os.ReadDir(root)
 	if err != nil {
@@ -139,6 +253,30 @@ func (a *App) InitRepo(encryptedPrivateKey string) core.AppResult {
 	if err != nil {
 		return core.NewAppResultWithError(ErrCreatingRepositoryConfig)
 	}
+	// Names are not encrypted by default: the recipients of a share below the root could not resolve its path,
+	// since they have no key of the parent directories (see migrate-names)
+	// New repositories encrypt the file links, so they never have links to migrate
+	if err := a.configService.SetLinkEncryption(true); err != nil {
+		return core.NewAppResultWithError(ErrCreatingRepositoryConfig)
+	}
+	if err := a.configService.SetLinksMigrated(true); err != nil {
+		return core.NewAppResultWithError(ErrCreatingRepositoryConfig)
+	}
+	a.linkRepo.SetEncryptLinks(true)
+	a.linkRepo.SetLinksMigrated(true)
+	// Size padding is optional since it increases the storage usage
+	if err := a.configService.SetSizePadding(padSizes); err != nil {
+		return core.NewAppResultWithError(ErrCreatingRepositoryConfig)
+	}
+	if err := a.configService.SetEncryptionAlgorithm(algorithm); err != nil {
+		return core.NewAppResultWithError(ErrCreatingRepositoryConfig)
+	}
+	if err := a.configService.SetHybridKeyWrapping(hybrid); err != nil {
+		return core.NewAppResultWithError(ErrCreatingRepositoryConfig)
+	}
+	a.keyStore.SetRequireHybridKeyWrapping(hybrid || a.cfg.IsHybridKeyWrappingRequired())
+	// The root vault key is sealed with the recovery key when it is created
+	a.keyStore.SetRecoveryKey(recoveryPublicKey)
 
 	// Set the private key
 	setResult := a.SetPrivateKey(encryptedPrivateKey)
@@ -150,6 +288,12 @@ func (a *App) InitRepo(encryptedPrivateKey string) core.AppResult {
 	if err := a.fileSystem.CreateVaultInPath("/"); err != nil {
 		return core.NewAppResultWithError(err)
 	}
+	// Record the recovery key in the policy of the repository, proved with the root vault key
+	if recoveryPublicKey != nil {
+		if err := a.keyStore.SaveRecoveryKey(*recoveryPublicKey); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
 	return core.NewAppResult()
 }
 
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"errors"
+	"path/filepath"
+)
+
+// AuditResult is the last writer of a file or directory, as attested by its signature.
+type AuditResult struct {
+	Path   string `json:"path" yaml:"path" xml:"path"`
+	Type   string `json:"type" yaml:"type" xml:"type"`
+	Writer string `json:"writer,omitempty" yaml:"writer,omitempty" xml:"writer,omitempty"`
+	Alias  string `json:"alias,omitempty" yaml:"alias,omitempty" xml:"alias,omitempty"`
+	Status string `json:"status" yaml:"status" xml:"status"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty" xml:"error,omitempty"`
+}
+
+// Audit verifies the signatures of the file or directory located at the path and of its sub files,
+// and reports the user who last wrote each of them.
+// The writer of a directory is the user who last wrote its vault.
+// It returns an AppResult containing a list of AuditResult.
+func (a *App) Audit(path string, encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	path = filepath.Join(string(filepath.Separator), path)
+	if !a.linkRepo.IsValidPath(path) {
+		return core.NewAppResultWithError(core.ErrInvalidPath)
+	}
+	results := make([]AuditResult, 0)
+	err := a.auditPath(path, &results)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(results)
+}
+
+// auditPath adds the writer of the file or directory located at the path and of its sub files to the results.
+// The signatures which cannot be read are reported with the error.
+func (a *App) auditPath(path string, results *[]AuditResult) error {
+	isDir := a.linkRepo.IsDir(path)
+	result := AuditResult{Path: path, Type: "file"}
+	if isDir {
+		result.Type = "dir"
+	}
+	signature, status, err := a.fileSystem.GetSignature(path)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Status = status
+		if signature != nil {
+			result.Writer = signature.Writer
+			entry, err := a.directory.Get(signature.Writer)
+			if err != nil && !errors.Is(err, repositories.ErrDirectoryEntryNotFound) {
+				return err
+			}
+			result.Alias = entry.Alias
+		}
+	}
+	*results = append(*results, result)
+	if !isDir {
+		return nil
+	}
+	subFiles, err := a.linkRepo.GetSubFiles(path)
+	if err != nil {
+		return nil
+	}
+	for _, subFile := range subFiles {
+		if subFile.Name() == ".meta" {
+			continue
+		}
+		err = a.auditPath(filepath.Join(path, subFile.Name()), results)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
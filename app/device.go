@@ -0,0 +1,85 @@
+package app
+
+import (
+	"ctb-cli/core"
+)
+
+// ListDevices returns the user of the private key with the devices of the user.
+// It returns an AppResult containing the core.User.
+func (a *App) ListDevices(encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	user, err := a.keyStore.GetUser()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(user)
+}
+
+// AddDevice adds the device with the public key to the user of the private key.
+// The files shared with the user are shared with the new device.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) AddDevice(publicKey string, name string, encryptedPrivateKey string) core.AppResult {
+	return a.updateDevices(publicKey, encryptedPrivateKey, func(devicePublicKey core.PublicKey) error {
+		return a.keyStore.AddDevice(devicePublicKey, name)
+	})
+}
+
+// AcceptDevice accepts that the device of the private key is a device of the user with the id,
+// once another device of the user has added it. The files shared with the user are then opened by the device.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) AcceptDevice(userId string, encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	if err := a.keyStore.AcceptDevice(userId); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// RemoveDevice removes the device with the public key from the user of the private key.
+// The files shared with the user are not shared with the device anymore.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) RemoveDevice(publicKey string, encryptedPrivateKey string) core.AppResult {
+	return a.updateDevices(publicKey, encryptedPrivateKey, func(devicePublicKey core.PublicKey) error {
+		return a.keyStore.RemoveDevice(devicePublicKey)
+	})
+}
+
+// updateDevices initializes the app, sets the private key and applies the update to the device with the public key.
+func (a *App) updateDevices(publicKey string, encryptedPrivateKey string, update func(devicePublicKey core.PublicKey) error) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	devicePublicKey, err := core.NewPublicKeyFromEncoded(publicKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := update(devicePublicKey); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
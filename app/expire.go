@@ -0,0 +1,116 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/services/filesystem_service"
+	"path/filepath"
+	"time"
+)
+
+// ExpiredShareResult is a share deleted because it is expired.
+type ExpiredShareResult struct {
+	Path      string    `json:"path" yaml:"path" xml:"path"`
+	Recipient string    `json:"recipient" yaml:"recipient" xml:"recipient"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at" xml:"expires_at"`
+}
+
+// ExpireResult is the result of the deletion of the expired shares.
+type ExpireResult struct {
+	Expired []ExpiredShareResult `json:"expired" yaml:"expired" xml:"expired"`
+	// DroppedPassphraseShares are the paths of the directories whose share with a passphrase was removed
+	// by the rotation of the keys, since the passphrase is not known to share the new keys
+	DroppedPassphraseShares []string `json:"dropped_passphrase_shares" yaml:"dropped_passphrase_shares" xml:"dropped_passphrase_shares"`
+}
+
+// Expire deletes the expired shares of the files and directories the user has access to,
+// and rotates the keys of the expired shares: the vault keys of a directory and its sub directories are rotated
+// and the files are encrypted again according to the re-encryption mode ("none", "lazy" or "eager"),
+// and a file is encrypted again with a new key.
+// It returns an AppResult containing an ExpireResult.
+func (a *App) Expire(encryptedPrivateKey string, reencrypt string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	mode, err := filesystem_service.ParseReencryptMode(reencrypt)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	result, err := a.expireShares(mode)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(result)
+}
+
+// expireShares deletes the expired shares in the repository and rotates their keys.
+func (a *App) expireShares(mode filesystem_service.ReencryptMode) (ExpireResult, error) {
+	result := ExpireResult{
+		Expired:                 make([]ExpiredShareResult, 0),
+		DroppedPassphraseShares: make([]string, 0),
+	}
+	// resume the rotations interrupted by a previous run
+	dropped, _, err := a.fileSystem.ResumeRotations("/", mode)
+	result.DroppedPassphraseShares = append(result.DroppedPassphraseShares, dropped...)
+	if err == nil {
+		err = a.expireSharesInPath("/", mode, time.Now(), &result)
+	}
+	// wait for the files to be encrypted and uploaded
+	a.fileSystem.Wait()
+	return result, err
+}
+
+// expireSharesInPath deletes the expired shares of the file or directory located at the path and of its sub files.
+// The files and directories the user has no access to are skipped.
+func (a *App) expireSharesInPath(path string, mode filesystem_service.ReencryptMode, now time.Time, result *ExpireResult) error {
+	isDir := a.linkRepo.IsDir(path)
+	if keyId, startVaultId, startVaultPath, err := a.shareService.GetKeyIdByPath(path); err == nil {
+		shares, err := a.keyStore.ExpireShares(keyId, startVaultId, startVaultPath, now)
+		if err != nil {
+			return err
+		}
+		for _, share := range shares {
+			result.Expired = append(result.Expired, ExpiredShareResult{
+				Path:      path,
+				Recipient: share.Recipient,
+				ExpiresAt: share.ExpiresAt,
+			})
+		}
+		// rotate the keys the recipients had access to
+		if len(shares) > 0 {
+			if isDir {
+				var dropped []string
+				dropped, err = a.fileSystem.RotateVault(path, mode)
+				result.DroppedPassphraseShares = append(result.DroppedPassphraseShares, dropped...)
+			} else {
+				err = a.fileSystem.Reencrypt(path)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !isDir {
+		return nil
+	}
+	subFiles, err := a.linkRepo.GetSubFiles(path)
+	if err != nil {
+		return nil
+	}
+	for _, subFile := range subFiles {
+		if subFile.Name() == ".meta" {
+			continue
+		}
+		err = a.expireSharesInPath(filepath.Join(path, subFile.Name()), mode, now, result)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,30 +2,59 @@ package app
 
 import (
 	"ctb-cli/core"
+	"ctb-cli/crypto/mnemonic"
 	"ctb-cli/services/key_service"
+
+	"golang.org/x/crypto/curve25519"
 )
 
 // GenerateUserKey generates a user private key and returns it as a string.
+// If withMnemonic is true, the private key is also returned as a mnemonic, to be backed up on paper.
 // It returns an AppResult containing the generated key on success,
 // or an AppErrorResult containing the error on failure.
-func (a *App) GenerateUserKey() core.AppResult {
-	keyStore := key_service.NewKeyStore(nil, nil)
+func (a *App) GenerateUserKey(withMnemonic bool) core.AppResult {
+	keyStore := key_service.NewKeyStore(nil, nil, nil, nil, nil)
 	// generate the key
 	key, err := keyStore.GenerateUserKey()
 	if err != nil {
 		return core.NewAppResultWithError(err)
 	}
+	return newGenerateUserKeyResult(*key, withMnemonic)
+}
+
+// RestoreUserKey decodes the private key from the mnemonic created by GenerateUserKey.
+// It returns an AppResult containing the private key and its public key.
+func (a *App) RestoreUserKey(words string) core.AppResult {
+	decoded, err := mnemonic.Decode(words)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if len(decoded) != curve25519.ScalarSize {
+		return core.NewAppResultWithError(ErrInvalidPrivateKeySize)
+	}
+	return newGenerateUserKeyResult(core.NewPrivateKeyFromBytes(decoded), false)
+}
+
+// newGenerateUserKeyResult returns the result with the private key, its public key, and optionally its mnemonic.
+func newGenerateUserKeyResult(key core.PrivateKey, withMnemonic bool) core.AppResult {
 	publicKey, err := key.ToPublicKey()
 	if err != nil {
 		return core.NewAppResultWithError(err)
 	}
-	return core.NewAppResultWithValue(GenerateUserKeyResult{
+	result := GenerateUserKeyResult{
 		PrivateKey: key.Unsafe().String(),
 		PublicKey:  publicKey.String(),
-	})
+	}
+	if withMnemonic {
+		if result.Mnemonic, err = mnemonic.Encode(key.Bytes()); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	return core.NewAppResultWithValue(result)
 }
 
 type GenerateUserKeyResult struct {
 	PrivateKey string `json:"private_key"`
 	PublicKey  string `json:"public_key"`
+	Mnemonic   string `json:"mnemonic,omitempty"`
 }
@@ -2,6 +2,7 @@ package app
 
 import (
 	"ctb-cli/core"
+	"ctb-cli/repositories"
 	"ctb-cli/services/config_service"
 )
 
@@ -17,7 +18,7 @@ func (a *App) GetStatus(encryptedPrivateKey string) core.AppResult {
 
 	// check if the repository is valid
 	rootPath, _ := a.cfg.GetRepoCtbRoot()
-	valid := config_service.New(rootPath).IsRepositoryConfigExists("")
+	valid := config_service.New(rootPath, repositories.NewPathResolver(rootPath)).IsRepositoryConfigExists("")
 
 	if !valid {
 		// return the repository status with IsValid = false if the repository config does not exist
@@ -31,8 +32,8 @@ func (a *App) GetStatus(encryptedPrivateKey string) core.AppResult {
 		return core.NewAppResultWithValue(core.NewInvalidRepositoyStatus(false))
 	}
 
-	// set the private key if it was passed
-	if encryptedPrivateKey != "" {
+	// set the private key if it was passed or held by the key agent
+	if encryptedPrivateKey != "" || a.agentSocket != "" {
 		a.SetAndCheckPrivateKey(encryptedPrivateKey)
 	}
 
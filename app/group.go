@@ -0,0 +1,111 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"sort"
+)
+
+// GroupResult is a group of users, with the user ids of its members.
+type GroupResult struct {
+	Name      string   `json:"name" yaml:"name" xml:"name"`
+	PublicKey string   `json:"public_key" yaml:"public_key" xml:"public_key"`
+	Members   []string `json:"members" yaml:"members" xml:"members"`
+}
+
+// newGroupResult returns the GroupResult of the group.
+func newGroupResult(group core.Group) GroupResult {
+	members := make([]string, 0, len(group.Members))
+	for userId := range group.Members {
+		members = append(members, userId)
+	}
+	sort.Strings(members)
+	return GroupResult{
+		Name:      group.Name,
+		PublicKey: group.PublicKey,
+		Members:   members,
+	}
+}
+
+// CreateGroup creates a group with the specified name. The user of the private key is the first member of the group.
+// It returns an AppResult containing the GroupResult.
+func (a *App) CreateGroup(name string, encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	group, err := a.keyStore.CreateGroup(name)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(newGroupResult(*group))
+}
+
+// ListGroups returns the groups of the repository.
+// It returns an AppResult containing a list of GroupResult.
+func (a *App) ListGroups() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	groups, err := a.keyStore.ListGroups()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	results := make([]GroupResult, 0, len(groups))
+	for _, group := range groups {
+		results = append(results, newGroupResult(group))
+	}
+	return core.NewAppResultWithValue(results)
+}
+
+// AddGroupMember adds the user with the public key or alias to the group.
+// The user of the private key must be a member of the group.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) AddGroupMember(name string, publicKey string, encryptedPrivateKey string) core.AppResult {
+	return a.updateGroupMembers(publicKey, encryptedPrivateKey, func(memberPublicKey core.PublicKey) error {
+		return a.keyStore.AddGroupMember(name, memberPublicKey)
+	})
+}
+
+// RemoveGroupMember removes the user with the public key or alias from the group, and replaces the key pair of the group.
+// The user of the private key must be a member of the group.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) RemoveGroupMember(name string, publicKey string, encryptedPrivateKey string) core.AppResult {
+	return a.updateGroupMembers(publicKey, encryptedPrivateKey, func(memberPublicKey core.PublicKey) error {
+		return a.keyStore.RemoveGroupMember(name, memberPublicKey)
+	})
+}
+
+// updateGroupMembers initializes the app, sets the private key and applies the update to the member
+// with the public key or alias.
+func (a *App) updateGroupMembers(publicKey string, encryptedPrivateKey string, update func(memberPublicKey core.PublicKey) error) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	publicKey, err := a.resolveRecipient(publicKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	memberPublicKey, err := core.NewPublicKeyFromEncoded(publicKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := update(memberPublicKey); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"ctb-cli/services/identity_service"
+)
+
+// IdentityResult is the result of the identity commands.
+type IdentityResult struct {
+	PublicKey string `json:"public_key" yaml:"public_key" xml:"public_key"`
+	Path      string `json:"path" yaml:"path" xml:"path"`
+}
+
+// identityService creates the identity service for the identity file of the configuration.
+func (a *App) identityService() (*identity_service.Service, error) {
+	path, err := a.cfg.GetIdentityPath()
+	if err != nil {
+		return nil, err
+	}
+	return identity_service.NewService(repositories.NewIdentityRepositoryFile(path)), nil
+}
+
+// HasIdentity returns true if the identity file of the user exists.
+func (a *App) HasIdentity() bool {
+	service, err := a.identityService()
+	if err != nil {
+		return false
+	}
+	return service.Exists()
+}
+
+// CreateIdentity seals the private key with the passphrase and stores it in the identity file.
+// If encodedPrivateKey is empty, a new private key is generated.
+// If overwrite is false, it fails if the identity file already exists.
+// It returns an AppResult containing the public key of the identity.
+func (a *App) CreateIdentity(encodedPrivateKey string, passphrase []byte, overwrite bool) core.AppResult {
+	service, err := a.identityService()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// Get the private key, or generate a new one
+	var privateKey core.PrivateKey
+	if encodedPrivateKey != "" {
+		privateKey, err = core.NewPrivateKeyFromEncoded(encodedPrivateKey)
+	} else {
+		privateKey, err = core.NewPrivateKeyFromRand()
+	}
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// Seal and save the private key
+	if err := service.Create(privateKey, passphrase, overwrite); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return a.identityResult(service, privateKey)
+}
+
+// UnlockIdentity checks that the identity file can be unlocked with the passphrase.
+// It returns an AppResult containing the public key of the identity.
+func (a *App) UnlockIdentity(passphrase []byte) core.AppResult {
+	service, err := a.identityService()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	privateKey, err := service.Unlock(passphrase)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return a.identityResult(service, privateKey)
+}
+
+// ChangeIdentityPassphrase seals the private key of the identity file with a new passphrase.
+func (a *App) ChangeIdentityPassphrase(oldPassphrase []byte, newPassphrase []byte) core.AppResult {
+	service, err := a.identityService()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := service.ChangePassphrase(oldPassphrase, newPassphrase); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// ExportIdentity unlocks the identity file with the passphrase.
+// It returns an AppResult containing the encoded private key.
+func (a *App) ExportIdentity(passphrase []byte) core.AppResult {
+	service, err := a.identityService()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	privateKey, err := service.Unlock(passphrase)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(privateKey.Unsafe().String())
+}
+
+// identityResult returns an AppResult containing the public key and the path of the identity.
+func (a *App) identityResult(service *identity_service.Service, privateKey core.PrivateKey) core.AppResult {
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(IdentityResult{
+		PublicKey: publicKey.String(),
+		Path:      service.GetPath(),
+	})
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/services/directory_service"
+	"errors"
+	"os"
+	"time"
+)
+
+var (
+	ErrInvitationPathRequired = errors.New("the path of the invitation file is required")
+)
+
+// Statuses of the invitations
+const (
+	InvitationPending = "pending" // the invitee has not joined yet
+	InvitationJoined  = "joined"  // the invitee has joined, the invitation can be accepted
+)
+
+// InvitationResult is an invitation with the public key of the invitee once the invitee has joined.
+type InvitationResult struct {
+	Id          string    `json:"id" yaml:"id" xml:"id"`
+	Alias       string    `json:"alias,omitempty" yaml:"alias,omitempty" xml:"alias,omitempty"`
+	Paths       []string  `json:"paths" yaml:"paths" xml:"paths"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at" xml:"created_at"`
+	Status      string    `json:"status" yaml:"status" xml:"status"`
+	PublicKey   string    `json:"public_key,omitempty" yaml:"public_key,omitempty" xml:"public_key,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty" xml:"fingerprint,omitempty"`
+	SafetyWords string    `json:"safety_words,omitempty" yaml:"safety_words,omitempty" xml:"safety_words,omitempty"`
+	File        string    `json:"file,omitempty" yaml:"file,omitempty" xml:"file,omitempty"`
+}
+
+// newInvitationResult returns the InvitationResult of the invitation.
+func newInvitationResult(invitation core.Invitation) InvitationResult {
+	result := InvitationResult{
+		Id:        invitation.Id,
+		Alias:     invitation.Alias,
+		Paths:     invitation.Paths,
+		CreatedAt: invitation.CreatedAt,
+		Status:    InvitationPending,
+	}
+	if invitation.IsAnswered() {
+		result.Status = InvitationJoined
+		result.PublicKey = invitation.PublicKey
+		if publicKey, err := core.NewPublicKeyFromEncoded(invitation.PublicKey); err == nil {
+			result.Fingerprint = sign_crypto.Fingerprint(publicKey)
+			result.SafetyWords = sign_crypto.SafetyWords(publicKey)
+		}
+	}
+	return result
+}
+
+// CreateInvitation creates a one-time invitation to the paths and writes the invitation file to give to the invitee.
+// If alias is set, the invitee is added to the user directory with the alias when the invitation is accepted.
+// It returns an AppResult containing the InvitationResult.
+func (a *App) CreateInvitation(paths []string, alias string, invitationPath string, encryptedPrivateKey string) core.AppResult {
+	if invitationPath == "" {
+		return core.NewAppResultWithError(ErrInvitationPathRequired)
+	}
+	if alias != "" && !directory_service.IsValidAlias(alias) {
+		return core.NewAppResultWithError(directory_service.ErrInvalidAlias)
+	}
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	// check that the paths can be shared
+	for _, path := range paths {
+		if !a.linkRepo.IsValidPath(path) {
+			return core.NewAppResultWithError(core.ErrInvalidPath)
+		}
+		if _, _, _, err := a.shareService.GetKeyIdByPath(path); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	invitation, file, err := a.invitations.Create(paths, alias)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	serialized, err := file.Marshal()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := os.WriteFile(invitationPath, serialized, 0600); err != nil {
+		_ = a.invitations.Revoke(invitation.Id)
+		return core.NewAppResultWithError(err)
+	}
+	result := newInvitationResult(invitation)
+	result.File = invitationPath
+	return core.NewAppResultWithValue(result)
+}
+
+// ListInvitations returns the pending invitations of the repository.
+// It returns an AppResult containing a list of InvitationResult.
+func (a *App) ListInvitations() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	invitations, err := a.invitations.List()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	results := make([]InvitationResult, 0, len(invitations))
+	for _, invitation := range invitations {
+		results = append(results, newInvitationResult(invitation))
+	}
+	return core.NewAppResultWithValue(results)
+}
+
+// AcceptInvitation accepts the invitation with the id once the invitee has joined:
+// the paths of the invitation are shared with the invitee, and the invitee is added to the user directory.
+// It returns an AppResult containing the InvitationResult.
+func (a *App) AcceptInvitation(id string, encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	invitation, err := a.invitations.Accept(id, func(invitation core.Invitation) error {
+		if invitation.Alias != "" {
+			publicKey, err := core.NewPublicKeyFromEncoded(invitation.PublicKey)
+			if err != nil {
+				return err
+			}
+			if _, err := a.directory.Add(publicKey, invitation.Alias, "", ""); err != nil {
+				return err
+			}
+		}
+		for _, path := range invitation.Paths {
+			if err := a.shareService.ShareByPublicKey(path, invitation.PublicKey, time.Time{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(newInvitationResult(invitation))
+}
+
+// RevokeInvitation deletes the invitation with the id, so that it cannot be used.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) RevokeInvitation(id string, encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	if err := a.invitations.Revoke(id); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
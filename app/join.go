@@ -2,19 +2,67 @@ package app
 
 import (
 	"ctb-cli/core"
+	"ctb-cli/services/key_service"
+	"errors"
+	"os"
 )
 
-// @Todo: Remove!
-
-// Join joins the user in the repository by creating the user key folder.
-// Returns an AppResult indicating the success or failure of the operation.
-func (a *App) Join(encryptedPrivateKey string) core.AppResult {
-	return core.NewAppResult()
+// Join joins the user of the private key in the repository by creating the user key folder.
+// If invitationPath is set, the user answers the invitation of the file with its public key,
+// and the inviter gets the public key of the user when accepting the invitation.
+// Returns an AppResult indicating the success or failure of the operation,
+// containing the InvitationResult of the answered invitation if any.
+func (a *App) Join(encryptedPrivateKey string, invitationPath string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key, the user has not joined yet
+	keySetRes := a.SetPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	if invitationPath == "" {
+		if err := a.keyStore.Join(); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResult()
+	}
+	// answer the invitation
+	content, err := os.ReadFile(invitationPath)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	file, err := core.UnmarshalInvitationFile(content)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	invitation, err := a.invitations.Answer(file)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.keyStore.Join(); err != nil && !errors.Is(err, key_service.ErrUserAlreadyJoined) {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(newInvitationResult(invitation))
 }
 
-// JoinByUserId joins the user identified by the given userId.
+// JoinByUserId joins the user identified by the given userId, a public key or an alias of the user directory.
 // It initializes the app services, then joins the user using the key store.
 // Returns an AppResult indicating the success or failure of the operation.
 func (a *App) JoinByUserId(userId string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	userId, err := a.resolveRecipient(userId)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.keyStore.JoinByUserId(userId); err != nil {
+		return core.NewAppResultWithError(err)
+	}
 	return core.NewAppResult()
 }
@@ -2,15 +2,27 @@ package app
 
 import "ctb-cli/core"
 
-func (a *App) ListAccess(path string) core.AppResult {
+// ListAccess returns the access list of the file or directory at the path.
+// The private key is optional: it is set if it was passed or held by the key agent.
+func (a *App) ListAccess(path string, encryptedPrivateKey string) core.AppResult {
 	// init the app
 	initRes := a.initServices()
 	if !initRes.Ok {
 		return initRes
 	}
+	// set the private key if it was passed or held by the key agent
+	if encryptedPrivateKey != "" || a.agentSocket != "" {
+		keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+		if !keySetRes.Ok {
+			return keySetRes
+		}
+	}
 	res, err := a.shareService.GetAccessList(path)
 	if err != nil {
 		return core.NewAppResultWithError(err)
 	}
+	if err := a.describeAccessList(res); err != nil {
+		return core.NewAppResultWithError(err)
+	}
 	return core.NewAppResultWithValue(res)
 }
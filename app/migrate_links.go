@@ -0,0 +1,43 @@
+package app
+
+import (
+	"ctb-cli/core"
+)
+
+// MigrateLinksResult is the result of the migration of the file links to encrypted links.
+type MigrateLinksResult struct {
+	// Sealed is the number of links sealed by the migration
+	Sealed int `json:"sealed" yaml:"sealed" xml:"sealed"`
+}
+
+// MigrateLinks seals the file links of a repository created without link encryption, or sealed bound to their name only,
+// and enables link encryption. Once the links are migrated, the plaintext links and the links bound to their name only
+// are refused. The user must have access to all the directories of the repository.
+// An interrupted migration is resumed by calling it again.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) MigrateLinks(encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	// Seal the links, then enable link encryption in the repository config and record the migration,
+	// so that the plaintext links and the links bound to their name only are refused afterwards
+	a.linkRepo.SetEncryptLinks(true)
+	sealed, err := a.linkRepo.SealExistingLinks("/")
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.configService.SetLinkEncryption(true); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.configService.SetLinksMigrated(true); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(MigrateLinksResult{Sealed: sealed})
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+)
+
+// MigrateNames encrypts the names of the files and directories of a repository created without name encryption.
+// The user must have access to all the directories of the repository.
+// With encrypted names, only the root can be shared, see share_service.ErrShareBelowRootWithEncryptedNames.
+// An interrupted migration is resumed by calling it again.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) MigrateNames(encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	if a.configService.IsNameEncryptionEnabled() {
+		return core.NewAppResultWithError(repositories.ErrNamesAlreadyEncrypted)
+	}
+	// Encrypt the names and enable name encryption in the repository config
+	if err := a.pathResolver.EncryptExistingNames(); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.configService.SetNameEncryption(true); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.pathResolver.CompleteNameMigration(); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
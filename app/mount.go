@@ -3,26 +3,52 @@ package app
 import (
 	"ctb-cli/core"
 	"ctb-cli/fuse"
+	"ctb-cli/services/filesystem_service"
+
+	log "github.com/sirupsen/logrus"
 )
 
-// Mount mounts the file system and returns the result.
+// Mount mounts the file system and returns the result once it is unmounted.
+// The files committed before the unmount are uploaded, then the transfers of the object storage are canceled.
 // It returns an AppResult containing the result of the operation.
 func (a *App) Mount() core.AppResult {
 	a.fuse.Mount()
+	a.fileSystem.Wait()
+	a.cancel()
 	return core.NewAppResult()
 }
 
-// PrepareMount creates the fuse file system and returns the result.
-func (a *App) PrepareMount(encryptedPrivateKey string) core.AppResult {
+// PrepareMount deletes the expired shares if expire is set, creates the fuse file system and returns the result.
+// Deleting the expired shares walks the whole repository and reads the key of every file, so it can be skipped
+// in large repositories and run with the expire command instead.
+// If a passphrase is given, the files shared with the passphrase are opened too. Without a private key,
+// only these files are opened and the expired shares are not deleted.
+func (a *App) PrepareMount(encryptedPrivateKey string, passphrase []byte, expire bool) core.AppResult {
 	// init the app
 	initRes := a.initServices()
 	if !initRes.Ok {
 		return initRes
 	}
-	// set the private key
-	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
-	if !keySetRes.Ok {
-		return keySetRes
+	// set the passphrase
+	if passphrase != nil {
+		a.keyStore.SetPassphrase(passphrase)
+	}
+	if encryptedPrivateKey != "" || a.agentSocket != "" || passphrase == nil {
+		// set the private key
+		keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+		if !keySetRes.Ok {
+			return keySetRes
+		}
+		// delete the expired shares
+		if expire {
+			result, err := a.expireShares(filesystem_service.ReencryptNone)
+			if err != nil {
+				return core.NewAppResultWithError(err)
+			}
+			for _, path := range result.DroppedPassphraseShares {
+				log.Warn("The share with a passphrase of ", path, " was removed by the rotation of its key")
+			}
+		}
 	}
 	// create the fuse
 	a.fuse = fuse.New(a.fileSystem)
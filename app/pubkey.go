@@ -6,13 +6,21 @@ import (
 )
 
 // GetPubkey generates a public key from a private key.
+// If no private key is given and a key agent is set, the public key is returned by the agent.
 func (a *App) GetPubkey(privateKeyString string) core.AppResult {
+	if privateKeyString == "" && a.agentSocket != "" {
+		res := a.GetAgentStatus(a.agentSocket)
+		if !res.Ok {
+			return res
+		}
+		return core.NewAppResultWithValue(res.Result.(AgentResult).PublicKey)
+	}
 	privateKey, err := core.NewPrivateKeyFromEncoded(privateKeyString)
 	if err != nil {
 		return core.NewAppResultWithError(err)
 	}
 	// Create a new key store without key and vault repositories.
-	keyStore := key_service.NewKeyStore(nil, nil)
+	keyStore := key_service.NewKeyStore(nil, nil, nil, nil, nil)
 	publicKey, err := keyStore.GetPublicKeyByPrivateKey(privateKey)
 	if err != nil {
 		return core.NewAppResultWithError(err)
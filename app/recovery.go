@@ -0,0 +1,161 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/services/key_service"
+	"errors"
+	"path/filepath"
+)
+
+var (
+	ErrRecoveryKeyInConfig = errors.New("the recovery key recorded in the repository configuration is not used since the configuration is not signed; set it again with recovery-key set")
+)
+
+// RecoveryKeyResult is the recovery public key of the repository.
+type RecoveryKeyResult struct {
+	PublicKey   string `json:"public_key" yaml:"public_key" xml:"public_key"`
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint" xml:"fingerprint"`
+}
+
+// RecoverResult is the result of the recovery of the repository for a new owner.
+type RecoverResult struct {
+	Owner string `json:"owner" yaml:"owner" xml:"owner"`
+	Keys  int    `json:"keys" yaml:"keys" xml:"keys"`
+}
+
+// SetRecoveryKey records the recovery public key in the policy of the repository, signed by the user and proved
+// with the root vault key, and seals the root vault key and the top-level vault keys with it. The vaults created afterwards are sealed with it when they are created.
+// The private key of the user must give access to the root directory.
+func (a *App) SetRecoveryKey(publicKey string, encryptedPrivateKey string) core.AppResult {
+	recoveryKey, err := core.NewPublicKeyFromEncoded(publicKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	// the policy can only be proved by a user with access to the root directory
+	if err := a.keyStore.SaveRecoveryKey(recoveryKey); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// seal the existing root and top-level vault keys
+	if err := a.keyStore.ShareVaultWithRecoveryKey("/"); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	subFiles, err := a.linkRepo.GetSubFiles("/")
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	for _, subFile := range subFiles {
+		path := filepath.Join("/", subFile.Name())
+		if subFile.Name() == ".meta" || !a.linkRepo.IsDir(path) {
+			continue
+		}
+		if err := a.keyStore.ShareVaultWithRecoveryKey(path); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	return newRecoveryKeyResult(recoveryKey)
+}
+
+// PublishRecoveryKey publishes the signing identity and the encapsulation key of the recovery key, so that
+// the vault keys can be sealed with it in a repository requiring the hybrid key wrapping.
+func (a *App) PublishRecoveryKey(encodedRecoveryKey string) core.AppResult {
+	recoveryKey, err := core.NewPrivateKeyFromEncoded(encodedRecoveryKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// The recovery key is not a user of the repository, so it is set without checking that it has joined
+	a.keyStore.SetPrivateKey(recoveryKey)
+	if err := a.keyStore.PublishIdentity(); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	publicKey, err := recoveryKey.ToPublicKey()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return newRecoveryKeyResult(publicKey)
+}
+
+// GetRecoveryKey returns the recovery public key of the policy of the repository, once the policy is verified
+// with the root vault key: the private key of the user must give access to the root directory.
+// It returns an AppResult containing a RecoveryKeyResult.
+func (a *App) GetRecoveryKey(encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	recoveryKey, err := a.keyStore.GetRecoveryKey()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if recoveryKey == nil {
+		// The recovery key recorded in the configuration by the previous versions is not trusted
+		if a.configService.GetRecoveryPublicKey() != "" {
+			return core.NewAppResultWithError(ErrRecoveryKeyInConfig)
+		}
+		return core.NewAppResultWithError(key_service.ErrNoRecoveryKey)
+	}
+	return newRecoveryKeyResult(*recoveryKey)
+}
+
+// Recover opens the keys sealed with the recovery key using the recovery private key, and shares them with
+// the new owner given as a public key or an alias of the user directory. The new owner is joined in the repository
+// and gets access to the root directory, as the owner who created it.
+// It returns an AppResult containing a RecoverResult.
+func (a *App) Recover(encodedRecoveryKey string, owner string) core.AppResult {
+	recoveryKey, err := core.NewPrivateKeyFromEncoded(encodedRecoveryKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	owner, err = a.resolveRecipient(owner)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	ownerPublicKey, err := core.NewPublicKeyFromEncoded(owner)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// The recovery key is not a user of the repository, so it is set without checking that it has joined
+	a.keyStore.SetPrivateKey(recoveryKey)
+	count, err := a.keyStore.Recover(ownerPublicKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(RecoverResult{
+		Owner: owner,
+		Keys:  count,
+	})
+}
+
+// newRecoveryKeyResult returns the result with the recovery public key and its fingerprint.
+func newRecoveryKeyResult(publicKey core.PublicKey) core.AppResult {
+	return core.NewAppResultWithValue(RecoveryKeyResult{
+		PublicKey:   publicKey.String(),
+		Fingerprint: sign_crypto.Fingerprint(publicKey),
+	})
+}
@@ -1,10 +1,66 @@
 package app
 
-import "ctb-cli/core"
+import (
+	"ctb-cli/core"
+	"ctb-cli/services/filesystem_service"
+	"errors"
+	"os"
+	"time"
+)
 
-// Share shares a file or directory located at the specified path with the given public key.
+var (
+	ErrRecipientOrGroup = errors.New("either a recipient public key or a group is required")
+	ErrOneRecipient     = errors.New("exactly one of a recipient public key, a group or a passphrase is required")
+	ErrInvalidExpiry    = errors.New("invalid expiry: use a date (2006-01-02) or a time (2006-01-02T15:04:05Z07:00)")
+	ErrExpiryInPast     = errors.New("the expiry is in the past")
+)
+
+// RotateResult is the result of a rotation of the keys.
+type RotateResult struct {
+	// DroppedPassphraseShares are the paths of the directories whose share with a passphrase was removed,
+	// since the passphrase is not known to share the new keys
+	DroppedPassphraseShares []string `json:"dropped_passphrase_shares" yaml:"dropped_passphrase_shares" xml:"dropped_passphrase_shares"`
+}
+
+// ParseExpiry parses the expiry of a share, given as the last day of the share or as an RFC 3339 time.
+// A share expiring on a day ends at the end of that day, in the local time zone.
+// It returns the zero time if expires is empty.
+func ParseExpiry(expires string, now time.Time) (time.Time, error) {
+	if expires == "" {
+		return time.Time{}, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		day, dayErr := time.ParseInLocation(time.DateOnly, expires, time.Local)
+		if dayErr != nil {
+			return time.Time{}, ErrInvalidExpiry
+		}
+		expiresAt = day.AddDate(0, 0, 1)
+	}
+	if !expiresAt.After(now) {
+		return time.Time{}, ErrExpiryInPast
+	}
+	return expiresAt, nil
+}
+
+// Share shares a file or directory located at the specified path with the given public key
+// or alias of the user directory, with the group with the given name, or with the people knowing the passphrase.
+// If expires is set, the share expires at the time parsed by ParseExpiry.
 // Returns an AppResult indicating the success or failure of the operation.
-func (a *App) Share(path string, publicKey string, encryptedPrivateKey string) core.AppResult {
+func (a *App) Share(path string, publicKey string, group string, passphrase []byte, expires string, encryptedPrivateKey string) core.AppResult {
+	recipients := 0
+	for _, given := range []bool{publicKey != "", group != "", passphrase != nil} {
+		if given {
+			recipients++
+		}
+	}
+	if recipients != 1 {
+		return core.NewAppResultWithError(ErrOneRecipient)
+	}
+	expiresAt, err := ParseExpiry(expires, time.Now())
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
 	// init the app
 	initRes := a.initServices()
 	if !initRes.Ok {
@@ -15,24 +71,87 @@ func (a *App) Share(path string, publicKey string, encryptedPrivateKey string) c
 	if !keySetRes.Ok {
 		return keySetRes
 	}
-	if err := a.shareService.ShareByPublicKey(path, publicKey); err != nil {
+	if group != "" {
+		if err := a.shareService.ShareWithGroup(path, group, expiresAt); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResult()
+	}
+	if passphrase != nil {
+		if err := a.shareService.ShareWithPassphrase(path, passphrase, expiresAt); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResult()
+	}
+	publicKey, err = a.resolveRecipient(publicKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.shareService.ShareByPublicKey(path, publicKey, expiresAt); err != nil {
 		return core.NewAppResultWithError(err)
 	}
 	return core.NewAppResult()
 }
 
-// Unshare removes the sharing of a file or directory with a specific public key.
+// Unshare removes the sharing of a file or directory with a specific public key or alias, or with the group with the given name.
+// The share with a passphrase is removed with the recipient core.PassphraseRecipient.
 // It initializes the app services and calls the UnshareByPublicKey method of the shareService.
+// If rotate is true, the keys the user had access to are replaced after the share is removed:
+// the vault keys of a directory and its sub directories are rotated and the files are encrypted again
+// according to the re-encryption mode ("none", "lazy" or "eager"), and a file is encrypted again with a new key.
+// Rotation requires the private key of the user. An interrupted rotation is resumed by unsharing again.
+// The shares with a passphrase cannot be renewed with the new keys: they are removed and reported in a RotateResult.
 // If an error occurs during the unsharing process, it returns an AppResult with the error.
 // Otherwise, it returns a successful AppResult.
-func (a *App) Unshare(path string, publicKey string) core.AppResult {
+func (a *App) Unshare(path string, publicKey string, group string, encryptedPrivateKey string, rotate bool, reencrypt string) core.AppResult {
+	if (publicKey == "") == (group == "") {
+		return core.NewAppResultWithError(ErrRecipientOrGroup)
+	}
 	// init the app
 	initRes := a.initServices()
 	if !initRes.Ok {
 		return initRes
 	}
-	if err := a.shareService.Unshare(path, publicKey); err != nil {
+	// check the re-encryption mode before removing the share
+	mode, err := filesystem_service.ParseReencryptMode(reencrypt)
+	if err != nil {
 		return core.NewAppResultWithError(err)
 	}
-	return core.NewAppResult()
+	// set the private key (required to rotate the keys)
+	if rotate {
+		keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+		if !keySetRes.Ok {
+			return keySetRes
+		}
+	}
+	if group != "" {
+		err = a.shareService.UnshareWithGroup(path, group)
+	} else if publicKey == core.PassphraseRecipient {
+		err = a.shareService.Unshare(path, publicKey)
+	} else {
+		publicKey, err = a.resolveRecipient(publicKey)
+		if err == nil {
+			err = a.shareService.Unshare(path, publicKey)
+		}
+	}
+	// the share is already removed if an interrupted rotation is resumed
+	if err != nil && !(rotate && errors.Is(err, os.ErrNotExist)) {
+		return core.NewAppResultWithError(err)
+	}
+	if !rotate {
+		return core.NewAppResult()
+	}
+	// rotate the keys
+	result := RotateResult{DroppedPassphraseShares: make([]string, 0)}
+	if a.linkRepo.IsDir(path) {
+		result.DroppedPassphraseShares, err = a.fileSystem.RotateVault(path, mode)
+	} else {
+		err = a.fileSystem.Reencrypt(path)
+	}
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// wait for the files to be encrypted and uploaded
+	a.fileSystem.Wait()
+	return core.NewAppResultWithValue(result)
 }
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/shamir"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// SplitKeyResult is the result of the split of a private key between custodians.
+type SplitKeyResult struct {
+	PublicKey string           `json:"public_key" yaml:"public_key" xml:"public_key"`
+	Threshold int              `json:"threshold" yaml:"threshold" xml:"threshold"`
+	Shares    []KeyShareResult `json:"shares" yaml:"shares" xml:"shares"`
+}
+
+// KeyShareResult is the share of a custodian, encoded in base58 and optionally as words.
+type KeyShareResult struct {
+	Index    int    `json:"index" yaml:"index" xml:"index"`
+	Share    string `json:"share" yaml:"share" xml:"share"`
+	Mnemonic string `json:"mnemonic,omitempty" yaml:"mnemonic,omitempty" xml:"mnemonic,omitempty"`
+}
+
+// SplitKey splits the private key into the given number of Shamir shares, any threshold of them giving back the key.
+// If withMnemonic is true, the shares are also returned as words, to be written down on paper.
+// It returns an AppResult containing a SplitKeyResult.
+func (a *App) SplitKey(encodedPrivateKey string, threshold int, count int, withMnemonic bool) core.AppResult {
+	privateKey, err := core.NewPrivateKeyFromEncoded(encodedPrivateKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	shares, err := shamir.Split(privateKey.Bytes(), threshold, count)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	result := SplitKeyResult{
+		PublicKey: publicKey.String(),
+		Threshold: threshold,
+		Shares:    make([]KeyShareResult, len(shares)),
+	}
+	for i, share := range shares {
+		result.Shares[i] = KeyShareResult{
+			Index: int(share.Index),
+			Share: share.Encode(),
+		}
+		if withMnemonic {
+			result.Shares[i].Mnemonic = share.Words()
+		}
+	}
+	return core.NewAppResultWithValue(result)
+}
+
+// CombineKey gives back the private key from the shares created by SplitKey, encoded in base58 or as words.
+// It returns an AppResult containing the private key and its public key.
+func (a *App) CombineKey(encodedShares []string) core.AppResult {
+	shares := make([]shamir.Share, len(encodedShares))
+	for i, encoded := range encodedShares {
+		share, err := shamir.DecodeShare(encoded)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		shares[i] = share
+	}
+	secret, err := shamir.Combine(shares)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if len(secret) != curve25519.ScalarSize {
+		return core.NewAppResultWithError(ErrInvalidPrivateKeySize)
+	}
+	return newGenerateUserKeyResult(core.NewPrivateKeyFromBytes(secret), false)
+}
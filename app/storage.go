@@ -0,0 +1,174 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"ctb-cli/config"
+	"ctb-cli/core"
+	"ctb-cli/objectstorage"
+	"ctb-cli/objectstorage/cloud"
+	"ctb-cli/objectstorage/local"
+	"ctb-cli/objectstorage/s3"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// storageTestObjectId is the id of the object written by the storage test. It is replaced by every test.
+const storageTestObjectId = "ctb-storage-test"
+
+// storageTestSize is the size of the object written by the storage test.
+const storageTestSize = 64 * 1024
+
+var (
+	ErrNoLocalStoragePath = errors.New("the local storage has no path in the configuration")
+	ErrStorageDisabled    = errors.New("the object storage is disabled by the configuration")
+	ErrStorageMismatch    = errors.New("the object read from the storage does not match the object written")
+)
+
+// StorageTestResult is the result of the test of the object storage.
+type StorageTestResult struct {
+	Type     string `json:"type" yaml:"type" xml:"type"`
+	Location string `json:"location" yaml:"location" xml:"location"`
+	Size     int    `json:"size" yaml:"size" xml:"size"`
+	Upload   string `json:"upload" yaml:"upload" xml:"upload"`
+	Download string `json:"download" yaml:"download" xml:"download"`
+}
+
+// getStorageConfig returns the storage configuration of the user overridden by the storage configuration
+// of the repository.
+func (a *App) getStorageConfig() config.Storage {
+	return a.cfg.GetStorage().Merge(a.configService.GetStorage())
+}
+
+// newStorageClient returns the object storage selected in the configuration.
+// A relative path of the local storage is relative to the repository root, so .meta/objects keeps the
+// objects next to the metadata of the repository.
+func (a *App) newStorageClient(root string) (core.CloudStorage, error) {
+	storage := a.getStorageConfig()
+	switch storage.Type {
+	case config.StorageCloud:
+		endpoint, chunkSize, concurrency := storage.Endpoint, storage.ChunkSize, storage.Concurrency
+		if endpoint == "" {
+			endpoint = cloud.DefaultURL
+		}
+		if chunkSize == 0 {
+			chunkSize = cloud.DefaultChunkSize
+		}
+		if concurrency == 0 {
+			concurrency = cloud.DefaultConcurrency
+		}
+		return cloud.NewClient(endpoint, chunkSize, concurrency), nil
+	case config.StorageS3:
+		return s3.NewClient(a.ctx, s3.Options{
+			Bucket:               storage.Bucket,
+			Prefix:               storage.Prefix,
+			Region:               storage.Region,
+			Profile:              storage.Profile,
+			Endpoint:             storage.Endpoint,
+			PathStyle:            storage.PathStyle,
+			ChunkSize:            int64(storage.ChunkSize),
+			Concurrency:          storage.Concurrency,
+			StorageClass:         storage.StorageClass,
+			ServerSideEncryption: storage.ServerSideEncryption,
+			KMSKeyId:             storage.KMSKeyId,
+		})
+	case config.StorageLocal:
+		path := storage.Path
+		if path == "" {
+			return nil, ErrNoLocalStoragePath
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		return local.NewClient(path), nil
+	case config.StorageNone:
+		return objectstorage.NewDummyClient(), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", storage.Type)
+	}
+}
+
+// TestStorage checks the connectivity to the object storage selected in the configuration,
+// by writing a test object and reading it back.
+// It returns an AppResult containing a StorageTestResult.
+func (a *App) TestStorage() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	storage := a.getStorageConfig()
+	if storage.Type == config.StorageNone {
+		return core.NewAppResultWithError(ErrStorageDisabled)
+	}
+
+	content := make([]byte, storageTestSize)
+	if _, err := rand.Read(content); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	start := time.Now()
+	if err := a.storage.Upload(bytes.NewReader(content), storageTestObjectId); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	uploadTime := time.Since(start)
+
+	start = time.Now()
+	var downloaded storageBuffer
+	if err := a.storage.Download(storageTestObjectId, &downloaded); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	downloadTime := time.Since(start)
+	if !bytes.Equal(downloaded.data, content) {
+		return core.NewAppResultWithError(ErrStorageMismatch)
+	}
+
+	return core.NewAppResultWithValue(StorageTestResult{
+		Type:     storage.Type,
+		Location: storageLocation(storage),
+		Size:     storageTestSize,
+		Upload:   uploadTime.Round(time.Millisecond).String(),
+		Download: downloadTime.Round(time.Millisecond).String(),
+	})
+}
+
+// storageLocation returns a description of the location of the objects in the storage.
+func storageLocation(storage config.Storage) string {
+	switch storage.Type {
+	case config.StorageS3:
+		location := path.Join(storage.Bucket, storage.Prefix)
+		if storage.Endpoint != "" {
+			return strings.TrimSuffix(storage.Endpoint, "/") + "/" + location
+		}
+		return "s3://" + location
+	case config.StorageLocal:
+		return storage.Path
+	case config.StorageCloud:
+		if storage.Endpoint != "" {
+			return storage.Endpoint
+		}
+		return cloud.DefaultURL
+	default:
+		return ""
+	}
+}
+
+// storageBuffer is an io.WriterAt writing in memory, to read back the test object.
+// The parts of the object may be written concurrently.
+type storageBuffer struct {
+	sync.Mutex
+	data []byte
+}
+
+func (b *storageBuffer) WriteAt(p []byte, off int64) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	if end := int(off) + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	return copy(b.data[off:], p), nil
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/repositories"
+	"ctb-cli/services/directory_service"
+	"errors"
+	"time"
+)
+
+// DirectoryEntryResult is a user of the user directory, with the fingerprint of its public key
+// and the status of the signature of the entry.
+type DirectoryEntryResult struct {
+	Alias        string    `json:"alias" yaml:"alias" xml:"alias"`
+	Name         string    `json:"name,omitempty" yaml:"name,omitempty" xml:"name,omitempty"`
+	Email        string    `json:"email,omitempty" yaml:"email,omitempty" xml:"email,omitempty"`
+	PublicKey    string    `json:"public_key" yaml:"public_key" xml:"public_key"`
+	Fingerprint  string    `json:"fingerprint" yaml:"fingerprint" xml:"fingerprint"`
+	SafetyWords  string    `json:"safety_words" yaml:"safety_words" xml:"safety_words"`
+	AddedBy      string    `json:"added_by" yaml:"added_by" xml:"added_by"`
+	AddedAt      time.Time `json:"added_at" yaml:"added_at" xml:"added_at"`
+	Verification string    `json:"verification" yaml:"verification" xml:"verification"`
+}
+
+// newDirectoryEntryResult returns the DirectoryEntryResult of the entry.
+// The user who added the entry is shown by its alias if it is in the directory.
+func newDirectoryEntryResult(entry core.DirectoryEntry, status string, aliases map[string]string) DirectoryEntryResult {
+	result := DirectoryEntryResult{
+		Alias:        entry.Alias,
+		Name:         entry.Name,
+		Email:        entry.Email,
+		PublicKey:    entry.PublicKey,
+		AddedBy:      entry.AddedBy,
+		AddedAt:      entry.AddedAt,
+		Verification: status,
+	}
+	if alias, ok := aliases[entry.AddedBy]; ok {
+		result.AddedBy = alias
+	}
+	if publicKey, err := core.NewPublicKeyFromEncoded(entry.PublicKey); err == nil {
+		result.Fingerprint = sign_crypto.Fingerprint(publicKey)
+		result.SafetyWords = sign_crypto.SafetyWords(publicKey)
+	}
+	return result
+}
+
+// AddUser adds the user with the public key to the user directory with an alias, a display name and an email.
+// The entry is signed by the user of the private key.
+// It returns an AppResult containing the DirectoryEntryResult.
+func (a *App) AddUser(publicKey string, alias string, name string, email string, encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	userPublicKey, err := core.NewPublicKeyFromEncoded(publicKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	entry, err := a.directory.Add(userPublicKey, alias, name, email)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(newDirectoryEntryResult(entry, core.SignatureVerified, nil))
+}
+
+// ListUsers returns the users of the user directory with the status of their signatures.
+// It returns an AppResult containing a list of DirectoryEntryResult.
+func (a *App) ListUsers() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	entries, err := a.directory.List()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	aliases := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		aliases[entry.PublicKey] = entry.Alias
+	}
+	results := make([]DirectoryEntryResult, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, newDirectoryEntryResult(entry.DirectoryEntry, entry.Status, aliases))
+	}
+	return core.NewAppResultWithValue(results)
+}
+
+// RemoveUser removes the user with the alias or public key from the user directory.
+// The files shared with the user stay shared.
+// Returns an AppResult indicating the success or failure of the operation.
+func (a *App) RemoveUser(aliasOrPublicKey string, encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	if err := a.directory.Remove(aliasOrPublicKey); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// resolveRecipient returns the public key of a recipient given as a public key or as an alias of the user directory.
+func (a *App) resolveRecipient(recipient string) (string, error) {
+	return a.directory.Resolve(recipient)
+}
+
+// describeAccessList adds the aliases, names and fingerprints of the users of the user directory to the access list.
+func (a *App) describeAccessList(list core.KeyAccessList) error {
+	for i := range list {
+		if list[i].Group != "" {
+			continue
+		}
+		if publicKey, err := core.NewPublicKeyFromEncoded(list[i].PublicKey); err == nil {
+			list[i].Fingerprint = sign_crypto.Fingerprint(publicKey)
+		}
+		entry, err := a.directory.Get(list[i].PublicKey)
+		if errors.Is(err, repositories.ErrDirectoryEntryNotFound) || errors.Is(err, directory_service.ErrUntrustedEntry) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		list[i].Alias = entry.Alias
+		list[i].Name = entry.Name
+	}
+	return nil
+}
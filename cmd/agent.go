@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"ctb-cli/agent"
+	"ctb-cli/app"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Manage the key agent",
+	Long: `Manage the key agent (ctb-agent). The agent holds your unlocked private key in locked memory and performs
+	the private key operations for the other commands over a Unix domain socket, so that the private key never leaves the agent.
+	The commands use the agent when the CTB_AGENT_SOCK environment variable is set and no other private key is given.`,
+}
+
+// agentStartCmd represents the agent start command
+var agentStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the key agent",
+	Long: `Start the key agent in the foreground, until it is stopped by 'agent stop' or a signal.
+	The private key is read from --key, --key-stdin, --key-fd, CTB_PRIVATE_KEY, or the identity file.
+	It prints the shell commands setting CTB_AGENT_SOCK for the other commands.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get the private key (the agent itself is not used)
+		loaded, err := loadExplicitPrivateKey(cmd)
+		if err == nil && !loaded {
+			err = unlockIdentity(cmd)
+		}
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		socket, _ := cmd.Flags().GetString("socket")
+		res := ctbApp.StartAgent(encryptedPrivateKey, socket)
+		encryptedPrivateKey = ""
+		if !res.Ok {
+			MarshalOutput(res)
+			return
+		}
+		if output == outputEnumText {
+			fmt.Printf("%s=%s; export %s;\n", agent.SocketEnv, res.Result.(app.AgentResult).Socket, agent.SocketEnv)
+		} else {
+			MarshalOutput(res)
+		}
+		// Stop the agent on interrupt
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			ctbApp.StopAgent()
+		}()
+		res = ctbApp.ServeAgent()
+		if !res.Ok {
+			MarshalOutput(res)
+		}
+	},
+}
+
+// agentStatusCmd represents the agent status command
+var agentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Get the status of the key agent",
+	Long:  `Connect to the key agent and return its public key.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.GetAgentStatus(agentSocketPath(cmd))
+		MarshalOutput(res)
+	},
+}
+
+// agentStopCmd represents the agent stop command
+var agentStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the key agent",
+	Long:  `Stop the key agent. The private key is erased from its memory.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.StopRemoteAgent(agentSocketPath(cmd))
+		MarshalOutput(res)
+	},
+}
+
+// agentSocketPath returns the socket of the agent given by the 'socket' flag, CTB_AGENT_SOCK, or the default socket path.
+func agentSocketPath(cmd *cobra.Command) string {
+	if socket, _ := cmd.Flags().GetString("socket"); socket != "" {
+		return socket
+	}
+	if socket := os.Getenv(agent.SocketEnv); socket != "" {
+		return socket
+	}
+	return agent.DefaultSocketPath()
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+	agentCmd.AddCommand(agentStartCmd)
+	agentCmd.AddCommand(agentStatusCmd)
+	agentCmd.AddCommand(agentStopCmd)
+
+	agentCmd.PersistentFlags().String("socket", "", "Path of the agent socket (default is $XDG_RUNTIME_DIR/ctb-agent.sock)")
+	setKeySourceFlags(agentStartCmd, "Your private key. Not recommended, it is visible in the shell history and the process list.")
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit [path]",
+	Short: "Show who last wrote the files and directories",
+	Long: `Verify the signatures of the file or directory at the path (the root by default) and of its sub files,
+	and show the user who last wrote each of them. The status of a signature is "verified", "unknown signer",
+	"invalid", or "unsigned" for the files written before the files were signed. Only the verified files can be read,
+	and the unsigned ones if allow_unsigned_records is set in the user configuration.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := ""
+		if len(args) > 0 {
+			path = args[0]
+		}
+		res := ctbApp.Audit(path, encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	SetRequiredKeyFlag(auditCmd)
+}
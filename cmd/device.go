@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// deviceCmd represents the device command
+var deviceCmd = &cobra.Command{
+	Use:   "device",
+	Short: "Manage the devices of the user",
+	Long: `Manage the devices of the user. Each device has its own private key, and the files shared with the user
+	are shared with every device of the user. The commands are run with the private key of a device of the user.`,
+}
+
+// deviceListCmd represents the device list command
+var deviceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the devices of the user",
+	Long:  `List the public keys of the devices of the user.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListDevices(encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// deviceAddCmd represents the device add command
+var deviceAddCmd = &cobra.Command{
+	Use:   "add <public key>",
+	Short: "Add a device to the user",
+	Long: `Add the device with the public key to the user. Generate the private key of the new device with generate-key.
+	The files shared with the user are shared with the new device once it accepts to be a device of the user:
+	run 'device accept <user id>' with the private key of the new device. The user id is shown by 'device list'.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		res := ctbApp.AddDevice(args[0], name, encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// deviceAcceptCmd represents the device accept command
+var deviceAcceptCmd = &cobra.Command{
+	Use:   "accept <user id>",
+	Short: "Accept to be a device of a user",
+	Long: `Accept that the device of the private key is a device of the user with the id, once another device of the user
+	has added it with 'device add'. The files shared with the user are then opened by this device.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.AcceptDevice(args[0], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// deviceRemoveCmd represents the device remove command
+var deviceRemoveCmd = &cobra.Command{
+	Use:   "remove <public key>",
+	Short: "Remove a device from the user",
+	Long: `Remove the device with the public key from the user. The current device cannot be removed.
+	The files shared with the user are not shared with the removed device anymore, but the keys it has already
+	opened are not changed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.RemoveDevice(args[0], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deviceCmd)
+	deviceCmd.AddCommand(deviceListCmd)
+	deviceCmd.AddCommand(deviceAddCmd)
+	deviceCmd.AddCommand(deviceAcceptCmd)
+	deviceCmd.AddCommand(deviceRemoveCmd)
+
+	SetRequiredKeyFlag(deviceListCmd)
+	SetRequiredKeyFlag(deviceAddCmd)
+	SetRequiredKeyFlag(deviceAcceptCmd)
+	SetRequiredKeyFlag(deviceRemoveCmd)
+	deviceAddCmd.Flags().String("name", "", "Name of the device.")
+}
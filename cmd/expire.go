@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// expireCmd represents the expire command
+var expireCmd = &cobra.Command{
+	Use:   "expire",
+	Short: "Delete the expired shares",
+	Long: `Delete the shares created with 'share --expires' which are expired, in the files and directories you have access to,
+	and rotate the keys the users had access to. The expired shares are also deleted when mounting the file system.
+	The shares with a passphrase of the rotated keys are removed and listed in the output.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		reencrypt, _ := cmd.Flags().GetString("reencrypt")
+		res := ctbApp.Expire(encryptedPrivateKey, reencrypt)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(expireCmd)
+	SetRequiredKeyFlag(expireCmd)
+	expireCmd.Flags().String("reencrypt", "none", `Re-encryption of the files after rotation. allowed: "none", "lazy", and "eager"`)
+}
@@ -13,13 +13,16 @@ var generateKeyCmd = &cobra.Command{
 	Short: "Generate a new private key for user",
 	Long: `Generate a new private key for user and return it as a string. The key is used to encrypt and decrypt data keys and vaults.
 	This funtion doesnt affect the state of the repository and only returns the key. The key is not stored in the repository.
-	You can use join command to join the repository and store the corresponding public key in the repository.`,
+	You can use join command to join the repository and store the corresponding public key in the repository.
+	Use --mnemonic to also return the key as a list of 24 words, to be written down as a backup and restored with restore-key.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		res := ctbApp.GenerateUserKey()
+		withMnemonic, _ := cmd.Flags().GetBool("mnemonic")
+		res := ctbApp.GenerateUserKey(withMnemonic)
 		MarshalOutput(res)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(generateKeyCmd)
+	generateKeyCmd.Flags().Bool("mnemonic", false, "Also return the private key as a mnemonic (24 words).")
 }
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// groupCmd represents the group command
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage the groups of users",
+	Long: `Manage the groups of users. A group is a share recipient: the files shared with a group with 'share --group'
+	are shared with all its members, including the members added later.
+	The members of a group are managed by the members of the group: a change of the group written by another user is refused,
+	and a group name used by several groups is refused until the duplicate group is removed from .meta/.groups.
+	The groups created by the previous versions, which do not record their creator, must be created again.`,
+}
+
+// groupListCmd represents the group list command
+var groupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the groups",
+	Long:  `List the groups of the repository with their members.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListGroups()
+		MarshalOutput(res)
+	},
+}
+
+// groupCreateCmd represents the group create command
+var groupCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a group",
+	Long:  `Create a group with the specified name. You are the first member of the group.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.CreateGroup(args[0], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// groupAddCmd represents the group add command
+var groupAddCmd = &cobra.Command{
+	Use:   "add <name> <public key|alias>",
+	Short: "Add a member to a group",
+	Long:  `Add the user with the public key to the group. The user gets access to the files shared with the group.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.AddGroupMember(args[0], args[1], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// groupRemoveCmd represents the group remove command
+var groupRemoveCmd = &cobra.Command{
+	Use:   "remove <name> <public key|alias>",
+	Short: "Remove a member from a group",
+	Long: `Remove the user with the public key from the group. The key pair of the group is replaced,
+	so that the user cannot open the keys shared with the group anymore.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.RemoveGroupMember(args[0], args[1], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupListCmd)
+	groupCmd.AddCommand(groupCreateCmd)
+	groupCmd.AddCommand(groupAddCmd)
+	groupCmd.AddCommand(groupRemoveCmd)
+
+	SetRequiredKeyFlag(groupCreateCmd)
+	SetRequiredKeyFlag(groupAddCmd)
+	SetRequiredKeyFlag(groupRemoveCmd)
+}
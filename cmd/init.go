@@ -12,9 +12,21 @@ var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Init in folder",
 	Long: `Init in folder. This command should be run in the root of the folder you want to use as a repository. It creates the necessary files to use the repository.
-	The user who runs this command is automatically joined in the repository as the owner.`,
+	The user who runs this command is automatically joined in the repository as the owner.
+	Use --pad-sizes to pad the stored objects so that the exact file sizes are not exposed.
+	Use --algorithm aes-256-gcm to encrypt the files with AES-256-GCM, which is faster on CPUs with AES instructions.
+	Use --recovery-key to seal the root and top-level vault keys with a recovery public key kept offline, see the recover command.
+	Use --hybrid to seal the data keys with the hybrid X25519 + ML-KEM-768 scheme only, against the decryption of the stored keys
+	by a future quantum computer. Sharing then fails for the recipients who have not published an ML-KEM encapsulation key
+	by opening the repository once. The recovery key is then set with recovery-key publish and recovery-key set.
+	Since the repository configuration can be changed by any writer of the repository, the members also set
+	require_hybrid_key_wrapping: true in their user configuration, so that the requirement cannot be turned off for them.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		res := ctbApp.InitRepo(encryptedPrivateKey)
+		padSizes, _ := cmd.Flags().GetBool("pad-sizes")
+		algorithm, _ := cmd.Flags().GetString("algorithm")
+		recoveryKey, _ := cmd.Flags().GetString("recovery-key")
+		hybrid, _ := cmd.Flags().GetBool("hybrid")
+		res := ctbApp.InitRepo(encryptedPrivateKey, padSizes, algorithm, recoveryKey, hybrid)
 		MarshalOutput(res)
 	},
 }
@@ -22,4 +34,8 @@ var initCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(initCmd)
 	SetRequiredKeyFlag(initCmd)
+	initCmd.Flags().Bool("pad-sizes", false, "Pad the stored objects to hide the exact file sizes.")
+	initCmd.Flags().String("algorithm", "chacha20-poly1305", `Encryption algorithm of the files. allowed: "chacha20-poly1305" and "aes-256-gcm"`)
+	initCmd.Flags().String("recovery-key", "", "Recovery public key of the repository.")
+	initCmd.Flags().Bool("hybrid", false, "Seal the data keys with the hybrid X25519 + ML-KEM-768 scheme only.")
 }
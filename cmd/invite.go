@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// inviteCmd represents the invite command
+var inviteCmd = &cobra.Command{
+	Use:   "invite",
+	Short: "Invite users to the repository",
+	Long: `Invite users to the repository without exchanging public keys.
+	Create an invitation file with 'invite create' and give it to the invitee, who joins with 'join --invite <file>'.
+	Then accept the invitation with 'invite accept' to share the invited paths with the invitee.
+	An invitation can be used once.`,
+}
+
+// inviteCreateCmd represents the invite create command
+var inviteCreateCmd = &cobra.Command{
+	Use:   "create <invitation file>",
+	Short: "Create an invitation",
+	Long: `Create a one-time invitation and write the invitation file to give to the invitee.
+	The invitation file contains a secret key: give it to the invitee only.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		paths, _ := cmd.Flags().GetStringArray("share")
+		alias, _ := cmd.Flags().GetString("alias")
+		res := ctbApp.CreateInvitation(paths, alias, args[0], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// inviteListCmd represents the invite list command
+var inviteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the pending invitations",
+	Long: `List the pending invitations. Once the invitee has joined, the fingerprint of the public key of the invitee is shown:
+	compare it with the invitee before accepting the invitation.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListInvitations()
+		MarshalOutput(res)
+	},
+}
+
+// inviteAcceptCmd represents the invite accept command
+var inviteAcceptCmd = &cobra.Command{
+	Use:   "accept <invitation id>",
+	Short: "Accept an invitation",
+	Long: `Accept the invitation once the invitee has joined: the invited paths are shared with the invitee.
+	Only the invitations you created and signed can be accepted.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.AcceptInvitation(args[0], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// inviteRevokeCmd represents the invite revoke command
+var inviteRevokeCmd = &cobra.Command{
+	Use:   "revoke <invitation id>",
+	Short: "Revoke an invitation",
+	Long:  `Delete the invitation, so that it cannot be used.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.RevokeInvitation(args[0], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inviteCmd)
+	inviteCmd.AddCommand(inviteCreateCmd)
+	inviteCmd.AddCommand(inviteListCmd)
+	inviteCmd.AddCommand(inviteAcceptCmd)
+	inviteCmd.AddCommand(inviteRevokeCmd)
+
+	SetRequiredKeyFlag(inviteCreateCmd)
+	SetRequiredKeyFlag(inviteAcceptCmd)
+	SetRequiredKeyFlag(inviteRevokeCmd)
+	inviteCreateCmd.Flags().StringArray("share", nil, "path to share with the invitee. Can be repeated.")
+	inviteCreateCmd.Flags().StringP("alias", "a", "", "alias of the invitee in the user directory")
+}
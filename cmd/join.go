@@ -12,10 +12,13 @@ var joinCmd = &cobra.Command{
 	Use:   "join",
 	Short: "Join user to the repository",
 	Long: `Join user to the repository. This command join the current user to the repository by storing the corresponding public key in the repository. 
-	Use generate-key command to generate the private key.`,
+	Use generate-key command to generate the private key.
+	Use --invite with the invitation file created by 'invite create' to answer an invitation: the inviter gets your public key
+	and gives you access to the invited paths with 'invite accept'.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		invite, _ := cmd.Flags().GetString("invite")
 		// join the user
-		res := ctbApp.Join(encryptedPrivateKey)
+		res := ctbApp.Join(encryptedPrivateKey, invite)
 		MarshalOutput(res)
 	},
 }
@@ -23,4 +26,5 @@ var joinCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(joinCmd)
 	SetRequiredKeyFlag(joinCmd)
+	joinCmd.Flags().StringP("invite", "i", "", "invitation file")
 }
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// keyCmd represents the key command
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage the identity file",
+	Long: `Manage the identity file, which stores your private key sealed with a passphrase.
+	When the identity file exists, the commands requiring a private key unlock it instead of taking the 'key' flag.
+	The passphrase is asked on the terminal, or read from --passphrase-fd or the CTB_PASSPHRASE environment variable.
+	Use split and combine to share a private key, e.g. the recovery key of a repository, between custodians.`,
+}
+
+// keyCreateCmd represents the key create command
+var keyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create the identity file",
+	Long: `Create the identity file with a new private key, sealed with a passphrase.
+	Use --import to seal an existing private key, given by --key, --key-stdin, --key-fd or CTB_PRIVATE_KEY.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get the private key to import
+		if imp, _ := cmd.Flags().GetBool("import"); imp {
+			if loaded, err := loadExplicitPrivateKey(cmd); err != nil || !loaded {
+				_ = withErrorOutput(cmd, ErrNoPrivateKey)
+				return
+			}
+		} else {
+			encryptedPrivateKey = ""
+		}
+		passphrase, err := readPassphrase(cmd, "passphrase-fd", envPassphrase, "New passphrase: ", true)
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		res := ctbApp.CreateIdentity(encryptedPrivateKey, passphrase, force)
+		MarshalOutput(res)
+	},
+}
+
+// keyUnlockCmd represents the key unlock command
+var keyUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Check the passphrase of the identity file",
+	Long:  `Unlock the identity file with the passphrase and return the public key.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		passphrase, err := readPassphrase(cmd, "passphrase-fd", envPassphrase, "Passphrase: ", false)
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		res := ctbApp.UnlockIdentity(passphrase)
+		MarshalOutput(res)
+	},
+}
+
+// keyChangePassphraseCmd represents the key change-passphrase command
+var keyChangePassphraseCmd = &cobra.Command{
+	Use:   "change-passphrase",
+	Short: "Change the passphrase of the identity file",
+	Long: `Change the passphrase of the identity file.
+	The new passphrase is asked on the terminal, or read from --new-passphrase-fd or the CTB_NEW_PASSPHRASE environment variable.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		oldPassphrase, err := readPassphrase(cmd, "passphrase-fd", envPassphrase, "Current passphrase: ", false)
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		newPassphrase, err := readPassphrase(cmd, "new-passphrase-fd", envNewPassphrase, "New passphrase: ", true)
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		res := ctbApp.ChangeIdentityPassphrase(oldPassphrase, newPassphrase)
+		MarshalOutput(res)
+	},
+}
+
+// keyExportCmd represents the key export command
+var keyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the private key of the identity file",
+	Long:  `Unlock the identity file with the passphrase and return the private key.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		passphrase, err := readPassphrase(cmd, "passphrase-fd", envPassphrase, "Passphrase: ", false)
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		res := ctbApp.ExportIdentity(passphrase)
+		MarshalOutput(res)
+	},
+}
+
+// keySplitCmd represents the key split command
+var keySplitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split a private key between custodians",
+	Long: `Split a private key into Shamir shares, to be given to custodians: any --threshold of the --shares shares give back the key,
+	and fewer shares tell nothing about it. Every share has a checksum, so that a corrupted share is rejected by key combine.
+	The private key is given by --key, --key-stdin or --key-fd, or unlocked from the identity file.
+	Use --mnemonic to also return the shares as words, to be written down on paper.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loaded, err := loadExplicitPrivateKey(cmd)
+		if err == nil && !loaded {
+			err = unlockIdentity(cmd)
+		}
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		threshold, _ := cmd.Flags().GetInt("threshold")
+		shares, _ := cmd.Flags().GetInt("shares")
+		withMnemonic, _ := cmd.Flags().GetBool("mnemonic")
+		res := ctbApp.SplitKey(encryptedPrivateKey, threshold, shares, withMnemonic)
+		MarshalOutput(res)
+	},
+}
+
+// keyCombineCmd represents the key combine command
+var keyCombineCmd = &cobra.Command{
+	Use:   "combine [shares...]",
+	Short: "Give back a private key from the shares of the custodians",
+	Long: `Give back a private key from the shares created by key split, in base58 or as words, and return it as a string.
+	The shares are read from the arguments, or from stdin, one share per line until an empty line or the end of the input,
+	which keeps them out of the shell history.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		shares := args
+		// Read the shares from stdin
+		if len(shares) == 0 {
+			var err error
+			shares, err = readShares()
+			if err != nil {
+				_ = withErrorOutput(cmd, err)
+				return
+			}
+		}
+		res := ctbApp.CombineKey(shares)
+		MarshalOutput(res)
+	},
+}
+
+// readShares reads the shares from stdin, one share per line until an empty line or the end of the input.
+func readShares() ([]string, error) {
+	shares := make([]string, 0)
+	for {
+		if isTerminal(os.Stdin) {
+			fmt.Fprintf(os.Stderr, "Share %d (empty line to finish): ", len(shares)+1)
+		}
+		line, err := readLine(os.Stdin)
+		if err == io.ErrUnexpectedEOF || (err == nil && strings.TrimSpace(line) == "") {
+			return shares, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, line)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(keyCmd)
+	keyCmd.AddCommand(keyCreateCmd)
+	keyCmd.AddCommand(keyUnlockCmd)
+	keyCmd.AddCommand(keyChangePassphraseCmd)
+	keyCmd.AddCommand(keyExportCmd)
+	keyCmd.AddCommand(keySplitCmd)
+	keyCmd.AddCommand(keyCombineCmd)
+
+	keyCmd.PersistentFlags().Int("passphrase-fd", -1, "Read the passphrase of the identity file from the file descriptor.")
+	keyCreateCmd.Flags().Bool("import", false, "Seal an existing private key instead of generating a new one.")
+	keyCreateCmd.Flags().Bool("force", false, "Overwrite the existing identity file.")
+	keyCreateCmd.Flags().StringVarP(&encryptedPrivateKey, "key", "k", "", "The private key to import.")
+	keyCreateCmd.Flags().Bool("key-stdin", false, "Read the private key to import from stdin.")
+	keyCreateCmd.Flags().Int("key-fd", -1, "Read the private key to import from the file descriptor.")
+	keyChangePassphraseCmd.Flags().Int("new-passphrase-fd", -1, "Read the new passphrase from the file descriptor.")
+	keySplitCmd.Flags().StringVarP(&encryptedPrivateKey, "key", "k", "", "The private key to split.")
+	keySplitCmd.Flags().Bool("key-stdin", false, "Read the private key to split from stdin.")
+	keySplitCmd.Flags().Int("key-fd", -1, "Read the private key to split from the file descriptor.")
+	keySplitCmd.Flags().Int("threshold", 2, "Number of shares needed to give back the private key.")
+	keySplitCmd.Flags().Int("shares", 3, "Number of shares.")
+	keySplitCmd.Flags().Bool("mnemonic", false, "Also return the shares as words.")
+}
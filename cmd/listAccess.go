@@ -12,16 +12,18 @@ var listAccessCmd = &cobra.Command{
 	Use:   "list-access",
 	Short: "List access to a file or directory",
 	Long: `This command lists the access to a file or directory located at the specified path.
-	The access list includes the public keys of users who have access to the file or directory.`,
+	The access list includes the public keys of users who have access to the file or directory.
+	You can use the 'key' or 'ssh-key' flag to pass your private key, which is needed when the names are encrypted.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
-		res := ctbApp.ListAccess(path)
+		res := ctbApp.ListAccess(path, encryptedPrivateKey)
 		MarshalOutput(res)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listAccessCmd)
+	SetOptionalKeyFlag(listAccessCmd)
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,28 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// migrateLinksCmd represents the migrate-links command
+var migrateLinksCmd = &cobra.Command{
+	Use:   "migrate-links",
+	Short: "Encrypt the links of files",
+	Long: `Encrypt the links of files of a repository created without link encryption, and enable link encryption.
+	The links encrypted by previous versions, bound to the name of the file only, are sealed again bound to its full path.
+	Once all the links are sealed, the plaintext links and the links bound to the name only are refused.
+	The user must have access to all the directories of the repository.
+	If the migration is interrupted, run the command again to resume it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.MigrateLinks(encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateLinksCmd)
+	SetRequiredKeyFlag(migrateLinksCmd)
+}
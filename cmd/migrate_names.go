@@ -0,0 +1,28 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// migrateNamesCmd represents the migrate-names command
+var migrateNamesCmd = &cobra.Command{
+	Use:   "migrate-names",
+	Short: "Encrypt the names of files and directories",
+	Long: `Encrypt the names of files and directories of a repository created without name encryption.
+	The user must have access to all the directories of the repository. New repositories do not encrypt the names.
+	With encrypted names, the recipients of a share cannot resolve the path of a directory or a file below the root,
+	since the names are encrypted with the keys of the parent directories: only the root can be shared.
+	If the migration is interrupted, run the command again to resume it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.MigrateNames(encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateNamesCmd)
+	SetRequiredKeyFlag(migrateNamesCmd)
+}
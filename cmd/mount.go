@@ -14,10 +14,28 @@ import (
 var mountCmd = &cobra.Command{
 	Use:   "mount",
 	Short: "Mount",
-	Long:  `Mount the file system. This command mounts the file system and blocks the terminal.`,
+	Long: `Mount the file system. This command mounts the file system and blocks the terminal.
+	Use --ssh-key to open the files with an OpenSSH ed25519 private key file, for the files shared with its ssh-ed25519 public key.
+	Use --passphrase to open the files shared with a passphrase: the private key is then optional.
+	The passphrase is asked on the terminal, or read from --share-passphrase-fd or the CTB_SHARE_PASSPHRASE environment variable.
+	The expired shares are deleted before mounting, which reads the key of every file of the repository:
+	use --no-expire to skip it in large repositories, and run the expire command instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		res := ctbApp.PrepareMount(encryptedPrivateKey)
+		var passphrase []byte
+		if usePassphrase, _ := cmd.Flags().GetBool("passphrase"); usePassphrase {
+			var err error
+			passphrase, err = readPassphrase(cmd, "share-passphrase-fd", envSharePassphrase, "Share passphrase: ", false)
+			if err != nil {
+				_ = withErrorOutput(cmd, err)
+				return
+			}
+		}
+		noExpire, _ := cmd.Flags().GetBool("no-expire")
+		res := ctbApp.PrepareMount(encryptedPrivateKey, passphrase, !noExpire)
 		MarshalOutput(res)
+		if !res.Ok {
+			return
+		}
 		fmt.Fprint(os.Stdout, "/**********************************\n")
 		ctbApp.Mount()
 	},
@@ -26,4 +44,12 @@ var mountCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(mountCmd)
 	SetRequiredKeyFlag(mountCmd)
+	// The private key is optional when the files are opened with a passphrase
+	mountCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		usePassphrase, _ := cmd.Flags().GetBool("passphrase")
+		return withErrorOutput(cmd, loadPrivateKey(cmd, !usePassphrase))
+	}
+	mountCmd.Flags().Bool("passphrase", false, "Open the files shared with a passphrase.")
+	mountCmd.Flags().Int("share-passphrase-fd", -1, "Read the share passphrase from the file descriptor.")
+	mountCmd.Flags().Bool("no-expire", false, "Do not delete the expired shares before mounting.")
 }
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// envRecoveryKey is the environment variable holding the recovery private key
+const envRecoveryKey = "CTB_RECOVERY_KEY"
+
+// recoveryKeyCmd represents the recovery-key command
+var recoveryKeyCmd = &cobra.Command{
+	Use:   "recovery-key",
+	Short: "Manage the recovery key of the repository",
+	Long: `Manage the recovery key of the repository. The root and top-level vault keys are sealed with the recovery public key,
+	so that the recover command can give access to a new owner if the owners lose their private keys.
+	Generate the recovery key with generate-key and keep the private key offline.`,
+}
+
+// recoveryKeySetCmd represents the recovery-key set command
+var recoveryKeySetCmd = &cobra.Command{
+	Use:   "set <public key>",
+	Short: "Set the recovery key of the repository",
+	Long: `Record the recovery public key in the policy of the repository and seal the root and top-level vault keys with it.
+	The command is run with the private key of an owner of the repository, who signs the policy and proves it with the root vault key.
+	A recovery key recorded in the repository configuration by the previous versions is not used anymore and must be set again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.SetRecoveryKey(args[0], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// recoveryKeyShowCmd represents the recovery-key show command
+var recoveryKeyShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the recovery key of the repository",
+	Long: `Show the recovery public key of the repository and its fingerprint.
+	The policy of the repository is verified with the root vault key, so the command is run with the private key of an owner of the repository.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.GetRecoveryKey(encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// recoveryKeyPublishCmd represents the recovery-key publish command
+var recoveryKeyPublishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Publish the encapsulation key of the recovery key",
+	Long: `Publish the signing identity and the ML-KEM encapsulation key of the recovery key in the repository.
+	It is needed before recovery-key set in a repository created with --hybrid, whose data keys are only sealed with the hybrid scheme.
+	The recovery private key is read as by the recover command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		recoveryKey, err := readRecoveryKey(cmd)
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		res := ctbApp.PublishRecoveryKey(recoveryKey)
+		MarshalOutput(res)
+	},
+}
+
+// recoverCmd represents the recover command
+var recoverCmd = &cobra.Command{
+	Use:   "recover <new owner>",
+	Short: "Give access to a new owner with the recovery key",
+	Long: `Open the root and top-level vault keys with the recovery private key and share them with the new owner,
+	given as a public key or an alias of the user directory. The new owner is joined in the repository.
+	The recovery private key is read from --recovery-key-fd, --recovery-key-stdin, the CTB_RECOVERY_KEY environment variable,
+	or asked on the terminal.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		recoveryKey, err := readRecoveryKey(cmd)
+		if err != nil {
+			_ = withErrorOutput(cmd, err)
+			return
+		}
+		res := ctbApp.Recover(recoveryKey, args[0])
+		MarshalOutput(res)
+	},
+}
+
+// readRecoveryKey reads the recovery private key from the file descriptor, stdin, the environment, or the terminal.
+func readRecoveryKey(cmd *cobra.Command) (string, error) {
+	if fd, _ := cmd.Flags().GetInt("recovery-key-fd"); fd >= 0 {
+		return readFd(fd)
+	}
+	if fromStdin, _ := cmd.Flags().GetBool("recovery-key-stdin"); fromStdin {
+		return readLine(os.Stdin)
+	}
+	if key := os.Getenv(envRecoveryKey); key != "" {
+		return key, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return "", ErrNoTerminal
+	}
+	return promptPassword("Recovery private key: ")
+}
+
+func init() {
+	rootCmd.AddCommand(recoveryKeyCmd)
+	recoveryKeyCmd.AddCommand(recoveryKeySetCmd)
+	recoveryKeyCmd.AddCommand(recoveryKeyShowCmd)
+	recoveryKeyCmd.AddCommand(recoveryKeyPublishCmd)
+	rootCmd.AddCommand(recoverCmd)
+
+	SetRequiredKeyFlag(recoveryKeySetCmd)
+	SetRequiredKeyFlag(recoveryKeyShowCmd)
+	recoverCmd.Flags().Int("recovery-key-fd", -1, "Read the recovery private key from the file descriptor.")
+	recoverCmd.Flags().Bool("recovery-key-stdin", false, "Read the recovery private key from stdin.")
+	recoveryKeyPublishCmd.Flags().Int("recovery-key-fd", -1, "Read the recovery private key from the file descriptor.")
+	recoveryKeyPublishCmd.Flags().Bool("recovery-key-stdin", false, "Read the recovery private key from stdin.")
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// restoreKeyCmd represents the restore-key command
+var restoreKeyCmd = &cobra.Command{
+	Use:   "restore-key [words...]",
+	Short: "Restore a private key from its mnemonic",
+	Long: `Restore a private key from the mnemonic returned by 'generate-key --mnemonic' and return it as a string.
+	The words are read from the arguments, or from stdin if no argument is given, which keeps them out of the shell history.
+	The checksum of the mnemonic is verified, so that a misspelled or missing word is detected.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		words := strings.Join(args, " ")
+		// Read the words from stdin
+		if len(args) == 0 {
+			if isTerminal(os.Stdin) {
+				fmt.Fprint(os.Stderr, "Mnemonic: ")
+			}
+			line, err := readLine(os.Stdin)
+			if err != nil {
+				_ = withErrorOutput(cmd, err)
+				return
+			}
+			words = line
+		}
+		res := ctbApp.RestoreUserKey(words)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreKeyCmd)
+}
@@ -18,6 +18,7 @@ import (
 )
 
 var cfgFile string
+var identityFile string
 var repoPath string
 var encryptedPrivateKey string
 var output outputEnum = outputEnumText
@@ -52,6 +53,7 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&repoPath, "path", "p", "", "path to the repository")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $USERPROFILE/.ctb/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&identityFile, "identity", "", "identity file (default is $HOME/.ctb/identity.json)")
 	rootCmd.PersistentFlags().VarP(&output, "output", "o", `Output format. allowed: "json", "text", "yaml", and "xml"`)
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -77,6 +79,7 @@ func initConfig() {
 		repoRootPath,
 		getTempPath(),
 		cfgFile,
+		identityFile,
 	)
 	if err != nil {
 		panic(err)
@@ -14,19 +14,36 @@ var shareCmd = &cobra.Command{
 	Use:   "share",
 	Short: "Share files with other users",
 	Long: `This command shares file or directory with the specified path with the given public key.
-	The files are shared with the user who has the corresponding private key.`,
+	The files are shared with the user who has the corresponding private key.
+	The recipient can also be an ssh-ed25519 public key: its owner opens the files with the SSH private key file given by --ssh-key.
+	Use --group instead of --recipient to share with all the members of a group.
+	Use --expires to give access until a date: the expired shares are deleted by the expire command.
+	Use --passphrase to share with people who have no key pair: they mount the repository with the same passphrase.
+	A file or directory is shared with a single passphrase: remove the share with 'unshare --passphrase' to change it.
+	The passphrase is asked on the terminal, or read from --share-passphrase-fd or the CTB_SHARE_PASSPHRASE environment variable.`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
 		recipient, _ := cmd.Flags().GetString("recipient")
-		if join, _ := cmd.Flags().GetBool("join"); join {
+		group, _ := cmd.Flags().GetString("group")
+		expires, _ := cmd.Flags().GetString("expires")
+		if join, _ := cmd.Flags().GetBool("join"); join && recipient != "" {
 			joinRes := ctbApp.JoinByUserId(recipient)
 			if !joinRes.Ok && joinRes.Err != key_service.ErrUserAlreadyJoined {
 				MarshalOutput(joinRes)
 				return
 			}
 		}
-		res := ctbApp.Share(path, recipient, encryptedPrivateKey)
+		var passphrase []byte
+		if usePassphrase, _ := cmd.Flags().GetBool("passphrase"); usePassphrase {
+			var err error
+			passphrase, err = readPassphrase(cmd, "share-passphrase-fd", envSharePassphrase, "Share passphrase: ", true)
+			if err != nil {
+				_ = withErrorOutput(cmd, err)
+				return
+			}
+		}
+		res := ctbApp.Share(path, recipient, group, passphrase, expires, encryptedPrivateKey)
 		MarshalOutput(res)
 	},
 }
@@ -34,10 +51,11 @@ var shareCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(shareCmd)
 	SetRequiredKeyFlag(shareCmd)
-	shareCmd.PersistentFlags().StringP("recipient", "r", "", "recipient public key. Required.")
+	shareCmd.PersistentFlags().StringP("recipient", "r", "", "recipient public key, ssh-ed25519 public key or alias of the user directory. Required unless --group or --passphrase is given.")
+	shareCmd.PersistentFlags().StringP("group", "g", "", "recipient group name.")
 	shareCmd.Flags().BoolP("join", "j", false, "Join the user if not already joined.")
-	err := shareCmd.MarkPersistentFlagRequired("recipient")
-	if err != nil {
-		panic(err)
-	}
+	shareCmd.Flags().String("expires", "", "last day of the share (2006-01-02), or expiry time (RFC 3339).")
+	shareCmd.Flags().Bool("passphrase", false, "share with a passphrase, for people who have no key pair.")
+	shareCmd.Flags().Int("share-passphrase-fd", -1, "Read the share passphrase from the file descriptor.")
+	shareCmd.MarkFlagsMutuallyExclusive("recipient", "group", "passphrase")
 }
@@ -1,14 +1,241 @@
 package cmd
 
 import (
+	"ctb-cli/agent"
+	"ctb-cli/core"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
-// SetRequiredKeyFlag sets the required 'key' flag for a command.
+const (
+	envPrivateKey      = "CTB_PRIVATE_KEY"      // envPrivateKey is the environment variable holding the private key
+	envPassphrase      = "CTB_PASSPHRASE"       // envPassphrase is the environment variable holding the passphrase of the identity file
+	envNewPassphrase   = "CTB_NEW_PASSPHRASE"   // envNewPassphrase is the environment variable holding the new passphrase of the identity file
+	envSharePassphrase = "CTB_SHARE_PASSPHRASE" // envSharePassphrase is the environment variable holding the passphrase of the shares with a passphrase
+)
+
+var (
+	ErrNoPrivateKey       = errors.New("no private key: use --key, --key-stdin, --key-fd, --ssh-key, " + envPrivateKey + ", " + agent.SocketEnv + " or create an identity file with 'key create'")
+	ErrPassphraseMismatch = errors.New("passphrases do not match")
+	ErrNoTerminal         = errors.New("cannot read the passphrase: no terminal, use --passphrase-fd or " + envPassphrase)
+)
+
+// SetRequiredKeyFlag sets the flags for the private key sources of a command, and requires the private key.
+// The private key is read from the first available source: the 'key' flag, stdin, a file descriptor,
+// an OpenSSH ed25519 private key file, the CTB_PRIVATE_KEY environment variable, the key agent, or the identity file unlocked with the passphrase.
 func SetRequiredKeyFlag(c *cobra.Command) {
-	c.PersistentFlags().StringVarP(&encryptedPrivateKey, "key", "k", "", "Your private key. Required.")
-	err := c.MarkPersistentFlagRequired("key")
+	setKeySourceFlags(c, "Your private key. Not recommended, it is visible in the shell history and the process list.")
+	c.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return withErrorOutput(cmd, loadPrivateKey(cmd, true))
+	}
+}
+
+// SetOptionalKeyFlag sets the flags for the private key sources of a command, without requiring the private key.
+// The identity file is not used, since unlocking it would require a passphrase.
+func SetOptionalKeyFlag(c *cobra.Command) {
+	setKeySourceFlags(c, "Your private key. Optional.")
+	c.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return withErrorOutput(cmd, loadPrivateKey(cmd, false))
+	}
+}
+
+// SetIdentityKeyFlag sets the flags for the private key sources of a command, without requiring the private key.
+// Unlike SetOptionalKeyFlag, the identity file is used if no other private key is given. If the passphrase
+// cannot be asked since there is no terminal, the command runs without the private key.
+func SetIdentityKeyFlag(c *cobra.Command) {
+	setKeySourceFlags(c, "Your private key. Optional.")
+	c.PreRunE = func(cmd *cobra.Command, args []string) error {
+		err := loadPrivateKey(cmd, false)
+		if err == nil && encryptedPrivateKey == "" && os.Getenv(agent.SocketEnv) == "" && ctbApp.HasIdentity() {
+			if err = unlockIdentity(cmd); errors.Is(err, ErrNoTerminal) {
+				err = nil
+			}
+		}
+		return withErrorOutput(cmd, err)
+	}
+}
+
+// setKeySourceFlags sets the flags for the private key sources of a command.
+func setKeySourceFlags(c *cobra.Command, keyUsage string) {
+	c.PersistentFlags().StringVarP(&encryptedPrivateKey, "key", "k", "", keyUsage)
+	c.PersistentFlags().Bool("key-stdin", false, "Read the private key from stdin.")
+	c.PersistentFlags().Int("key-fd", -1, "Read the private key from the file descriptor.")
+	c.PersistentFlags().String("ssh-key", "", "Use the OpenSSH ed25519 private key file, shared with as an ssh-ed25519 public key.")
+	c.PersistentFlags().Int("passphrase-fd", -1, "Read the passphrase of the identity file or the SSH key from the file descriptor.")
+}
+
+// withErrorOutput prints the error as an AppResult and silences the usage, so that the errors of the
+// pre-run functions are printed like the errors of the commands.
+func withErrorOutput(cmd *cobra.Command, err error) error {
 	if err != nil {
-		panic(err)
+		MarshalOutput(core.NewAppResultWithError(err))
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+	}
+	return err
+}
+
+// loadPrivateKey sets the private key from the first available source: the 'key' flag, stdin, a file descriptor,
+// an OpenSSH private key file, the CTB_PRIVATE_KEY environment variable, the key agent (CTB_AGENT_SOCK), or the identity file.
+// If required is false, the identity file is not used and no error is returned if no private key is found.
+func loadPrivateKey(cmd *cobra.Command, required bool) error {
+	if loaded, err := loadExplicitPrivateKey(cmd); loaded || err != nil {
+		return err
+	}
+	// Use the key agent
+	if socket := os.Getenv(agent.SocketEnv); socket != "" {
+		ctbApp.UseAgent(socket)
+		return nil
+	}
+	if !required {
+		return nil
+	}
+	return unlockIdentity(cmd)
+}
+
+// loadExplicitPrivateKey sets the private key from the 'key' flag, stdin, a file descriptor,
+// an OpenSSH private key file, or the CTB_PRIVATE_KEY environment variable. It returns false if none of them is given.
+func loadExplicitPrivateKey(cmd *cobra.Command) (loaded bool, err error) {
+	if encryptedPrivateKey != "" {
+		return true, nil
+	}
+	// Read the private key from stdin
+	if fromStdin, _ := cmd.Flags().GetBool("key-stdin"); fromStdin {
+		encryptedPrivateKey, err = readLine(os.Stdin)
+		return true, err
+	}
+	// Read the private key from the file descriptor
+	if fd, _ := cmd.Flags().GetInt("key-fd"); fd >= 0 {
+		encryptedPrivateKey, err = readFd(fd)
+		return true, err
+	}
+	// Read the private key from the OpenSSH private key file
+	if path, _ := cmd.Flags().GetString("ssh-key"); path != "" {
+		encryptedPrivateKey, err = readSSHPrivateKey(cmd, path)
+		return true, err
+	}
+	// Read the private key from the environment
+	if key := os.Getenv(envPrivateKey); key != "" {
+		encryptedPrivateKey = key
+		return true, nil
+	}
+	return false, nil
+}
+
+// unlockIdentity sets the private key from the identity file, unlocked with the passphrase.
+func unlockIdentity(cmd *cobra.Command) error {
+	if !ctbApp.HasIdentity() {
+		return ErrNoPrivateKey
+	}
+	passphrase, err := readPassphrase(cmd, "passphrase-fd", envPassphrase, "Passphrase: ", false)
+	if err != nil {
+		return err
+	}
+	res := ctbApp.ExportIdentity(passphrase)
+	if !res.Ok {
+		return res.Err
+	}
+	encryptedPrivateKey = res.Result.(string)
+	return nil
+}
+
+// readSSHPrivateKey reads the OpenSSH ed25519 private key file and returns the encoded X25519 private key it converts to.
+// The passphrase of a protected file is read like the passphrase of the identity file.
+func readSSHPrivateKey(cmd *cobra.Command, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	privateKey, err := core.NewPrivateKeyFromSSH(data, nil)
+	if errors.Is(err, core.ErrSSHPassphraseRequired) {
+		passphrase, passErr := readPassphrase(cmd, "passphrase-fd", envPassphrase, "SSH key passphrase: ", false)
+		if passErr != nil {
+			return "", passErr
+		}
+		privateKey, err = core.NewPrivateKeyFromSSH(data, passphrase)
+	}
+	if err != nil {
+		return "", err
+	}
+	return privateKey.Unsafe().Encode(), nil
+}
+
+// readPassphrase reads a passphrase from the file descriptor given by the flag, the environment variable,
+// or the terminal. If confirm is true, the passphrase is asked twice on the terminal.
+func readPassphrase(cmd *cobra.Command, fdFlag string, env string, prompt string, confirm bool) ([]byte, error) {
+	// Read the passphrase from the file descriptor
+	if fd, _ := cmd.Flags().GetInt(fdFlag); fd >= 0 {
+		passphrase, err := readFd(fd)
+		return []byte(passphrase), err
+	}
+	// Read the passphrase from the environment
+	if passphrase := os.Getenv(env); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	// Ask the passphrase on the terminal
+	if !isTerminal(os.Stdin) {
+		return nil, ErrNoTerminal
+	}
+	passphrase, err := promptPassword(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		confirmation, err := promptPassword("Confirm " + strings.ToLower(prompt))
+		if err != nil {
+			return nil, err
+		}
+		if confirmation != passphrase {
+			return nil, ErrPassphraseMismatch
+		}
+	}
+	return []byte(passphrase), nil
+}
+
+// promptPassword prints the prompt on stderr and reads a line from the terminal without echo.
+func promptPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+	return readPassword(os.Stdin)
+}
+
+// readFd reads the first line of the file descriptor.
+func readFd(fd int) (string, error) {
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if file == nil {
+		return "", fmt.Errorf("invalid file descriptor: %d", fd)
+	}
+	defer file.Close()
+	return readLine(file)
+}
+
+// readLine reads a line from the reader, without the line ending.
+// It reads byte by byte so that nothing after the line is consumed.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err == io.EOF {
+			if len(line) == 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			break
+		}
+		if err != nil {
+			return "", err
+		}
 	}
+	return strings.TrimSuffix(string(line), "\r"), nil
 }
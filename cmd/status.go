@@ -13,7 +13,8 @@ var statusCmd = &cobra.Command{
 	Short: "Get the status of the repository.",
 	Long: `Get the status of the repository. It checks if the repository is valid and if the user has joined.
 	Returns an AppResult with the repository status.
-	You can use the 'key' flag to pass your private key. If you don't pass it, the joined status will be false.`,
+	You can use the 'key' or 'ssh-key' flag to pass your private key. If you don't pass it, the key agent or the identity file is used.
+	Without a private key, or if the passphrase of the identity file cannot be asked, the joined status will be false.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		res := ctbApp.GetStatus(encryptedPrivateKey)
 		MarshalOutput(res)
@@ -22,5 +23,5 @@ var statusCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
-	statusCmd.PersistentFlags().StringVarP(&encryptedPrivateKey, "key", "k", "", "Your private key. Optional.")
+	SetIdentityKeyFlag(statusCmd)
 }
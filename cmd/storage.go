@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// storageCmd represents the storage command
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage the object storage of the repository",
+	Long: `Manage the object storage of the repository. The storage is selected by the storage section of the repository
+	configuration (.meta/config.yaml) or of the user configuration (--config, default $HOME/.ctb/config.yaml),
+	the repository overriding the user:
+
+	storage:
+	  type: s3            # cloud, s3, local or none
+	  endpoint: https://s3.example.com
+	  bucket: my-bucket
+	  region: eu-west-1
+	  profile: work       # profile of the shared AWS credentials
+	  path: /mnt/objects  # directory of the local storage
+	  chunk_size: 16MB
+	  concurrency: 8
+
+	The S3 storage also takes:
+
+	  prefix: team/repo          # prefix of the object keys, to keep several repositories in a bucket
+	  path_style: true           # address the bucket in the URL path, for MinIO and Ceph
+	  storage_class: STANDARD_IA
+	  sse: aws:kms               # server-side encryption, AES256 or aws:kms
+	  sse_kms_key_id: alias/ctb`,
+}
+
+// storageTestCmd represents the storage test command
+var storageTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Test the connectivity to the object storage",
+	Long:  `Write a test object to the object storage selected in the configuration and read it back.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.TestStorage()
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+	storageCmd.AddCommand(storageTestCmd)
+}
@@ -4,6 +4,8 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"ctb-cli/core"
+
 	"github.com/spf13/cobra"
 )
 
@@ -11,20 +13,39 @@ import (
 var unshareCmd = &cobra.Command{
 	Use:   "unshare",
 	Short: "Unshare files with other users",
-	Long:  `This command unshares file or directory with the specified path with the given public key.`,
+	Long: `This command unshares file or directory with the specified path with the given public key.
+	With --rotate, the keys are replaced so that the user can no longer decrypt the files, even with cached keys.
+	The files can be encrypted again on their next write (lazy) or immediately (eager).
+	If the rotation is interrupted, run the command again to resume it.
+	The shares with a passphrase cannot be renewed with the new keys: they are removed and listed in the output.
+	Use --group instead of --recipient to unshare with a group, or --passphrase to remove the share with a passphrase.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
 		recipient, _ := cmd.Flags().GetString("recipient")
-		res := ctbApp.Unshare(path, recipient)
+		group, _ := cmd.Flags().GetString("group")
+		if usePassphrase, _ := cmd.Flags().GetBool("passphrase"); usePassphrase {
+			recipient = core.PassphraseRecipient
+		}
+		rotate, _ := cmd.Flags().GetBool("rotate")
+		reencrypt, _ := cmd.Flags().GetString("reencrypt")
+		res := ctbApp.Unshare(path, recipient, group, encryptedPrivateKey, rotate, reencrypt)
 		MarshalOutput(res)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(unshareCmd)
-	unshareCmd.PersistentFlags().StringP("recipient", "r", "", "recipient public key. Required.")
-	err := unshareCmd.MarkPersistentFlagRequired("recipient")
-	if err != nil {
-		panic(err)
+	unshareCmd.PersistentFlags().StringP("recipient", "r", "", "recipient public key or alias of the user directory. Required unless --group or --passphrase is given.")
+	unshareCmd.PersistentFlags().StringP("group", "g", "", "recipient group name.")
+	SetOptionalKeyFlag(unshareCmd)
+	// The private key is required to rotate the keys
+	unshareCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		rotate, _ := cmd.Flags().GetBool("rotate")
+		return withErrorOutput(cmd, loadPrivateKey(cmd, rotate))
 	}
+	unshareCmd.Flags().Bool("rotate", false, "Rotate the keys the user had access to.")
+	unshareCmd.Flags().String("reencrypt", "none", `Re-encryption of the files after rotation. allowed: "none", "lazy", and "eager"`)
+	unshareCmd.Flags().Bool("passphrase", false, "remove the share with a passphrase.")
+	unshareCmd.MarkFlagsMutuallyExclusive("recipient", "group", "passphrase")
 }
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// userCmd represents the user command
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage the user directory",
+	Long: `Manage the user directory of the repository. The directory maps the public keys of the users to aliases,
+	display names and emails. The aliases can be used instead of the public keys with 'share -r' and 'group add'.
+	Every entry is signed by the user who added it. Compare the fingerprint or the safety words of a key
+	with its owner before adding it.`,
+}
+
+// userAddCmd represents the user add command
+var userAddCmd = &cobra.Command{
+	Use:   "add <public key>",
+	Short: "Add a user to the user directory",
+	Long: `Add the user with the public key to the user directory, or replace its entry.
+	The entry is signed with your key.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, _ := cmd.Flags().GetString("alias")
+		name, _ := cmd.Flags().GetString("name")
+		email, _ := cmd.Flags().GetString("email")
+		res := ctbApp.AddUser(args[0], alias, name, email, encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+// userListCmd represents the user list command
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the users of the user directory",
+	Long: `List the users of the user directory with the fingerprints of their keys and the verification of their entries.
+	An entry is verified if it is signed by the owner of the repository, who wrote the root vault, or by you.
+	The entries signed by other users are untrusted, and their aliases cannot be used as recipients.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListUsers()
+		MarshalOutput(res)
+	},
+}
+
+// userRemoveCmd represents the user remove command
+var userRemoveCmd = &cobra.Command{
+	Use:   "remove <alias|public key>",
+	Short: "Remove a user from the user directory",
+	Long: `Remove the user with the alias or public key from the user directory. The files shared with the user stay shared.
+	Only the user who added the entry or the owner of the repository can remove it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.RemoveUser(args[0], encryptedPrivateKey)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userAddCmd)
+	userCmd.AddCommand(userListCmd)
+	userCmd.AddCommand(userRemoveCmd)
+
+	SetRequiredKeyFlag(userAddCmd)
+	SetRequiredKeyFlag(userRemoveCmd)
+	userAddCmd.Flags().StringP("alias", "a", "", "alias of the user")
+	userAddCmd.Flags().StringP("name", "n", "", "display name of the user")
+	userAddCmd.Flags().StringP("email", "e", "", "email of the user")
+	_ = userAddCmd.MarkFlagRequired("alias")
+}
@@ -1,22 +1,75 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+
+	"github.com/spf13/viper"
 )
 
 // Config represents the configuration of the application
 type Config struct {
-	repoPath string // path to the repository
-	tempPath string // path to the temporary folder of the application
+	repoPath     string // path to the repository
+	tempPath     string // path to the temporary folder of the application
+	identityPath string // path to the identity file of the user
+	storage      Storage
+	// allowUnsignedRecords accepts the records written before the records were signed. It is only read from the
+	// configuration of the user, since a writer of the repository could replace the records by removing their signature.
+	allowUnsignedRecords bool
+	// requireHybridKeyWrapping seals the data keys with the hybrid X25519 + ML-KEM-768 scheme only, even if the
+	// configuration of the repository, which a writer of the repository can change, does not require it
+	requireHybridKeyWrapping bool
 }
 
 // New returns a new Config
-func New(repoPath string, tempPath string, cfgFile string) (*Config, error) {
-	return &Config{
-		repoPath: repoPath,
-		tempPath: tempPath,
-	}, nil
+// If identityPath is empty, the identity file is stored in the .ctb folder of the user home directory.
+// The configuration of the user is read from cfgFile, or from config.yaml in the .ctb folder of the user
+// home directory if cfgFile is empty and the file exists.
+func New(repoPath string, tempPath string, cfgFile string, identityPath string) (*Config, error) {
+	cfg := &Config{
+		repoPath:     repoPath,
+		tempPath:     tempPath,
+		identityPath: identityPath,
+	}
+	userCfg, err := readUserConfig(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.storage = ReadStorage(userCfg)
+	cfg.allowUnsignedRecords = userCfg.GetBool("allow_unsigned_records")
+	cfg.requireHybridKeyWrapping = userCfg.GetBool("require_hybrid_key_wrapping")
+	return cfg, nil
+}
+
+// IsUnsignedRecordsAllowed returns true if the user accepts the records written before the records were signed,
+// with allow_unsigned_records in the configuration of the user. It is an explicit exception for the legacy repositories.
+func (c *Config) IsUnsignedRecordsAllowed() bool {
+	return c.allowUnsignedRecords
+}
+
+// IsHybridKeyWrappingRequired returns true if the user requires the hybrid key wrapping in every repository,
+// with require_hybrid_key_wrapping in the configuration of the user.
+func (c *Config) IsHybridKeyWrappingRequired() bool {
+	return c.requireHybridKeyWrapping
+}
+
+// GetStorage returns the storage configuration of the user. It is overridden by the storage configuration
+// of the repository.
+func (c *Config) GetStorage() Storage {
+	return c.storage
+}
+
+// GetIdentityPath returns the path of the identity file of the user.
+func (c *Config) GetIdentityPath() (string, error) {
+	if c.identityPath != "" {
+		return c.identityPath, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ctb", "identity.json"), nil
 }
 
 // GetTempRoot returns the root path of the temporary folder.
@@ -43,3 +96,24 @@ func (c *Config) GetCacheRoot() (string, error) {
 	}
 	return path, nil
 }
+
+// readUserConfig reads the configuration file of the user. An empty configuration is returned if no file
+// is given and the default file does not exist.
+func readUserConfig(cfgFile string) (*viper.Viper, error) {
+	cfg := viper.New()
+	if cfgFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return cfg, nil
+		}
+		cfgFile = filepath.Join(home, ".ctb", "config.yaml")
+		if _, err := os.Stat(cfgFile); errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+	}
+	cfg.SetConfigFile(cfgFile)
+	if err := cfg.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnsignedRecordsAllowedByUser(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgFile, []byte("allow_unsigned_records: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := New(t.TempDir(), t.TempDir(), cfgFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.IsUnsignedRecordsAllowed() {
+		t.Error("Expected the unsigned records to be allowed by the user configuration")
+	}
+}
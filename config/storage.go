@@ -0,0 +1,103 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// The types of object storage
+const (
+	StorageCloud = "cloud" // StorageCloud stores the objects in the cloud service.
+	StorageS3    = "s3"    // StorageS3 stores the objects in an S3 bucket.
+	StorageLocal = "local" // StorageLocal stores the objects in a local directory.
+	StorageNone  = "none"  // StorageNone does not store the objects, which are only kept in the repository.
+)
+
+// Storage is the configuration of the object storage, read from the storage section of a configuration file.
+// The zero values are not set, and the defaults of the storage are used for them.
+type Storage struct {
+	Type        string // Type is the type of the storage, StorageCloud if it is not set.
+	Endpoint    string // Endpoint is the URL of the cloud service or of the S3 service.
+	Bucket      string // Bucket is the name of the S3 bucket.
+	Region      string // Region is the region of the S3 bucket.
+	Profile     string // Profile is the name of the profile of the shared credentials of S3.
+	Path        string // Path is the directory of the local storage.
+	ChunkSize   uint64 // ChunkSize is the size of the parts of the transfers, in bytes.
+	Concurrency int    // Concurrency is the number of parts transferred at the same time.
+
+	Prefix               string // Prefix is prepended to the keys of the S3 objects, to keep several repositories in a bucket.
+	PathStyle            bool   // PathStyle addresses the S3 bucket in the path of the URL, as MinIO and Ceph need.
+	StorageClass         string // StorageClass is the S3 storage class of the uploaded objects.
+	ServerSideEncryption string // ServerSideEncryption is the S3 server-side encryption of the uploaded objects.
+	KMSKeyId             string // KMSKeyId is the KMS key of the aws:kms server-side encryption.
+}
+
+// ReadStorage reads the storage section of the configuration.
+// The chunk size is a number of bytes, with an optional unit such as "16MB".
+func ReadStorage(cfg *viper.Viper) Storage {
+	return Storage{
+		Type:        cfg.GetString("storage.type"),
+		Endpoint:    cfg.GetString("storage.endpoint"),
+		Bucket:      cfg.GetString("storage.bucket"),
+		Region:      cfg.GetString("storage.region"),
+		Profile:     cfg.GetString("storage.profile"),
+		Path:        cfg.GetString("storage.path"),
+		ChunkSize:   uint64(cfg.GetSizeInBytes("storage.chunk_size")),
+		Concurrency: cfg.GetInt("storage.concurrency"),
+
+		Prefix:               cfg.GetString("storage.prefix"),
+		PathStyle:            cfg.GetBool("storage.path_style"),
+		StorageClass:         cfg.GetString("storage.storage_class"),
+		ServerSideEncryption: cfg.GetString("storage.sse"),
+		KMSKeyId:             cfg.GetString("storage.sse_kms_key_id"),
+	}
+}
+
+// Merge returns the storage configuration overridden by the values set in other.
+// It is used to override the configuration of the user with the configuration of the repository.
+// If other sets another type of storage, its whole configuration is used, since the values of the other
+// storage do not apply to it. The path-style addressing belongs to the endpoint: it is taken from other
+// if other sets the endpoint, and used if either of them enables it otherwise.
+func (s Storage) Merge(other Storage) Storage {
+	if other.Type != "" && other.Type != s.storageType() {
+		return other
+	}
+	merged := s
+	setString(&merged.Type, other.Type)
+	setString(&merged.Endpoint, other.Endpoint)
+	setString(&merged.Bucket, other.Bucket)
+	setString(&merged.Region, other.Region)
+	setString(&merged.Profile, other.Profile)
+	setString(&merged.Path, other.Path)
+	if other.ChunkSize != 0 {
+		merged.ChunkSize = other.ChunkSize
+	}
+	if other.Concurrency != 0 {
+		merged.Concurrency = other.Concurrency
+	}
+	setString(&merged.Prefix, other.Prefix)
+	if other.Endpoint != "" {
+		merged.PathStyle = other.PathStyle
+	} else {
+		merged.PathStyle = merged.PathStyle || other.PathStyle
+	}
+	setString(&merged.StorageClass, other.StorageClass)
+	setString(&merged.ServerSideEncryption, other.ServerSideEncryption)
+	setString(&merged.KMSKeyId, other.KMSKeyId)
+	merged.Type = merged.storageType()
+	return merged
+}
+
+// storageType returns the type of the storage, StorageCloud if it is not set.
+func (s Storage) storageType() string {
+	if s.Type == "" {
+		return StorageCloud
+	}
+	return s.Type
+}
+
+// setString sets the value if it is not empty.
+func setString(target *string, value string) {
+	if value != "" {
+		*target = value
+	}
+}
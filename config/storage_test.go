@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageConfig(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "config.yaml")
+	content := "storage:\n  type: s3\n  bucket: user-bucket\n  profile: work\n  chunk_size: 16MB\n  concurrency: 8\n"
+	if err := os.WriteFile(cfgFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := New(t.TempDir(), t.TempDir(), cfgFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := cfg.GetStorage()
+	if user.Type != StorageS3 || user.Bucket != "user-bucket" || user.ChunkSize != 16*1024*1024 || user.Concurrency != 8 {
+		t.Errorf("Unexpected storage configuration of the user: %+v", user)
+	}
+
+	// The repository overrides the values it sets, and keeps the others of the user
+	merged := user.Merge(Storage{Bucket: "repo-bucket", Region: "eu-west-1"})
+	expected := Storage{Type: StorageS3, Bucket: "repo-bucket", Region: "eu-west-1", Profile: "work", ChunkSize: 16 * 1024 * 1024, Concurrency: 8}
+	if merged != expected {
+		t.Errorf("Expected %+v, got %+v", expected, merged)
+	}
+
+	// Another type of storage set by the repository replaces the whole configuration of the user
+	merged = user.Merge(Storage{Type: StorageLocal, Path: "objects"})
+	expected = Storage{Type: StorageLocal, Path: "objects"}
+	if merged != expected {
+		t.Errorf("Expected %+v, got %+v", expected, merged)
+	}
+	merged = (Storage{Endpoint: "https://cloud.example.com"}).Merge(Storage{Type: StorageS3, Bucket: "repo-bucket"})
+	expected = Storage{Type: StorageS3, Bucket: "repo-bucket"}
+	if merged != expected {
+		t.Errorf("Expected %+v, got %+v", expected, merged)
+	}
+
+	// The path-style addressing is taken with the endpoint
+	pathStyle := Storage{Type: StorageS3, Endpoint: "http://minio:9000", PathStyle: true}
+	if merged := pathStyle.Merge(Storage{Endpoint: "https://s3.example.com"}); merged.PathStyle {
+		t.Error("Expected the path-style addressing of the user not to apply to the endpoint of the repository")
+	}
+	if merged := pathStyle.Merge(Storage{Bucket: "repo-bucket"}); !merged.PathStyle {
+		t.Error("Expected the path-style addressing of the user to apply to its endpoint")
+	}
+
+	// The cloud storage is used if no type is set
+	if storage := (Storage{}).Merge(Storage{}); storage.Type != StorageCloud {
+		t.Errorf("Expected the cloud storage by default, got %q", storage.Type)
+	}
+}
+
+func TestMissingConfigFile(t *testing.T) {
+	if _, err := New(t.TempDir(), t.TempDir(), filepath.Join(t.TempDir(), "missing.yaml"), ""); err == nil {
+		t.Error("A missing configuration file given explicitly is accepted")
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spf13/viper"
 )
@@ -9,7 +10,7 @@ func GetStringConfigOrPrintErr(path string, err string) (string, error) {
 	res := viper.GetString(path)
 	if res == "" {
 		fmt.Println(err)
-		return "", fmt.Errorf(err)
+		return "", errors.New(err)
 	}
 	return res, nil
 }
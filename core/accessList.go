@@ -1,8 +1,33 @@
 package core
 
+import "time"
+
+// PassphraseRecipient is the recipient of the data keys shared with a passphrase,
+// for the people who have no key pair.
+const PassphraseRecipient = "passphrase"
+
+// RecoveryRecipient is the recipient of the root and top-level vault keys sealed with the recovery key
+// of the repository, so that access can be granted again if the owners lose their keys.
+const RecoveryRecipient = "recovery"
+
 type KeyAccess struct {
 	PublicKey string
 	Inherited bool
+	// Devices are the public keys of the devices of the user, if the user has several devices
+	Devices []string `json:",omitempty"`
+	// Group is the name of the group, if the key is shared with a group
+	Group string `json:",omitempty"`
+	// Alias and Name are the alias and the display name of the user in the user directory
+	Alias string `json:",omitempty"`
+	Name  string `json:",omitempty"`
+	// Fingerprint is the fingerprint of the public key of the user
+	Fingerprint string `json:",omitempty"`
+	// Passphrase is true if the key is shared with a passphrase instead of a public key
+	Passphrase bool `json:",omitempty"`
+	// Recovery is true if the key is sealed with the recovery key of the repository
+	Recovery bool `json:",omitempty"`
+	// Expires is the expiry of the share, if the share expires
+	Expires *time.Time `json:",omitempty"`
 }
 
 type KeyAccessList = []KeyAccess
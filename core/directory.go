@@ -0,0 +1,45 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DirectoryEntry is an entry of the user directory of the repository.
+// It maps the public key of a user to an alias, a display name and an email,
+// and is signed by the user who added the entry.
+type DirectoryEntry struct {
+	PublicKey string    `json:"publicKey"`
+	Alias     string    `json:"alias"`
+	Name      string    `json:"name,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	AddedBy   string    `json:"addedBy"`   // AddedBy is the public key of the user who added the entry
+	SignerKey string    `json:"signerKey"` // SignerKey is the signing public key of the user who added the entry
+	AddedAt   time.Time `json:"addedAt"`
+	Signature string    `json:"signature"`
+}
+
+// IsSelfSigned returns true if the entry was added by the user of the entry.
+func (e *DirectoryEntry) IsSelfSigned() bool {
+	return e.AddedBy == e.PublicKey
+}
+
+// SignedPayload returns the content of the entry covered by the signature: the entry without the signature.
+func (e *DirectoryEntry) SignedPayload() ([]byte, error) {
+	unsigned := *e
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+func (e *DirectoryEntry) Marshal() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
+
+func UnmarshalDirectoryEntry(data []byte) (DirectoryEntry, error) {
+	var entry DirectoryEntry
+	err := json.Unmarshal(data, &entry)
+	if err != nil {
+		return DirectoryEntry{}, err
+	}
+	return entry, nil
+}
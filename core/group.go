@@ -0,0 +1,93 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrUnauthorizedGroupWriter = errors.New("the group is not written by its creator or by a member of its previous version")
+)
+
+// groupIdV1Info is the info string used for deriving the id of a group from its creator.
+const groupIdV1Info = "cognitechbridge.com/v1/GroupId"
+
+// Group is a named group of users, used as a share recipient.
+// A group has its own key pair: the data keys shared with the group are sealed with the public key of the group,
+// and the private key of the group is sealed for every member.
+// The group is signed by the member who wrote it, and every version embeds the version it replaces, so that the
+// writer of every version can be checked against the members of the previous version, up to the creator.
+type Group struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+	// EncapsulationKey is the ML-KEM-768 encapsulation key of the group, encoded in base64, used to seal the data keys
+	// shared with the group with the hybrid scheme
+	EncapsulationKey string `json:"encapsulationKey,omitempty"`
+	// Members are the private key of the group sealed for every member, by user id
+	Members map[string]string `json:"members"`
+	// PreviousPublicKey and PreviousMembers are the key pair of the group being replaced, kept until every data key
+	// shared with the group is sealed with the new public key, so that an interrupted replacement can be resumed
+	PreviousPublicKey string            `json:"previousPublicKey,omitempty"`
+	PreviousMembers   map[string]string `json:"previousMembers,omitempty"`
+	// Creator is the id of the user who created the group, and Nonce the random value the id of the group
+	// is derived from with the creator, so that no other user can create a group with the same id
+	Creator string `json:"creator,omitempty"`
+	Nonce   string `json:"nonce,omitempty"`
+	// Previous is the version of the group replaced by this version, or nil for the version created by the creator
+	Previous  *Group     `json:"previous,omitempty"`
+	Signature *Signature `json:"signature,omitempty"` // Signature is the signature of the group by the member who wrote it
+}
+
+// NewGroupId returns a new id of a group created by the user, and the nonce it is derived from.
+func NewGroupId(creator string) (id string, nonce string, err error) {
+	nonce, err = NewUid()
+	if err != nil {
+		return "", "", err
+	}
+	return GroupId(creator, nonce), nonce, nil
+}
+
+// GroupId returns the id of the group created by the user, derived from the nonce.
+func GroupId(creator string, nonce string) string {
+	sum := sha256.Sum256([]byte(groupIdV1Info + "\x00" + creator + "\x00" + nonce))
+	id, _ := EncodeUid(sum[:])
+	return id
+}
+
+// IsCreatedBy returns true if the group is derived from the user as its creator.
+func (g *Group) IsCreatedBy(userId string) bool {
+	return g.Creator == userId && g.Id == GroupId(g.Creator, g.Nonce)
+}
+
+// HasMember returns true if the user is a member of the group.
+func (g *Group) HasMember(userId string) bool {
+	_, found := g.Members[userId]
+	return found
+}
+
+// IsKeyReplaced returns true if the key pair of the group is being replaced.
+func (g *Group) IsKeyReplaced() bool {
+	return g.PreviousPublicKey != ""
+}
+
+// SignedPayload returns the content of the group covered by the signature: the group without the signature.
+func (g *Group) SignedPayload() ([]byte, error) {
+	unsigned := *g
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+func (g *Group) Marshal() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+func UnmarshalGroup(data []byte) (Group, error) {
+	var group Group
+	err := json.Unmarshal(data, &group)
+	if err != nil {
+		return Group{}, err
+	}
+	return group, nil
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"encoding/json"
+)
+
+const (
+	IdentityV1 = "cognitechbridge.com/v1/Identity" // IdentityV1 is the version of the identity file format
+)
+
+// Identity is the private key of the user sealed with a passphrase, stored in the identity file.
+type Identity struct {
+	Version   string `json:"version"`
+	PublicKey string `json:"publicKey"`
+	SealedKey string `json:"sealedKey"`
+}
+
+func (i *Identity) Marshal() ([]byte, error) {
+	return json.MarshalIndent(i, "", "  ")
+}
+
+func UnmarshalIdentity(data []byte) (Identity, error) {
+	var identity Identity
+	err := json.Unmarshal(data, &identity)
+	if err != nil {
+		return Identity{}, err
+	}
+	return identity, nil
+}
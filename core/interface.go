@@ -1,8 +1,29 @@
 package core
 
-import "io"
+import (
+	"crypto/ed25519"
+	"io"
+)
 
 type CloudStorage interface {
 	Download(id string, writeAt io.WriterAt) error
 	Upload(reader io.Reader, fileId string) error
 }
+
+// CloudRemover is implemented by the cloud storages which can remove objects.
+// The objects and chunks which are no longer referenced are removed from them.
+type CloudRemover interface {
+	Remove(fileId string) error
+}
+
+// Decrypter performs the operations requiring the private key of the user.
+// It allows the private key to be held by another process, such as the key agent.
+// The signing key and the ML-KEM encapsulation key of the user are derived from the private key.
+type Decrypter interface {
+	PublicKey() (PublicKey, error)
+	OpenDataKey(serialized string) (*Key, error)
+	SigningPublicKey() (ed25519.PublicKey, error)
+	Sign(message []byte) ([]byte, error)
+	EncapsulationKey() ([]byte, error)
+	ProveIdentity(message []byte) ([]byte, error)
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Invitation is a one-time invitation of a user to the repository, stored in the repository.
+// The invitee answers the invitation with its public key, signed with the ephemeral key of the invitation file,
+// and the inviter accepts the answer by sharing the requested paths with the invitee.
+// The invitation is signed by the inviter, so that its paths and its key cannot be changed in the repository.
+type Invitation struct {
+	Id        string     `json:"id"`
+	InvitedBy string     `json:"invitedBy"` // InvitedBy is the public key of the user who created the invitation
+	Alias     string     `json:"alias,omitempty"`
+	Paths     []string   `json:"paths"`
+	Key       string     `json:"key"` // Key is the signing public key of the ephemeral key of the invitation file
+	CreatedAt time.Time  `json:"createdAt"`
+	Record    *Signature `json:"record,omitempty"` // Record is the signature of the invitation by the inviter
+
+	// PublicKey and Signature are the answer of the invitee
+	PublicKey string    `json:"publicKey,omitempty"`
+	JoinedAt  time.Time `json:"joinedAt,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+// IsAnswered returns true if the invitee has answered the invitation.
+func (i *Invitation) IsAnswered() bool {
+	return i.PublicKey != ""
+}
+
+// SignedPayload returns the content of the invitation covered by the signature of the inviter:
+// the invitation without the signature and the answer.
+func (i *Invitation) SignedPayload() ([]byte, error) {
+	return json.Marshal(Invitation{
+		Id:        i.Id,
+		InvitedBy: i.InvitedBy,
+		Alias:     i.Alias,
+		Paths:     i.Paths,
+		Key:       i.Key,
+		CreatedAt: i.CreatedAt,
+	})
+}
+
+// AnswerPayload returns the content of the answer covered by the signature.
+func (i *Invitation) AnswerPayload() []byte {
+	return []byte(i.Id + "\x00" + i.PublicKey)
+}
+
+func (i *Invitation) Marshal() ([]byte, error) {
+	return json.MarshalIndent(i, "", "  ")
+}
+
+func UnmarshalInvitation(data []byte) (Invitation, error) {
+	var invitation Invitation
+	err := json.Unmarshal(data, &invitation)
+	if err != nil {
+		return Invitation{}, err
+	}
+	return invitation, nil
+}
+
+// InvitationFile is the file given to the invitee, with the ephemeral private key of the invitation.
+type InvitationFile struct {
+	Id  string `json:"id"`
+	Key string `json:"key"`
+}
+
+func (f *InvitationFile) Marshal() ([]byte, error) {
+	return json.MarshalIndent(f, "", "  ")
+}
+
+func UnmarshalInvitationFile(data []byte) (InvitationFile, error) {
+	var file InvitationFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return InvitationFile{}, err
+	}
+	return file, nil
+}
@@ -22,12 +22,18 @@ func EmptyPublicKey() PublicKey {
 }
 
 // NewPublicKeyFromEncoded creates a PublicKey from an encoded base58 string.
+// The encoded key is 43 or 44 characters long, depending on the key.
+// An ssh-ed25519 public key in the authorized_keys format is also accepted, see NewPublicKeyFromSSH.
 func NewPublicKeyFromEncoded(encoded string) (PublicKey, error) {
-	if len(encoded) != 44 {
+	if IsSSHPublicKey(encoded) {
+		return NewPublicKeyFromSSH(encoded)
+	}
+	decoded := base58.Decode(encoded)
+	if len(decoded) != curve25519.PointSize {
 		return EmptyPublicKey(), ErrInvalidPublicKey
 	}
 	return PublicKey{
-		value: base58.Decode(encoded),
+		value: decoded,
 	}, nil
 }
 
@@ -73,12 +79,14 @@ func EmptyPrivateKey() PrivateKey {
 }
 
 // NewPrivateKeyFromEncoded creates a PrivateKey from an encoded base58 string.
+// The encoded key is 43 or 44 characters long, depending on the key.
 func NewPrivateKeyFromEncoded(encoded string) (PrivateKey, error) {
-	if len(encoded) != 44 {
+	decoded := base58.Decode(encoded)
+	if len(decoded) != curve25519.ScalarSize {
 		return EmptyPrivateKey(), ErrInvalidPublicKey
 	}
 	return PrivateKey{
-		value: base58.Decode(encoded),
+		value: decoded,
 	}, nil
 }
 
@@ -106,6 +114,11 @@ func (key PrivateKey) Bytes() []byte {
 	return key.value
 }
 
+// Wipe erases the bytes of the PrivateKey, once it is not used anymore.
+func (key PrivateKey) Wipe() {
+	clear(key.value)
+}
+
 // Unsafe returns an UnsafePrivateKey for unsafe operations.
 func (key PrivateKey) Unsafe() UnsafePrivateKey {
 	return UnsafePrivateKey{key}
@@ -1,6 +1,8 @@
 package core
 
 type Link struct {
-	ObjectId string `json:"objectId"`
-	Size     int64  `json:"size"`
+	ObjectId  string `json:"objectId"`
+	Size      int64  `json:"size"`
+	Metadata  string `json:"metadata,omitempty"`  // Metadata is the sealed FileMetadata of the file
+	Reencrypt bool   `json:"reencrypt,omitempty"` // Reencrypt is set when the whole file must be encrypted again on the next write
 }
@@ -0,0 +1,25 @@
+package core
+
+import "time"
+
+// FileMetadata represents the metadata of a file (times, mode bits, flags and extended attributes).
+// It is stored encrypted in the file link.
+type FileMetadata struct {
+	ModTime    time.Time         `json:"mtime"`
+	ChangeTime time.Time         `json:"ctime"`
+	BirthTime  time.Time         `json:"birthtime"`
+	Mode       uint32            `json:"mode"`
+	Flags      uint32            `json:"flags,omitempty"`
+	Xattrs     map[string][]byte `json:"xattrs,omitempty"`
+}
+
+// NewFileMetadata returns a new FileMetadata with the given mode bits and all the times set to now.
+func NewFileMetadata(mode uint32) FileMetadata {
+	now := time.Now()
+	return FileMetadata{
+		ModTime:    now,
+		ChangeTime: now,
+		BirthTime:  now,
+		Mode:       mode,
+	}
+}
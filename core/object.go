@@ -0,0 +1,6 @@
+package core
+
+// ObjectChunkSize is the size of the chunks of large objects.
+// Objects larger than a chunk are stored as a manifest of independently encrypted chunks,
+// so that only the modified chunks are encrypted and uploaded again.
+const ObjectChunkSize = 4 * 1024 * 1024
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"encoding/json"
+)
+
+// RepositoryPolicy is the policy of the repository set by its owners, the users with access to the root directory.
+// The policy is signed by the owner who wrote it, and bound to the root vault key by a proof sealed with it,
+// so that a user who can only write to the repository cannot replace it.
+type RepositoryPolicy struct {
+	// RecoveryPublicKey is the recovery public key of the repository, if any
+	RecoveryPublicKey string `json:"recoveryPublicKey,omitempty"`
+	// RootKeyId is the id of the root vault key the proof is sealed with
+	RootKeyId string `json:"rootKeyId"`
+	// Proof is the content of the policy sealed with the root vault key
+	Proof     string     `json:"proof"`
+	Signature *Signature `json:"signature,omitempty"` // Signature is the signature of the policy by the owner who wrote it
+}
+
+// ProvedPayload returns the content of the policy covered by the proof: the policy without the proof and the signature.
+func (p *RepositoryPolicy) ProvedPayload() ([]byte, error) {
+	unproved := *p
+	unproved.Proof = ""
+	unproved.Signature = nil
+	return json.Marshal(unproved)
+}
+
+// SignedPayload returns the content of the policy covered by the signature: the policy without the signature.
+func (p *RepositoryPolicy) SignedPayload() ([]byte, error) {
+	unsigned := *p
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+func (p *RepositoryPolicy) Marshal() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+func UnmarshalRepositoryPolicy(data []byte) (RepositoryPolicy, error) {
+	var policy RepositoryPolicy
+	err := json.Unmarshal(data, &policy)
+	if err != nil {
+		return RepositoryPolicy{}, err
+	}
+	return policy, nil
+}
@@ -1,9 +1,14 @@
 package core
 
-import "io/fs"
+import (
+	"io/fs"
+	"time"
+)
 
 type ObjectService interface {
 	Read(id string, dir string, buff []byte, ofst int64, key *KeyInfo) (int, error)
+	ReadAt(id string, dir string, buff []byte, ofst int64, key *KeyInfo) (int, error)
+	LoadToCache(id string, dir string, key *KeyInfo) error
 	Write(id string, buff []byte, ofst int64) (int, error)
 	Create(id string) error
 	Move(oldId string, newId string) error
@@ -26,14 +31,27 @@ type FileSystemService interface {
 	OpenInWrite(path string) error
 	GetUserFileAccess(path string, isDir bool) fs.FileMode
 	GetDiskUsage() (totalBytes, freeBytes uint64, err error)
+	GetMetadata(path string) (*FileMetadata, error)
+	UpdateMetadata(path string, update func(metadata *FileMetadata)) error
 }
 
 type KeyService interface {
 	SetPrivateKey(privateKey PrivateKey)
+	SetDecrypter(decrypter Decrypter)
+	SetPassphrase(passphrase []byte)
 	Get(keyID string, startVaultId string, startVaultPath string) (*KeyInfo, error)
+	GetVaultKeyByPath(path string) (*KeyInfo, error)
 	Insert(key *KeyInfo, path string) error
-	Share(keyId string, startVaultId string, startVaultPath string, recipient PublicKey, recipientUserId string) error
+	Share(keyId string, startVaultId string, startVaultPath string, recipient PublicKey, recipientUserId string, expiresAt time.Time) error
+	ShareWithPassphrase(keyId string, startVaultId string, startVaultPath string, passphrase []byte, expiresAt time.Time) error
+	ExpireShares(keyId string, startVaultId string, startVaultPath string, now time.Time) ([]ShareExpiry, error)
 	GetPublicKey() (PublicKey, error)
+	GetSigningPublicKey() (string, error)
+	Sign(info string, payload []byte) (string, error)
+	RecordSigner
+	SetAcceptUnsignedRecords(accept bool)
+	SetRequireHybridKeyWrapping(require bool)
+	PublishIdentity() error
 	GetPublicKeyByPrivateKey(PrivateKey PrivateKey) (PublicKey, error)
 	CreateVault(parentId string, path string) (*Vault, error)
 	GenerateKeyInVault(vaultId string, vaultPath string) (*KeyInfo, error)
@@ -42,7 +60,25 @@ type KeyService interface {
 	MoveKey(keyId string, oldVaultId string, oldVaultPath string, newVaultId string, newVaultPath string) error
 	GenerateUserKey() (*PrivateKey, error)
 	IsUserJoined() bool
+	Join() error
+	JoinByUserId(userId string) error
 	GetHasAccessToKey(keyId string, startVaultId string, startVaultPath string, userId string) (bool, bool)
 	GetKeyAccessList(keyId string, startVaultId string, startVaultPath string) (KeyAccessList, error)
 	Unshare(keyId string, recipientUserId string, path string) error
+	RotateVaultKey(vaultPath string) (oldKey *KeyInfo, newKey *KeyInfo, err error)
+	CompleteVaultKeyRotation(vaultPath string) (bool, error)
+	SetRecoveryKey(publicKey *PublicKey)
+	SaveRecoveryKey(publicKey PublicKey) error
+	GetRecoveryKey() (*PublicKey, error)
+	ShareVaultWithRecoveryKey(vaultPath string) error
+	Recover(owner PublicKey) (int, error)
+	GetUser() (User, error)
+	AddDevice(publicKey PublicKey, name string) error
+	RemoveDevice(publicKey PublicKey) error
+	AcceptDevice(userId string) error
+	CreateGroup(name string) (*Group, error)
+	GetGroupByName(name string) (Group, error)
+	ListGroups() ([]Group, error)
+	AddGroupMember(name string, publicKey PublicKey) error
+	RemoveGroupMember(name string, publicKey PublicKey) error
 }
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ShareExpiry is the expiry of the share of a data key with a recipient, stored next to the shared data key.
+// It is signed by the user who shared the data key.
+type ShareExpiry struct {
+	KeyId     string    `json:"keyId"`
+	Recipient string    `json:"recipient"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	SharedBy  string    `json:"sharedBy"`  // SharedBy is the public key of the user who shared the data key
+	SignerKey string    `json:"signerKey"` // SignerKey is the signing public key of the user who shared the data key
+	Signature string    `json:"signature"`
+}
+
+// IsExpired returns true if the share is expired at the given time.
+func (e *ShareExpiry) IsExpired(now time.Time) bool {
+	return !now.Before(e.ExpiresAt)
+}
+
+// SignedPayload returns the content of the expiry covered by the signature: the expiry without the signature.
+func (e *ShareExpiry) SignedPayload() ([]byte, error) {
+	unsigned := *e
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+func (e *ShareExpiry) Marshal() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
+
+func UnmarshalShareExpiry(data []byte) (ShareExpiry, error) {
+	var expiry ShareExpiry
+	err := json.Unmarshal(data, &expiry)
+	if err != nil {
+		return ShareExpiry{}, err
+	}
+	return expiry, nil
+}
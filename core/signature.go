@@ -0,0 +1,79 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrInvalidSignature = errors.New("the signature of the record is not valid")
+	ErrUnsignedRecord   = errors.New("the record is not signed; set allow_unsigned_records in your user configuration (~/.ctb/config.yaml) to accept the records written before the records were signed")
+	ErrUnknownSigner    = errors.New("the signer of the record has no signing identity bound to its public key")
+)
+
+// Signature statuses of the signed records
+const (
+	SignatureVerified      = "verified"       // the record is signed by a user whose signing key is known
+	SignatureUnknownSigner = "unknown signer" // the signature is valid, but the signing key of the signer is unknown
+	SignatureInvalid       = "invalid"        // the signature is not valid
+	SignatureUnsigned      = "unsigned"       // the record is not signed, it was written before the records were signed
+)
+
+// Signature is the signature of a record by the user who wrote it.
+type Signature struct {
+	Writer    string `json:"writer"`    // Writer is the public key of the user who wrote the record
+	SignerKey string `json:"signerKey"` // SignerKey is the signing public key of the writer
+	Value     string `json:"value"`
+}
+
+// RecordSigner signs the records written to the repository with the signing key of the user,
+// and verifies the signatures of the records read from the repository.
+// VerifyRecord returns the status of the signature, and CheckRecord returns an error if the record must be refused.
+type RecordSigner interface {
+	SignRecord(info string, payload []byte) (*Signature, error)
+	VerifyRecord(signature *Signature, info string, payload []byte) string
+	CheckRecord(signature *Signature, info string, payload []byte) error
+}
+
+// SignatureStatusError returns the error of a record with the signature status: nil if the record is verified,
+// or unsigned and the unsigned records are accepted, and ErrUnsignedRecord, ErrUnknownSigner or
+// ErrInvalidSignature otherwise.
+func SignatureStatusError(status string, acceptUnsigned bool) error {
+	switch status {
+	case SignatureVerified:
+		return nil
+	case SignatureUnsigned:
+		if acceptUnsigned {
+			return nil
+		}
+		return ErrUnsignedRecord
+	case SignatureUnknownSigner:
+		return ErrUnknownSigner
+	default:
+		return ErrInvalidSignature
+	}
+}
+
+// SigningIdentity is the signing public key of a user, published in the repository
+// so that the signatures of the user can be attributed to the user.
+// It also holds the ML-KEM-768 encapsulation key of the user, used to seal the data keys shared with the user
+// with the hybrid post-quantum scheme.
+type SigningIdentity struct {
+	PublicKey        string `json:"publicKey"`
+	SigningKey       string `json:"signingKey"`
+	EncapsulationKey string `json:"encapsulationKey,omitempty"` // EncapsulationKey is the encoded ML-KEM-768 encapsulation key
+	Proof            string `json:"proof,omitempty"`            // Proof binds the signing and encapsulation keys to the public key
+}
+
+func (i *SigningIdentity) Marshal() ([]byte, error) {
+	return json.MarshalIndent(i, "", "  ")
+}
+
+func UnmarshalSigningIdentity(data []byte) (SigningIdentity, error) {
+	var identity SigningIdentity
+	err := json.Unmarshal(data, &identity)
+	if err != nil {
+		return SigningIdentity{}, err
+	}
+	return identity, nil
+}
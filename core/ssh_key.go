@@ -0,0 +1,80 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"strings"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrUnsupportedSSHKey     = errors.New("unsupported SSH key: only ssh-ed25519 keys are supported")
+	ErrSSHPassphraseRequired = errors.New("the SSH private key is protected by a passphrase")
+)
+
+// IsSSHPublicKey returns true if the encoded public key is an SSH public key in the authorized_keys format.
+func IsSSHPublicKey(encoded string) bool {
+	return strings.HasPrefix(encoded, "ssh-")
+}
+
+// NewPublicKeyFromSSH creates a PublicKey from an ssh-ed25519 public key in the authorized_keys format.
+// The Ed25519 key is converted to the equivalent X25519 key, like age does, so that the owner of the
+// SSH private key can open the files shared with it.
+func NewPublicKeyFromSSH(authorizedKey string) (PublicKey, error) {
+	sshKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return EmptyPublicKey(), ErrInvalidPublicKey
+	}
+	if sshKey.Type() != ssh.KeyAlgoED25519 {
+		return EmptyPublicKey(), ErrUnsupportedSSHKey
+	}
+	cryptoKey, ok := sshKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return EmptyPublicKey(), ErrUnsupportedSSHKey
+	}
+	edKey, ok := cryptoKey.CryptoPublicKey().(ed25519.PublicKey)
+	if !ok {
+		return EmptyPublicKey(), ErrUnsupportedSSHKey
+	}
+	point, err := new(edwards25519.Point).SetBytes(edKey)
+	if err != nil {
+		return EmptyPublicKey(), ErrInvalidPublicKey
+	}
+	return NewPublicKeyFromBytes(point.BytesMontgomery()), nil
+}
+
+// NewPrivateKeyFromSSH creates a PrivateKey from an OpenSSH ed25519 private key file.
+// The Ed25519 key is converted to the X25519 key matching NewPublicKeyFromSSH.
+// If the file is protected and passphrase is nil, it returns ErrSSHPassphraseRequired.
+func NewPrivateKeyFromSSH(pemBytes []byte, passphrase []byte) (PrivateKey, error) {
+	var raw interface{}
+	var err error
+	if passphrase == nil {
+		raw, err = ssh.ParseRawPrivateKey(pemBytes)
+	} else {
+		raw, err = ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, passphrase)
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return EmptyPrivateKey(), ErrSSHPassphraseRequired
+	}
+	if err != nil {
+		return EmptyPrivateKey(), err
+	}
+	var edKey ed25519.PrivateKey
+	switch key := raw.(type) {
+	case *ed25519.PrivateKey:
+		edKey = *key
+	case ed25519.PrivateKey:
+		edKey = key
+	default:
+		return EmptyPrivateKey(), ErrUnsupportedSSHKey
+	}
+	// The X25519 scalar is the first half of the hash of the seed, as for the Ed25519 signing scalar
+	hash := sha512.Sum512(edKey.Seed())
+	return NewPrivateKeyFromBytes(hash[:curve25519.ScalarSize]), nil
+}
@@ -0,0 +1,106 @@
+package core_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"ctb-cli/core"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newSSHKey returns a new Ed25519 key in the authorized_keys format and as an OpenSSH private key file.
+func newSSHKey(t *testing.T, passphrase []byte) (string, []byte) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == nil {
+		block, err = ssh.MarshalPrivateKey(privateKey, "user@host")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, "user@host", passphrase)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey))) + " user@host"
+	return authorizedKey, pem.EncodeToMemory(block)
+}
+
+func TestSSHKeyConversion(t *testing.T) {
+	authorizedKey, pemBytes := newSSHKey(t, nil)
+
+	publicKey, err := core.NewPublicKeyFromEncoded(authorizedKey)
+	if err != nil {
+		t.Fatalf("SSH public key rejected: %v", err)
+	}
+	privateKey, err := core.NewPrivateKeyFromSSH(pemBytes, nil)
+	if err != nil {
+		t.Fatalf("SSH private key rejected: %v", err)
+	}
+	// The converted private key matches the converted public key
+	derived, err := privateKey.ToPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !derived.Equals(publicKey) {
+		t.Error("The converted private key does not match the converted public key")
+	}
+	// The converted public key is used like any other public key
+	decoded, err := core.NewPublicKeyFromEncoded(publicKey.String())
+	if err != nil || !decoded.Equals(publicKey) {
+		t.Errorf("The converted public key cannot be decoded again: %v", err)
+	}
+}
+
+func TestSSHKeyPassphrase(t *testing.T) {
+	authorizedKey, pemBytes := newSSHKey(t, []byte("secret"))
+
+	if _, err := core.NewPrivateKeyFromSSH(pemBytes, nil); err != core.ErrSSHPassphraseRequired {
+		t.Errorf("Expected ErrSSHPassphraseRequired, got %v", err)
+	}
+	if _, err := core.NewPrivateKeyFromSSH(pemBytes, []byte("wrong")); err == nil {
+		t.Error("Wrong passphrase accepted")
+	}
+	privateKey, err := core.NewPrivateKeyFromSSH(pemBytes, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := core.NewPublicKeyFromSSH(authorizedKey)
+	derived, _ := privateKey.ToPublicKey()
+	if !derived.Equals(publicKey) {
+		t.Error("The converted private key does not match the converted public key")
+	}
+}
+
+func TestSSHKeyUnsupported(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := core.NewPublicKeyFromEncoded(string(ssh.MarshalAuthorizedKey(sshPublicKey))); err != core.ErrUnsupportedSSHKey {
+		t.Errorf("Expected ErrUnsupportedSSHKey for an RSA public key, got %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(rsaKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := core.NewPrivateKeyFromSSH(pem.EncodeToMemory(block), nil); err != core.ErrUnsupportedSSHKey {
+		t.Errorf("Expected ErrUnsupportedSSHKey for an RSA private key, got %v", err)
+	}
+	if _, err := core.NewPublicKeyFromEncoded("ssh-ed25519 invalid"); err != core.ErrInvalidPublicKey {
+		t.Errorf("Expected ErrInvalidPublicKey for an invalid SSH key, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// User is the identity of a user having several devices, each with its own private key.
+// The data keys shared with the user are sealed with the public key of every device.
+// The id of the user is the public key of its first device, so that the data keys shared
+// with a user having a single device are the data keys shared with the user.
+// The user record is signed by a device of the user, and every other device is endorsed by a device of the user.
+type User struct {
+	Id        string     `json:"id"`
+	Devices   []Device   `json:"devices"`
+	Signature *Signature `json:"signature,omitempty"` // Signature is the signature of the user record by the device which wrote it
+}
+
+// Device is a device of a user, identified by its public key.
+type Device struct {
+	PublicKey   string     `json:"publicKey"`
+	Name        string     `json:"name,omitempty"`
+	Endorsement *Signature `json:"endorsement,omitempty"` // Endorsement is the signature of the device by the device of the user which added it
+	Consent     *Signature `json:"consent,omitempty"`     // Consent is the signature of the device by itself, once it accepts to be a device of the user
+}
+
+// NewUser creates a user with a single device, whose public key is the id of the user.
+func NewUser(userId string, deviceName string) User {
+	return User{
+		Id:      userId,
+		Devices: []Device{{PublicKey: userId, Name: deviceName}},
+	}
+}
+
+// HasDevice returns true if the public key is the public key of a device of the user.
+func (u *User) HasDevice(publicKey string) bool {
+	for _, device := range u.Devices {
+		if device.PublicKey == publicKey {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDevice returns the device of the user with the public key.
+func (u *User) GetDevice(publicKey string) (Device, bool) {
+	for _, device := range u.Devices {
+		if device.PublicKey == publicKey {
+			return device, true
+		}
+	}
+	return Device{}, false
+}
+
+// DevicePayload returns the payload of the endorsement and of the consent of the device with the public key.
+func (u *User) DevicePayload(publicKey string) []byte {
+	return []byte(u.Id + "\x00" + publicKey)
+}
+
+// SignedPayload returns the content of the user record covered by the signature: the user without the signature.
+func (u *User) SignedPayload() ([]byte, error) {
+	unsigned := *u
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+// RemoveDevice removes the device with the public key from the user.
+func (u *User) RemoveDevice(publicKey string) {
+	devices := make([]Device, 0, len(u.Devices))
+	for _, device := range u.Devices {
+		if device.PublicKey != publicKey {
+			devices = append(devices, device)
+		}
+	}
+	u.Devices = devices
+}
+
+// DevicePublicKeys returns the public keys of the devices of the user.
+func (u *User) DevicePublicKeys() []string {
+	keys := make([]string, 0, len(u.Devices))
+	for _, device := range u.Devices {
+		keys = append(keys, device.PublicKey)
+	}
+	return keys
+}
+
+func (u *User) Marshal() ([]byte, error) {
+	return json.MarshalIndent(u, "", "  ")
+}
+
+func UnmarshalUser(data []byte) (User, error) {
+	var user User
+	err := json.Unmarshal(data, &user)
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// DeviceKeyShares is a data key shared with a user having several devices:
+// the data key sealed with the public key of every device, by device public key.
+type DeviceKeyShares map[string]string
+
+// IsDeviceKeyShares returns true if the serialized data key share is a DeviceKeyShares,
+// and not a data key sealed with a single public key.
+func IsDeviceKeyShares(serialized string) bool {
+	return strings.HasPrefix(serialized, "{")
+}
+
+func (s DeviceKeyShares) Marshal() (string, error) {
+	serialized, err := json.Marshal(s)
+	return string(serialized), err
+}
+
+func UnmarshalDeviceKeyShares(serialized string) (DeviceKeyShares, error) {
+	var shares DeviceKeyShares
+	err := json.Unmarshal([]byte(serialized), &shares)
+	if err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
@@ -12,12 +12,28 @@ var (
 type Vault struct {
 	Id    string `json:"id"`
 	KeyId string `json:"keyId"`
+	// PreviousKeyId is the id of the vault key being replaced, kept until the vault and its content are sealed
+	// with the new vault key, so that an interrupted rotation can be resumed
+	PreviousKeyId string     `json:"previousKeyId,omitempty"`
+	Signature     *Signature `json:"signature,omitempty"` // Signature is the signature of the vault by the user who wrote it
+}
+
+// IsKeyRotated returns true if the vault key is being rotated.
+func (v *Vault) IsKeyRotated() bool {
+	return v.PreviousKeyId != ""
 }
 
 func (v *Vault) Marshal() ([]byte, error) {
 	return json.Marshal(v)
 }
 
+// SignedPayload returns the content of the vault covered by the signature: the vault without the signature.
+func (v *Vault) SignedPayload() ([]byte, error) {
+	unsigned := *v
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
 func UnmarshalVault(data []byte) (Vault, error) {
 	var vault Vault
 	err := json.Unmarshal(data, &vault)
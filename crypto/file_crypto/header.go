@@ -1,18 +1,93 @@
 package file_crypto
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	ContentTypeData     = ""         // ContentTypeData is the content type of files holding the data itself.
+	ContentTypeManifest = "manifest" // ContentTypeManifest is the content type of files holding the manifest of a chunked object.
+	ContentTypeChunk    = "chunk"    // ContentTypeChunk is the content type of files holding a chunk of a chunked object.
+)
+
+const (
+	FileHeaderV2Info = "cognitechbridge.com/v2/FileHeader" // FileHeaderV2Info is the info string used for deriving the header MAC key from the file key.
+
+	headerMACSize = sha256.Size // headerMACSize is the size of the MAC of the header in the version 2.
+)
+
+var (
+	ErrContentMismatch      = errors.New("the content of the file does not match the signed header")
+	ErrHeaderAuthentication = errors.New("failed to authenticate the header of the file")
 )
 
 // Header represents the header of an encryption file
 type Header struct {
-	Version string `json:"version"`
-	Alg     string `json:"alg"`
-	FileID  string `json:"file_id"`
-	KeyId   string `json:"key_id"`
+	Version     string `json:"version"`
+	Alg         string `json:"alg"`
+	FileID      string `json:"file_id"`
+	KeyId       string `json:"key_id"`
+	ContentType string `json:"content_type,omitempty"`
+	// ContentHash is the SHA-256 hash of the decrypted content, covered by the signature of the header
+	ContentHash []byte `json:"content_hash,omitempty"`
+	// Signature is the signature of the header by the writer of the file
+	Signature *core.Signature `json:"signature,omitempty"`
+}
+
+// SignedPayload returns the content of the header covered by the signature: the header without the signature.
+func (h *Header) SignedPayload() ([]byte, error) {
+	unsigned := *h
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+// Sign sets the hash of the content and signs the header with the signer.
+func (h *Header) Sign(contentHash []byte, signer core.RecordSigner) error {
+	h.ContentHash = contentHash
+	h.Signature = nil
+	payload, err := h.SignedPayload()
+	if err != nil {
+		return err
+	}
+	h.Signature, err = signer.SignRecord(sign_crypto.ObjectV1Info, payload)
+	return err
+}
+
+// Verify returns the status of the signature of the header, as returned by the verifier.
+func (h *Header) Verify(verifier core.RecordSigner) string {
+	payload, err := h.SignedPayload()
+	if err != nil {
+		return core.SignatureInvalid
+	}
+	return verifier.VerifyRecord(h.Signature, sign_crypto.ObjectV1Info, payload)
+}
+
+// Check returns the error of the verifier if the header must be refused, such as core.ErrInvalidSignature.
+func (h *Header) Check(verifier core.RecordSigner) error {
+	payload, err := h.SignedPayload()
+	if err != nil {
+		return core.ErrInvalidSignature
+	}
+	return verifier.CheckRecord(h.Signature, sign_crypto.ObjectV1Info, payload)
+}
+
+// VerifyContent checks the hash of the decrypted content against the hash of the header.
+// It returns ErrContentMismatch if the hashes differ. The content of a header without hash is not checked.
+func (h *Header) VerifyContent(contentHash []byte) error {
+	if h.ContentHash != nil && !bytes.Equal(h.ContentHash, contentHash) {
+		return ErrContentMismatch
+	}
+	return nil
 }
 
 // Marshal header
@@ -43,17 +118,61 @@ func ParseHeader(reader io.Reader) (*Header, error) {
 	if err != nil {
 		return nil, err
 	}
+	return unmarshalHeader(headerContext)
+}
 
-	// Deserialize file header
+// unmarshalHeader deserializes the header read from the file.
+func unmarshalHeader(headerContext []byte) (*Header, error) {
 	var fileHeader Header
-	err = json.Unmarshal(headerContext, &fileHeader)
+	err := json.Unmarshal(headerContext, &fileHeader)
 	if err != nil {
 		return nil, err
 	}
-
 	return &fileHeader, nil
 }
 
+// rawHeader is the header as read from the file, with the file version and the MAC of the header.
+type rawHeader struct {
+	version byte
+	context []byte // the serialized header, without its length
+	mac     []byte // the MAC of the header, only in the version 2
+}
+
+// authenticate checks the MAC of the header with the file key and returns the associated data of the stream.
+// The header of the version 1 is not authenticated, and its stream has no associated data.
+// It returns ErrHeaderAuthentication if the header or its MAC is modified.
+func (r rawHeader) authenticate(key []byte) ([]byte, error) {
+	if r.version == fileVersionV1 {
+		return nil, nil
+	}
+	headerBytes, err := formatContext(r.context)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := headerMAC(key, r.version, headerBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, r.mac) {
+		return nil, ErrHeaderAuthentication
+	}
+	return r.mac, nil
+}
+
+// headerMAC computes the MAC of the file version and the serialized header (with its length),
+// with a key derived from the file key.
+func headerMAC(key []byte, version byte, headerBytes []byte) ([]byte, error) {
+	macKey := make([]byte, sha256.Size)
+	_, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(FileHeaderV2Info)), macKey)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write([]byte{version})
+	mac.Write(headerBytes)
+	return mac.Sum(nil), nil
+}
+
 // readContext reads the context from the given reader and returns it as a byte slice.
 // It first reads the context size, then reads the context itself.
 // If any error occurs during reading, it returns nil and the error.
@@ -12,31 +12,63 @@ type writer struct {
 	header       Header         // The header of the file.
 	notFirst     bool           // Indicates whether it is not the first write operation.
 	dst          io.Writer      // The destination writer to write the encrypted data to.
-	streamWriter *stream.Writer // The stream writer used for encryption.
+	key          []byte         // The file key.
+	streamWriter *stream.Writer // The stream writer used for encryption, created with the header.
 }
 
+const (
+	fileVersionV1 = 1 // the header is not authenticated
+	fileVersionV2 = 2 // the header is authenticated by a MAC, bound into the stream as associated data
+)
+
 var (
-	fileVersion = []byte{1} //Current encryption file version
+	fileVersion = []byte{fileVersionV2} //Current encryption file version
+
+	ErrHeaderWritten          = errors.New("the header is already written")
+	ErrUnsupportedFileVersion = errors.New("unsupported file version")
 )
 
 // NewWriter creates a new writer object that encrypts data and writes it to the specified destination writer.
 // It takes the destination writer, key information, and file ID as parameters.
 // The function returns a pointer to the writer object and an error if any occurred during the creation process.
 func NewWriter(dst io.Writer, keyInfo *core.KeyInfo, fileId string) (*writer, error) {
-	// Create a new stream writer with the key and the destination writer.
-	streamWriter, err := stream.NewWriter(keyInfo.Key.Bytes(), dst)
-	if err != nil {
+	return NewWriterWithContentType(dst, keyInfo, fileId, ContentTypeData)
+}
+
+// NewWriterWithContentType creates a new writer like NewWriter and sets the content type in the header.
+func NewWriterWithContentType(dst io.Writer, keyInfo *core.KeyInfo, fileId string, contentType string) (*writer, error) {
+	return NewWriterWithAlgorithm(dst, keyInfo, fileId, contentType, stream.ChaCha20Poly1305)
+}
+
+// NewWriterWithAlgorithm creates a new writer like NewWriterWithContentType, which encrypts with the AEAD algorithm
+// (stream.ChaCha20Poly1305 or stream.AES256GCM) set in the header.
+// The stream writer is created when the header is written, since the header is bound into the stream.
+func NewWriterWithAlgorithm(dst io.Writer, keyInfo *core.KeyInfo, fileId string, contentType string, alg string) (*writer, error) {
+	// Check the algorithm and the key
+	if _, err := stream.NewAEAD(alg, keyInfo.Key.Bytes()); err != nil {
 		return nil, err
 	}
-	// Create a new writer object with the destination writer, header, and stream writer.
+	// Create a new writer object with the destination writer, header, and key.
+	header := newHeader(fileId, keyInfo.Id)
+	header.Alg = alg
+	header.ContentType = contentType
 	return &writer{
-		dst:          dst,
-		header:       newHeader(fileId, keyInfo.Id),
-		notFirst:     false,
-		streamWriter: streamWriter,
+		dst:      dst,
+		header:   header,
+		notFirst: false,
+		key:      keyInfo.Key.Bytes(),
 	}, nil
 }
 
+// Sign signs the header with the signer, with the SHA-256 hash of the content to be written.
+// It must be called before the first write.
+func (e *writer) Sign(contentHash []byte, signer core.RecordSigner) error {
+	if e.notFirst {
+		return ErrHeaderWritten
+	}
+	return e.header.Sign(contentHash, signer)
+}
+
 // Write writes the given byte slice to the underlying stream.
 // It first checks if it's the first write operation, and if so, it writes the file version and header.
 // Returns the number of bytes written and any error encountered.
@@ -51,7 +83,8 @@ func (e *writer) Write(buf []byte) (int, error) {
 	return e.streamWriter.Write(buf)
 }
 
-// writeFileVersionAndHeader writes the file version and header to the destination writer.
+// writeFileVersionAndHeader writes the file version, the header and the MAC of the header to the destination writer,
+// and creates the stream writer with the MAC of the header as associated data.
 // It returns an error if there was a problem writing the version or header.
 func (e *writer) writeFileVersionAndHeader() (err error) {
 	// write file version.
@@ -65,7 +98,24 @@ func (e *writer) writeFileVersionAndHeader() (err error) {
 		return err
 	}
 	_, err = e.dst.Write(headerBytes)
-	return err
+	if err != nil {
+		return err
+	}
+	// Write the MAC of the version and header, and bind it into the stream
+	mac, err := headerMAC(e.key, fileVersion[0], headerBytes)
+	if err != nil {
+		return err
+	}
+	_, err = e.dst.Write(mac)
+	if err != nil {
+		return err
+	}
+	aead, err := stream.NewAEAD(e.header.Alg, e.key)
+	if err != nil {
+		return err
+	}
+	e.streamWriter = stream.NewWriterWithAEAD(aead, mac, e.dst)
+	return nil
 }
 
 // Close closes the writer and finalizes the encryption.
@@ -86,11 +136,11 @@ func (e *writer) Close() error {
 }
 
 // newHeader creates a new Header struct with the specified fileId and keyId.
-// It sets the default algorithm by calling the getAlgorithmName function.
+// It sets the default algorithm, ChaCha20-Poly1305.
 func newHeader(fileId string, keyId string) Header {
 	return Header{
-		Version: "V1",
-		Alg:     "AEAD_ChaCha20_Poly1305", // Set default algorithm
+		Version: "V2",
+		Alg:     stream.ChaCha20Poly1305, // Set default algorithm
 		FileID:  fileId,
 		KeyId:   keyId,
 	}
@@ -99,55 +149,109 @@ func newHeader(fileId string, keyId string) Header {
 // EncryptedStream represents an encrypted stream of data.
 type EncryptedStream struct {
 	source io.Reader
+	alg    string // the AEAD algorithm of the stream
+	header rawHeader
 }
 
 // Parse reads the encrypted data from the provided source and returns the parsed header,
 // an encrypted stream, and any error encountered during the process.
+// The header is not authenticated until the stream is decrypted.
 func Parse(source io.Reader) (*Header, *EncryptedStream, error) {
-	header, err := readFileVersionAndHeader(source)
+	header, raw, err := readFileVersionAndHeader(source)
 	if err != nil {
 		return nil, nil, err
 	}
-	return header, &EncryptedStream{source: source}, nil
+	return header, &EncryptedStream{source: source, alg: header.Alg, header: raw}, nil
 }
 
-// Decrypt decrypts the encrypted stream using the provided key.
+// Decrypt authenticates the header and decrypts the encrypted stream using the provided key,
+// with the algorithm of the header.
 // It returns an io.Reader that can be used to read the decrypted data.
 // If an error occurs during decryption, it is returned along with nil reader.
 func (e EncryptedStream) Decrypt(key *core.KeyInfo) (io.Reader, error) {
-	return stream.NewReader(key.Key.Bytes(), e.source)
-}
-
-// readFileVersionAndHeader reads the file version and header from the given source.
-// It returns the parsed header and any error encountered during the process.
-func readFileVersionAndHeader(source io.Reader) (*Header, error) {
-	err := readFileVersion(source)
+	ad, err := e.header.authenticate(key.Key.Bytes())
 	if err != nil {
 		return nil, err
 	}
-	header, err := ParseHeader(source)
+	aead, err := stream.NewAEAD(e.alg, key.Key.Bytes())
 	if err != nil {
 		return nil, err
 	}
-	return header, err
+	return stream.NewReaderWithAEAD(aead, ad, e.source), nil
+}
+
+// NewReaderAt parses the encrypted file of the given size read from src and returns its header
+// and a reader that decrypts the content with random access using the provided key.
+// Only the chunks covering the requested ranges are decrypted.
+func NewReaderAt(src io.ReaderAt, size int64, key *core.KeyInfo) (*Header, *stream.ReaderAt, error) {
+	// Parse the file version and header
+	section := io.NewSectionReader(src, 0, size)
+	header, raw, err := readFileVersionAndHeader(section)
+	if err != nil {
+		return nil, nil, err
+	}
+	ad, err := raw.authenticate(key.Key.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	// The encrypted stream starts after the header
+	start, err := section.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := stream.NewAEAD(header.Alg, key.Key.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := stream.NewReaderAtWithAEAD(aead, ad, io.NewSectionReader(src, start, size-start), size-start)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, reader, nil
+}
+
+// readFileVersionAndHeader reads the file version and header from the given source,
+// followed by the MAC of the header for the version 2.
+// It returns the parsed header, the raw header to authenticate and any error encountered during the process.
+func readFileVersionAndHeader(source io.Reader) (*Header, rawHeader, error) {
+	version, err := readFileVersion(source)
+	if err != nil {
+		return nil, rawHeader{}, err
+	}
+	headerContext, err := readContext(source)
+	if err != nil {
+		return nil, rawHeader{}, err
+	}
+	header, err := unmarshalHeader(headerContext)
+	if err != nil {
+		return nil, rawHeader{}, err
+	}
+	raw := rawHeader{version: version, context: headerContext}
+	if version == fileVersionV2 {
+		raw.mac = make([]byte, headerMACSize)
+		if _, err := io.ReadFull(source, raw.mac); err != nil {
+			return nil, rawHeader{}, err
+		}
+	}
+	return header, raw, nil
 }
 
 // readFileVersion reads the version byte from the given source.
-// It returns an error if the version is not supported.
+// It returns ErrUnsupportedFileVersion if the version is not supported.
 // The version byte is expected to be the first byte in the source.
-// The current version is 1.
-func readFileVersion(source io.Reader) error {
+// The versions 1 and 2 are supported, the current version is 2.
+func readFileVersion(source io.Reader) (byte, error) {
 	// Create a buffer to hold the version byte
 	versionBuffer := make([]byte, 1)
 	_, err := io.ReadFull(source, versionBuffer)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	version := versionBuffer[0]
 
-	// Check the version (assuming version 1 is expected)
-	if version != 1 {
-		return errors.New("unsupported file version")
+	// Check the version
+	if version != fileVersionV1 && version != fileVersionV2 {
+		return 0, ErrUnsupportedFileVersion
 	}
-	return err
+	return version, nil
 }
@@ -2,14 +2,19 @@ package file_crypto_test
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
 	"ctb-cli/core"
 	"ctb-cli/crypto/file_crypto"
+	"ctb-cli/crypto/stream"
+	"encoding/hex"
+	"errors"
 	"io"
 	"testing"
 )
 
-func testRoundTrip(t *testing.T, length int) {
+func testRoundTrip(t *testing.T, alg string, length int) {
 	// Generate some random data
 	originalData := make([]byte, length)
 	if length != 0 {
@@ -26,7 +31,7 @@ func testRoundTrip(t *testing.T, length int) {
 	memBuf := bytes.NewBuffer(nil)
 
 	// Create a writer that writes to the in-memory buffer
-	memEncryptedWriter, err := file_crypto.NewWriter(memBuf, &keyInfo, "fileId")
+	memEncryptedWriter, err := file_crypto.NewWriterWithAlgorithm(memBuf, &keyInfo, "fileId", file_crypto.ContentTypeData, alg)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -48,6 +53,8 @@ func testRoundTrip(t *testing.T, length int) {
 		t.Fatal(err)
 	}
 
+	encrypted := bytes.Clone(memBuf.Bytes())
+
 	// Create a parser to read the data back
 	header, encStream, err := file_crypto.Parse(memBuf)
 	if err != nil {
@@ -61,11 +68,11 @@ func testRoundTrip(t *testing.T, length int) {
 	if header.KeyId != "ID" {
 		t.Errorf("Expected KeyID to be 'ID', got '%s'", header.KeyId)
 	}
-	if header.Alg != "AEAD_ChaCha20_Poly1305" {
-		t.Errorf("Expected Alg to be 'AEAD_ChaCha20_Poly1305', got '%s'", header.Alg)
+	if header.Alg != alg {
+		t.Errorf("Expected Alg to be '%s', got '%s'", alg, header.Alg)
 	}
-	if header.Version != "V1" {
-		t.Errorf("Expected Version to be V1, got %s", header.Version)
+	if header.Version != "V2" {
+		t.Errorf("Expected Version to be V2, got %s", header.Version)
 	}
 
 	// Read the data back
@@ -85,11 +92,269 @@ func testRoundTrip(t *testing.T, length int) {
 	if !bytes.Equal(originalData, readData) {
 		t.Errorf("Original and read data do not match")
 	}
+
+	// Read the data back with random access
+	header, readerAt, err := file_crypto.NewReaderAt(bytes.NewReader(encrypted), int64(len(encrypted)), &keyInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.FileID != "fileId" {
+		t.Errorf("Expected FileID to be 'fileId', got '%s'", header.FileID)
+	}
+	if readerAt.Size() != int64(length) {
+		t.Errorf("Expected size %d, got %d", length, readerAt.Size())
+	}
+	if length > 0 {
+		ofst := length / 3
+		buf := make([]byte, length-ofst)
+		n, err := readerAt.ReadAt(buf, int64(ofst))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], originalData[ofst:]) {
+			t.Errorf("Original and random access read data do not match")
+		}
+	}
 }
 
-// TestRoundTrip tests the round trip of writing and reading encrypted data
+// TestRoundTrip tests the round trip of writing and reading encrypted data with both algorithms
 func TestRoundTrip(t *testing.T) {
-	testRoundTrip(t, 0)
-	testRoundTrip(t, 1024)
-	testRoundTrip(t, 1024*1024)
+	for _, alg := range []string{stream.ChaCha20Poly1305, stream.AES256GCM} {
+		testRoundTrip(t, alg, 0)
+		testRoundTrip(t, alg, 1024)
+		testRoundTrip(t, alg, 1024*1024)
+	}
+}
+
+// TestDefaultAlgorithm tests that the files are encrypted with ChaCha20-Poly1305 by default
+func TestDefaultAlgorithm(t *testing.T) {
+	keyInfo := core.KeyInfo{
+		Id:  "ID",
+		Key: core.NewKeyFromRand(),
+	}
+	memBuf := bytes.NewBuffer(nil)
+	writer, err := file_crypto.NewWriter(memBuf, &keyInfo, "fileId")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	header, _, err := file_crypto.Parse(memBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Alg != stream.ChaCha20Poly1305 {
+		t.Errorf("Expected Alg to be '%s', got '%s'", stream.ChaCha20Poly1305, header.Alg)
+	}
+	if _, err := file_crypto.NewWriterWithAlgorithm(memBuf, &keyInfo, "fileId", file_crypto.ContentTypeData, "AEAD_Unknown"); !errors.Is(err, stream.ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
+
+// TestHeaderTampering tests that a modified header fails the decryption
+func TestHeaderTampering(t *testing.T) {
+	keyInfo := core.KeyInfo{
+		Id:  "ID",
+		Key: core.NewKeyFromRand(),
+	}
+	memBuf := bytes.NewBuffer(nil)
+	writer, err := file_crypto.NewWriter(memBuf, &keyInfo, "fileId")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("content")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Change the file ID without changing the size of the header
+	tampered := bytes.Replace(memBuf.Bytes(), []byte(`"fileId"`), []byte(`"fileXd"`), 1)
+
+	header, encStream, err := file_crypto.Parse(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.FileID != "fileXd" {
+		t.Fatalf("Expected the tampered FileID, got '%s'", header.FileID)
+	}
+	if _, err := encStream.Decrypt(&keyInfo); !errors.Is(err, file_crypto.ErrHeaderAuthentication) {
+		t.Errorf("Expected ErrHeaderAuthentication, got %v", err)
+	}
+	_, _, err = file_crypto.NewReaderAt(bytes.NewReader(tampered), int64(len(tampered)), &keyInfo)
+	if !errors.Is(err, file_crypto.ErrHeaderAuthentication) {
+		t.Errorf("Expected ErrHeaderAuthentication, got %v", err)
+	}
+}
+
+// TestReadVersion1 tests that the files of the version 1, with an unauthenticated header, can still be read
+func TestReadVersion1(t *testing.T) {
+	keyInfo := core.KeyInfo{
+		Id:  "ID",
+		Key: core.NewKeyFromRand(),
+	}
+	content := []byte("version 1 content")
+	header := file_crypto.Header{Version: "V1", Alg: "AEAD_ChaCha20_Poly1305", FileID: "fileId", KeyId: "ID"}
+	headerBytes, err := header.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	memBuf := bytes.NewBuffer([]byte{1})
+	memBuf.Write(headerBytes)
+	streamWriter, err := stream.NewWriter(keyInfo.Key.Bytes(), memBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := streamWriter.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := streamWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := bytes.Clone(memBuf.Bytes())
+
+	parsed, encStream, err := file_crypto.Parse(memBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Version != "V1" {
+		t.Errorf("Expected Version to be V1, got %s", parsed.Version)
+	}
+	decrypted, err := encStream.Decrypt(&keyInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readData, err := io.ReadAll(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readData, content) {
+		t.Errorf("Original and read data do not match")
+	}
+	_, readerAt, err := file_crypto.NewReaderAt(bytes.NewReader(encrypted), int64(len(encrypted)), &keyInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(content))
+	if _, err := readerAt.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, content) {
+		t.Errorf("Original and random access read data do not match")
+	}
+
+	// Other versions are not supported
+	encrypted[0] = 3
+	if _, _, err := file_crypto.Parse(bytes.NewReader(encrypted)); !errors.Is(err, file_crypto.ErrUnsupportedFileVersion) {
+		t.Errorf("Expected ErrUnsupportedFileVersion, got %v", err)
+	}
+}
+
+// TestContentType tests that the content type is written in the header
+func TestContentType(t *testing.T) {
+	keyInfo := core.KeyInfo{
+		Id:  "ID",
+		Key: core.NewKeyFromRand(),
+	}
+	memBuf := bytes.NewBuffer(nil)
+	writer, err := file_crypto.NewWriterWithContentType(memBuf, &keyInfo, "fileId", file_crypto.ContentTypeManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	header, _, err := file_crypto.Parse(memBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.ContentType != file_crypto.ContentTypeManifest {
+		t.Errorf("Expected content type '%s', got '%s'", file_crypto.ContentTypeManifest, header.ContentType)
+	}
+}
+
+// testSigner is a RecordSigner signing the records with an Ed25519 key
+type testSigner struct {
+	key ed25519.PrivateKey
+}
+
+func (s testSigner) SignRecord(info string, payload []byte) (*core.Signature, error) {
+	value := ed25519.Sign(s.key, append([]byte(info), payload...))
+	return &core.Signature{Writer: "writer", Value: hex.EncodeToString(value)}, nil
+}
+
+func (s testSigner) VerifyRecord(signature *core.Signature, info string, payload []byte) string {
+	if signature == nil {
+		return core.SignatureUnsigned
+	}
+	value, err := hex.DecodeString(signature.Value)
+	if err != nil || !ed25519.Verify(s.key.Public().(ed25519.PublicKey), append([]byte(info), payload...), value) {
+		return core.SignatureInvalid
+	}
+	return core.SignatureVerified
+}
+
+func (s testSigner) CheckRecord(signature *core.Signature, info string, payload []byte) error {
+	return core.SignatureStatusError(s.VerifyRecord(signature, info, payload), false)
+}
+
+// TestSignedHeader tests that the signature and the content hash of the header are written and verified
+func TestSignedHeader(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer := testSigner{key: key}
+	keyInfo := core.KeyInfo{
+		Id:  "ID",
+		Key: core.NewKeyFromRand(),
+	}
+	content := []byte("signed content")
+	hash := sha256.Sum256(content)
+	memBuf := bytes.NewBuffer(nil)
+	writer, err := file_crypto.NewWriter(memBuf, &keyInfo, "fileId")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Sign(hash[:], signer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Sign(hash[:], signer); !errors.Is(err, file_crypto.ErrHeaderWritten) {
+		t.Errorf("Expected ErrHeaderWritten, got %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	header, _, err := file_crypto.Parse(memBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := header.Verify(signer); status != core.SignatureVerified {
+		t.Errorf("Expected status '%s', got '%s'", core.SignatureVerified, status)
+	}
+	if err := header.VerifyContent(hash[:]); err != nil {
+		t.Errorf("Expected the content to match, got %v", err)
+	}
+	otherHash := sha256.Sum256([]byte("other content"))
+	if err := header.VerifyContent(otherHash[:]); !errors.Is(err, file_crypto.ErrContentMismatch) {
+		t.Errorf("Expected ErrContentMismatch, got %v", err)
+	}
+	// A modified header does not match its signature
+	header.FileID = "otherFileId"
+	if status := header.Verify(signer); status != core.SignatureInvalid {
+		t.Errorf("Expected status '%s', got '%s'", core.SignatureInvalid, status)
+	}
+	if err := header.Check(signer); err != core.ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+	// A header whose signature is removed is refused
+	header.FileID = "fileId"
+	if err := header.Check(signer); err != nil {
+		t.Errorf("Expected the header to be accepted, got %v", err)
+	}
+	header.Signature = nil
+	if err := header.Check(signer); err != core.ErrUnsignedRecord {
+		t.Errorf("Expected ErrUnsignedRecord, got %v", err)
+	}
 }
@@ -0,0 +1,174 @@
+package key_crypto
+
+import (
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	X25519MLKEM768V1Info = "cognitechbridge.com/v1/X25519MLKEM768" // X25519MLKEM768V1Info is the info string used for deriving the wrap key from the hybrid shared secrets.
+	MLKEM768SeedV1Info   = "cognitechbridge.com/v1/MLKEM768Seed"   // MLKEM768SeedV1Info is the info string used for deriving the ML-KEM-768 seed from the private key.
+)
+
+// x25519MLKEM768V1Format is the first line of a data key sealed with the hybrid X25519 + ML-KEM-768 scheme.
+// Data keys sealed with X25519 only have no format line.
+const x25519MLKEM768V1Format = "x25519-mlkem768-v1"
+
+var (
+	ErrInvalidEncapsulationKey = errors.New("invalid ML-KEM encapsulation key")
+)
+
+// deriveDecapsulationKey derives the ML-KEM-768 decapsulation key of the user from the private key,
+// so that the user has a single secret for both the X25519 and the ML-KEM-768 key exchanges.
+func deriveDecapsulationKey(privateKey core.PrivateKey) (*mlkem.DecapsulationKey768, error) {
+	hk := hkdf.New(sha256.New, privateKey.Bytes(), nil, []byte(MLKEM768SeedV1Info))
+	seed := make([]byte, mlkem.SeedSize)
+	_, err := io.ReadFull(hk, seed)
+	if err != nil {
+		return nil, err
+	}
+	return mlkem.NewDecapsulationKey768(seed)
+}
+
+// EncapsulationKey returns the ML-KEM-768 encapsulation key derived from the private key.
+// It is published so that data keys can be sealed for the user with SealDataKeyHybrid.
+func EncapsulationKey(privateKey core.PrivateKey) ([]byte, error) {
+	decapsulationKey, err := deriveDecapsulationKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return decapsulationKey.EncapsulationKey().Bytes(), nil
+}
+
+// deriveHybridWrapKey derives the wrap key from the concatenation of the X25519 and the ML-KEM-768 shared secrets,
+// so that the wrap key stays secret as long as one of the two key exchanges is not broken.
+func deriveHybridWrapKey(x25519Secret []byte, mlkemSecret []byte, salt []byte) ([]byte, error) {
+	secret := make([]byte, 0, len(x25519Secret)+len(mlkemSecret))
+	secret = append(secret, x25519Secret...)
+	secret = append(secret, mlkemSecret...)
+	hk := hkdf.New(sha256.New, secret, salt, []byte(X25519MLKEM768V1Info))
+	wrapKey := make([]byte, chacha20poly1305.KeySize)
+	_, err := io.ReadFull(hk, wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return wrapKey, nil
+}
+
+// SealDataKeyHybrid encrypts a data key for the recipient with the public key and the ML-KEM-768 encapsulation key.
+// It performs an X25519 key exchange with an ephemeral secret as SealDataKey, and encapsulates an ML-KEM-768 shared secret.
+// The wrap key is derived from both shared secrets using HKDF and SHA-256, with the ephemeral share,
+// the public key and the ML-KEM ciphertext as the salt.
+// The result is returned in the format "x25519-mlkem768-v1 \n ephemeralShare \n mlkemCiphertext \n cipheredDataKey".
+func SealDataKeyHybrid(key core.Key, publicKey core.PublicKey, encapsulationKey []byte) (string, error) {
+	ek, err := mlkem.NewEncapsulationKey768(encapsulationKey)
+	if err != nil {
+		return "", ErrInvalidEncapsulationKey
+	}
+	// Generate a random 32-byte ephemeral secret
+	ephemeralSecret := make([]byte, 32)
+	_, err = io.ReadFull(rand.Reader, ephemeralSecret)
+	if err != nil {
+		return "", ErrGeneratingRandomEphemeralSecret
+	}
+	// Derive the ephemeral share and the X25519 shared secret
+	ephemeralShare, err := curve25519.X25519(ephemeralSecret, curve25519.Basepoint)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting data key: %v", err)
+	}
+	x25519Secret, err := curve25519.X25519(ephemeralSecret, publicKey.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("error encrypting data key: %v", err)
+	}
+	// Encapsulate the ML-KEM-768 shared secret
+	mlkemSecret, mlkemCiphertext := ek.Encapsulate()
+	ephemeralShareString := base64.RawStdEncoding.EncodeToString(ephemeralShare)
+	mlkemCiphertextString := base64.RawStdEncoding.EncodeToString(mlkemCiphertext)
+	// Derive the wrap key from both shared secrets
+	salt := ephemeralShareString + publicKey.Encode() + mlkemCiphertextString
+	wrapKey, err := deriveHybridWrapKey(x25519Secret, mlkemSecret, []byte(salt))
+	if err != nil {
+		return "", fmt.Errorf("error generating wrap key: %v", err)
+	}
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return "", ErrFaliledToCreateCipher
+	}
+	// Each wrap key is used once, so an all-zero nonce is used
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	ciphered := aead.Seal(nil, nonce, key.Bytes(), nil)
+	res := strings.Join([]string{
+		x25519MLKEM768V1Format,
+		ephemeralShareString,
+		mlkemCiphertextString,
+		base64.RawStdEncoding.EncodeToString(ciphered),
+	}, "\n")
+	return res, nil
+}
+
+// IsHybridSealed returns true if the data key is sealed with the hybrid X25519 + ML-KEM-768 scheme.
+func IsHybridSealed(serialized string) bool {
+	return strings.HasPrefix(serialized, x25519MLKEM768V1Format+"\n")
+}
+
+// openDataKeyHybrid decrypts a data key sealed with SealDataKeyHybrid, without the format line.
+func openDataKeyHybrid(parts []string, privateKey core.PrivateKey) (*core.Key, error) {
+	if len(parts) != 3 {
+		return nil, ErrInvalidSerializedKey
+	}
+	ephemeralShareString, mlkemCiphertextString := parts[0], parts[1]
+	ephemeralShare, err1 := base64.RawStdEncoding.DecodeString(ephemeralShareString)
+	mlkemCiphertext, err2 := base64.RawStdEncoding.DecodeString(mlkemCiphertextString)
+	ciphered, err3 := base64.RawStdEncoding.DecodeString(parts[2])
+	if errors.Join(err1, err2, err3) != nil {
+		return nil, ErrInvalidSerializedKey
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	// Derive the X25519 shared secret and decapsulate the ML-KEM-768 shared secret
+	x25519Secret, err := curve25519.X25519(privateKey.Bytes(), ephemeralShare)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	decapsulationKey, err := deriveDecapsulationKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	mlkemSecret, err := decapsulationKey.Decapsulate(mlkemCiphertext)
+	if err != nil {
+		return nil, ErrInvalidSerializedKey
+	}
+	// Derive the wrap key from both shared secrets
+	salt := ephemeralShareString + publicKey.Encode() + mlkemCiphertextString
+	wrapKey, err := deriveHybridWrapKey(x25519Secret, mlkemSecret, []byte(salt))
+	if err != nil {
+		return nil, ErrErrorDerivingWrapKey
+	}
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, ErrFaliledToCreateCipher
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	deciphered, err := aead.Open(nil, nonce, ciphered, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	key, err := core.KeyFromBytes(deciphered)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	return &key, nil
+}
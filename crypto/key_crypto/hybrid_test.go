@@ -0,0 +1,104 @@
+package key_crypto_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"strings"
+	"testing"
+)
+
+func TestSealAndOpenDataKeyHybrid(t *testing.T) {
+	// Generate a random data key and private key
+	dataKey := core.NewKeyFromRand()
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := privateKey.ToPublicKey()
+	encapsulationKey, err := key_crypto.EncapsulationKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Seal the data key with both key exchanges
+	sealedKey, err := key_crypto.SealDataKeyHybrid(dataKey, publicKey, encapsulationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealedKey, "x25519-mlkem768-v1\n") {
+		t.Fatalf("Sealed key does not start with the hybrid format line")
+	}
+
+	// Open the sealed key, the format is detected by OpenDataKey
+	openedKey, err := key_crypto.OpenDataKey(sealedKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openedKey.Equals(dataKey) {
+		t.Errorf("Opened key does not match original data key")
+	}
+}
+
+func TestEncapsulationKeyIsDeterministic(t *testing.T) {
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := key_crypto.EncapsulationKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := key_crypto.EncapsulationKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("Encapsulation keys derived from the same private key differ")
+	}
+}
+
+func TestOpenDataKeyHybridWrongKey(t *testing.T) {
+	dataKey := core.NewKeyFromRand()
+	privateKey, _ := core.NewPrivateKeyFromRand()
+	otherKey, _ := core.NewPrivateKeyFromRand()
+	publicKey, _ := privateKey.ToPublicKey()
+	encapsulationKey, _ := key_crypto.EncapsulationKey(privateKey)
+
+	sealedKey, err := key_crypto.SealDataKeyHybrid(dataKey, publicKey, encapsulationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key_crypto.OpenDataKey(sealedKey, otherKey); err == nil {
+		t.Errorf("Data key opened with another private key")
+	}
+}
+
+func TestOpenDataKeyHybridTampered(t *testing.T) {
+	dataKey := core.NewKeyFromRand()
+	privateKey, _ := core.NewPrivateKeyFromRand()
+	publicKey, _ := privateKey.ToPublicKey()
+	encapsulationKey, _ := key_crypto.EncapsulationKey(privateKey)
+
+	sealedKey, err := key_crypto.SealDataKeyHybrid(dataKey, publicKey, encapsulationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(sealedKey, "\n")
+
+	// A data key sealed with the hybrid scheme cannot be opened as an X25519 only data key
+	if _, err := key_crypto.OpenDataKey(parts[1]+"\n"+parts[3], privateKey); err == nil {
+		t.Errorf("Hybrid data key opened without the ML-KEM shared secret")
+	}
+
+	// Replacing the ML-KEM ciphertext changes the wrap key
+	other, _ := key_crypto.SealDataKeyHybrid(dataKey, publicKey, encapsulationKey)
+	parts[2] = strings.Split(other, "\n")[2]
+	if _, err := key_crypto.OpenDataKey(strings.Join(parts, "\n"), privateKey); err == nil {
+		t.Errorf("Data key opened with a replaced ML-KEM ciphertext")
+	}
+
+	// An invalid encapsulation key is rejected
+	if _, err := key_crypto.SealDataKeyHybrid(dataKey, publicKey, encapsulationKey[1:]); err != key_crypto.ErrInvalidEncapsulationKey {
+		t.Errorf("Expected ErrInvalidEncapsulationKey, got %v", err)
+	}
+}
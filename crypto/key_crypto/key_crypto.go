@@ -191,7 +191,8 @@ func SealDataKey(key core.Key, publicKey core.PublicKey) (string, error) {
 }
 
 // OpenDataKey decrypts a serialized key using the provided private key.
-// It splits the serialized key into the ephemeral share and ciphered data key,
+// Data keys sealed with SealDataKeyHybrid are detected by their format line and opened with both key exchanges.
+// Otherwise, it splits the serialized key into the ephemeral share and ciphered data key,
 // decodes them from base64, and derives the shared secret and wrap key.
 // Finally, it decrypts the data key using the wrap key and returns it as a core.Key.
 //
@@ -205,6 +206,9 @@ func SealDataKey(key core.Key, publicKey core.PublicKey) (string, error) {
 func OpenDataKey(serialized string, privateKey core.PrivateKey) (*core.Key, error) {
 	// Split the serialized key into the ephemeral share and ciphered data key by the newline separator
 	parts := strings.Split(serialized, "\n")
+	if parts[0] == x25519MLKEM768V1Format {
+		return openDataKeyHybrid(parts[1:], privateKey)
+	}
 	if len(parts) != 2 {
 		return nil, ErrInvalidSerializedKey
 	}
@@ -1,6 +1,7 @@
 package key_crypto_test
 
 import (
+	"bytes"
 	"ctb-cli/core"
 	"ctb-cli/crypto/key_crypto"
 	"testing"
@@ -56,3 +57,64 @@ func TestSealAndOpenDataKey(t *testing.T) {
 		t.Errorf("Opened key does not match original data key")
 	}
 }
+
+func TestSealAndOpenPrivateKey(t *testing.T) {
+	// Generate a random private key
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Seal the private key with a passphrase
+	sealedKey, err := key_crypto.SealPrivateKey(privateKey, []byte("correct horse battery staple"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Open the sealed key
+	openedKey, err := key_crypto.OpenPrivateKey(sealedKey, []byte("correct horse battery staple"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(openedKey.Bytes(), privateKey.Bytes()) {
+		t.Errorf("Opened key does not match original private key")
+	}
+
+	// Open the sealed key with a wrong passphrase
+	_, err = key_crypto.OpenPrivateKey(sealedKey, []byte("wrong passphrase"))
+	if err != key_crypto.ErrInvalidPassphrase {
+		t.Errorf("Expected ErrInvalidPassphrase, got %v", err)
+	}
+
+	// Seal the private key with an empty passphrase
+	_, err = key_crypto.SealPrivateKey(privateKey, nil)
+	if err != key_crypto.ErrEmptyPassphrase {
+		t.Errorf("Expected ErrEmptyPassphrase, got %v", err)
+	}
+}
+
+func TestSealAndOpenDataKeyWithPassphrase(t *testing.T) {
+	// Generate a random data key
+	dataKey := core.NewKeyFromRand()
+
+	// Seal the data key with a passphrase
+	sealedKey, err := key_crypto.SealDataKeyWithPassphrase(dataKey, []byte("correct horse battery staple"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Open the sealed key
+	openedKey, err := key_crypto.OpenDataKeyWithPassphrase(sealedKey, []byte("correct horse battery staple"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openedKey.Equals(dataKey) {
+		t.Errorf("Opened key does not match original data key")
+	}
+
+	// Open the sealed key with a wrong passphrase
+	_, err = key_crypto.OpenDataKeyWithPassphrase(sealedKey, []byte("wrong passphrase"))
+	if err != key_crypto.ErrInvalidPassphrase {
+		t.Errorf("Expected ErrInvalidPassphrase, got %v", err)
+	}
+}
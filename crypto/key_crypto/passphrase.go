@@ -0,0 +1,142 @@
+package key_crypto
+
+import (
+	"crypto/rand"
+	"ctb-cli/core"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	ScryptV1Info  = "cognitechbridge.com/v1/scrypt" // ScryptV1Info is the associated data of the keys sealed with a passphrase.
+	scryptLabel   = "scrypt"                        // scryptLabel is the first part of the serialized keys sealed with a passphrase.
+	scryptLogN    = 15                              // scryptLogN is the log2 of the scrypt work factor used to seal new keys.
+	scryptMaxLogN = 22                              // scryptMaxLogN is the maximum work factor accepted when opening a sealed key.
+)
+
+var (
+	ErrInvalidPassphrase = errors.New("invalid passphrase")
+	ErrEmptyPassphrase   = errors.New("empty passphrase")
+)
+
+// derivePassphraseKey derives a key from the passphrase and the salt using scrypt with the given work factor.
+func derivePassphraseKey(passphrase []byte, salt []byte, logN int) ([]byte, error) {
+	return scrypt.Key(passphrase, salt, 1<<logN, 8, 1, chacha20poly1305.KeySize)
+}
+
+// sealWithPassphrase encrypts the plaintext with a key derived from the passphrase and a random salt.
+// The result is returned as a string in the format "scrypt:logN:salt:ciphered".
+func sealWithPassphrase(plaintext []byte, passphrase []byte) (string, error) {
+	if len(passphrase) == 0 {
+		return "", ErrEmptyPassphrase
+	}
+	// Generate a random 32-byte salt
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", ErrGeneratingRandomSalt
+	}
+	// Derive the wrap key from the passphrase and the salt using scrypt
+	wrapKey, err := derivePassphraseKey(passphrase, salt, scryptLogN)
+	if err != nil {
+		return "", ErrGeneratingDerivedKey
+	}
+	// Create a new AEAD cipher using the wrap key
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return "", ErrFaliledToCreateCipher
+	}
+	// Create a all-zero nonce (the wrap key is unique since the salt is random)
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	ciphered := aead.Seal(nil, nonce, plaintext, []byte(ScryptV1Info))
+	// Serialize the work factor, salt and ciphered data
+	res := fmt.Sprintf("%s:%d:%s:%s",
+		scryptLabel,
+		scryptLogN,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(ciphered),
+	)
+	return res, nil
+}
+
+// openWithPassphrase decrypts the data sealed by sealWithPassphrase.
+// It returns ErrInvalidPassphrase if the passphrase is wrong or the data is modified.
+func openWithPassphrase(serialized string, passphrase []byte) ([]byte, error) {
+	// Split the serialized data into the label, work factor, salt and ciphered data
+	parts := strings.Split(serialized, ":")
+	if len(parts) != 4 || parts[0] != scryptLabel {
+		return nil, ErrInvalidSerializedKey
+	}
+	logN, err := strconv.Atoi(parts[1])
+	if err != nil || logN <= 0 || logN > scryptMaxLogN {
+		return nil, ErrInvalidSerializedKey
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[2])
+	ciphered, err2 := base64.RawStdEncoding.DecodeString(parts[3])
+	if errors.Join(err1, err2) != nil {
+		return nil, ErrInvalidSerializedKey
+	}
+	// Derive the wrap key from the passphrase and the salt using scrypt
+	wrapKey, err := derivePassphraseKey(passphrase, salt, logN)
+	if err != nil {
+		return nil, ErrGeneratingDerivedKey
+	}
+	// Create AEAD cipher using the wrap key
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, ErrFaliledToCreateCipher
+	}
+	// Decrypt the data using the AEAD cipher
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	plaintext, err := aead.Open(nil, nonce, ciphered, []byte(ScryptV1Info))
+	if err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+	return plaintext, nil
+}
+
+// SealPrivateKey encrypts the private key with a key derived from the passphrase using scrypt.
+// The result is returned as a string in the format "scrypt:logN:salt:cipheredPrivateKey".
+func SealPrivateKey(privateKey core.PrivateKey, passphrase []byte) (string, error) {
+	return sealWithPassphrase(privateKey.Bytes(), passphrase)
+}
+
+// OpenPrivateKey decrypts a private key sealed with a passphrase.
+// It returns ErrInvalidPassphrase if the passphrase is wrong.
+func OpenPrivateKey(serialized string, passphrase []byte) (core.PrivateKey, error) {
+	plaintext, err := openWithPassphrase(serialized, passphrase)
+	if err != nil {
+		return core.EmptyPrivateKey(), err
+	}
+	if len(plaintext) != 32 {
+		return core.EmptyPrivateKey(), ErrInvalidKey
+	}
+	return core.NewPrivateKeyFromBytes(plaintext), nil
+}
+
+// SealDataKeyWithPassphrase encrypts a data key with a key derived from the passphrase using scrypt,
+// for the recipients who have no key pair.
+// The result is returned as a string in the format "scrypt:logN:salt:cipheredDataKey".
+func SealDataKeyWithPassphrase(key core.Key, passphrase []byte) (string, error) {
+	return sealWithPassphrase(key.Bytes(), passphrase)
+}
+
+// OpenDataKeyWithPassphrase decrypts a data key sealed with a passphrase.
+// It returns ErrInvalidPassphrase if the passphrase is wrong.
+func OpenDataKeyWithPassphrase(serialized string, passphrase []byte) (*core.Key, error) {
+	plaintext, err := openWithPassphrase(serialized, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	key, err := core.KeyFromBytes(plaintext)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return &key, nil
+}
@@ -0,0 +1,152 @@
+// Package mnemonic implements the encoding of keys as word lists, following BIP39.
+// The key is followed by a checksum made of the first bits of its SHA-256 hash (one bit for every
+// 32 bits of key), and every 11 bits of the result are encoded as a word of the BIP39 English word list.
+// A 32-byte key is encoded as 24 words, which can be written down on paper as a backup of the key.
+package mnemonic
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"errors"
+	"strings"
+)
+
+const (
+	wordBits  = 11   // wordBits is the number of bits encoded by a word.
+	wordCount = 2048 // wordCount is the number of words of the word list.
+)
+
+var (
+	ErrInvalidKeyLength = errors.New("key length must be a multiple of 4 bytes, between 16 and 32 bytes")
+	ErrInvalidWordCount = errors.New("invalid number of words in mnemonic")
+	ErrInvalidWord      = errors.New("invalid word in mnemonic")
+	ErrInvalidChecksum  = errors.New("invalid mnemonic checksum")
+)
+
+//go:embed english.txt
+var english string
+
+// words is the BIP39 English word list, and wordIndex maps the words to their index.
+var (
+	words     = strings.Fields(english)
+	wordIndex = make(map[string]int, wordCount)
+)
+
+func init() {
+	if len(words) != wordCount {
+		panic("mnemonic: invalid word list")
+	}
+	for i, word := range words {
+		wordIndex[word] = i
+	}
+}
+
+// Encode encodes the key as a mnemonic, a list of words separated by spaces.
+func Encode(key []byte) (string, error) {
+	if len(key) < 16 || len(key) > 32 || len(key)%4 != 0 {
+		return "", ErrInvalidKeyLength
+	}
+	// Append the checksum to the key
+	checksumBits := len(key) * 8 / 32
+	hash := sha256.Sum256(key)
+	data := append(append([]byte{}, key...), hash[0])
+
+	// Encode every 11 bits as a word
+	count := (len(key)*8 + checksumBits) / wordBits
+	result := make([]string, count)
+	for i := 0; i < count; i++ {
+		result[i] = words[readBits(data, i*wordBits, wordBits)]
+	}
+	return strings.Join(result, " "), nil
+}
+
+// Decode decodes the mnemonic and returns the key.
+// Words are separated by white space and are not case-sensitive.
+// It returns ErrInvalidChecksum if the words were not written down or typed correctly.
+func Decode(mnemonic string) ([]byte, error) {
+	list := strings.Fields(strings.ToLower(mnemonic))
+	if len(list) < 12 || len(list) > 24 || len(list)%3 != 0 {
+		return nil, ErrInvalidWordCount
+	}
+	// Decode every word as 11 bits
+	totalBits := len(list) * wordBits
+	checksumBits := totalBits / 33
+	data := make([]byte, (totalBits+7)/8)
+	for i, word := range list {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, ErrInvalidWord
+		}
+		writeBits(data, i*wordBits, wordBits, index)
+	}
+
+	// Check the checksum
+	key := data[:(totalBits-checksumBits)/8]
+	hash := sha256.Sum256(key)
+	if readBits(data, len(key)*8, checksumBits) != readBits(hash[:], 0, checksumBits) {
+		return nil, ErrInvalidChecksum
+	}
+	return append([]byte{}, key...), nil
+}
+
+// EncodeData encodes data of any length as words, without checksum: every 11 bits are encoded as a word,
+// and the last word is padded with zero bits. It is used for data carrying its own integrity check.
+func EncodeData(data []byte) string {
+	count := (len(data)*8 + wordBits - 1) / wordBits
+	padded := append(append([]byte{}, data...), 0, 0)
+	return strings.Join(Words(padded, count), " ")
+}
+
+// DecodeData decodes the words encoded by EncodeData as data of the given length.
+// Words are separated by white space and are not case-sensitive.
+func DecodeData(mnemonic string, length int) ([]byte, error) {
+	list := strings.Fields(strings.ToLower(mnemonic))
+	if len(list) != (length*8+wordBits-1)/wordBits {
+		return nil, ErrInvalidWordCount
+	}
+	data := make([]byte, (len(list)*wordBits+7)/8)
+	for i, word := range list {
+		index, ok := wordIndex[word]
+		if !ok {
+			return nil, ErrInvalidWord
+		}
+		writeBits(data, i*wordBits, wordBits, index)
+	}
+	// The padding bits are zero
+	for _, b := range data[length:] {
+		if b != 0 {
+			return nil, ErrInvalidWord
+		}
+	}
+	return data[:length], nil
+}
+
+// Words returns the words encoding the first count*11 bits of data, without checksum.
+// It is used to show a hash, e.g. a key fingerprint, as words that are easy to compare.
+// It panics if data is shorter than count*11 bits.
+func Words(data []byte, count int) []string {
+	result := make([]string, count)
+	for i := 0; i < count; i++ {
+		result[i] = words[readBits(data, i*wordBits, wordBits)]
+	}
+	return result
+}
+
+// readBits returns n bits of data starting at bit offset, most significant bit first.
+func readBits(data []byte, offset int, n int) int {
+	value := 0
+	for i := offset; i < offset+n; i++ {
+		value = value<<1 | int(data[i/8]>>(7-i%8)&1)
+	}
+	return value
+}
+
+// writeBits writes the n least significant bits of value to data starting at bit offset, most significant bit first.
+func writeBits(data []byte, offset int, n int, value int) {
+	for i := 0; i < n; i++ {
+		if value>>(n-1-i)&1 == 1 {
+			pos := offset + i
+			data[pos/8] |= 1 << (7 - pos%8)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package mnemonic_test
+
+import (
+	"bytes"
+	"ctb-cli/crypto/mnemonic"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// vectors are test vectors of the BIP39 reference implementation (English word list).
+var vectors = []struct {
+	key      string
+	mnemonic string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+	},
+	{
+		"9e885d952ad362caeb4efe34a8e91bd2",
+		"ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth title",
+	},
+	{
+		"8080808080808080808080808080808080808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic bless",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+	},
+	{
+		"68a79eaca2324873eacc50cb9c6eca8cc68ea5d936f98787c60c7ebc74e6ce7c",
+		"hamster diagram private dutch cause delay private meat slide toddler razor book happy fancy gospel tennis maple dilemma loan word shrug inflict delay length",
+	},
+	{
+		"9f6a2878b2520799a44ef18bc7df394e7061a224d2c33cd015b157d746869863",
+		"panda eyebrow bullet gorilla call smoke muffin taste mesh discover soft ostrich alcohol speed nation flash devote level hobby quick inner drive ghost inside",
+	},
+	{
+		"066dca1a2bb7e8a1db2832148ce9933eea0f3ac9548d793112d9a95c9407efad",
+		"all hour make first leader extend hole alien behind guard gospel lava path output census museum junior mass reopen famous sing advance salt reform",
+	},
+	{
+		"f585c11aec520db57dd353c69554b21a89b20fb0650966fa0a9d6f74fd989d8f",
+		"void come effort suffer camp survey warrior heavy shoot primary clutch crush open amazing screen patrol group space point ten exist slush involve unfold",
+	},
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		key, _ := hex.DecodeString(v.key)
+		encoded, err := mnemonic.Encode(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoded != v.mnemonic {
+			t.Errorf("Encode(%s) = %q, want %q", v.key, encoded, v.mnemonic)
+		}
+		decoded, err := mnemonic.Decode(v.mnemonic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, key) {
+			t.Errorf("Decode(%q) = %x, want %s", v.mnemonic, decoded, v.key)
+		}
+	}
+}
+
+func TestDecodeNormalizesWhiteSpaceAndCase(t *testing.T) {
+	v := vectors[7]
+	decoded, err := mnemonic.Decode("  " + strings.ToUpper(strings.ReplaceAll(v.mnemonic, " ", "\n\t ")) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(decoded) != v.key {
+		t.Errorf("Decoded key %x does not match %s", decoded, v.key)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	words := strings.Fields(vectors[7].mnemonic)
+	// Swap two words
+	swapped := append([]string{}, words...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	// Replace a word by a word that is not in the list
+	misspelled := append([]string{}, words...)
+	misspelled[3] = "gorila"
+
+	tests := []struct {
+		mnemonic string
+		err      error
+	}{
+		{strings.Join(swapped, " "), mnemonic.ErrInvalidChecksum},
+		{strings.Join(misspelled, " "), mnemonic.ErrInvalidWord},
+		{strings.Join(words[:23], " "), mnemonic.ErrInvalidWordCount},
+		{"", mnemonic.ErrInvalidWordCount},
+	}
+	for _, test := range tests {
+		if _, err := mnemonic.Decode(test.mnemonic); err != test.err {
+			t.Errorf("Decode(%q) returned %v, want %v", test.mnemonic, err, test.err)
+		}
+	}
+}
+
+func TestEncodeInvalidKeyLength(t *testing.T) {
+	for _, length := range []int{0, 15, 17, 33} {
+		if _, err := mnemonic.Encode(make([]byte, length)); err != mnemonic.ErrInvalidKeyLength {
+			t.Errorf("Encode of %d bytes returned %v, want %v", length, err, mnemonic.ErrInvalidKeyLength)
+		}
+	}
+}
+
+func TestEncodeAndDecodeData(t *testing.T) {
+	for _, length := range []int{1, 11, 43, 44, 45} {
+		data := make([]byte, length)
+		for i := range data {
+			data[i] = byte(255 - i*7)
+		}
+		encoded := mnemonic.EncodeData(data)
+		decoded, err := mnemonic.DecodeData(encoded, length)
+		if err != nil {
+			t.Fatalf("DecodeData of %d bytes failed: %v", length, err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("DecodeData(%q) = %x, want %x", encoded, decoded, data)
+		}
+		// The word count is checked
+		if _, err := mnemonic.DecodeData(encoded+" abandon", length); err != mnemonic.ErrInvalidWordCount {
+			t.Errorf("DecodeData with an extra word returned %v, want %v", err, mnemonic.ErrInvalidWordCount)
+		}
+	}
+	// The padding bits of the last word are zero
+	if _, err := mnemonic.DecodeData("zoo", 1); err != mnemonic.ErrInvalidWord {
+		t.Errorf("DecodeData with padding bits set returned %v, want %v", err, mnemonic.ErrInvalidWord)
+	}
+}
@@ -0,0 +1,159 @@
+// Package name_crypto implements the deterministic encryption of file and directory names.
+// Names are encrypted with a SIV-style construction: a synthetic IV is computed as the
+// HMAC-SHA256 of the name, and the name is encrypted with ChaCha20 using the synthetic IV as nonce.
+// Encrypting the same name with the same key always gives the same result, which makes it possible
+// to look up an encrypted name without listing the directory.
+package name_crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"encoding/base32"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	NameV1Info         = "cognitechbridge.com/v1/NameSIV"     // NameV1Info is the info string used for deriving the name key from the vault key.
+	nameEncryptionInfo = "cognitechbridge.com/v1/NameSIV/Enc" // nameEncryptionInfo is the info string used for deriving the encryption sub key.
+	nameMacInfo        = "cognitechbridge.com/v1/NameSIV/Mac" // nameMacInfo is the info string used for deriving the mac sub key.
+
+	sivSize = 16 // sivSize is the size of the synthetic IV prepended to the encrypted name.
+
+	// MaxNameLength is the maximum length of a stored name. Longer encrypted names are shortened.
+	MaxNameLength = 255
+	// LongNamePrefix is the prefix of shortened names.
+	LongNamePrefix = "ctb-long-"
+)
+
+var (
+	ErrInvalidEncryptedName        = errors.New("invalid encrypted name")
+	ErrCannotDeriveKeyFromEmptyKey = errors.New("cannot derive key from empty key")
+	ErrEmptyName                   = errors.New("name is empty")
+)
+
+// encoding is the encoding used for encrypted names.
+// Base32 is used instead of base64 to keep the names valid on case-insensitive file systems.
+var encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// DeriveNameKey derives the key used for encrypting the names of a directory from the directory vault key.
+func DeriveNameKey(vaultKey core.Key) (core.Key, error) {
+	if vaultKey.IsEmpty() {
+		return core.EmptyKey(), ErrCannotDeriveKeyFromEmptyKey
+	}
+	derived, err := expand(vaultKey.Bytes(), NameV1Info)
+	if err != nil {
+		return core.EmptyKey(), err
+	}
+	return core.KeyFromBytes(derived)
+}
+
+// EncryptName encrypts the name using the name key and returns the encoded result.
+// The result is deterministic and may be longer than MaxNameLength (see ShortenName).
+func EncryptName(nameKey core.Key, name string) (string, error) {
+	if name == "" {
+		return "", ErrEmptyName
+	}
+	encKey, macKey, err := subKeys(nameKey)
+	if err != nil {
+		return "", err
+	}
+	// Compute the synthetic IV from the name
+	siv := computeSiv(macKey, []byte(name))
+	// Encrypt the name using the synthetic IV as nonce
+	ciphered, err := xorKeyStream(encKey, siv, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(append(siv, ciphered...)), nil
+}
+
+// DecryptName decrypts an encrypted name produced by EncryptName.
+// It returns ErrInvalidEncryptedName if the name was not encrypted with the given key or has been tampered with.
+func DecryptName(nameKey core.Key, encrypted string) (string, error) {
+	raw, err := encoding.DecodeString(encrypted)
+	if err != nil || len(raw) <= sivSize {
+		return "", ErrInvalidEncryptedName
+	}
+	encKey, macKey, err := subKeys(nameKey)
+	if err != nil {
+		return "", err
+	}
+	siv, ciphered := raw[:sivSize], raw[sivSize:]
+	// Decrypt the name using the synthetic IV as nonce
+	name, err := xorKeyStream(encKey, siv, ciphered)
+	if err != nil {
+		return "", err
+	}
+	// Authenticate the name by recomputing the synthetic IV
+	if !hmac.Equal(siv, computeSiv(macKey, name)) {
+		return "", ErrInvalidEncryptedName
+	}
+	return string(name), nil
+}
+
+// IsLongName returns true if the encrypted name must be shortened before it is stored.
+func IsLongName(encrypted string) bool {
+	return len(encrypted) > MaxNameLength
+}
+
+// ShortenName returns the name used to store a long encrypted name.
+// The full encrypted name should be stored separately and found using the shortened name.
+func ShortenName(encrypted string) string {
+	hash := sha256.Sum256([]byte(encrypted))
+	return LongNamePrefix + encoding.EncodeToString(hash[:])
+}
+
+// IsShortenedName returns true if the stored name is a shortened long name.
+func IsShortenedName(stored string) bool {
+	return strings.HasPrefix(stored, LongNamePrefix)
+}
+
+// subKeys derives the encryption and mac sub keys from the name key.
+func subKeys(nameKey core.Key) (encKey []byte, macKey []byte, err error) {
+	if nameKey.IsEmpty() {
+		return nil, nil, ErrCannotDeriveKeyFromEmptyKey
+	}
+	encKey, err = expand(nameKey.Bytes(), nameEncryptionInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+	macKey, err = expand(nameKey.Bytes(), nameMacInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encKey, macKey, nil
+}
+
+// expand derives a 32-byte key from the root key and info using HKDF and SHA-256.
+func expand(rootKey []byte, info string) ([]byte, error) {
+	hk := hkdf.New(sha256.New, rootKey, nil, []byte(info))
+	derived := make([]byte, chacha20.KeySize)
+	if _, err := io.ReadFull(hk, derived); err != nil {
+		return nil, err
+	}
+	return derived, nil
+}
+
+// computeSiv computes the synthetic IV of the name.
+func computeSiv(macKey []byte, name []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(name)
+	return mac.Sum(nil)[:sivSize]
+}
+
+// xorKeyStream encrypts or decrypts the input with ChaCha20 using the first bytes of the synthetic IV as nonce.
+func xorKeyStream(encKey []byte, siv []byte, in []byte) ([]byte, error) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(encKey, siv[:chacha20.NonceSize])
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(in))
+	cipher.XORKeyStream(out, in)
+	return out, nil
+}
@@ -0,0 +1,93 @@
+package name_crypto_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/name_crypto"
+	"strings"
+	"testing"
+)
+
+func TestEncryptAndDecryptName(t *testing.T) {
+	// Derive a name key from a random vault key
+	nameKey, err := name_crypto.DeriveNameKey(core.NewKeyFromRand())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a", "report.pdf", "Ünïcödé name", strings.Repeat("x", 255)} {
+		encrypted, err := name_crypto.EncryptName(nameKey, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Encrypted names must be deterministic
+		again, _ := name_crypto.EncryptName(nameKey, name)
+		if encrypted != again {
+			t.Errorf("Encrypting %q twice gave different results", name)
+		}
+		// Encrypted names must be lower case to be valid on case-insensitive file systems
+		if strings.ToLower(encrypted) != encrypted {
+			t.Errorf("Encrypted name %q is not lower case", encrypted)
+		}
+		decrypted, err := name_crypto.DecryptName(nameKey, encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != name {
+			t.Errorf("Decrypted name %q does not match original name %q", decrypted, name)
+		}
+	}
+}
+
+func TestDecryptNameWithWrongKey(t *testing.T) {
+	nameKey, _ := name_crypto.DeriveNameKey(core.NewKeyFromRand())
+	otherKey, _ := name_crypto.DeriveNameKey(core.NewKeyFromRand())
+
+	encrypted, err := name_crypto.EncryptName(nameKey, "secret.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := name_crypto.DecryptName(otherKey, encrypted); err != name_crypto.ErrInvalidEncryptedName {
+		t.Errorf("Expected ErrInvalidEncryptedName, got %v", err)
+	}
+}
+
+func TestDecryptTamperedName(t *testing.T) {
+	nameKey, _ := name_crypto.DeriveNameKey(core.NewKeyFromRand())
+
+	encrypted, err := name_crypto.EncryptName(nameKey, "secret.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replace the first character of the encrypted name
+	replacement := "a"
+	if encrypted[0] == 'a' {
+		replacement = "b"
+	}
+	tampered := replacement + encrypted[1:]
+	if _, err := name_crypto.DecryptName(nameKey, tampered); err == nil {
+		t.Errorf("Expected an error decrypting a tampered name")
+	}
+}
+
+func TestShortenName(t *testing.T) {
+	nameKey, _ := name_crypto.DeriveNameKey(core.NewKeyFromRand())
+
+	short, _ := name_crypto.EncryptName(nameKey, "short")
+	if name_crypto.IsLongName(short) {
+		t.Errorf("Short name should not be shortened")
+	}
+	long, _ := name_crypto.EncryptName(nameKey, strings.Repeat("long", 50))
+	if !name_crypto.IsLongName(long) {
+		t.Fatalf("Long name should be shortened")
+	}
+	shortened := name_crypto.ShortenName(long)
+	if len(shortened) > name_crypto.MaxNameLength {
+		t.Errorf("Shortened name is too long: %d", len(shortened))
+	}
+	if !name_crypto.IsShortenedName(shortened) {
+		t.Errorf("Shortened name is not recognized as shortened")
+	}
+	if name_crypto.IsShortenedName(short) {
+		t.Errorf("Encrypted name is recognized as shortened")
+	}
+}
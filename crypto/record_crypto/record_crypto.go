@@ -0,0 +1,114 @@
+// Package record_crypto seals small records (e.g. file links and metadata) with keys derived from a vault key.
+package record_crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	FileMetadataV1Info = "cognitechbridge.com/v1/FileMetadata" // FileMetadataV1Info is the info string used for deriving the file metadata key from the vault key.
+	LinkV1Info         = "cognitechbridge.com/v1/Link"         // LinkV1Info is the info string used for deriving the key of the file links bound to their name.
+	LinkV2Info         = "cognitechbridge.com/v2/Link"         // LinkV2Info is the info string used for deriving the key of the file links bound to their full path.
+	DirMetadataV1Info  = "cognitechbridge.com/v1/DirMetadata"  // DirMetadataV1Info is the info string used for deriving the directory metadata key from the vault key.
+	PolicyV1Info       = "cognitechbridge.com/v1/Policy"       // PolicyV1Info is the info string used for deriving the key of the proof of the repository policy from the root vault key.
+)
+
+var (
+	ErrGeneratingRandomSalt        = errors.New("error generating random salt")
+	ErrGeneratingDerivedKey        = errors.New("error generating derived key")
+	ErrFaliledToCreateCipher       = errors.New("failed to create cipher")
+	ErrInvalidSealedRecord         = errors.New("invalid sealed record")
+	ErrOpeningRecord               = errors.New("error opening record")
+	ErrCannotDeriveKeyFromEmptyKey = errors.New("cannot derive key from empty key")
+)
+
+// deriveKey derives a key from the root key, salt, and info using HKDF and SHA-256.
+func deriveKey(rootKey core.Key, salt []byte, info string) (core.Key, error) {
+	// Check if the root key is empty
+	if rootKey.IsEmpty() {
+		return core.EmptyKey(), ErrCannotDeriveKeyFromEmptyKey
+	}
+	hk := hkdf.New(sha256.New, rootKey.Bytes(), salt, []byte(info))
+	derivedKey := make([]byte, chacha20poly1305.KeySize)
+	_, err := io.ReadFull(hk, derivedKey)
+	if err != nil {
+		return core.EmptyKey(), err
+	}
+	return core.KeyFromBytes(derivedKey)
+}
+
+// Seal encrypts the record using a key derived from the vault key and the info string.
+// A random 32-byte salt is used for deriving the key, so each sealed record uses a fresh key and an all-zero nonce.
+// The associated data is authenticated but not stored; the same value must be passed to Open.
+// The result is returned as a string in the format "salt:cipheredRecord".
+func Seal(vaultKey core.Key, info string, record []byte, associatedData []byte) (string, error) {
+	// Generate a random 32-byte salt
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return "", ErrGeneratingRandomSalt
+	}
+	// Derive a key from the vault key, salt, and info
+	derivedKey, err := deriveKey(vaultKey, salt, info)
+	if err != nil {
+		return "", ErrGeneratingDerivedKey
+	}
+	// Create a new AEAD cipher using the derived key
+	aead, err := chacha20poly1305.New(derivedKey.Bytes())
+	if err != nil {
+		return "", ErrFaliledToCreateCipher
+	}
+	// Create a all-zero nonce
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	// Encrypt the record using the AEAD cipher
+	ciphered := aead.Seal(nil, nonce, record, associatedData)
+	// Serialize the salt and ciphered record
+	res := fmt.Sprintf("%s:%s",
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(ciphered),
+	)
+	return res, nil
+}
+
+// Open decrypts a record sealed by Seal using the vault key, info string and associated data.
+// It returns ErrOpeningRecord if the record cannot be authenticated.
+func Open(vaultKey core.Key, info string, sealed string, associatedData []byte) ([]byte, error) {
+	// Split the sealed record into the salt and ciphered record by the colon separator
+	parts := strings.Split(sealed, ":")
+	if len(parts) != 2 {
+		return nil, ErrInvalidSealedRecord
+	}
+	// Decode the salt and ciphered record from raw base64
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[0])
+	ciphered, err2 := base64.RawStdEncoding.DecodeString(parts[1])
+	if errors.Join(err1, err2) != nil {
+		return nil, ErrInvalidSealedRecord
+	}
+	// Derive the key from the vault key, salt, and info
+	derivedKey, err := deriveKey(vaultKey, salt, info)
+	if err != nil {
+		return nil, ErrGeneratingDerivedKey
+	}
+	// Create AEAD cipher using the derived key
+	aead, err := chacha20poly1305.New(derivedKey.Bytes())
+	if err != nil {
+		return nil, ErrFaliledToCreateCipher
+	}
+	// Create a all-zero nonce
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	// Decrypt the record using the AEAD cipher
+	record, err := aead.Open(nil, nonce, ciphered, associatedData)
+	if err != nil {
+		return nil, ErrOpeningRecord
+	}
+	return record, nil
+}
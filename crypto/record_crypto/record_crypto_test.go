@@ -0,0 +1,51 @@
+package record_crypto_test
+
+import (
+	"bytes"
+	"ctb-cli/core"
+	"ctb-cli/crypto/record_crypto"
+	"testing"
+)
+
+func TestSealAndOpen(t *testing.T) {
+	vaultKey := core.NewKeyFromRand()
+	record := []byte(`{"mtime":"2024-01-01T00:00:00Z"}`)
+
+	sealed, err := record_crypto.Seal(vaultKey, record_crypto.FileMetadataV1Info, record, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := record_crypto.Open(vaultKey, record_crypto.FileMetadataV1Info, sealed, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, record) {
+		t.Errorf("Opened record does not match original record")
+	}
+}
+
+func TestOpenWithWrongInputs(t *testing.T) {
+	vaultKey := core.NewKeyFromRand()
+	record := []byte("record")
+
+	sealed, err := record_crypto.Seal(vaultKey, record_crypto.FileMetadataV1Info, record, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wrong key
+	if _, err := record_crypto.Open(core.NewKeyFromRand(), record_crypto.FileMetadataV1Info, sealed, []byte("ad")); err == nil {
+		t.Errorf("Expected an error opening the record with a wrong key")
+	}
+	// Wrong info
+	if _, err := record_crypto.Open(vaultKey, "other", sealed, []byte("ad")); err == nil {
+		t.Errorf("Expected an error opening the record with a wrong info")
+	}
+	// Wrong associated data
+	if _, err := record_crypto.Open(vaultKey, record_crypto.FileMetadataV1Info, sealed, []byte("other")); err == nil {
+		t.Errorf("Expected an error opening the record with a wrong associated data")
+	}
+	// Invalid format
+	if _, err := record_crypto.Open(vaultKey, record_crypto.FileMetadataV1Info, "invalid", nil); err != record_crypto.ErrInvalidSealedRecord {
+		t.Errorf("Expected ErrInvalidSealedRecord, got %v", err)
+	}
+}
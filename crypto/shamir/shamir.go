@@ -0,0 +1,240 @@
+// Package shamir implements Shamir's secret sharing over GF(256), to split a key between custodians.
+// Every byte of the secret is the constant term of a random polynomial of degree threshold-1, and the
+// share of a custodian is the value of the polynomials at the index of the custodian. Any threshold shares
+// give back the secret by Lagrange interpolation, and fewer shares tell nothing about it.
+// Every share carries a checksum, so that a corrupted or mistyped share is rejected, and a check value
+// of the secret, so that a wrong combination of shares is detected.
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"ctb-cli/crypto/mnemonic"
+	"errors"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	ShareV1Version = 1                                     // ShareV1Version is the version of the share format.
+	SecretV1Info   = "cognitechbridge.com/v1/ShamirSecret" // SecretV1Info is the info string used for computing the check value of the secret.
+
+	// MaxShares is the maximum number of shares, since the indexes of the shares are the non-zero elements of GF(256).
+	MaxShares = 255
+
+	headerSize      = 7 // headerSize is the size of the version, threshold, index and secret check value of a share.
+	secretCheckSize = 4 // secretCheckSize is the size of the check value of the secret.
+	// checksumSize is the size of the checksum of a share. With 5 bytes, the share of a 32-byte key
+	// is 44 bytes, which is exactly 32 words.
+	checksumSize = 5
+)
+
+var (
+	ErrInvalidThreshold  = errors.New("the threshold must be at least 2 and at most the number of shares")
+	ErrInvalidShareCount = errors.New("the number of shares must be at most 255")
+	ErrEmptySecret       = errors.New("the secret is empty")
+	ErrCorruptedShare    = errors.New("corrupted share: the checksum does not match")
+	ErrNotEnoughShares   = errors.New("not enough shares to reach the threshold")
+	ErrDuplicateShare    = errors.New("the same share is given twice")
+	ErrMismatchedShares  = errors.New("the shares do not belong to the same split")
+	ErrInvalidShares     = errors.New("the shares do not give back the secret")
+)
+
+// Share is the share of a custodian.
+type Share struct {
+	Threshold   byte   // Threshold is the number of shares needed to give back the secret.
+	Index       byte   // Index is the x coordinate of the share, between 1 and 255.
+	SecretCheck []byte // SecretCheck is the check value of the secret, the same for all the shares of a split.
+	Value       []byte // Value is the value of the polynomials at the index, one byte for each byte of the secret.
+}
+
+// Split splits the secret into the given number of shares, any threshold of them giving back the secret.
+func Split(secret []byte, threshold int, shares int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	if shares > MaxShares {
+		return nil, ErrInvalidShareCount
+	}
+	if threshold < 2 || threshold > shares {
+		return nil, ErrInvalidThreshold
+	}
+	check := secretCheck(secret)
+	result := make([]Share, shares)
+	for i := range result {
+		result[i] = Share{
+			Threshold:   byte(threshold),
+			Index:       byte(i + 1),
+			SecretCheck: check,
+			Value:       make([]byte, len(secret)),
+		}
+	}
+	// The coefficients of the polynomial of a byte, the constant term being the byte of the secret
+	coefficients := make([]byte, threshold)
+	defer clear(coefficients)
+	for b := range secret {
+		coefficients[0] = secret[b]
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for i := range result {
+			result[i].Value[b] = evaluate(coefficients, result[i].Index)
+		}
+	}
+	return result, nil
+}
+
+// Combine gives back the secret from the shares. At least threshold shares of the same split are required.
+// If more shares are given, the first threshold shares are used.
+// It returns ErrInvalidShares if the shares do not give back the secret they were split from.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+	first := shares[0]
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if share.Threshold != first.Threshold || len(share.Value) != len(first.Value) || !bytes.Equal(share.SecretCheck, first.SecretCheck) {
+			return nil, ErrMismatchedShares
+		}
+		if share.Index == 0 || seen[share.Index] {
+			return nil, ErrDuplicateShare
+		}
+		seen[share.Index] = true
+	}
+	if len(shares) < int(first.Threshold) {
+		return nil, ErrNotEnoughShares
+	}
+	// Interpolate the polynomials at 0 with threshold shares
+	shares = shares[:first.Threshold]
+	secret := make([]byte, len(first.Value))
+	for i, share := range shares {
+		// The Lagrange basis polynomial of the share at 0: the product of x_j / (x_j - x_i), where subtraction is xor
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = mul(basis, div(other.Index, other.Index^share.Index))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= mul(basis, share.Value[b])
+		}
+	}
+	if subtle.ConstantTimeCompare(secretCheck(secret), first.SecretCheck) != 1 {
+		clear(secret)
+		return nil, ErrInvalidShares
+	}
+	return secret, nil
+}
+
+// Bytes returns the serialized share: the version, the threshold, the index, the check value of the secret,
+// the value, and the checksum of all of them.
+func (s Share) Bytes() []byte {
+	data := make([]byte, 0, headerSize+len(s.Value)+checksumSize)
+	data = append(data, ShareV1Version, s.Threshold, s.Index)
+	data = append(data, s.SecretCheck...)
+	data = append(data, s.Value...)
+	return append(data, checksum(data)...)
+}
+
+// Encode returns the base58 encoding of the share.
+func (s Share) Encode() string {
+	return base58.Encode(s.Bytes())
+}
+
+// Words returns the share as words, which are easier to write down and to type.
+func (s Share) Words() string {
+	return mnemonic.EncodeData(s.Bytes())
+}
+
+// ParseShare parses a serialized share.
+// It returns ErrCorruptedShare if the checksum of the share does not match.
+func ParseShare(data []byte) (Share, error) {
+	if len(data) <= headerSize+checksumSize || data[0] != ShareV1Version {
+		return Share{}, ErrCorruptedShare
+	}
+	body := data[:len(data)-checksumSize]
+	if subtle.ConstantTimeCompare(checksum(body), data[len(body):]) != 1 {
+		return Share{}, ErrCorruptedShare
+	}
+	return Share{
+		Threshold:   body[1],
+		Index:       body[2],
+		SecretCheck: append([]byte{}, body[3:headerSize]...),
+		Value:       append([]byte{}, body[headerSize:]...),
+	}, nil
+}
+
+// DecodeShare decodes a share encoded by Encode, or written as words by Words.
+func DecodeShare(encoded string) (Share, error) {
+	encoded = strings.TrimSpace(encoded)
+	if !strings.ContainsAny(encoded, " \t\n") {
+		return ParseShare(base58.Decode(encoded))
+	}
+	// The padding of the last word may be longer than a byte, so the data may be one byte shorter
+	length := len(strings.Fields(encoded)) * 11 / 8
+	for _, l := range []int{length, length - 1} {
+		data, err := mnemonic.DecodeData(encoded, l)
+		if err != nil {
+			continue
+		}
+		if share, err := ParseShare(data); err == nil {
+			return share, nil
+		}
+	}
+	return Share{}, ErrCorruptedShare
+}
+
+// secretCheck returns the check value of the secret.
+func secretCheck(secret []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte(SecretV1Info))
+	hash.Write([]byte{0})
+	hash.Write(secret)
+	return hash.Sum(nil)[:secretCheckSize]
+}
+
+// checksum returns the checksum of a serialized share.
+func checksum(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:checksumSize]
+}
+
+// evaluate returns the value of the polynomial with the coefficients at x, using Horner's method.
+func evaluate(coefficients []byte, x byte) byte {
+	result := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// mul multiplies a and b in GF(256) with the AES polynomial x^8 + x^4 + x^3 + x + 1, in constant time.
+func mul(a, b byte) byte {
+	result := byte(0)
+	for i := 0; i < 8; i++ {
+		result ^= a & -(b & 1)
+		b >>= 1
+		// Reduce by the polynomial if the high bit of a is set
+		a = a<<1 ^ 0x1b&-(a>>7)
+	}
+	return result
+}
+
+// div divides a by b in GF(256). b must not be zero.
+func div(a, b byte) byte {
+	return mul(a, inverse(b))
+}
+
+// inverse returns the multiplicative inverse of a in GF(256), a^254, in constant time.
+func inverse(a byte) byte {
+	result := byte(1)
+	for i := 0; i < 7; i++ {
+		a = mul(a, a)
+		result = mul(result, a)
+	}
+	return result
+}
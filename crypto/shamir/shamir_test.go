@@ -0,0 +1,133 @@
+package shamir_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"ctb-cli/crypto/shamir"
+	"strings"
+	"testing"
+)
+
+func newSecret(t *testing.T) []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func TestSplitAndCombineWithExactlyThresholdShares(t *testing.T) {
+	secret := newSecret(t)
+	shares, err := shamir.Split(secret, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("Expected 5 shares, got %d", len(shares))
+	}
+	// Every combination of 3 shares gives back the secret
+	for i := 0; i < 5; i++ {
+		for j := i + 1; j < 5; j++ {
+			for k := j + 1; k < 5; k++ {
+				combined, err := shamir.Combine([]shamir.Share{shares[k], shares[i], shares[j]})
+				if err != nil {
+					t.Fatalf("Combine of shares %d, %d, %d failed: %v", i, j, k, err)
+				}
+				if !bytes.Equal(combined, secret) {
+					t.Errorf("Combine of shares %d, %d, %d gave a different secret", i, j, k)
+				}
+			}
+		}
+	}
+	// Fewer shares than the threshold are not enough
+	if _, err := shamir.Combine(shares[:2]); err != shamir.ErrNotEnoughShares {
+		t.Errorf("Expected ErrNotEnoughShares, got %v", err)
+	}
+}
+
+func TestEncodeAndDecodeShares(t *testing.T) {
+	secret := newSecret(t)
+	shares, err := shamir.Split(secret, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBase58, err := shamir.DecodeShare(shares[0].Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := shares[2].Words()
+	if count := len(strings.Fields(words)); count != 32 {
+		t.Errorf("Expected the share of a 32-byte secret to be 32 words, got %d", count)
+	}
+	fromWords, err := shamir.DecodeShare(strings.ToUpper(words))
+	if err != nil {
+		t.Fatal(err)
+	}
+	combined, err := shamir.Combine([]shamir.Share{fromBase58, fromWords})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(combined, secret) {
+		t.Error("The decoded shares gave a different secret")
+	}
+}
+
+func TestCorruptedSharesAreRejected(t *testing.T) {
+	shares, err := shamir.Split(newSecret(t), 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A flipped bit anywhere in a share is detected by its checksum
+	data := shares[0].Bytes()
+	for i := range data {
+		corrupted := append([]byte{}, data...)
+		corrupted[i] ^= 0x10
+		if _, err := shamir.ParseShare(corrupted); err != shamir.ErrCorruptedShare {
+			t.Errorf("Share corrupted at byte %d: expected ErrCorruptedShare, got %v", i, err)
+		}
+	}
+	// A swapped word is detected
+	words := strings.Fields(shares[0].Words())
+	words[4], words[5] = words[5], words[4]
+	if _, err := shamir.DecodeShare(strings.Join(words, " ")); err != shamir.ErrCorruptedShare {
+		t.Errorf("Share with swapped words: expected ErrCorruptedShare, got %v", err)
+	}
+	// A share whose value was altered without updating its checksum gives a wrong secret, which is detected
+	altered := shares[1]
+	altered.Value = append([]byte{}, altered.Value...)
+	altered.Value[0] ^= 1
+	if _, err := shamir.Combine([]shamir.Share{shares[0], altered, shares[2]}); err != shamir.ErrInvalidShares {
+		t.Errorf("Expected ErrInvalidShares, got %v", err)
+	}
+}
+
+func TestMismatchedShares(t *testing.T) {
+	first, _ := shamir.Split(newSecret(t), 2, 3)
+	second, _ := shamir.Split(newSecret(t), 2, 3)
+	if _, err := shamir.Combine([]shamir.Share{first[0], second[1]}); err != shamir.ErrMismatchedShares {
+		t.Errorf("Expected ErrMismatchedShares, got %v", err)
+	}
+	if _, err := shamir.Combine([]shamir.Share{first[0], first[0]}); err != shamir.ErrDuplicateShare {
+		t.Errorf("Expected ErrDuplicateShare, got %v", err)
+	}
+}
+
+func TestSplitInvalidParameters(t *testing.T) {
+	secret := newSecret(t)
+	tests := []struct {
+		threshold, shares int
+		err               error
+	}{
+		{1, 5, shamir.ErrInvalidThreshold},
+		{6, 5, shamir.ErrInvalidThreshold},
+		{3, 256, shamir.ErrInvalidShareCount},
+	}
+	for _, test := range tests {
+		if _, err := shamir.Split(secret, test.threshold, test.shares); err != test.err {
+			t.Errorf("Split(%d of %d) returned %v, want %v", test.threshold, test.shares, err, test.err)
+		}
+	}
+	if _, err := shamir.Split(nil, 2, 3); err != shamir.ErrEmptySecret {
+		t.Errorf("Split of an empty secret returned %v, want %v", err, shamir.ErrEmptySecret)
+	}
+}
@@ -0,0 +1,105 @@
+package sign_crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"ctb-cli/core"
+	"errors"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// IdentityV1Info is the info string used for the proofs binding the signing identities to the X25519 keys.
+const IdentityV1Info = "cognitechbridge.com/v1/SigningIdentity"
+
+var (
+	ErrInvalidIdentityProof = errors.New("the signing identity is not bound to the public key of the user")
+)
+
+// IdentityPayload returns the payload of the proof of a signing identity: the X25519 public key of the user,
+// its signing key and its encapsulation key.
+func IdentityPayload(publicKey string, signingKey string, encapsulationKey string) []byte {
+	payload := make([]byte, 0, len(publicKey)+len(signingKey)+len(encapsulationKey)+2)
+	payload = append(payload, publicKey...)
+	payload = append(payload, 0)
+	payload = append(payload, signingKey...)
+	payload = append(payload, 0)
+	return append(payload, encapsulationKey...)
+}
+
+// ProveIdentity signs the message with the X25519 private key itself, using XEdDSA, so that anyone knowing
+// only the X25519 public key can check that the message comes from the owner of the private key.
+// It is used to bind the signing key derived from the private key to the public key.
+func ProveIdentity(privateKey core.PrivateKey, message []byte) ([]byte, error) {
+	a, err := edwards25519.NewScalar().SetBytesWithClamping(privateKey.Bytes())
+	if err != nil {
+		return nil, ErrInvalidSigningKey
+	}
+	// The public key of XEdDSA is the Edwards point of the X25519 key with a positive sign,
+	// so the scalar is negated if the point is negative
+	A := edwards25519.NewIdentityPoint().ScalarBaseMult(a)
+	if A.Bytes()[31]>>7 == 1 {
+		a.Negate(a)
+		A.Negate(A)
+	}
+	// The nonce is derived from the scalar, the message and random bytes
+	random := make([]byte, 64)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	nonceHash := sha512.New()
+	nonceHash.Write(hashPrefix)
+	nonceHash.Write(a.Bytes())
+	nonceHash.Write(message)
+	nonceHash.Write(random)
+	r, err := edwards25519.NewScalar().SetUniformBytes(nonceHash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	R := edwards25519.NewIdentityPoint().ScalarBaseMult(r)
+	// The signature is an Ed25519 signature for the public key A
+	challengeHash := sha512.New()
+	challengeHash.Write(R.Bytes())
+	challengeHash.Write(A.Bytes())
+	challengeHash.Write(message)
+	h, err := edwards25519.NewScalar().SetUniformBytes(challengeHash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	s := edwards25519.NewScalar().MultiplyAdd(h, a, r)
+	return append(R.Bytes(), s.Bytes()...), nil
+}
+
+// VerifyIdentity checks the proof of the message made by ProveIdentity with the private key of the X25519 public key.
+// It returns ErrInvalidIdentityProof if the proof is not valid.
+func VerifyIdentity(publicKey core.PublicKey, message []byte, proof []byte) error {
+	edwardsKey, err := edwardsPublicKey(publicKey)
+	if err != nil || !ed25519.Verify(edwardsKey, message, proof) {
+		return ErrInvalidIdentityProof
+	}
+	return nil
+}
+
+// hashPrefix is the prefix of the hash of the nonces of XEdDSA, which keeps them apart from the hashes of Ed25519.
+var hashPrefix = []byte{
+	0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+}
+
+// edwardsPublicKey converts the X25519 public key u to the Ed25519 public key y = (u - 1) / (u + 1),
+// with a positive sign.
+func edwardsPublicKey(publicKey core.PublicKey) (ed25519.PublicKey, error) {
+	u, err := new(field.Element).SetBytes(publicKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	one := new(field.Element).One()
+	denominator := new(field.Element).Add(u, one)
+	if denominator.Equal(new(field.Element).Zero()) == 1 {
+		return nil, ErrInvalidIdentityProof
+	}
+	y := new(field.Element).Multiply(new(field.Element).Subtract(u, one), new(field.Element).Invert(denominator))
+	return y.Bytes(), nil
+}
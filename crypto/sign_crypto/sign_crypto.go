@@ -0,0 +1,139 @@
+// Package sign_crypto implements the signatures of the users and the fingerprints of their keys.
+// The Ed25519 signing key of a user is derived from the X25519 private key of the user,
+// so that the user has a single secret to keep.
+// Every signature is bound to an info string describing what is signed, so that a signature
+// of one kind of record cannot be used for another one.
+package sign_crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"ctb-cli/crypto/mnemonic"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	Ed25519V1Info          = "cognitechbridge.com/v1/Ed25519"          // Ed25519V1Info is the info string used for deriving the signing key from the private key.
+	FingerprintV1Info      = "cognitechbridge.com/v1/Fingerprint"      // FingerprintV1Info is the info string used for computing the fingerprints.
+	DirectoryV1Info        = "cognitechbridge.com/v1/UserDirectory"    // DirectoryV1Info is the info string used for signing the user directory entries.
+	InvitationV1Info       = "cognitechbridge.com/v1/Invitation"       // InvitationV1Info is the info string used for signing the answers to the invitations.
+	InvitationRecordV1Info = "cognitechbridge.com/v1/InvitationRecord" // InvitationRecordV1Info is the info string used for signing the invitations.
+	ShareExpiryV1Info      = "cognitechbridge.com/v1/ShareExpiry"      // ShareExpiryV1Info is the info string used for signing the expiries of the shares.
+	ObjectV1Info           = "cognitechbridge.com/v1/ObjectHeader"     // ObjectV1Info is the info string used for signing the headers of the objects.
+	VaultV1Info            = "cognitechbridge.com/v1/Vault"            // VaultV1Info is the info string used for signing the vaults.
+	VaultKeyV1Info         = "cognitechbridge.com/v1/VaultKey"         // VaultKeyV1Info is the info string used for signing the keys sealed in the vaults.
+	ShareV1Info            = "cognitechbridge.com/v1/Share"            // ShareV1Info is the info string used for signing the shared data keys.
+	GroupV1Info            = "cognitechbridge.com/v1/Group"            // GroupV1Info is the info string used for signing the groups.
+	UserV1Info             = "cognitechbridge.com/v1/User"             // UserV1Info is the info string used for signing the user records.
+	DeviceV1Info           = "cognitechbridge.com/v1/Device"           // DeviceV1Info is the info string used for signing the endorsements of the devices.
+	DeviceConsentV1Info    = "cognitechbridge.com/v1/DeviceConsent"    // DeviceConsentV1Info is the info string used for signing the consents of the devices.
+	PolicyV1Info           = "cognitechbridge.com/v1/Policy"           // PolicyV1Info is the info string used for signing the policy of the repository.
+
+	// SafetyWordCount is the number of safety words of a fingerprint.
+	SafetyWordCount = 6
+)
+
+var (
+	ErrInvalidSignature  = errors.New("invalid signature")
+	ErrInvalidSigningKey = errors.New("invalid signing key")
+)
+
+// DeriveSigningKey derives the Ed25519 signing key of the user from the X25519 private key using HKDF and SHA-256.
+func DeriveSigningKey(privateKey core.PrivateKey) (ed25519.PrivateKey, error) {
+	if len(privateKey.Bytes()) == 0 {
+		return nil, ErrInvalidSigningKey
+	}
+	hk := hkdf.New(sha256.New, privateKey.Bytes(), nil, []byte(Ed25519V1Info))
+	seed := make([]byte, ed25519.SeedSize)
+	defer clear(seed)
+	if _, err := io.ReadFull(hk, seed); err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// SignedMessage returns the message actually signed for the payload: the info string, a zero byte, and the payload.
+func SignedMessage(info string, payload []byte) []byte {
+	message := make([]byte, 0, len(info)+1+len(payload))
+	message = append(message, info...)
+	message = append(message, 0)
+	return append(message, payload...)
+}
+
+// Sign signs the payload with the signing key and returns the encoded signature.
+func Sign(signingKey ed25519.PrivateKey, info string, payload []byte) string {
+	return EncodeSignature(ed25519.Sign(signingKey, SignedMessage(info, payload)))
+}
+
+// Verify verifies the encoded signature of the payload with the encoded signing public key.
+// It returns ErrInvalidSignature if the signature is not valid.
+func Verify(encodedPublicKey string, info string, payload []byte, signature string) error {
+	publicKey, err := DecodeSigningPublicKey(encodedPublicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.RawStdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(publicKey, SignedMessage(info, payload), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// RecordPayload returns the payload signed for a record written by the writer: the writer, its signing key and the record.
+func RecordPayload(writer string, signerKey string, record []byte) []byte {
+	payload := make([]byte, 0, len(writer)+len(signerKey)+len(record)+2)
+	payload = append(payload, writer...)
+	payload = append(payload, 0)
+	payload = append(payload, signerKey...)
+	payload = append(payload, 0)
+	return append(payload, record...)
+}
+
+// EncodeSignature encodes a signature.
+func EncodeSignature(signature []byte) string {
+	return base64.RawStdEncoding.EncodeToString(signature)
+}
+
+// EncodeSigningPublicKey encodes a signing public key, like the X25519 public keys.
+func EncodeSigningPublicKey(publicKey ed25519.PublicKey) string {
+	return core.NewPublicKeyFromBytes(publicKey).String()
+}
+
+// DecodeSigningPublicKey decodes a signing public key encoded by EncodeSigningPublicKey.
+func DecodeSigningPublicKey(encoded string) (ed25519.PublicKey, error) {
+	publicKey, err := core.NewPublicKeyFromEncoded(encoded)
+	if err != nil || len(publicKey.Bytes()) != ed25519.PublicKeySize {
+		return nil, ErrInvalidSigningKey
+	}
+	return publicKey.Bytes(), nil
+}
+
+// Fingerprint returns the short fingerprint of the public key: 16 hexadecimal digits in groups of 4,
+// to be compared out of band with the fingerprint shown to the owner of the key.
+func Fingerprint(publicKey core.PublicKey) string {
+	hash := fingerprintHash(publicKey)
+	encoded := hex.EncodeToString(hash[:8])
+	groups := make([]string, 0, 4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// SafetyWords returns the fingerprint of the public key as words, which are easier to compare aloud.
+func SafetyWords(publicKey core.PublicKey) string {
+	hash := fingerprintHash(publicKey)
+	return strings.Join(mnemonic.Words(hash[:], SafetyWordCount), " ")
+}
+
+// fingerprintHash returns the hash of the public key used for the fingerprints.
+func fingerprintHash(publicKey core.PublicKey) [sha256.Size]byte {
+	return sha256.Sum256(SignedMessage(FingerprintV1Info, publicKey.Bytes()))
+}
@@ -0,0 +1,87 @@
+package sign_crypto_test
+
+import (
+	"crypto/ed25519"
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey, err := sign_crypto.DeriveSigningKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The signing key is derived deterministically from the private key
+	again, _ := sign_crypto.DeriveSigningKey(privateKey)
+	if !signingKey.Equal(again) {
+		t.Error("Deriving the signing key twice gave different keys")
+	}
+	publicKey := sign_crypto.EncodeSigningPublicKey(signingKey.Public().(ed25519.PublicKey))
+
+	payload := []byte("payload")
+	signature := sign_crypto.Sign(signingKey, "info", payload)
+	if err := sign_crypto.Verify(publicKey, "info", payload, signature); err != nil {
+		t.Errorf("Valid signature rejected: %v", err)
+	}
+	// The signature is bound to the payload and the info string
+	if err := sign_crypto.Verify(publicKey, "info", []byte("other"), signature); err != sign_crypto.ErrInvalidSignature {
+		t.Errorf("Signature of another payload accepted: %v", err)
+	}
+	if err := sign_crypto.Verify(publicKey, "other", payload, signature); err != sign_crypto.ErrInvalidSignature {
+		t.Errorf("Signature with another info string accepted: %v", err)
+	}
+	// The signature is bound to the signing key
+	otherPrivateKey, _ := core.NewPrivateKeyFromRand()
+	otherSigningKey, _ := sign_crypto.DeriveSigningKey(otherPrivateKey)
+	otherPublicKey := sign_crypto.EncodeSigningPublicKey(otherSigningKey.Public().(ed25519.PublicKey))
+	if err := sign_crypto.Verify(otherPublicKey, "info", payload, signature); err != sign_crypto.ErrInvalidSignature {
+		t.Errorf("Signature verified with another key: %v", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	publicKey := core.NewPublicKeyFromBytes(make([]byte, 32))
+	fingerprint := sign_crypto.Fingerprint(publicKey)
+	if len(fingerprint) != 19 || strings.Count(fingerprint, " ") != 3 {
+		t.Errorf("Invalid fingerprint format: %q", fingerprint)
+	}
+	words := strings.Fields(sign_crypto.SafetyWords(publicKey))
+	if len(words) != sign_crypto.SafetyWordCount {
+		t.Errorf("Expected %d safety words, got %q", sign_crypto.SafetyWordCount, words)
+	}
+	// Different keys have different fingerprints
+	other := core.NewPublicKeyFromBytes(append(make([]byte, 31), 1))
+	if sign_crypto.Fingerprint(other) == fingerprint || sign_crypto.SafetyWords(other) == sign_crypto.SafetyWords(publicKey) {
+		t.Error("Different keys have the same fingerprint")
+	}
+}
+
+func TestIdentityProof(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		privateKey, _ := core.NewPrivateKeyFromRand()
+		publicKey, _ := privateKey.ToPublicKey()
+		message := sign_crypto.SignedMessage(sign_crypto.IdentityV1Info, []byte("identity"))
+		proof, err := sign_crypto.ProveIdentity(privateKey, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sign_crypto.VerifyIdentity(publicKey, message, proof); err != nil {
+			t.Fatalf("Valid proof rejected: %v", err)
+		}
+		// The proof is bound to the message and to the public key
+		if err := sign_crypto.VerifyIdentity(publicKey, []byte("other"), proof); err != sign_crypto.ErrInvalidIdentityProof {
+			t.Errorf("Proof of another message accepted: %v", err)
+		}
+		otherPrivateKey, _ := core.NewPrivateKeyFromRand()
+		otherPublicKey, _ := otherPrivateKey.ToPublicKey()
+		if err := sign_crypto.VerifyIdentity(otherPublicKey, message, proof); err != sign_crypto.ErrInvalidIdentityProof {
+			t.Errorf("Proof verified with another public key: %v", err)
+		}
+	}
+}
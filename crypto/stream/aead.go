@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The AEAD algorithms of the STREAM, named as in the file headers
+const (
+	ChaCha20Poly1305 = "AEAD_ChaCha20_Poly1305"
+	AES256GCM        = "AEAD_AES_256_GCM"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported encryption algorithm")
+	ErrInvalidKeySize       = errors.New("invalid key size")
+)
+
+// algorithmNames maps the short names of the algorithms, used in the configuration, to the algorithms.
+var algorithmNames = map[string]string{
+	"chacha20-poly1305": ChaCha20Poly1305,
+	"aes-256-gcm":       AES256GCM,
+}
+
+// ParseAlgorithm returns the algorithm with the given name, either its short name
+// ("chacha20-poly1305" or "aes-256-gcm") or its name in the file headers.
+func ParseAlgorithm(name string) (string, error) {
+	if alg, ok := algorithmNames[name]; ok {
+		return alg, nil
+	}
+	if name == ChaCha20Poly1305 || name == AES256GCM {
+		return name, nil
+	}
+	return "", ErrUnsupportedAlgorithm
+}
+
+// NewAEAD returns the AEAD of the algorithm with the 32-byte key.
+// Both algorithms use a 12-byte nonce and a 16-byte tag, so they follow the same chunking rules.
+func NewAEAD(alg string, key []byte) (cipher.AEAD, error) {
+	switch alg {
+	case ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	case AES256GCM:
+		// AES-128 and AES-192 keys are accepted by aes.NewCipher, only AES-256 is allowed
+		if len(key) != 32 {
+			return nil, ErrInvalidKeySize
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
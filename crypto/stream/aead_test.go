@@ -0,0 +1,132 @@
+package stream_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"ctb-cli/crypto/stream"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// TestAEADRoundTrip tests the round trip of both algorithms, with sequential and random access reads
+func TestAEADRoundTrip(t *testing.T) {
+	for _, alg := range []string{stream.ChaCha20Poly1305, stream.AES256GCM} {
+		for _, length := range []int{0, 1000, cs, cs + 100, 3 * cs} {
+			t.Run(fmt.Sprintf("alg=%s,len=%d", alg, length), func(t *testing.T) { testAEADRoundTrip(t, alg, length) })
+		}
+	}
+}
+
+func testAEADRoundTrip(t *testing.T, alg string, length int) {
+	src := make([]byte, length)
+	if _, err := rand.Read(src); err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	ad := []byte("associated data")
+	aead, err := stream.NewAEAD(alg, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := stream.NewWriterWithAEAD(aead, ad, buf)
+	if _, err := w.Write(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := bytes.Clone(buf.Bytes())
+	chunks := max(1, (length+cs-1)/cs)
+	if expected := length + chunks*16; len(encrypted) != expected {
+		t.Errorf("Expected %d encrypted bytes, got %d", expected, len(encrypted))
+	}
+
+	// Sequential read
+	readBuf, err := io.ReadAll(stream.NewReaderWithAEAD(aead, ad, buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readBuf, src) {
+		t.Error("Original and read data do not match")
+	}
+
+	// Random access read
+	ra, err := stream.NewReaderAtWithAEAD(aead, ad, bytes.NewReader(encrypted), int64(len(encrypted)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.Size() != int64(length) {
+		t.Errorf("Expected size %d, got %d", length, ra.Size())
+	}
+	if length > 0 {
+		ofst := length / 3
+		part := make([]byte, length-ofst)
+		if _, err := ra.ReadAt(part, int64(ofst)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(part, src[ofst:]) {
+			t.Error("Original and random access read data do not match")
+		}
+	}
+
+	// The associated data is authenticated
+	_, err = io.ReadAll(stream.NewReaderWithAEAD(aead, []byte("other data"), bytes.NewReader(encrypted)))
+	if err == nil {
+		t.Error("Expected an error with other associated data")
+	}
+}
+
+// TestAlgorithmMismatch tests that a stream cannot be decrypted with the other algorithm
+func TestAlgorithmMismatch(t *testing.T) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := stream.NewAEAD(stream.AES256GCM, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chacha, err := stream.NewAEAD(stream.ChaCha20Poly1305, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	w := stream.NewWriterWithAEAD(gcm, nil, buf)
+	if _, err := w.Write([]byte("content")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(stream.NewReaderWithAEAD(chacha, nil, buf)); err == nil {
+		t.Error("Expected an error when decrypting with the other algorithm")
+	}
+}
+
+// TestNewAEAD tests the parsing of the algorithms and the key size check
+func TestNewAEAD(t *testing.T) {
+	for name, expected := range map[string]string{
+		"chacha20-poly1305":     stream.ChaCha20Poly1305,
+		"aes-256-gcm":           stream.AES256GCM,
+		stream.AES256GCM:        stream.AES256GCM,
+		stream.ChaCha20Poly1305: stream.ChaCha20Poly1305,
+	} {
+		alg, err := stream.ParseAlgorithm(name)
+		if err != nil || alg != expected {
+			t.Errorf("ParseAlgorithm(%q) = %q, %v, expected %q", name, alg, err, expected)
+		}
+	}
+	if _, err := stream.ParseAlgorithm("aes-128-gcm"); !errors.Is(err, stream.ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+	if _, err := stream.NewAEAD(stream.AES256GCM, make([]byte, 16)); !errors.Is(err, stream.ErrInvalidKeySize) {
+		t.Errorf("Expected ErrInvalidKeySize, got %v", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 
 	"golang.org/x/crypto/chacha20poly1305"
 )
@@ -19,6 +20,7 @@ const ChunkSize = 64 * 1024
 type Reader struct {
 	a   cipher.AEAD
 	src io.Reader
+	ad  []byte // associated data authenticated with every chunk
 
 	unread []byte // decrypted but unread data, backed by buf
 	buf    [encChunkSize]byte
@@ -37,10 +39,18 @@ func NewReader(key []byte, src io.Reader) (*Reader, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewReaderWithAEAD(aead, nil, src), nil
+}
+
+// NewReaderWithAEAD returns a Reader like NewReader, which decrypts with the AEAD (see NewAEAD)
+// and authenticates the associated data with every chunk.
+// The AEAD and the associated data must be the ones given to NewWriterWithAEAD.
+func NewReaderWithAEAD(aead cipher.AEAD, ad []byte, src io.Reader) *Reader {
 	return &Reader{
 		a:   aead,
 		src: src,
-	}, nil
+		ad:  ad,
+	}
 }
 
 func (r *Reader) Read(p []byte) (int, error) {
@@ -110,12 +120,12 @@ func (r *Reader) readChunk() (last bool, err error) {
 	}
 
 	outBuf := make([]byte, 0, ChunkSize)
-	out, err := r.a.Open(outBuf, r.nonce[:], in, nil)
+	out, err := r.a.Open(outBuf, r.nonce[:], in, r.ad)
 	if err != nil && !last {
 		// Check if this was a full-length final chunk.
 		last = true
 		setLastChunkFlag(&r.nonce)
-		out, err = r.a.Open(outBuf, r.nonce[:], in, nil)
+		out, err = r.a.Open(outBuf, r.nonce[:], in, r.ad)
 	}
 	if err != nil {
 		return false, errors.New("failed to decrypt and authenticate payload chunk")
@@ -149,6 +159,7 @@ func nonceIsZero(nonce *[chacha20poly1305.NonceSize]byte) bool {
 type Writer struct {
 	a         cipher.AEAD
 	dst       io.Writer
+	ad        []byte // associated data authenticated with every chunk
 	unwritten []byte // backed by buf
 	buf       [encChunkSize]byte
 	nonce     [chacha20poly1305.NonceSize]byte
@@ -160,12 +171,19 @@ func NewWriter(key []byte, dst io.Writer) (*Writer, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewWriterWithAEAD(aead, nil, dst), nil
+}
+
+// NewWriterWithAEAD returns a Writer like NewWriter, which encrypts with the AEAD (see NewAEAD)
+// and authenticates the associated data with every chunk. The associated data is not written.
+func NewWriterWithAEAD(aead cipher.AEAD, ad []byte, dst io.Writer) *Writer {
 	w := &Writer{
 		a:   aead,
 		dst: dst,
+		ad:  ad,
 	}
 	w.unwritten = w.buf[:0]
-	return w, nil
+	return w
 }
 
 func (w *Writer) Write(p []byte) (n int, err error) {
@@ -222,9 +240,133 @@ func (w *Writer) flushChunk(last bool) error {
 	if last {
 		setLastChunkFlag(&w.nonce)
 	}
-	buf := w.a.Seal(w.buf[:0], w.nonce[:], w.unwritten, nil)
+	buf := w.a.Seal(w.buf[:0], w.nonce[:], w.unwritten, w.ad)
 	_, err := w.dst.Write(buf)
 	w.unwritten = w.buf[:0]
 	incNonce(&w.nonce)
 	return err
 }
+
+// ReaderAt decrypts a STREAM ciphertext with random access.
+// Only the chunks covering the requested range are decrypted and authenticated.
+// The size of the ciphertext must be known, so the last chunk can be identified
+// by its position and a truncated ciphertext is detected.
+type ReaderAt struct {
+	a          cipher.AEAD
+	src        io.ReaderAt
+	ad         []byte // associated data authenticated with every chunk
+	size       int64  // size of the ciphertext
+	plainBytes int64  // size of the plaintext
+	chunkCount int64  // number of chunks, the last one is flagged
+
+	mu        sync.Mutex
+	cached    []byte // plaintext of the last decrypted chunk, backed by buf
+	cachedIdx int64  // index of the last decrypted chunk, or -1
+	buf       [encChunkSize]byte
+}
+
+// NewReaderAt returns a ReaderAt that decrypts the ciphertext of the given size read from src.
+// It returns an error if the size is not a valid ciphertext size.
+func NewReaderAt(key []byte, src io.ReaderAt, size int64) (*ReaderAt, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewReaderAtWithAEAD(aead, nil, src, size)
+}
+
+// NewReaderAtWithAEAD returns a ReaderAt like NewReaderAt, which decrypts with the AEAD (see NewAEAD)
+// and authenticates the associated data with every chunk.
+// The AEAD and the associated data must be the ones given to NewWriterWithAEAD.
+func NewReaderAtWithAEAD(aead cipher.AEAD, ad []byte, src io.ReaderAt, size int64) (*ReaderAt, error) {
+	// A message has at least one chunk, which can be empty only if it's the only chunk
+	if size < int64(aead.Overhead()) {
+		return nil, errors.New("encrypted size is too small")
+	}
+	chunkCount := (size + encChunkSize - 1) / encChunkSize
+	lastChunkSize := size - (chunkCount-1)*encChunkSize
+	if lastChunkSize < int64(aead.Overhead()) || (chunkCount > 1 && lastChunkSize == int64(aead.Overhead())) {
+		return nil, errors.New("last chunk is empty or truncated")
+	}
+	return &ReaderAt{
+		a:          aead,
+		src:        src,
+		ad:         ad,
+		size:       size,
+		cachedIdx:  -1,
+		plainBytes: size - chunkCount*int64(aead.Overhead()),
+		chunkCount: chunkCount,
+	}, nil
+}
+
+// Size returns the size of the plaintext.
+func (r *ReaderAt) Size() int64 {
+	return r.plainBytes
+}
+
+// ReadAt reads len(p) bytes of plaintext starting at offset off.
+// It returns io.EOF if fewer than len(p) bytes are available.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.plainBytes {
+		return 0, io.EOF
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for n < len(p) && off < r.plainBytes {
+		idx := off / ChunkSize
+		chunk, err := r.readChunk(idx)
+		if err != nil {
+			return n, err
+		}
+		nn := copy(p[n:], chunk[off-idx*ChunkSize:])
+		n += nn
+		off += int64(nn)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readChunk decrypts and authenticates the chunk with the given index and returns its plaintext.
+// The last decrypted chunk is kept, so sequential reads do not decrypt a chunk twice.
+func (r *ReaderAt) readChunk(idx int64) ([]byte, error) {
+	if idx == r.cachedIdx {
+		return r.cached, nil
+	}
+	// Read the ciphertext of the chunk
+	off := idx * encChunkSize
+	encSize := int64(encChunkSize)
+	if off+encSize > r.size {
+		encSize = r.size - off
+	}
+	in := r.buf[:encSize]
+	if _, err := r.src.ReadAt(in, off); err != nil && err != io.EOF {
+		return nil, err
+	}
+	// Compute the nonce of the chunk, the last chunk is flagged
+	var nonce [chacha20poly1305.NonceSize]byte
+	setChunkCounter(&nonce, idx)
+	if idx == r.chunkCount-1 {
+		setLastChunkFlag(&nonce)
+	}
+	out, err := r.a.Open(in[:0], nonce[:], in, r.ad)
+	if err != nil {
+		r.cachedIdx = -1
+		return nil, errors.New("failed to decrypt and authenticate payload chunk")
+	}
+	r.cached = out
+	r.cachedIdx = idx
+	return out, nil
+}
+
+// setChunkCounter sets the counter part of the nonce to the chunk index.
+func setChunkCounter(nonce *[chacha20poly1305.NonceSize]byte, idx int64) {
+	for i := len(nonce) - 2; i >= 0 && idx > 0; i-- {
+		nonce[i] = byte(idx)
+		idx >>= 8
+	}
+}
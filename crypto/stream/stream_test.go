@@ -9,6 +9,7 @@ import (
 	"crypto/rand"
 	"ctb-cli/crypto/stream"
 	"fmt"
+	"io"
 	"testing"
 	
 	"golang.org/x/crypto/chacha20poly1305"
@@ -91,3 +92,92 @@ func testRoundTrip(t *testing.T, stepSize, length int) {
 		n += nn
 	}
 }
+
+func encrypt(t *testing.T, key []byte, src []byte) []byte {
+	buf := &bytes.Buffer{}
+	w, err := stream.NewWriter(key, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(src); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReaderAt(t *testing.T) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	for _, length := range []int{0, 1000, cs, cs + 100, 3 * cs} {
+		src := make([]byte, length)
+		if _, err := rand.Read(src); err != nil {
+			t.Fatal(err)
+		}
+		enc := encrypt(t, key, src)
+		r, err := stream.NewReaderAt(key, bytes.NewReader(enc), int64(len(enc)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Size() != int64(length) {
+			t.Errorf("Size returned %d, expected %d", r.Size(), length)
+		}
+		for _, off := range []int{0, 1, cs - 1, cs, cs + 50, 2*cs + 7} {
+			for _, size := range []int{1, 100, cs, 2 * cs} {
+				buf := make([]byte, size)
+				n, err := r.ReadAt(buf, int64(off))
+				expected := 0
+				if off < length {
+					expected = min(size, length-off)
+				}
+				if n != expected {
+					t.Fatalf("len=%d off=%d size=%d: ReadAt returned %d, expected %d", length, off, size, n, expected)
+				}
+				if n < size && err != io.EOF {
+					t.Errorf("len=%d off=%d size=%d: expected io.EOF, got %v", length, off, size, err)
+				}
+				if n == size && err != nil {
+					t.Errorf("len=%d off=%d size=%d: unexpected error %v", length, off, size, err)
+				}
+				if n > 0 && !bytes.Equal(buf[:n], src[off:off+n]) {
+					t.Errorf("len=%d off=%d size=%d: wrong data", length, off, size)
+				}
+			}
+		}
+	}
+}
+
+func TestReaderAtTruncated(t *testing.T) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	src := make([]byte, 2*cs)
+	enc := encrypt(t, key, src)
+	// Drop the last chunk: the new last chunk is not flagged and must not authenticate
+	truncated := enc[:cs+chacha20poly1305.Overhead]
+	r, err := stream.NewReaderAt(key, bytes.NewReader(truncated), int64(len(truncated)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(make([]byte, 10), 0); err == nil {
+		t.Error("Expected an error reading a truncated stream")
+	}
+	// A tampered chunk must not authenticate
+	tampered := bytes.Clone(enc)
+	tampered[cs+chacha20poly1305.Overhead+10] ^= 1
+	r, err = stream.NewReaderAt(key, bytes.NewReader(tampered), int64(len(tampered)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(make([]byte, 10), 0); err != nil {
+		t.Errorf("Unexpected error reading an untouched chunk: %v", err)
+	}
+	if _, err := r.ReadAt(make([]byte, 10), cs); err == nil {
+		t.Error("Expected an error reading a tampered chunk")
+	}
+}
@@ -48,7 +48,14 @@ func New(fs core.FileSystemService) *CtbFs {
 	}
 	defer c.synchronize()()
 	modePerm := fs.GetUserFileAccess("/", true)
+	metadata, err := fs.GetMetadata("/")
+	if err == nil {
+		modePerm &= os.FileMode(metadata.Mode) & os.ModePerm
+	}
 	c.root = c.newNode(0, true, "/", uint32(modePerm))
+	if err == nil {
+		applyMetadata(c.root, metadata)
+	}
 	return &c
 }
 
@@ -172,6 +179,9 @@ func (c *CtbFs) exploreDir(path string) (err error) {
 			node := c.newNode(0, info.IsDir(), path, uint32(info.Mode()))
 			node.path = join(path, info.Name())
 			node.stat.Size = info.Size()
+			if metadata, ok := info.Sys().(*core.FileMetadata); ok && metadata != nil {
+				applyMetadata(node, metadata)
+			}
 			parent.chld[info.Name()] = node
 		}
 	}
@@ -431,7 +441,7 @@ func (c *CtbFs) Chmod(path string, mode uint32) (errc int) {
 	}
 	node.stat.Mode = (node.stat.Mode & fuse.S_IFMT) | mode&07777
 	node.stat.Ctim = fuse.Now()
-	return 0
+	return c.persistMetadata(path, node)
 }
 
 func (c *CtbFs) Chown(path string, uid uint32, gid uint32) (errc int) {
@@ -468,7 +478,7 @@ func (c *CtbFs) Utimens(path string, tmsp []fuse.Timespec) (errc int) {
 	}
 	node.stat.Atim = tmsp[0]
 	node.stat.Mtim = tmsp[1]
-	return 0
+	return c.persistMetadata(path, node)
 }
 
 func (c *CtbFs) Open(path string, flags int) (errc int, fh uint64) {
@@ -560,7 +570,7 @@ func (c *CtbFs) Setxattr(path string, name string, value []byte, flags int) (err
 		node.xatr = map[string][]byte{}
 	}
 	node.xatr[name] = xatr
-	return 0
+	return c.persistMetadata(path, node)
 }
 
 func (c *CtbFs) Getxattr(path string, name string) (errc int, xatr []byte) {
@@ -600,7 +610,7 @@ func (c *CtbFs) Removexattr(path string, name string) (errc int) {
 		return -fuse.ENOATTR
 	}
 	delete(node.xatr, name)
-	return 0
+	return c.persistMetadata(path, node)
 }
 
 func (c *CtbFs) Listxattr(path string, fill func(name string) bool) (errc int) {
@@ -630,7 +640,7 @@ func (c *CtbFs) Chflags(path string, flags uint32) (errc int) {
 	}
 	node.stat.Flags = flags
 	node.stat.Ctim = fuse.Now()
-	return 0
+	return c.persistMetadata(path, node)
 }
 
 func (c *CtbFs) Setcrtime(path string, tmsp fuse.Timespec) (errc int) {
@@ -643,7 +653,7 @@ func (c *CtbFs) Setcrtime(path string, tmsp fuse.Timespec) (errc int) {
 	}
 	node.stat.Birthtim = tmsp
 	node.stat.Ctim = fuse.Now()
-	return 0
+	return c.persistMetadata(path, node)
 }
 
 func (c *CtbFs) Setchgtime(path string, tmsp fuse.Timespec) (errc int) {
@@ -655,7 +665,7 @@ func (c *CtbFs) Setchgtime(path string, tmsp fuse.Timespec) (errc int) {
 		return -fuse.ENOENT
 	}
 	node.stat.Ctim = tmsp
-	return 0
+	return c.persistMetadata(path, node)
 }
 
 func (c *CtbFs) synchronize() func() {
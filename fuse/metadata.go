@@ -0,0 +1,42 @@
+package fuse
+
+import (
+	"ctb-cli/core"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/winfsp/cgofuse/fuse"
+)
+
+// applyMetadata sets the times, flags and extended attributes of the node from the file metadata.
+// The mode bits are already applied by the file system service in the file info.
+func applyMetadata(node *Node, metadata *core.FileMetadata) {
+	node.stat.Mtim = fuse.NewTimespec(metadata.ModTime)
+	node.stat.Atim = node.stat.Mtim
+	node.stat.Ctim = fuse.NewTimespec(metadata.ChangeTime)
+	node.stat.Birthtim = fuse.NewTimespec(metadata.BirthTime)
+	node.stat.Flags = metadata.Flags
+	if len(metadata.Xattrs) > 0 {
+		node.xatr = make(map[string][]byte, len(metadata.Xattrs))
+		for name, value := range metadata.Xattrs {
+			node.xatr[name] = value
+		}
+	}
+}
+
+// persistMetadata stores the times, mode bits, flags and extended attributes of the node in the metadata
+// of the file or directory, so they are kept after the file system is mounted again.
+func (c *CtbFs) persistMetadata(path string, node *Node) int {
+	err := c.fs.UpdateMetadata(path, func(metadata *core.FileMetadata) {
+		metadata.ModTime = node.stat.Mtim.Time()
+		metadata.ChangeTime = node.stat.Ctim.Time()
+		metadata.BirthTime = node.stat.Birthtim.Time()
+		metadata.Mode = node.stat.Mode & 07777
+		metadata.Flags = node.stat.Flags
+		metadata.Xattrs = node.xatr
+	})
+	if err != nil {
+		log.Error("Error storing metadata of node: ", path, ". error: ", err)
+		return -fuse.EIO
+	}
+	return 0
+}
@@ -49,9 +49,9 @@ func (d *downloader) Download() error {
 	}
 
 	// Spin up workers
-	ch := make(chan dlchunk, Concurrency)
+	ch := make(chan dlchunk, d.client.concurrency)
 
-	for i := 0; i < Concurrency; i++ {
+	for i := 0; i < d.client.concurrency; i++ {
 		d.wg.Add(1)
 		go d.downloadPart(ch)
 	}
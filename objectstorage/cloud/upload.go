@@ -38,8 +38,8 @@ func (c *Client) Upload(reader io.Reader, fileId string) error {
 func (u *Uploader) Upload() error {
 	partNumber := int32(1)
 
-	ch := make(chan chunk, Concurrency)
-	for i := 0; i < Concurrency; i++ {
+	ch := make(chan chunk, u.client.concurrency)
+	for i := 0; i < u.client.concurrency; i++ {
 		u.wg.Add(1)
 		go u.readChunk(ch)
 	}
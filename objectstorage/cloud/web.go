@@ -2,19 +2,26 @@ package cloud
 
 import "net/http"
 
-const Concurrency = 5
+const (
+	DefaultURL         = "http://localhost:1323" // DefaultURL is the URL of the cloud service used when no endpoint is set.
+	DefaultChunkSize   = 10 * 1024 * 1024        // DefaultChunkSize is the size of the parts of the transfers.
+	DefaultConcurrency = 5                       // DefaultConcurrency is the number of parts transferred at the same time.
+)
 
 type Client struct {
-	baseURL    string
-	chunkSize  uint64
-	httpClient *http.Client
+	baseURL     string
+	chunkSize   uint64
+	concurrency int
+	httpClient  *http.Client
 }
 
 // NewClient NewUploaderClient creates a new Client.
-func NewClient(baseURL string, chunkSize uint64) *Client {
+// The objects are transferred in parts of chunkSize bytes, concurrency parts at the same time.
+func NewClient(baseURL string, chunkSize uint64, concurrency int) *Client {
 	return &Client{
-		baseURL:    baseURL,
-		chunkSize:  chunkSize,
-		httpClient: &http.Client{},
+		baseURL:     baseURL,
+		chunkSize:   chunkSize,
+		concurrency: concurrency,
+		httpClient:  &http.Client{},
 	}
 }
@@ -0,0 +1,136 @@
+// Package local implements an object storage in a local directory, for offline use, tests and network shares.
+// The objects are stored in a sharded layout, root/ab/cd/abcd..., so that no directory holds too many files.
+// An object is written to a temporary file that is synced and then renamed, so a crash never leaves a partial object.
+package local
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	shardLevels = 2 // shardLevels is the number of directory levels of the layout.
+	shardWidth  = 2 // shardWidth is the number of characters of the id used for each level.
+
+	tempPrefix = ".upload-" // tempPrefix is the prefix of the temporary files of the uploads in progress.
+)
+
+var (
+	ErrInvalidObjectId = errors.New("invalid object id")
+	ErrObjectNotFound  = errors.New("object not found in the local storage")
+)
+
+// Client stores the objects in a local directory.
+type Client struct {
+	root string
+}
+
+// NewClient returns a new Client storing the objects in the root directory.
+// The directory is created on the first upload if it does not exist.
+func NewClient(root string) *Client {
+	return &Client{
+		root: root,
+	}
+}
+
+// Upload writes the content of the reader as the object fileId.
+// An existing object with the same id is replaced atomically.
+func (c *Client) Upload(reader io.Reader, fileId string) error {
+	path, err := c.objectPath(fileId)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// Write in a temporary file of the same directory, so that the rename is atomic
+	temp, err := os.CreateTemp(dir, tempPrefix+fileId+"-*")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	if err := writeAndSync(temp, reader); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	// Sync the directory so that the rename survives a crash
+	return syncDir(dir)
+}
+
+// Download writes the content of the object fileId to writeAt, starting at offset 0.
+// It returns ErrObjectNotFound if the object is not in the storage.
+func (c *Client) Download(fileId string, writeAt io.WriterAt) error {
+	path, err := c.objectPath(fileId)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrObjectNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(io.NewOffsetWriter(writeAt, 0), file)
+	return err
+}
+
+// Remove removes the object fileId. Removing an object which is not in the storage is not an error.
+func (c *Client) Remove(fileId string) error {
+	path, err := c.objectPath(fileId)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// objectPath returns the path of the object in the sharded layout.
+func (c *Client) objectPath(fileId string) (string, error) {
+	if len(fileId) < shardLevels*shardWidth || strings.ContainsAny(fileId, `/\.`) {
+		return "", ErrInvalidObjectId
+	}
+	parts := []string{c.root}
+	for i := 0; i < shardLevels; i++ {
+		parts = append(parts, fileId[i*shardWidth:(i+1)*shardWidth])
+	}
+	return filepath.Join(append(parts, fileId)...), nil
+}
+
+// writeAndSync copies the reader to the file, syncs and closes it.
+func writeAndSync(file *os.File, reader io.Reader) error {
+	if _, err := io.Copy(file, reader); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir syncs the directory entries. It is not supported on every platform, so failures to open
+// the directory for syncing are ignored.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) && !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	return nil
+}
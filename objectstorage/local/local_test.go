@@ -0,0 +1,85 @@
+package local
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buffer is an io.WriterAt writing in memory.
+type buffer struct {
+	data []byte
+}
+
+func (b *buffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	return copy(b.data[off:], p), nil
+}
+
+func TestUploadDownload(t *testing.T) {
+	root := t.TempDir()
+	client := NewClient(root)
+	id := "4vJ9JU1bJJE96FWSJKvHsmmFADCg4gpZQff4P3bkLKi"
+
+	if err := client.Upload(bytes.NewReader([]byte("first")), id); err != nil {
+		t.Fatal(err)
+	}
+	// The object is replaced by a new upload with the same id
+	content := bytes.Repeat([]byte("content"), 100000)
+	if err := client.Upload(bytes.NewReader(content), id); err != nil {
+		t.Fatal(err)
+	}
+	var downloaded buffer
+	if err := client.Download(id, &downloaded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded.data, content) {
+		t.Error("The downloaded object does not match the uploaded one")
+	}
+
+	// The object is in the sharded layout, without temporary files left
+	if _, err := os.Stat(filepath.Join(root, "4v", "J9", id)); err != nil {
+		t.Errorf("The object is not in the sharded layout: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "4v", "J9"))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the object in its directory, got %d entries", len(entries))
+	}
+}
+
+func TestDownloadMissing(t *testing.T) {
+	client := NewClient(t.TempDir())
+	if err := client.Download("4vJ9JU1bJJE96FWSJKvHsmmFADCg4gpZQff4P3bkLKi", &buffer{}); err != ErrObjectNotFound {
+		t.Errorf("Expected ErrObjectNotFound, got %v", err)
+	}
+}
+
+func TestInvalidObjectId(t *testing.T) {
+	client := NewClient(t.TempDir())
+	for _, id := range []string{"", "abc", "../../etc/passwd", `ab\cd\ef`} {
+		if err := client.Upload(bytes.NewReader(nil), id); err != ErrInvalidObjectId {
+			t.Errorf("Expected ErrInvalidObjectId for %q, got %v", id, err)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	client := NewClient(t.TempDir())
+	id := "4vJ9JU1bJJE96FWSJKvHsmmFADCg4gpZQff4P3bkLKi"
+	if err := client.Upload(bytes.NewReader([]byte("content")), id); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Remove(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Download(id, &buffer{}); err != ErrObjectNotFound {
+		t.Errorf("Expected the removed object not to be found, got %v", err)
+	}
+	// Removing a missing object is not an error
+	if err := client.Remove(id); err != nil {
+		t.Errorf("Expected no error removing a missing object, got %v", err)
+	}
+}
@@ -1,67 +1,218 @@
+// Package s3 implements an object storage in an S3 bucket, on AWS or on an S3-compatible service
+// such as MinIO or Ceph.
 package s3
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"slices"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+const (
+	DefaultChunkSize   = 10 * 1024 * 1024 // DefaultChunkSize is the size of the parts of the transfers.
+	DefaultConcurrency = 5                // DefaultConcurrency is the number of parts transferred at the same time.
+
+	// DefaultEndpointRegion is the region used with a custom endpoint when no region is set,
+	// since S3-compatible services usually ignore it but the requests must be signed with one.
+	DefaultEndpointRegion = "us-east-1"
+)
+
+var (
+	ErrNoBucket                    = errors.New("no S3 bucket is set")
+	ErrInvalidStorageClass         = errors.New("invalid S3 storage class")
+	ErrInvalidServerSideEncryption = errors.New("invalid S3 server-side encryption")
+	ErrKMSKeyWithoutKMSEncryption  = errors.New("a KMS key is set without the aws:kms server-side encryption")
+	ErrObjectNotFound              = errors.New("object not found in the S3 bucket")
+	ErrMinimumChunkSize            = fmt.Errorf("the S3 chunk size must be at least %d bytes", manager.MinUploadPartSize)
+	ErrInvalidConcurrency          = errors.New("the S3 concurrency must be positive")
+)
+
+// Options is the configuration of the S3 client. The zero values are not set, and the defaults
+// of the AWS SDK are used for them.
+type Options struct {
+	Bucket      string // Bucket is the name of the bucket of the objects.
+	Prefix      string // Prefix is prepended to the keys of the objects, to keep several repositories in a bucket.
+	Region      string // Region is the region of the bucket.
+	Profile     string // Profile is the name of the profile of the shared credentials.
+	Endpoint    string // Endpoint is the URL of the S3 service, when it is not AWS.
+	PathStyle   bool   // PathStyle addresses the bucket in the path of the URL instead of the host name, as MinIO and Ceph need.
+	ChunkSize   int64  // ChunkSize is the size of the parts of the transfers, in bytes.
+	Concurrency int    // Concurrency is the number of parts transferred at the same time.
+
+	StorageClass         string // StorageClass is the storage class of the uploaded objects, such as STANDARD_IA.
+	ServerSideEncryption string // ServerSideEncryption is the server-side encryption of the uploaded objects, AES256 or aws:kms.
+	KMSKeyId             string // KMSKeyId is the KMS key of the aws:kms server-side encryption.
+}
+
 // Client S3Client represents the objectstorage configuration for S3
 type Client struct {
-	BucketName string
-	ChunkSize  int64
-	Client     *s3.Client
+	BucketName  string
+	Prefix      string
+	ChunkSize   int64
+	Concurrency int
+	Client      *s3.Client
+
+	storageClass         types.StorageClass
+	serverSideEncryption types.ServerSideEncryption
+	kmsKeyId             string
+
+	// ctx is the context of the operations of Upload and Download. Canceling it aborts the transfers in progress.
+	ctx context.Context
 }
 
-// NewClient creates a new instance of S3Client
-func NewClient(bucketName string, chunkSize int64) *Client {
-	ctx := context.TODO()
-	cfg, err := config.LoadDefaultConfig(ctx)
+// NewClient creates a new instance of S3Client.
+// The context is used by Upload and Download, so that the transfers are aborted when it is canceled.
+func NewClient(ctx context.Context, options Options) (*Client, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	var loadOptions []func(*config.LoadOptions) error
+	if options.Region == "" && options.Endpoint != "" {
+		options.Region = DefaultEndpointRegion
+	}
+	if options.Region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(options.Region))
+	}
+	if options.Profile != "" {
+		loadOptions = append(loadOptions, config.WithSharedConfigProfile(options.Profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOptions...)
 	if err != nil {
-		log.Fatalf("unable to load SDK config, %v", err)
+		return nil, fmt.Errorf("unable to load the S3 configuration: %w", err)
 	}
 
 	// Create an Amazon S3 service client
-	client := s3.NewFromConfig(cfg)
-	return &Client{
-		BucketName: bucketName,
-		ChunkSize:  chunkSize,
-		Client:     client,
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if options.Endpoint != "" {
+			o.BaseEndpoint = aws.String(options.Endpoint)
+		}
+		o.UsePathStyle = options.PathStyle
+	})
+	if options.ChunkSize == 0 {
+		options.ChunkSize = DefaultChunkSize
+	}
+	if options.Concurrency == 0 {
+		options.Concurrency = DefaultConcurrency
 	}
+	return &Client{
+		BucketName:           options.Bucket,
+		Prefix:               options.Prefix,
+		ChunkSize:            options.ChunkSize,
+		Concurrency:          options.Concurrency,
+		Client:               client,
+		storageClass:         types.StorageClass(options.StorageClass),
+		serverSideEncryption: types.ServerSideEncryption(options.ServerSideEncryption),
+		kmsKeyId:             options.KMSKeyId,
+		ctx:                  ctx,
+	}, nil
 }
 
+// Upload uploads the content of the reader as the object key, in the context of the client.
 func (s *Client) Upload(reader io.Reader, key string) error {
+	return s.UploadContext(s.ctx, reader, key)
+}
+
+// UploadContext uploads the content of the reader as the object key.
+// Large objects are uploaded in parts, and the parts already uploaded are aborted if the context is canceled.
+func (s *Client) UploadContext(ctx context.Context, reader io.Reader, key string) error {
 	uploader := manager.NewUploader(s.Client, func(u *manager.Uploader) {
 		u.PartSize = s.ChunkSize
+		u.Concurrency = s.Concurrency
 	})
-	_, err := uploader.Upload(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(s.BucketName),
-		Key:    aws.String(key),
-		Body:   reader,
-	})
-	if err != nil {
-		return fmt.Errorf("couldn't upload object. Here's why: %v", err)
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(s.BucketName),
+		Key:                  aws.String(s.objectKey(key)),
+		Body:                 reader,
+		StorageClass:         s.storageClass,
+		ServerSideEncryption: s.serverSideEncryption,
+	}
+	if s.kmsKeyId != "" {
+		input.SSEKMSKeyId = aws.String(s.kmsKeyId)
 	}
-	return err
+	if _, err := uploader.Upload(ctx, input); err != nil {
+		return fmt.Errorf("cannot upload object %s: %w", key, err)
+	}
+	return nil
 }
 
+// Download downloads the object key to writeAt, in the context of the client.
 func (s *Client) Download(key string, writeAt io.WriterAt) error {
-	var partMiBs int64 = 10
+	return s.DownloadContext(s.ctx, key, writeAt)
+}
+
+// DownloadContext downloads the object key to writeAt, in parts written concurrently.
+// It returns ErrObjectNotFound if the object is not in the bucket.
+func (s *Client) DownloadContext(ctx context.Context, key string, writeAt io.WriterAt) error {
 	downloader := manager.NewDownloader(s.Client, func(d *manager.Downloader) {
-		d.PartSize = partMiBs * 1024 * 1024
+		d.PartSize = s.ChunkSize
+		d.Concurrency = s.Concurrency
+	})
+	_, err := downloader.Download(ctx, writeAt, &s3.GetObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(s.objectKey(key)),
 	})
-	_, err := downloader.Download(context.TODO(), writeAt, &s3.GetObjectInput{
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	if errors.As(err, &noSuchKey) || errors.As(err, &notFound) {
+		return ErrObjectNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("cannot download object %s: %w", key, err)
+	}
+	return nil
+}
+
+// Remove removes the object key, in the context of the client.
+// S3 does not report an error when the object is not in the bucket.
+func (s *Client) Remove(key string) error {
+	_, err := s.Client.DeleteObject(s.ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.BucketName),
-		Key:    aws.String(key),
+		Key:    aws.String(s.objectKey(key)),
 	})
 	if err != nil {
-		return fmt.Errorf("couldn't download. Here's why: %v", err)
+		return fmt.Errorf("cannot remove object %s: %w", key, err)
+	}
+	return nil
+}
+
+// objectKey returns the key of the object in the bucket, under the prefix of the client.
+func (s *Client) objectKey(key string) string {
+	prefix := strings.Trim(s.Prefix, "/")
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+// validate checks the options which would otherwise only be rejected by the first transfer.
+func (o Options) validate() error {
+	if o.Bucket == "" {
+		return ErrNoBucket
+	}
+	if o.ChunkSize != 0 && o.ChunkSize < manager.MinUploadPartSize {
+		return ErrMinimumChunkSize
+	}
+	if o.Concurrency < 0 {
+		return ErrInvalidConcurrency
+	}
+	if o.StorageClass != "" && !slices.Contains(types.StorageClass("").Values(), types.StorageClass(o.StorageClass)) {
+		return fmt.Errorf("%w: %s", ErrInvalidStorageClass, o.StorageClass)
+	}
+	sse := types.ServerSideEncryption(o.ServerSideEncryption)
+	if sse != "" && !slices.Contains(types.ServerSideEncryption("").Values(), sse) {
+		return fmt.Errorf("%w: %s", ErrInvalidServerSideEncryption, o.ServerSideEncryption)
+	}
+	if o.KMSKeyId != "" && sse != types.ServerSideEncryptionAwsKms && sse != types.ServerSideEncryptionAwsKmsDsse {
+		return ErrKMSKeyWithoutKMSEncryption
 	}
 	return nil
 }
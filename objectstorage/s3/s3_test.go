@@ -0,0 +1,126 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// buffer is an io.WriterAt writing in memory. The parts of the objects are written concurrently.
+type buffer struct {
+	sync.Mutex
+	data []byte
+}
+
+func (b *buffer) WriteAt(p []byte, off int64) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	if end := int(off) + len(p); end > len(b.data) {
+		b.data = append(b.data, make([]byte, end-len(b.data))...)
+	}
+	return copy(b.data[off:], p), nil
+}
+
+func TestOptionsValidation(t *testing.T) {
+	tests := []struct {
+		options Options
+		err     error
+	}{
+		{Options{}, ErrNoBucket},
+		{Options{Bucket: "b", ChunkSize: 1024}, ErrMinimumChunkSize},
+		{Options{Bucket: "b", Concurrency: -1}, ErrInvalidConcurrency},
+		{Options{Bucket: "b", StorageClass: "COLD"}, ErrInvalidStorageClass},
+		{Options{Bucket: "b", ServerSideEncryption: "rot13"}, ErrInvalidServerSideEncryption},
+		{Options{Bucket: "b", ServerSideEncryption: "AES256", KMSKeyId: "alias/key"}, ErrKMSKeyWithoutKMSEncryption},
+	}
+	for _, test := range tests {
+		if _, err := NewClient(context.Background(), test.options); !errors.Is(err, test.err) {
+			t.Errorf("Expected %v for %+v, got %v", test.err, test.options, err)
+		}
+	}
+	valid := Options{Bucket: "b", StorageClass: "STANDARD_IA", ServerSideEncryption: "aws:kms", KMSKeyId: "alias/key"}
+	if err := valid.validate(); err != nil {
+		t.Errorf("Valid options rejected: %v", err)
+	}
+}
+
+func TestObjectKey(t *testing.T) {
+	for prefix, expected := range map[string]string{"": "id", "repo": "repo/id", "/team/repo/": "team/repo/id"} {
+		client := Client{Prefix: prefix}
+		if key := client.objectKey("id"); key != expected {
+			t.Errorf("Expected %s for the prefix %q, got %s", expected, prefix, key)
+		}
+	}
+}
+
+// TestMinIO runs against the S3-compatible service at CTB_TEST_S3_ENDPOINT, such as a local MinIO started with:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	CTB_TEST_S3_ENDPOINT=http://localhost:9000 go test ./objectstorage/s3
+//
+// The credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, minioadmin by default.
+func TestMinIO(t *testing.T) {
+	endpoint := os.Getenv("CTB_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("CTB_TEST_S3_ENDPOINT is not set")
+	}
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		t.Setenv("AWS_ACCESS_KEY_ID", "minioadmin")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "minioadmin")
+	}
+	ctx := context.Background()
+	client, err := NewClient(ctx, Options{
+		Bucket:      "ctb-integration-test",
+		Prefix:      "repo",
+		Endpoint:    endpoint,
+		PathStyle:   true,
+		ChunkSize:   5 * 1024 * 1024,
+		Concurrency: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var owned *types.BucketAlreadyOwnedByYou
+	if _, err := client.Client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(client.BucketName)}); err != nil && !errors.As(err, &owned) {
+		t.Fatal(err)
+	}
+
+	// An object larger than the chunk size is transferred in parts
+	content := make([]byte, 12*1024*1024+123)
+	_, _ = rand.Read(content)
+	if err := client.Upload(bytes.NewReader(content), "object"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(client.BucketName), Key: aws.String("repo/object")}); err != nil {
+		t.Errorf("The object is not stored under the prefix: %v", err)
+	}
+	var downloaded buffer
+	if err := client.Download("object", &downloaded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded.data, content) {
+		t.Error("The downloaded object does not match the uploaded one")
+	}
+
+	if err := client.Download("missing", &buffer{}); err != ErrObjectNotFound {
+		t.Errorf("Expected ErrObjectNotFound, got %v", err)
+	}
+
+	// The transfers are aborted when the context is canceled
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := client.UploadContext(canceled, bytes.NewReader(content), "canceled"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for the upload, got %v", err)
+	}
+	if err := client.DownloadContext(canceled, "object", &buffer{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for the download, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrDirectoryEntryNotFound = errors.New("user not found in the user directory")
+)
+
+// DirectoryRepository is an interface for persisting the user directory
+type DirectoryRepository interface {
+	Get(publicKey string) (core.DirectoryEntry, error)
+	Save(entry core.DirectoryEntry) error
+	Delete(publicKey string) error
+	List() ([]core.DirectoryEntry, error)
+}
+
+type DirectoryRepositoryFile struct {
+	rootPath string
+}
+
+var _ DirectoryRepository = &DirectoryRepositoryFile{}
+
+func NewDirectoryRepositoryFile(rootPath string) *DirectoryRepositoryFile {
+	return &DirectoryRepositoryFile{
+		rootPath: rootPath,
+	}
+}
+
+// Get returns the entry of the user with the public key.
+// It returns ErrDirectoryEntryNotFound if the user is not in the directory.
+func (d *DirectoryRepositoryFile) Get(publicKey string) (core.DirectoryEntry, error) {
+	content, err := os.ReadFile(filepath.Join(d.directoryFolder(), publicKey))
+	if os.IsNotExist(err) {
+		return core.DirectoryEntry{}, ErrDirectoryEntryNotFound
+	}
+	if err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	return core.UnmarshalDirectoryEntry(content)
+}
+
+// Save writes the entry.
+func (d *DirectoryRepositoryFile) Save(entry core.DirectoryEntry) error {
+	serialized, err := entry.Marshal()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(d.directoryFolder(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(d.directoryFolder(), entry.PublicKey), serialized, 0644)
+}
+
+// Delete removes the entry of the user with the public key.
+func (d *DirectoryRepositoryFile) Delete(publicKey string) error {
+	err := os.Remove(filepath.Join(d.directoryFolder(), publicKey))
+	if os.IsNotExist(err) {
+		return ErrDirectoryEntryNotFound
+	}
+	return err
+}
+
+// List returns the entries of the directory.
+func (d *DirectoryRepositoryFile) List() ([]core.DirectoryEntry, error) {
+	list := make([]core.DirectoryEntry, 0)
+	entries, err := os.ReadDir(d.directoryFolder())
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range entries {
+		if file.IsDir() {
+			continue
+		}
+		entry, err := d.Get(file.Name())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+// directoryFolder returns the folder of the user directory, in the root of the repository.
+func (d *DirectoryRepositoryFile) directoryFolder() string {
+	return filepath.Join(d.rootPath, ".meta", ".directory")
+}
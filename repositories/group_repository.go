@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrGroupNotFound      = errors.New("group not found")
+	ErrDuplicateGroupName = errors.New("several groups have the name; the duplicate group must be removed from .meta/.groups")
+)
+
+// GroupSigner signs the groups and verifies their versions
+type GroupSigner interface {
+	core.RecordSigner
+	// VerifyGroup checks the signatures of the group and of its previous versions, and that every version
+	// is written by the creator of the group or by a member of the previous version
+	VerifyGroup(group core.Group) error
+}
+
+// GroupRepository is an interface for persisting the groups of users
+type GroupRepository interface {
+	Get(groupId string) (core.Group, error)
+	GetByName(name string) (core.Group, error)
+	Save(group core.Group) error
+	List() ([]core.Group, error)
+}
+
+type GroupRepositoryFile struct {
+	rootPath string
+	signer   GroupSigner
+}
+
+var _ GroupRepository = &GroupRepositoryFile{}
+
+func NewGroupRepositoryFile(rootPath string) *GroupRepositoryFile {
+	return &GroupRepositoryFile{
+		rootPath: rootPath,
+	}
+}
+
+// SetRecordSigner sets the signer of the groups.
+// The groups are signed when they are saved and verified when they are read.
+func (g *GroupRepositoryFile) SetRecordSigner(signer GroupSigner) {
+	g.signer = signer
+}
+
+// Get returns the group with the specified id and verifies its signature and its previous versions.
+// It returns ErrGroupNotFound if the group does not exist,
+// and the error of the signer if the group must be refused.
+func (g *GroupRepositoryFile) Get(groupId string) (core.Group, error) {
+	content, err := os.ReadFile(filepath.Join(g.groupsFolder(), groupId))
+	if os.IsNotExist(err) {
+		return core.Group{}, ErrGroupNotFound
+	}
+	if err != nil {
+		return core.Group{}, err
+	}
+	group, err := core.UnmarshalGroup(content)
+	if err != nil {
+		return core.Group{}, err
+	}
+	if group.Id != groupId {
+		return core.Group{}, core.ErrUnauthorizedGroupWriter
+	}
+	if g.signer == nil {
+		return group, nil
+	}
+	if err := g.signer.VerifyGroup(group); err != nil {
+		return core.Group{}, err
+	}
+	return group, nil
+}
+
+// GetByName returns the group with the specified name.
+// It returns ErrGroupNotFound if the group does not exist, and ErrDuplicateGroupName if several groups
+// have the name, so that a group created with the name of another group does not receive its shares.
+func (g *GroupRepositoryFile) GetByName(name string) (core.Group, error) {
+	groups, err := g.List()
+	if err != nil {
+		return core.Group{}, err
+	}
+	var found *core.Group
+	for i, group := range groups {
+		if group.Name != name {
+			continue
+		}
+		if found != nil {
+			return core.Group{}, ErrDuplicateGroupName
+		}
+		found = &groups[i]
+	}
+	if found == nil {
+		return core.Group{}, ErrGroupNotFound
+	}
+	return *found, nil
+}
+
+// Save signs and writes the group. The version of the group being replaced is embedded in the new version.
+func (g *GroupRepositoryFile) Save(group core.Group) (err error) {
+	group.Signature = nil
+	group.Previous = nil
+	previous, err := g.Get(group.Id)
+	if err == nil {
+		group.Previous = &previous
+	} else if !errors.Is(err, ErrGroupNotFound) {
+		return err
+	}
+	if g.signer != nil {
+		payload, err := group.SignedPayload()
+		if err != nil {
+			return err
+		}
+		group.Signature, err = g.signer.SignRecord(sign_crypto.GroupV1Info, payload)
+		if err != nil {
+			return err
+		}
+	}
+	serialized, err := group.Marshal()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(g.groupsFolder(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(g.groupsFolder(), group.Id), serialized, 0644)
+}
+
+// List returns the groups of the repository.
+// The groups refused by the signer are skipped, so that a tampered group does not hide the others.
+func (g *GroupRepositoryFile) List() ([]core.Group, error) {
+	list := make([]core.Group, 0)
+	entries, err := os.ReadDir(g.groupsFolder())
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		group, err := g.Get(entry.Name())
+		if isRefusedRecord(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, group)
+	}
+	return list, nil
+}
+
+// groupsFolder returns the folder of the groups, in the root of the repository.
+func (g *GroupRepositoryFile) groupsFolder() string {
+	return filepath.Join(g.rootPath, ".meta", ".groups")
+}
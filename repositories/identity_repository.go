@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrIdentityNotFound = errors.New("identity file not found")
+)
+
+// IdentityRepository is an interface for persisting the identity of the user
+type IdentityRepository interface {
+	Exists() bool
+	Get() (core.Identity, error)
+	Save(identity core.Identity) error
+	GetPath() string
+}
+
+type IdentityRepositoryFile struct {
+	path string
+}
+
+var _ IdentityRepository = &IdentityRepositoryFile{}
+
+func NewIdentityRepositoryFile(path string) *IdentityRepositoryFile {
+	return &IdentityRepositoryFile{
+		path: path,
+	}
+}
+
+// Exists returns true if the identity file exists.
+func (i *IdentityRepositoryFile) Exists() bool {
+	_, err := os.Stat(i.path)
+	return err == nil
+}
+
+// Get reads the identity file.
+// It returns ErrIdentityNotFound if the identity file does not exist.
+func (i *IdentityRepositoryFile) Get() (core.Identity, error) {
+	content, err := os.ReadFile(i.path)
+	if os.IsNotExist(err) {
+		return core.Identity{}, ErrIdentityNotFound
+	}
+	if err != nil {
+		return core.Identity{}, err
+	}
+	return core.UnmarshalIdentity(content)
+}
+
+// Save writes the identity file, readable only by the user.
+// The file is written to a temporary file first and renamed, so that the identity is never partially written.
+func (i *IdentityRepositoryFile) Save(identity core.Identity) error {
+	serialized, err := identity.Marshal()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(i.path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := i.path + ".tmp"
+	err = os.WriteFile(tmp, serialized, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, i.path)
+}
+
+// GetPath returns the path of the identity file.
+func (i *IdentityRepositoryFile) GetPath() string {
+	return i.path
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+// InvitationRepository is an interface for persisting the pending invitations
+type InvitationRepository interface {
+	Get(id string) (core.Invitation, error)
+	Save(invitation core.Invitation) error
+	Delete(id string) error
+	List() ([]core.Invitation, error)
+}
+
+type InvitationRepositoryFile struct {
+	rootPath string
+}
+
+var _ InvitationRepository = &InvitationRepositoryFile{}
+
+func NewInvitationRepositoryFile(rootPath string) *InvitationRepositoryFile {
+	return &InvitationRepositoryFile{
+		rootPath: rootPath,
+	}
+}
+
+// Get returns the invitation with the id.
+// It returns ErrInvitationNotFound if the invitation does not exist.
+func (r *InvitationRepositoryFile) Get(id string) (core.Invitation, error) {
+	content, err := os.ReadFile(filepath.Join(r.invitationsFolder(), filepath.Base(id)))
+	if os.IsNotExist(err) {
+		return core.Invitation{}, ErrInvitationNotFound
+	}
+	if err != nil {
+		return core.Invitation{}, err
+	}
+	return core.UnmarshalInvitation(content)
+}
+
+// Save writes the invitation.
+func (r *InvitationRepositoryFile) Save(invitation core.Invitation) error {
+	serialized, err := invitation.Marshal()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(r.invitationsFolder(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.invitationsFolder(), invitation.Id), serialized, 0644)
+}
+
+// Delete removes the invitation with the id.
+func (r *InvitationRepositoryFile) Delete(id string) error {
+	err := os.Remove(filepath.Join(r.invitationsFolder(), filepath.Base(id)))
+	if os.IsNotExist(err) {
+		return ErrInvitationNotFound
+	}
+	return err
+}
+
+// List returns the pending invitations.
+func (r *InvitationRepositoryFile) List() ([]core.Invitation, error) {
+	list := make([]core.Invitation, 0)
+	entries, err := os.ReadDir(r.invitationsFolder())
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		invitation, err := r.Get(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, invitation)
+	}
+	return list, nil
+}
+
+// invitationsFolder returns the folder of the invitations, in the root of the repository.
+func (r *InvitationRepositoryFile) invitationsFolder() string {
+	return filepath.Join(r.rootPath, ".meta", ".invitations")
+}
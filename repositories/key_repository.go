@@ -2,11 +2,13 @@ package repositories
 
 import (
 	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 var (
@@ -19,26 +21,45 @@ type KeyRepository interface {
 	SaveDataKey(keyId, key, recipient string, path string) error
 	GetDataKey(keyID string, userId string, path string) (string, error)
 	DataKeyExist(keyId string, userId string, path string) bool
+	ListDataKeys(userId string, path string) ([]string, error)
 	IsUserJoined(userId string) bool
+	JoinUser(userId string) error
 	ListUsers() ([]string, error)
 	DeleteDataKey(keyID string, userId string, path string) error
+	UpdateDataKeys(userId string, update func(keyId string, key string) (string, error)) error
+	SaveDataKeyExpiry(expiry core.ShareExpiry, path string) error
+	GetDataKeyExpiry(keyId string, userId string, path string) (*core.ShareExpiry, error)
+	ListDataKeyExpiries(keyId string, path string) ([]core.ShareExpiry, error)
+	DeleteDataKeyExpiry(keyId string, userId string, path string) error
 }
 
 type KeyRepositoryFile struct {
 	rootPath string
+	resolver *PathResolver
+	signer   core.RecordSigner
 }
 
 var _ KeyRepository = &KeyRepositoryFile{}
 
-func NewKeyRepositoryFile(rootPath string) *KeyRepositoryFile {
+func NewKeyRepositoryFile(rootPath string, resolver *PathResolver) *KeyRepositoryFile {
 	return &KeyRepositoryFile{
 		rootPath: rootPath,
+		resolver: resolver,
 	}
 }
 
+// SetRecordSigner sets the signer of the shared data keys.
+// The data keys are signed when they are saved, and their signatures are verified when they are read.
+func (k *KeyRepositoryFile) SetRecordSigner(signer core.RecordSigner) {
+	k.signer = signer
+}
+
 func (k *KeyRepositoryFile) SaveDataKey(keyId, key, recipient string, path string) error {
-	datapath := k.getDataPath(recipient, path)
-	err := os.MkdirAll(datapath, os.ModePerm)
+	datapath, err := k.getDataPath(recipient, path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(datapath, os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -52,11 +73,14 @@ func (k *KeyRepositoryFile) SaveDataKey(keyId, key, recipient string, path strin
 	if err != nil {
 		return err
 	}
-	return nil
+	return writeSignature(p, sign_crypto.ShareV1Info, recordPayload(keyId, recipient, key), k.signer)
 }
 
 func (k *KeyRepositoryFile) GetDataKey(keyID string, userId string, path string) (string, error) {
-	datapath := k.getDataPath(userId, path)
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return "", err
+	}
 	if _, err := os.Stat(datapath); err != nil {
 		return "", err
 	}
@@ -73,13 +97,20 @@ func (k *KeyRepositoryFile) GetDataKey(keyID string, userId string, path string)
 	if err != nil {
 		return "", err
 	}
-	return string(content), err
+	err = verifySignature(p, sign_crypto.ShareV1Info, recordPayload(keyID, userId, string(content)), k.signer)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
 }
 
 // DataKeyExist checks if a data key with the given key ID exists for the specified user.
 // It returns true if the data key exists, and false otherwise.
 func (k *KeyRepositoryFile) DataKeyExist(keyId string, userId string, path string) bool {
-	datapath := k.getDataPath(userId, path)
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return false
+	}
 	if _, err := os.Stat(datapath); err != nil {
 		return false
 	}
@@ -91,6 +122,29 @@ func (k *KeyRepositoryFile) DataKeyExist(keyId string, userId string, path strin
 	}
 }
 
+// ListDataKeys returns the ids of the data keys shared with the user in the path.
+func (k *KeyRepositoryFile) ListDataKeys(userId string, path string) ([]string, error) {
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(datapath)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyIds := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), expirySuffix) || strings.HasSuffix(entry.Name(), signatureSuffix) {
+			continue
+		}
+		keyIds = append(keyIds, entry.Name())
+	}
+	return keyIds, nil
+}
+
 func (k *KeyRepositoryFile) IsUserJoined(userId string) bool {
 	users, err := k.GetJoinedUsers()
 	if err != nil {
@@ -104,6 +158,11 @@ func (k *KeyRepositoryFile) IsUserJoined(userId string) bool {
 	return false
 }
 
+// JoinUser joins the user by creating the key share folder of the user in the root of the repository.
+func (k *KeyRepositoryFile) JoinUser(userId string) error {
+	return os.MkdirAll(filepath.Join(keysFolder(k.rootPath), userId), os.ModePerm)
+}
+
 // ListUsers returns a list of users stored in the key repository.
 func (k *KeyRepositoryFile) ListUsers() ([]string, error) {
 	joinedUser, err := k.GetJoinedUsers()
@@ -121,15 +180,150 @@ func (k *KeyRepositoryFile) ListUsers() ([]string, error) {
 // It removes the file corresponding to the keyID from the user's data path.
 // If an error occurs during the deletion process, it is returned.
 func (k *KeyRepositoryFile) DeleteDataKey(keyID string, userId string, path string) error {
-	datapath := k.getDataPath(userId, path)
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return err
+	}
 	if _, err := os.Stat(datapath); err != nil {
 		return err
 	}
 	p := filepath.Join(datapath, keyID)
-	err := os.Remove(p)
+	err = os.Remove(p)
+	if err != nil {
+		return err
+	}
+	// The expiry and the signature of the share are removed with the share
+	err = os.Remove(p + expirySuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return removeSignature(p)
+}
+
+// SaveDataKeyExpiry writes the expiry of the share of a data key, next to the shared data key.
+func (k *KeyRepositoryFile) SaveDataKeyExpiry(expiry core.ShareExpiry, path string) error {
+	datapath, err := k.getDataPath(expiry.Recipient, path)
+	if err != nil {
+		return err
+	}
+	serialized, err := expiry.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(datapath, expiry.KeyId+expirySuffix), serialized, 0644)
+}
+
+// GetDataKeyExpiry returns the expiry of the share of the data key with the user, or nil if the share does not expire.
+func (k *KeyRepositoryFile) GetDataKeyExpiry(keyId string, userId string, path string) (*core.ShareExpiry, error) {
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(datapath, keyId+expirySuffix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	expiry, err := core.UnmarshalShareExpiry(content)
+	if err != nil {
+		return nil, err
+	}
+	// The recipient is the user of the folder: an expiry moved to the folder of another user has an invalid signature
+	expiry.Recipient = userId
+	return &expiry, nil
+}
+
+// ListDataKeyExpiries returns the expiries of the shares of the data key with all the users in the path.
+func (k *KeyRepositoryFile) ListDataKeyExpiries(keyId string, path string) ([]core.ShareExpiry, error) {
+	list := make([]core.ShareExpiry, 0)
+	keysPath, err := k.getKeysPath(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(keysPath)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		expiry, err := k.GetDataKeyExpiry(keyId, entry.Name(), path)
+		if err != nil {
+			return nil, err
+		}
+		if expiry != nil {
+			list = append(list, *expiry)
+		}
+	}
+	return list, nil
+}
+
+// DeleteDataKeyExpiry removes the expiry of the share of the data key with the user, so that the share does not expire.
+func (k *KeyRepositoryFile) DeleteDataKeyExpiry(keyId string, userId string, path string) error {
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(datapath, keyId+expirySuffix))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// UpdateDataKeys replaces every data key of the user, in all the directories of the repository,
+// by the result of the update function.
+func (k *KeyRepositoryFile) UpdateDataKeys(userId string, update func(keyId string, key string) (string, error)) error {
+	return k.updateDataKeysInPath(userId, "", update)
+}
+
+// updateDataKeysInPath replaces the data keys of the user in the stored path and its sub folders.
+// The path is the stored path relative to the root, so it is not resolved.
+func (k *KeyRepositoryFile) updateDataKeysInPath(userId string, path string, update func(keyId string, key string) (string, error)) error {
+	datapath := filepath.Join(keysFolder(filepath.Join(k.rootPath, path)), userId)
+	entries, err := os.ReadDir(datapath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), expirySuffix) || strings.HasSuffix(entry.Name(), signatureSuffix) {
+			continue
+		}
+		p := filepath.Join(datapath, entry.Name())
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		updated, err := update(entry.Name(), string(content))
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(p, []byte(updated), 0644)
+		if err != nil {
+			return err
+		}
+		err = writeSignature(p, sign_crypto.ShareV1Info, recordPayload(entry.Name(), userId, updated), k.signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	subs, err := k.getSubFolders(path)
 	if err != nil {
 		return err
 	}
+	for _, sub := range subs {
+		err = k.updateDataKeysInPath(userId, sub, update)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -137,10 +331,12 @@ func (k *KeyRepositoryFile) GetJoinedUsers() ([]core.JoinedUser, error) {
 	return k.getJoinedUsersInPath("")
 }
 
+// getJoinedUsersInPath returns the users having a key share folder in the stored path or its sub folders.
+// The path is the stored path relative to the root, so it is not resolved.
 func (k *KeyRepositoryFile) getJoinedUsersInPath(path string) ([]core.JoinedUser, error) {
 	list := make([]core.JoinedUser, 0)
 
-	entries, err := os.ReadDir(k.getKeysPath(path))
+	entries, err := os.ReadDir(keysFolder(filepath.Join(k.rootPath, path)))
 	if err != nil {
 		fmt.Println("Error reading directory:", err)
 		return list, err
@@ -190,12 +386,27 @@ func (k *KeyRepositoryFile) getSubFolders(path string) ([]string, error) {
 	return list, nil
 }
 
-func (k *KeyRepositoryFile) getDataPath(recipient string, path string) string {
-	p := filepath.Join(k.getKeysPath(path), recipient)
-	return p
+func (k *KeyRepositoryFile) getDataPath(recipient string, path string) (string, error) {
+	keysPath, err := k.getKeysPath(path)
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(keysPath, recipient)
+	return p, nil
+}
+
+func (k *KeyRepositoryFile) getKeysPath(path string) (string, error) {
+	absPath, err := k.resolver.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return keysFolder(absPath), nil
 }
 
-func (k *KeyRepositoryFile) getKeysPath(path string) string {
-	dir := filepath.Join(k.rootPath, path, ".meta", ".key-share")
-	return dir
+// expirySuffix is the suffix of the file of the expiry of a share, next to the file of the shared data key.
+const expirySuffix = ".expiry"
+
+// keysFolder returns the key share folder of the stored directory.
+func keysFolder(storageDir string) string {
+	return filepath.Join(storageDir, ".meta", ".key-share")
 }
@@ -1,12 +1,16 @@
 package repositories
 
 import (
+	"bytes"
 	"ctb-cli/core"
+	"ctb-cli/crypto/record_crypto"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
 )
 
 var (
@@ -14,25 +18,63 @@ var (
 	ErrReadingLinkFile       = errors.New("error reading link file")
 	ErrRemovingVaultLinkFile = errors.New("error removing vault link file")
 	ErrPathIsNotDir          = errors.New("path is not a valid directory")
+	ErrOpeningLink           = errors.New("error opening encrypted link")
+	ErrLinkEncryptionOff     = errors.New("link encryption is not enabled")
+	ErrCannotResealLink      = errors.New("the link cannot be sealed again with the new path")
+	ErrUnsealedLink          = errors.New("the link is not sealed bound to its path, although all the links of the repository are")
 )
 
 type LinkRepository struct {
-	rootPath string
+	rootPath      string
+	resolver      *PathResolver
+	encryptLinks  bool
+	linksMigrated bool // linksMigrated refuses the plaintext links and the links bound to their name only
 }
 
-func NewLinkRepository(rootPath string) *LinkRepository {
+func NewLinkRepository(rootPath string, resolver *PathResolver) *LinkRepository {
 	return &LinkRepository{
 		rootPath: rootPath,
+		resolver: resolver,
 	}
 }
 
+// SetEncryptLinks enables or disables link encryption.
+// If enabled, the links are sealed with the vault key of their parent directory when they are written.
+// Plaintext and encrypted links are both readable until the links are migrated, see SetLinksMigrated.
+func (c *LinkRepository) SetEncryptLinks(enabled bool) {
+	c.encryptLinks = enabled
+}
+
+// SetLinksMigrated sets whether all the links are sealed bound to their full path. If set, the plaintext links and
+// the links bound to their name only are refused, so that a writer of the repository cannot replace a link with them.
+func (c *LinkRepository) SetLinksMigrated(migrated bool) {
+	c.linksMigrated = migrated
+}
+
+// IsEncryptNames returns true if the names of the files and directories are encrypted.
+func (c *LinkRepository) IsEncryptNames() bool {
+	return c.resolver.IsEncryptNames()
+}
+
+// IsEncryptLinks returns true if link encryption is enabled.
+func (c *LinkRepository) IsEncryptLinks() bool {
+	return c.encryptLinks
+}
+
 // Create creates a new file at the specified path and writes the JSON representation of the given link to it.
 // If the file or any necessary directories do not exist, they will be created.
 // The path parameter specifies the relative path to the file, and the link parameter contains the data to be written.
 // Returns an error if any error occurs during the creation or writing process.
 func (c *LinkRepository) Create(path string, link core.Link) error {
-	absPath := filepath.Join(c.rootPath, path)
-	err := os.MkdirAll(filepath.Dir(absPath), os.ModePerm)
+	absPath, err := c.resolver.Prepare(path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(absPath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	data, err := c.sealLink(path, link)
 	if err != nil {
 		return err
 	}
@@ -41,82 +83,333 @@ func (c *LinkRepository) Create(path string, link core.Link) error {
 		return err
 	}
 	defer file.Close()
-	js, _ := json.Marshal(link)
-	_, _ = file.Write(js)
+	_, _ = file.Write(data)
 	return nil
 }
 
 // Update updates the link file at the specified path with the provided link data.
 // It returns an error if there was a problem updating the file.
 func (c *LinkRepository) Update(path string, link core.Link) error {
-	absPath := filepath.Join(c.rootPath, path)
-	file, err := os.OpenFile(absPath, os.O_RDWR, 0666)
+	absPath, err := c.resolver.Abs(path)
+	if err != nil {
+		return fmt.Errorf("error updating link file: %v", err)
+	}
+	data, err := c.sealLink(path, link)
+	if err != nil {
+		return fmt.Errorf("error updating link file: %v", err)
+	}
+	file, err := os.OpenFile(absPath, os.O_RDWR|os.O_TRUNC, 0666)
 	if err != nil {
 		return fmt.Errorf("error updating link file: %v", err)
 	}
 	defer file.Close()
-	js, _ := json.Marshal(link)
-	_, err = file.Write(js)
+	_, err = file.Write(data)
 	return err
 }
 
 // GetByPath retrieves a link from the repository based on the given path.
+// Encrypted links are opened with the vault key of the parent directory.
 // It returns the retrieved link and an error, if any.
 func (c *LinkRepository) GetByPath(path string) (core.Link, error) {
-	p := filepath.Join(c.rootPath, path)
-	js, err := os.ReadFile(p)
-	if os.IsNotExist(err) {
-		return core.Link{}, ErrVaultLinkNotFount
+	data, err := c.readLink(path)
+	if err != nil {
+		return core.Link{}, err
+	}
+	return c.openLink(path, data, nil)
+}
+
+// GetByPathWithKey retrieves a link from the repository like GetByPath, but an encrypted link is opened with
+// the given vault key. It is used to read the links still sealed with the old vault key during a rotation.
+func (c *LinkRepository) GetByPathWithKey(path string, vaultKey core.Key) (core.Link, error) {
+	data, err := c.readLink(path)
+	if err != nil {
+		return core.Link{}, err
 	}
+	return c.openLink(path, data, &vaultKey)
+}
+
+// readLink reads the content of the link file located at the specified path.
+func (c *LinkRepository) readLink(path string) ([]byte, error) {
+	p, err := c.resolver.Abs(path)
 	if err != nil {
-		return core.Link{}, ErrReadingLinkFile
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, ErrVaultLinkNotFount
 	}
-	var link core.Link
-	err = json.Unmarshal(js, &link)
 	if err != nil {
-		return core.Link{}, fmt.Errorf("error unmarshalink link file: %v", err)
+		return nil, ErrReadingLinkFile
 	}
-	return link, nil
+	return data, nil
 }
 
 // Remove deletes the file at the specified path.
 // It takes the relative path of the file as input and returns an error if any.
 func (c *LinkRepository) Remove(path string) error {
-	absPath := filepath.Join(c.rootPath, path)
-	err := os.Remove(absPath)
+	absPath, err := c.resolver.Abs(path)
 	if err != nil {
 		return err
 	}
-	return nil
+	err = os.Remove(absPath)
+	if err != nil {
+		return err
+	}
+	return c.resolver.RemoveLongName(path)
 }
 
 // RemoveDir removes the directory at the specified path.
 // It takes the path of the directory to be removed as a parameter.
 // Returns an error if the directory removal fails.
 func (c *LinkRepository) RemoveDir(path string) error {
-	p := filepath.Join(c.rootPath, path)
-	err := os.Remove(p)
-	return err
+	p, err := c.resolver.Abs(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if err != nil {
+		return err
+	}
+	c.resolver.Forget(path)
+	return c.resolver.RemoveLongName(path)
 }
 
 // Rename renames a file or directory from the old path to the new path.
+// Since encrypted links are bound to their full path, the link of a renamed file and the links of all the files
+// under a renamed directory are sealed again. The caller checks with CheckRename that the links under a directory
+// can be opened before moving its vault, so that no link stays bound to the old path. The rename of a directory is
+// recorded in a journal until the links are sealed again, so that an interrupted rename is resumed by ResumeRename.
 // It takes the old path and the new path as parameters and returns an error if any.
 func (c *LinkRepository) Rename(oldPath string, newPath string) error {
-	o := filepath.Join(c.rootPath, oldPath)
-	n := filepath.Join(c.rootPath, newPath)
-	err := os.Rename(o, n)
+	o, err := c.resolver.Abs(oldPath)
+	if err != nil {
+		return err
+	}
+	n, err := c.resolver.Prepare(newPath)
+	if err != nil {
+		return err
+	}
+	//Read the link of the file before renaming it if it must be sealed again
+	var link core.Link
+	reseal := c.encryptLinks && c.IsFile(oldPath)
+	resealDir := c.encryptLinks && c.IsDir(oldPath)
+	if reseal {
+		link, err = c.GetByPath(oldPath)
+		if err != nil {
+			return err
+		}
+	}
+	if resealDir {
+		if err := c.writeRenameJournal(renameJournal{OldPath: oldPath, NewPath: newPath}); err != nil {
+			return err
+		}
+	}
+	err = os.Rename(o, n)
+	if err != nil {
+		return err
+	}
+	c.resolver.Forget(oldPath)
+	err = c.resolver.RemoveLongName(oldPath)
+	if err != nil {
+		return err
+	}
+	if reseal {
+		return c.Update(newPath, link)
+	}
+	if resealDir {
+		if err := c.resealDirLinks(oldPath, newPath); err != nil {
+			return err
+		}
+		return c.removeRenameJournal()
+	}
+	return nil
+}
+
+// ResumeRename seals again the links of the directory whose rename was interrupted, if any.
+// If the directory was not renamed yet, the rename is dropped.
+func (c *LinkRepository) ResumeRename() error {
+	journal, found, err := c.readRenameJournal()
+	if err != nil || !found {
+		return err
+	}
+	if c.IsDir(journal.NewPath) {
+		if err := c.resealDirLinks(journal.OldPath, journal.NewPath); err != nil {
+			return err
+		}
+	}
+	return c.removeRenameJournal()
+}
+
+// CheckRename checks that the links under the directory can be sealed again if it is renamed: it opens
+// the links of all the files under it, and returns ErrCannotResealLink for the first link that cannot be opened.
+// Nothing is checked for a file, or if the links are not encrypted.
+func (c *LinkRepository) CheckRename(path string) error {
+	if !c.encryptLinks || !c.IsDir(path) {
+		return nil
+	}
+	files, err := c.listFiles(path)
 	if err != nil {
 		return err
 	}
+	for _, file := range files {
+		data, err := c.readLink(file)
+		if err != nil {
+			return err
+		}
+		if _, _, err := c.openBoundLink(file, file, data, nil); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCannotResealLink, file, err)
+		}
+	}
 	return nil
 }
 
+// resealDirLinks seals again the links of all the files under a directory renamed from the old path to the new path.
+// The links are opened with the vault keys of their directories at the new path, and the old path as bound path.
+// The links already sealed with the new path are skipped, so that an interrupted rename is resumed.
+func (c *LinkRepository) resealDirLinks(oldPath string, newPath string) error {
+	files, err := c.listFiles(newPath)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		rel, err := filepath.Rel(newPath, file)
+		if err != nil {
+			return err
+		}
+		data, err := c.readLink(file)
+		if err != nil {
+			return err
+		}
+		if _, current, err := c.openBoundLink(file, file, data, nil); err == nil && current {
+			continue
+		}
+		link, _, err := c.openBoundLink(file, filepath.Join(oldPath, rel), data, nil)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCannotResealLink, file, err)
+		}
+		if err := c.Update(file, link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameJournal records the directory being renamed until the links under it are sealed again with the new path.
+type renameJournal struct {
+	OldPath string `json:"oldPath"`
+	NewPath string `json:"newPath"`
+}
+
+// readRenameJournal reads the journal of the interrupted rename, if any.
+func (c *LinkRepository) readRenameJournal() (renameJournal, bool, error) {
+	content, err := os.ReadFile(c.renameJournalPath())
+	if os.IsNotExist(err) {
+		return renameJournal{}, false, nil
+	}
+	if err != nil {
+		return renameJournal{}, false, err
+	}
+	var journal renameJournal
+	if err := json.Unmarshal(content, &journal); err != nil {
+		return renameJournal{}, false, fmt.Errorf("error reading rename journal: %v", err)
+	}
+	return journal, true, nil
+}
+
+// writeRenameJournal writes the journal of the rename, through a temporary file so that it is never partially written.
+func (c *LinkRepository) writeRenameJournal(journal renameJournal) error {
+	content, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+	tmp := c.renameJournalPath() + ".tmp"
+	if err := os.WriteFile(tmp, content, 0666); err != nil {
+		return fmt.Errorf("error writing rename journal: %v", err)
+	}
+	return os.Rename(tmp, c.renameJournalPath())
+}
+
+// removeRenameJournal removes the journal of the rename once the links are sealed again.
+func (c *LinkRepository) removeRenameJournal() error {
+	err := os.Remove(c.renameJournalPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// renameJournalPath returns the path of the journal of the rename of a directory.
+func (c *LinkRepository) renameJournalPath() string {
+	return filepath.Join(c.rootPath, ".meta", "links-rename.json")
+}
+
+// SealExistingLinks seals the links of all the files under the specified directory that are still plaintext,
+// or sealed bound to their name only, and returns the number of links sealed.
+// Links already sealed are skipped, so an interrupted migration is resumed by calling it again.
+// Link encryption must be enabled, and the user must have access to all the directories.
+func (c *LinkRepository) SealExistingLinks(path string) (int, error) {
+	if !c.encryptLinks {
+		return 0, ErrLinkEncryptionOff
+	}
+	files, err := c.listFiles(path)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, file := range files {
+		data, err := c.readLink(file)
+		if err != nil {
+			return count, err
+		}
+		link, current, err := c.openBoundLink(file, file, data, nil)
+		if err != nil {
+			return count, fmt.Errorf("cannot open link of %s: %v", file, err)
+		}
+		if current {
+			continue
+		}
+		if err := c.Update(file, link); err != nil {
+			return count, fmt.Errorf("cannot seal link of %s: %v", file, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// listFiles returns the plaintext paths of all the files under the specified directory.
+func (c *LinkRepository) listFiles(path string) ([]string, error) {
+	subFiles, err := c.GetSubFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, subFile := range subFiles {
+		if subFile.Name() == ".meta" {
+			continue
+		}
+		p := filepath.Join(path, subFile.Name())
+		if !subFile.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		subs, err := c.listFiles(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, subs...)
+	}
+	return files, nil
+}
+
 // CreateDir creates a directory at the specified path.
 // If the directory already exists, it does nothing.
 // It returns an error if there was a problem creating the directory.
 func (c *LinkRepository) CreateDir(path string) (err error) {
 	// Create the directory
-	absPath := filepath.Join(c.rootPath, path)
+	absPath, err := c.resolver.Prepare(path)
+	if err != nil {
+		return err
+	}
 	err = os.MkdirAll(absPath, os.ModePerm)
 	if err != nil {
 		return err
@@ -133,27 +426,57 @@ func (c *LinkRepository) CreateDir(path string) (err error) {
 
 // GetSubFiles returns a list of sub-files in the specified directory path.
 // It takes a path string as input and returns a slice of os.FileInfo and an error.
+// The names of the sub-files are the plaintext names. If name encryption is enabled,
+// the sub-files whose names cannot be decrypted are skipped and logged.
 func (c *LinkRepository) GetSubFiles(path string) ([]os.FileInfo, error) {
 	// Make sure the path is a directory
 	if !c.IsDir(path) {
 		return nil, ErrPathIsNotDir
 	}
 	// Read the sub-files
-	p := filepath.Join(c.rootPath, path)
+	p, err := c.resolver.Abs(path)
+	if err != nil {
+		return nil, err
+	}
 	file, err := os.Open(p)
 	if err != nil {
 		return nil, fmt.Errorf("error opening dir to Read sub files: %v", err)
 	}
 	defer file.Close()
 	subFiles, _ := file.Readdir(0)
-	return subFiles, nil
+	if !c.resolver.IsEncryptNames() {
+		return subFiles, nil
+	}
+	// Decrypt the names of the sub-files
+	res := make([]os.FileInfo, 0, len(subFiles))
+	for _, subFile := range subFiles {
+		if subFile.Name() == ".meta" {
+			continue
+		}
+		name, err := c.resolver.DecryptName(path, subFile.Name())
+		if err != nil {
+			log.Warn("Skipping sub file ", subFile.Name(), " of ", path, ": cannot decrypt its name. error: ", err)
+			continue
+		}
+		res = append(res, plainFileInfo{FileInfo: subFile, name: name})
+	}
+	return res, nil
+}
+
+// ReencryptNames encrypts again the names of the sub-files of the specified directory
+// after its vault key is replaced with a new one.
+func (c *LinkRepository) ReencryptNames(path string, oldVaultKey core.Key, newVaultKey core.Key) error {
+	return c.resolver.ReencryptNames(path, oldVaultKey, newVaultKey)
 }
 
 // IsDir checks if the given path is a valid directory.
 // It returns true if the path is a directory.
 // It returns false if the path is not a directory or if there was an issue accessing the file system.
 func (c *LinkRepository) IsDir(path string) bool {
-	p := filepath.Join(c.rootPath, path)
+	p, err := c.resolver.Abs(path)
+	if err != nil {
+		return false
+	}
 	fi, err := os.Stat(p)
 	if err != nil {
 		return false
@@ -165,7 +488,10 @@ func (c *LinkRepository) IsDir(path string) bool {
 // It returns true if the path is a file.
 // It returns false if the path is not a file or if there was an issue accessing the file system.
 func (c *LinkRepository) IsFile(path string) bool {
-	p := filepath.Join(c.rootPath, path)
+	p, err := c.resolver.Abs(path)
+	if err != nil {
+		return false
+	}
 	fi, err := os.Stat(p)
 	if err != nil {
 		return false
@@ -175,8 +501,11 @@ func (c *LinkRepository) IsFile(path string) bool {
 
 // IsValidPath checks if the given path is a valid path.
 func (c *LinkRepository) IsValidPath(path string) bool {
-	absPath := filepath.Join(c.rootPath, path)
-	_, err := os.Stat(absPath)
+	absPath, err := c.resolver.Abs(path)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(absPath)
 	return err == nil
 }
 
@@ -184,3 +513,92 @@ func (c *LinkRepository) IsValidPath(path string) bool {
 func (c *LinkRepository) GetRootPath() string {
 	return c.rootPath
 }
+
+// sealLink serializes the link of the file located at the specified path.
+// If link encryption is enabled, the link is sealed with the vault key of the parent directory
+// and the full path of the file is used as associated data, so that the link cannot be moved to another file.
+func (c *LinkRepository) sealLink(path string, link core.Link) ([]byte, error) {
+	js, err := json.Marshal(link)
+	if err != nil {
+		return nil, err
+	}
+	if !c.encryptLinks {
+		return js, nil
+	}
+	vaultKey, err := c.resolver.DirKey(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := record_crypto.Seal(*vaultKey, record_crypto.LinkV2Info, js, linkAssociatedData(path))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(sealed), nil
+}
+
+// openLink deserializes the link of the file located at the specified path.
+// Plaintext links are JSON objects; any other content is opened as an encrypted link,
+// with the given vault key, or with the vault key of the parent directory if it is nil.
+func (c *LinkRepository) openLink(path string, data []byte, vaultKey *core.Key) (core.Link, error) {
+	link, _, err := c.openBoundLink(path, path, data, vaultKey)
+	return link, err
+}
+
+// openBoundLink deserializes the link of the file located at the specified path like openLink,
+// but an encrypted link is opened with the bound path as associated data. The bound path differs from the path
+// when the link is read after its parent directory is renamed.
+// Links sealed before the full path was bound are opened with the name only, unless the links are migrated:
+// the plaintext links are then refused with ErrUnsealedLink, and the links bound to their name only with ErrOpeningLink.
+// It also returns true if the link is sealed bound to the full path.
+func (c *LinkRepository) openBoundLink(path string, boundPath string, data []byte, vaultKey *core.Key) (core.Link, bool, error) {
+	js := data
+	current := false
+	plaintext := bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+	if plaintext && c.linksMigrated {
+		return core.Link{}, false, ErrUnsealedLink
+	}
+	if !plaintext {
+		var err error
+		if vaultKey == nil {
+			vaultKey, err = c.resolver.DirKey(filepath.Dir(path))
+			if err != nil {
+				return core.Link{}, false, err
+			}
+		}
+		js, err = record_crypto.Open(*vaultKey, record_crypto.LinkV2Info, string(data), linkAssociatedData(boundPath))
+		if err == nil {
+			current = true
+		} else if c.linksMigrated {
+			return core.Link{}, false, ErrOpeningLink
+		} else {
+			js, err = record_crypto.Open(*vaultKey, record_crypto.LinkV1Info, string(data), []byte(filepath.Base(boundPath)))
+			if err != nil {
+				return core.Link{}, false, ErrOpeningLink
+			}
+		}
+	}
+	var link core.Link
+	err := json.Unmarshal(js, &link)
+	if err != nil {
+		return core.Link{}, false, fmt.Errorf("error unmarshalink link file: %v", err)
+	}
+	return link, current, nil
+}
+
+// linkAssociatedData returns the associated data of the encrypted link of the file located at the specified path.
+// The full plaintext path is used, so a link cannot be replaced by the link of another file of the same directory
+// or of a directory sharing the same vault key.
+func linkAssociatedData(path string) []byte {
+	return []byte(filepath.ToSlash(cleanPlainPath(path)))
+}
+
+// plainFileInfo is an os.FileInfo of a stored file with its plaintext name.
+type plainFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+// Name returns the plaintext name of the file.
+func (f plainFileInfo) Name() string {
+	return f.name
+}
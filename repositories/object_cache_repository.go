@@ -1,16 +1,32 @@
 package repositories
 
 import (
+	"ctb-cli/core"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 type ObjectCacheRepository struct {
 	resolver  func(id string, writer io.Writer) (err error)
 	readPath  string
 	writePath string
+	dirty     *dirtyChunks
+}
+
+// dirtyChunks keeps track of the chunks modified in the write cache since the object was moved to it.
+// An object without tracking state (e.g. created in the write cache) is considered entirely modified.
+type dirtyChunks struct {
+	sync.Mutex
+	objects map[string]*dirtyState
+}
+
+// dirtyState is the tracking state of an object in the write cache.
+type dirtyState struct {
+	chunks        map[int64]struct{} // indexes of the modified chunks
+	truncatedFrom int64              // all the chunks from this offset are modified, or -1
 }
 
 func NewObjectCacheRepository(path string) ObjectCacheRepository {
@@ -22,6 +38,7 @@ func NewObjectCacheRepository(path string) ObjectCacheRepository {
 	return ObjectCacheRepository{
 		readPath:  path,
 		writePath: writePath,
+		dirty:     &dirtyChunks{objects: make(map[string]*dirtyState)},
 	}
 }
 
@@ -38,6 +55,10 @@ func (o *ObjectCacheRepository) Move(oldId string, newId string) (err error) {
 	if err != nil {
 		return
 	}
+	//Start tracking the modified chunks, the moved object is not modified yet
+	o.dirty.Lock()
+	o.dirty.objects[newId] = &dirtyState{chunks: make(map[int64]struct{}), truncatedFrom: -1}
+	o.dirty.Unlock()
 	return nil
 }
 
@@ -58,6 +79,7 @@ func (o *ObjectCacheRepository) Write(id string, buff []byte, ofst int64) (n int
 		return 0, err
 	}
 	n, err = file.WriteAt(buff, ofst)
+	o.markDirty(id, ofst, int64(n))
 	return
 }
 
@@ -68,10 +90,17 @@ func (o *ObjectCacheRepository) Truncate(id string, size int64) (err error) {
 		return err
 	}
 	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
 	err = file.Truncate(size)
 	if err != nil {
 		return err
 	}
+	if size != stat.Size() {
+		o.markTruncated(id, size)
+	}
 	return nil
 }
 
@@ -119,9 +148,57 @@ func (o *ObjectCacheRepository) IsInCache(id string) (is bool) {
 func (o *ObjectCacheRepository) Flush(id string) (err error) {
 	p := filepath.Join(o.writePath, id)
 	err = os.Remove(p)
+	o.dirty.Lock()
+	delete(o.dirty.objects, id)
+	o.dirty.Unlock()
 	return
 }
 
+// IsChunkDirty returns true if the chunk with the given index (of size core.ObjectChunkSize)
+// has been modified in the write cache. If the object is not tracked, all the chunks are considered modified.
+func (o *ObjectCacheRepository) IsChunkDirty(id string, idx int64) bool {
+	o.dirty.Lock()
+	defer o.dirty.Unlock()
+	state, ok := o.dirty.objects[id]
+	if !ok {
+		return true
+	}
+	if state.truncatedFrom >= 0 && idx >= state.truncatedFrom/core.ObjectChunkSize {
+		return true
+	}
+	_, ok = state.chunks[idx]
+	return ok
+}
+
+// markDirty marks the chunks covering the written range as modified.
+func (o *ObjectCacheRepository) markDirty(id string, ofst int64, size int64) {
+	if size <= 0 {
+		return
+	}
+	o.dirty.Lock()
+	defer o.dirty.Unlock()
+	state, ok := o.dirty.objects[id]
+	if !ok {
+		return
+	}
+	for idx := ofst / core.ObjectChunkSize; idx <= (ofst+size-1)/core.ObjectChunkSize; idx++ {
+		state.chunks[idx] = struct{}{}
+	}
+}
+
+// markTruncated marks the chunks from the truncated size as modified.
+func (o *ObjectCacheRepository) markTruncated(id string, size int64) {
+	o.dirty.Lock()
+	defer o.dirty.Unlock()
+	state, ok := o.dirty.objects[id]
+	if !ok {
+		return
+	}
+	if state.truncatedFrom < 0 || size < state.truncatedFrom {
+		state.truncatedFrom = size
+	}
+}
+
 func (o *ObjectCacheRepository) AsFile(id string) (file *os.File, err error) {
 	p := filepath.Join(o.readPath, id)
 	file, err = os.OpenFile(p, os.O_RDONLY, 0666)
@@ -8,16 +8,21 @@ import (
 
 type ObjectRepository struct {
 	rootPath string
+	resolver *PathResolver
 }
 
-func NewObjectRepository(rootPath string) ObjectRepository {
+func NewObjectRepository(rootPath string, resolver *PathResolver) ObjectRepository {
 	return ObjectRepository{
 		rootPath: rootPath,
+		resolver: resolver,
 	}
 }
 
 func (o *ObjectRepository) IsInRepo(id string, dir string) (is bool) {
-	p := o.GetPath(id, dir)
+	p, err := o.GetPath(id, dir)
+	if err != nil {
+		return false
+	}
 	if _, err := os.Stat(p); os.IsNotExist(err) {
 		return false
 	}
@@ -25,24 +30,79 @@ func (o *ObjectRepository) IsInRepo(id string, dir string) (is bool) {
 }
 
 func (o *ObjectRepository) CreateFile(id string, dir string) (*os.File, error) {
-	path := o.GetPath(id, dir)
+	path, err := o.GetPath(id, dir)
+	if err != nil {
+		return nil, err
+	}
 	file, _ := os.Create(path)
 	return file, nil
 }
 
 func (o *ObjectRepository) OpenObject(id string, dir string) (io.ReadCloser, error) {
-	path := o.GetPath(id, dir)
+	path, err := o.GetPath(id, dir)
+	if err != nil {
+		return nil, err
+	}
 	file, _ := os.Open(path)
 	return file, nil
 }
 
+// OpenObjectFile opens the object file for random access.
+func (o *ObjectRepository) OpenObjectFile(id string, dir string) (*os.File, error) {
+	path, err := o.GetPath(id, dir)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
 func (o *ObjectRepository) ChangeDir(id string, oldDir string, newDir string) error {
-	oldPath := o.GetPath(id, oldDir)
-	newPath := o.GetPath(id, newDir)
+	oldPath, err := o.GetPath(id, oldDir)
+	if err != nil {
+		return err
+	}
+	newPath, err := o.GetPath(id, newDir)
+	if err != nil {
+		return err
+	}
 	return os.Rename(oldPath, newPath)
 }
 
-func (o *ObjectRepository) GetPath(id string, dir string) string {
-	path := filepath.Join(o.rootPath, dir, ".meta", ".object", id)
-	return path
+func (o *ObjectRepository) GetPath(id string, dir string) (string, error) {
+	absDir, err := o.resolver.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(absDir, ".meta", ".object", id)
+	return path, nil
+}
+
+// IsChunkInRepo returns true if the chunk with the specified ID is stored in the repository.
+func (o *ObjectRepository) IsChunkInRepo(id string) bool {
+	if _, err := os.Stat(o.GetChunkPath(id)); os.IsNotExist(err) {
+		return false
+	}
+	return true
+}
+
+// CreateChunkFile creates the file of the chunk with the specified ID.
+func (o *ObjectRepository) CreateChunkFile(id string) (*os.File, error) {
+	path := o.GetChunkPath(id)
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// OpenChunkFile opens the file of the chunk with the specified ID for random access.
+func (o *ObjectRepository) OpenChunkFile(id string) (*os.File, error) {
+	return os.Open(o.GetChunkPath(id))
+}
+
+// GetChunkPath returns the path of the chunk with the specified ID.
+// The chunks are stored in the root of the repository, since they may be shared
+// by several versions of an object and do not move with it.
+func (o *ObjectRepository) GetChunkPath(id string) string {
+	return filepath.Join(o.rootPath, ".meta", ".chunk", id)
 }
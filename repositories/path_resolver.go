@@ -0,0 +1,497 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/name_crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrDirKeyProviderNotSet  = errors.New("directory key provider is not set")
+	ErrNamesAlreadyEncrypted = errors.New("names are already encrypted")
+)
+
+// DirKeyProvider returns the vault key of the directory located at the given plaintext path.
+type DirKeyProvider func(dirPath string) (*core.Key, error)
+
+// PathResolver maps the plaintext paths used by the services to the paths stored in the repository.
+// If name encryption is enabled, each name is encrypted with a key derived from the vault key of its parent directory.
+// Otherwise, the plaintext path is used as it is.
+type PathResolver struct {
+	sync.Mutex
+	rootPath     string
+	encryptNames bool
+	keyProvider  DirKeyProvider
+	nameKeys     map[string]core.Key // name keys cached by plaintext directory path
+}
+
+// NewPathResolver creates a new PathResolver with name encryption disabled.
+func NewPathResolver(rootPath string) *PathResolver {
+	return &PathResolver{
+		rootPath: rootPath,
+		nameKeys: make(map[string]core.Key),
+	}
+}
+
+// SetEncryptNames enables or disables name encryption.
+func (r *PathResolver) SetEncryptNames(enabled bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.encryptNames = enabled
+}
+
+// IsEncryptNames returns true if name encryption is enabled.
+func (r *PathResolver) IsEncryptNames() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.encryptNames
+}
+
+// SetDirKeyProvider sets the function used to retrieve the vault key of a directory.
+func (r *PathResolver) SetDirKeyProvider(provider DirKeyProvider) {
+	r.keyProvider = provider
+}
+
+// Abs returns the absolute path of the stored file or directory for the given plaintext path.
+func (r *PathResolver) Abs(path string) (string, error) {
+	storageDir, encrypted, err := r.resolveParent(path)
+	if err != nil {
+		return "", err
+	}
+	if encrypted == "" {
+		return storageDir, nil
+	}
+	return filepath.Join(storageDir, r.storedName(encrypted)), nil
+}
+
+// Prepare returns the absolute path of the stored file or directory like Abs.
+// If the encrypted name of the last component is too long, it also stores the full encrypted name
+// in the long names folder of the parent directory. It should be called before creating a file or directory.
+func (r *PathResolver) Prepare(path string) (string, error) {
+	storageDir, encrypted, err := r.resolveParent(path)
+	if err != nil {
+		return "", err
+	}
+	if encrypted == "" {
+		return storageDir, nil
+	}
+	stored := r.storedName(encrypted)
+	if stored != encrypted {
+		// Store the full encrypted name to be able to decrypt the shortened name
+		namesFolder := longNamesFolder(storageDir)
+		if err := os.MkdirAll(namesFolder, os.ModePerm); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(namesFolder, stored), []byte(encrypted), 0666); err != nil {
+			return "", fmt.Errorf("error writing long name file: %v", err)
+		}
+	}
+	return filepath.Join(storageDir, stored), nil
+}
+
+// RemoveLongName removes the long name file of the last component of the path, if it exists.
+// It should be called after removing or renaming a file or directory.
+func (r *PathResolver) RemoveLongName(path string) error {
+	storageDir, encrypted, err := r.resolveParent(path)
+	if err != nil {
+		return err
+	}
+	stored := r.storedName(encrypted)
+	if encrypted == "" || stored == encrypted {
+		return nil
+	}
+	err = os.Remove(filepath.Join(longNamesFolder(storageDir), stored))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DecryptName returns the plaintext name of a stored name in the directory located at the given plaintext path.
+func (r *PathResolver) DecryptName(dirPath string, stored string) (string, error) {
+	if !r.IsEncryptNames() {
+		return stored, nil
+	}
+	encrypted := stored
+	if name_crypto.IsShortenedName(stored) {
+		// Read the full encrypted name from the long names folder
+		storageDir, err := r.Abs(dirPath)
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(filepath.Join(longNamesFolder(storageDir), stored))
+		if err != nil {
+			return "", fmt.Errorf("error reading long name file: %v", err)
+		}
+		encrypted = string(content)
+	}
+	key, err := r.nameKey(dirPath)
+	if err != nil {
+		return "", err
+	}
+	return name_crypto.DecryptName(key, encrypted)
+}
+
+// Forget removes the cached keys of the path and all its sub paths.
+// It should be called when a directory is renamed or removed.
+func (r *PathResolver) Forget(path string) {
+	r.Lock()
+	defer r.Unlock()
+	clean := cleanPlainPath(path)
+	for p := range r.nameKeys {
+		if p == clean || strings.HasPrefix(p, clean+string(filepath.Separator)) {
+			delete(r.nameKeys, p)
+		}
+	}
+}
+
+// namesJournal records the progress of the migration to encrypted names, so an interrupted migration can be resumed.
+type namesJournal struct {
+	Dirs []string `json:"dirs"` // plaintext paths of the directories, from the deepest to the root
+	Done int      `json:"done"` // number of directories whose entries are renamed
+}
+
+// EncryptExistingNames migrates a repository with plaintext names to encrypted names.
+// It first retrieves the name keys of all the directories, so it fails before renaming anything
+// if the user does not have access to one of the directories.
+// Then it renames the entries starting from the deepest directories, so the parent paths stay valid.
+// The progress is recorded in a journal in the repository: if the migration is interrupted, calling it again
+// resumes it with the remaining directories, and skips the entries of a directory whose names are already encrypted.
+// Name encryption is enabled when the migration is done. The journal is kept until CompleteNameMigration is called.
+func (r *PathResolver) EncryptExistingNames() error {
+	if r.IsEncryptNames() {
+		return ErrNamesAlreadyEncrypted
+	}
+	journal, err := r.readNamesJournal()
+	if err != nil {
+		return err
+	}
+	if journal == nil {
+		// Collect all the directories while the names are still plaintext
+		dirs, err := r.listPlainDirs(string(filepath.Separator))
+		if err != nil {
+			return err
+		}
+		// Sort the directories from the deepest to the root
+		sort.SliceStable(dirs, func(i, j int) bool {
+			return len(splitPlainPath(dirs[i])) > len(splitPlainPath(dirs[j]))
+		})
+		journal = &namesJournal{Dirs: dirs}
+	}
+	// Retrieve the name keys of the remaining directories
+	remaining := journal.Dirs[journal.Done:]
+	keys := make(map[string]core.Key, len(remaining))
+	for _, dir := range remaining {
+		key, err := r.nameKey(dir)
+		if err != nil {
+			return fmt.Errorf("cannot get name key of %s: %v", dir, err)
+		}
+		keys[dir] = key
+	}
+	if err := r.writeNamesJournal(journal); err != nil {
+		return err
+	}
+	for _, dir := range remaining {
+		if err := encryptDirNames(filepath.Join(r.rootPath, dir), keys[dir]); err != nil {
+			return err
+		}
+		journal.Done++
+		if err := r.writeNamesJournal(journal); err != nil {
+			return err
+		}
+	}
+	r.SetEncryptNames(true)
+	return nil
+}
+
+// CompleteNameMigration removes the journal of the migration to encrypted names.
+// It should be called once name encryption is enabled in the repository config.
+func (r *PathResolver) CompleteNameMigration() error {
+	err := os.Remove(r.namesJournalPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// encryptDirNames encrypts the plaintext names of the entries of the stored directory with the name key.
+// The entries whose names are already encrypted with the name key are skipped.
+func encryptDirNames(storageDir string, key core.Key) error {
+	entries, err := os.ReadDir(storageDir)
+	if err != nil {
+		return err
+	}
+	namesFolder := longNamesFolder(storageDir)
+	for _, entry := range entries {
+		if entry.Name() == ".meta" || isEncryptedName(namesFolder, entry.Name(), key) {
+			continue
+		}
+		encrypted, err := name_crypto.EncryptName(key, entry.Name())
+		if err != nil {
+			return err
+		}
+		stored := encrypted
+		if name_crypto.IsLongName(encrypted) {
+			stored = name_crypto.ShortenName(encrypted)
+			if err := os.MkdirAll(namesFolder, os.ModePerm); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(namesFolder, stored), []byte(encrypted), 0666); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(filepath.Join(storageDir, entry.Name()), filepath.Join(storageDir, stored)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEncryptedName returns true if the stored name is a name encrypted with the name key.
+func isEncryptedName(namesFolder string, stored string, key core.Key) bool {
+	encrypted := stored
+	if name_crypto.IsShortenedName(stored) {
+		content, err := os.ReadFile(filepath.Join(namesFolder, stored))
+		if err != nil {
+			return false
+		}
+		encrypted = string(content)
+	}
+	_, err := name_crypto.DecryptName(key, encrypted)
+	return err == nil
+}
+
+// readNamesJournal returns the journal of the migration to encrypted names, or nil if no migration was started.
+func (r *PathResolver) readNamesJournal() (*namesJournal, error) {
+	content, err := os.ReadFile(r.namesJournalPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading names journal: %v", err)
+	}
+	var journal namesJournal
+	if err := json.Unmarshal(content, &journal); err != nil {
+		return nil, fmt.Errorf("error reading names journal: %v", err)
+	}
+	if journal.Done > len(journal.Dirs) {
+		return nil, fmt.Errorf("error reading names journal: invalid progress")
+	}
+	return &journal, nil
+}
+
+// writeNamesJournal writes the journal of the migration to encrypted names.
+// It is written to a temporary file first, so an interruption does not leave a partial journal.
+func (r *PathResolver) writeNamesJournal(journal *namesJournal) error {
+	content, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+	tmp := r.namesJournalPath() + ".tmp"
+	if err := os.WriteFile(tmp, content, 0666); err != nil {
+		return fmt.Errorf("error writing names journal: %v", err)
+	}
+	return os.Rename(tmp, r.namesJournalPath())
+}
+
+// namesJournalPath returns the path of the journal of the migration to encrypted names.
+func (r *PathResolver) namesJournalPath() string {
+	return filepath.Join(r.rootPath, ".meta", "names-migration.json")
+}
+
+// ReencryptNames encrypts again the names of the entries of the directory located at the given plaintext path,
+// when the vault key of the directory is replaced. The names are decrypted with the old vault key
+// and encrypted with the new one. Entries whose names cannot be decrypted are left untouched.
+// It does nothing if name encryption is disabled.
+func (r *PathResolver) ReencryptNames(dirPath string, oldVaultKey core.Key, newVaultKey core.Key) error {
+	if !r.IsEncryptNames() {
+		return nil
+	}
+	storageDir, err := r.Abs(dirPath)
+	if err != nil {
+		return err
+	}
+	oldNameKey, err := name_crypto.DeriveNameKey(oldVaultKey)
+	if err != nil {
+		return err
+	}
+	newNameKey, err := name_crypto.DeriveNameKey(newVaultKey)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(storageDir)
+	if err != nil {
+		return err
+	}
+	namesFolder := longNamesFolder(storageDir)
+	for _, entry := range entries {
+		if entry.Name() == ".meta" {
+			continue
+		}
+		// Decrypt the name with the old name key
+		encrypted := entry.Name()
+		if name_crypto.IsShortenedName(encrypted) {
+			content, err := os.ReadFile(filepath.Join(namesFolder, entry.Name()))
+			if err != nil {
+				return fmt.Errorf("error reading long name file: %v", err)
+			}
+			encrypted = string(content)
+		}
+		name, err := name_crypto.DecryptName(oldNameKey, encrypted)
+		if err != nil {
+			continue
+		}
+		// Encrypt the name with the new name key
+		newEncrypted, err := name_crypto.EncryptName(newNameKey, name)
+		if err != nil {
+			return err
+		}
+		stored := newEncrypted
+		if name_crypto.IsLongName(newEncrypted) {
+			stored = name_crypto.ShortenName(newEncrypted)
+			if err := os.MkdirAll(namesFolder, os.ModePerm); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(namesFolder, stored), []byte(newEncrypted), 0666); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(filepath.Join(storageDir, entry.Name()), filepath.Join(storageDir, stored)); err != nil {
+			return err
+		}
+		if name_crypto.IsShortenedName(entry.Name()) {
+			if err := os.Remove(filepath.Join(namesFolder, entry.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	r.Forget(dirPath)
+	return nil
+}
+
+// listPlainDirs returns the plaintext path of the directory and all its sub directories.
+// It must only be used when the names are not encrypted.
+func (r *PathResolver) listPlainDirs(dir string) ([]string, error) {
+	list := []string{dir}
+	entries, err := os.ReadDir(filepath.Join(r.rootPath, dir))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == ".meta" {
+			continue
+		}
+		subs, err := r.listPlainDirs(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, subs...)
+	}
+	return list, nil
+}
+
+// resolveParent returns the absolute path of the stored parent directory and the encrypted name of the last component.
+// For the root path, it returns the root path and an empty name.
+func (r *PathResolver) resolveParent(path string) (storageDir string, encrypted string, err error) {
+	components := splitPlainPath(path)
+	if len(components) == 0 {
+		return r.rootPath, "", nil
+	}
+	if !r.IsEncryptNames() {
+		return filepath.Join(r.rootPath, filepath.Join(components[:len(components)-1]...)), components[len(components)-1], nil
+	}
+	storageDir = r.rootPath
+	plainDir := string(filepath.Separator)
+	for i, component := range components {
+		encrypted, err = r.encryptName(plainDir, component)
+		if err != nil {
+			return "", "", err
+		}
+		if i == len(components)-1 {
+			break
+		}
+		storageDir = filepath.Join(storageDir, r.storedName(encrypted))
+		plainDir = filepath.Join(plainDir, component)
+	}
+	return storageDir, encrypted, nil
+}
+
+// encryptName encrypts the name using the name key of the directory located at the given plaintext path.
+func (r *PathResolver) encryptName(dirPath string, name string) (string, error) {
+	key, err := r.nameKey(dirPath)
+	if err != nil {
+		return "", err
+	}
+	return name_crypto.EncryptName(key, name)
+}
+
+// storedName returns the name used to store the encrypted name, shortening it if needed.
+func (r *PathResolver) storedName(encrypted string) string {
+	if r.IsEncryptNames() && name_crypto.IsLongName(encrypted) {
+		return name_crypto.ShortenName(encrypted)
+	}
+	return encrypted
+}
+
+// DirKey returns the vault key of the directory located at the given plaintext path using the key provider.
+func (r *PathResolver) DirKey(dirPath string) (*core.Key, error) {
+	if r.keyProvider == nil {
+		return nil, ErrDirKeyProviderNotSet
+	}
+	return r.keyProvider(cleanPlainPath(dirPath))
+}
+
+// nameKey returns the name key of the directory located at the given plaintext path.
+// The keys are cached, and the lock is not held while calling the key provider since it resolves paths itself.
+func (r *PathResolver) nameKey(dirPath string) (core.Key, error) {
+	clean := cleanPlainPath(dirPath)
+	r.Lock()
+	key, ok := r.nameKeys[clean]
+	r.Unlock()
+	if ok {
+		return key, nil
+	}
+	if r.keyProvider == nil {
+		return core.EmptyKey(), ErrDirKeyProviderNotSet
+	}
+	vaultKey, err := r.keyProvider(clean)
+	if err != nil {
+		return core.EmptyKey(), err
+	}
+	key, err = name_crypto.DeriveNameKey(*vaultKey)
+	if err != nil {
+		return core.EmptyKey(), err
+	}
+	r.Lock()
+	r.nameKeys[clean] = key
+	r.Unlock()
+	return key, nil
+}
+
+// longNamesFolder returns the path of the folder storing the long names of the directory.
+func longNamesFolder(storageDir string) string {
+	return filepath.Join(storageDir, ".meta", ".names")
+}
+
+// splitPlainPath splits the plaintext path into its components.
+func splitPlainPath(path string) []string {
+	components := make([]string, 0)
+	for _, c := range strings.Split(filepath.ToSlash(path), "/") {
+		if c != "" && c != "." {
+			components = append(components, c)
+		}
+	}
+	return components
+}
+
+// cleanPlainPath returns the plaintext path in the canonical form used by the services (e.g. "/a/b").
+func cleanPlainPath(path string) string {
+	return filepath.Join(append([]string{string(filepath.Separator)}, splitPlainPath(path)...)...)
+}
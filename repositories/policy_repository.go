@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrPolicyNotFound = errors.New("the repository has no policy")
+)
+
+// PolicyRepository is an interface for persisting the policy of the repository
+type PolicyRepository interface {
+	Get() (core.RepositoryPolicy, error)
+	Save(policy core.RepositoryPolicy) error
+}
+
+type PolicyRepositoryFile struct {
+	rootPath string
+	signer   core.RecordSigner
+}
+
+var _ PolicyRepository = &PolicyRepositoryFile{}
+
+func NewPolicyRepositoryFile(rootPath string) *PolicyRepositoryFile {
+	return &PolicyRepositoryFile{
+		rootPath: rootPath,
+	}
+}
+
+// SetRecordSigner sets the signer of the policy.
+// The policy is signed when it is saved and verified when it is read.
+func (p *PolicyRepositoryFile) SetRecordSigner(signer core.RecordSigner) {
+	p.signer = signer
+}
+
+// Get returns the policy of the repository and verifies its signature.
+// It returns ErrPolicyNotFound if the repository has no policy, and the error of the signature status
+// if the policy is not signed by a verified user: an unsigned policy is refused even if the unsigned
+// records are accepted, since the policy was never written unsigned.
+func (p *PolicyRepositoryFile) Get() (core.RepositoryPolicy, error) {
+	content, err := os.ReadFile(p.policyPath())
+	if os.IsNotExist(err) {
+		return core.RepositoryPolicy{}, ErrPolicyNotFound
+	}
+	if err != nil {
+		return core.RepositoryPolicy{}, err
+	}
+	policy, err := core.UnmarshalRepositoryPolicy(content)
+	if err != nil {
+		return core.RepositoryPolicy{}, err
+	}
+	if p.signer == nil {
+		return policy, nil
+	}
+	payload, err := policy.SignedPayload()
+	if err != nil {
+		return core.RepositoryPolicy{}, err
+	}
+	status := p.signer.VerifyRecord(policy.Signature, sign_crypto.PolicyV1Info, payload)
+	if err := core.SignatureStatusError(status, false); err != nil {
+		return core.RepositoryPolicy{}, err
+	}
+	return policy, nil
+}
+
+// Save signs and writes the policy of the repository.
+func (p *PolicyRepositoryFile) Save(policy core.RepositoryPolicy) (err error) {
+	policy.Signature = nil
+	if p.signer != nil {
+		payload, err := policy.SignedPayload()
+		if err != nil {
+			return err
+		}
+		policy.Signature, err = p.signer.SignRecord(sign_crypto.PolicyV1Info, payload)
+		if err != nil {
+			return err
+		}
+	}
+	serialized, err := policy.Marshal()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p.policyPath()), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	// The policy is written to a temporary file first, so that an interrupted write does not remove the policy
+	tmp := p.policyPath() + ".tmp"
+	if err := os.WriteFile(tmp, serialized, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.policyPath())
+}
+
+// policyPath returns the path of the policy file, in the root of the repository.
+func (p *PolicyRepositoryFile) policyPath() string {
+	return filepath.Join(p.rootPath, ".meta", ".policy")
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// signatureSuffix is the suffix of the file of the signature of a record, next to the file of the record.
+const signatureSuffix = ".sig"
+
+// recordPayload returns the payload signed for a record made of the fields.
+func recordPayload(fields ...string) []byte {
+	return []byte(strings.Join(fields, "\x00"))
+}
+
+// writeSignature signs the payload of the record stored in the file p and writes the signature next to the file.
+// Nothing is written if there is no signer.
+func writeSignature(p string, info string, payload []byte, signer core.RecordSigner) error {
+	if signer == nil {
+		return nil
+	}
+	signature, err := signer.SignRecord(info, payload)
+	if err != nil {
+		return err
+	}
+	serialized, err := json.Marshal(signature)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p+signatureSuffix, serialized, 0644)
+}
+
+// readSignature returns the signature written next to the file p, or nil if the record is not signed.
+func readSignature(p string) (*core.Signature, error) {
+	content, err := os.ReadFile(p + signatureSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var signature core.Signature
+	if err := json.Unmarshal(content, &signature); err != nil {
+		return nil, core.ErrInvalidSignature
+	}
+	return &signature, nil
+}
+
+// verifySignature checks the signature written next to the file p against the payload of the record.
+// It returns the error of the verifier if the record must be refused: core.ErrInvalidSignature,
+// core.ErrUnknownSigner, or core.ErrUnsignedRecord if the signature was removed and the unsigned
+// records are not accepted. All the records are accepted if there is no verifier.
+func verifySignature(p string, info string, payload []byte, verifier core.RecordSigner) error {
+	if verifier == nil {
+		return nil
+	}
+	signature, err := readSignature(p)
+	if err != nil {
+		return err
+	}
+	return verifier.CheckRecord(signature, info, payload)
+}
+
+// removeSignature removes the signature written next to the file p, if any.
+func removeSignature(p string) error {
+	err := os.Remove(p + signatureSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// isRefusedRecord returns true if the error is the error of a record refused by the verifier.
+func isRefusedRecord(err error) bool {
+	return errors.Is(err, core.ErrInvalidSignature) || errors.Is(err, core.ErrUnknownSigner) || errors.Is(err, core.ErrUnsignedRecord) ||
+		errors.Is(err, core.ErrUnauthorizedGroupWriter)
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrSigningIdentityNotFound = errors.New("signing identity not found")
+)
+
+// SignerRepository is an interface for persisting the signing identities of the users
+type SignerRepository interface {
+	Get(publicKey string) (core.SigningIdentity, error)
+	Save(identity core.SigningIdentity) error
+}
+
+type SignerRepositoryFile struct {
+	rootPath string
+}
+
+var _ SignerRepository = &SignerRepositoryFile{}
+
+func NewSignerRepositoryFile(rootPath string) *SignerRepositoryFile {
+	return &SignerRepositoryFile{
+		rootPath: rootPath,
+	}
+}
+
+// Get returns the signing identity of the user with the public key.
+// It returns ErrSigningIdentityNotFound if the user has not published a signing identity.
+func (s *SignerRepositoryFile) Get(publicKey string) (core.SigningIdentity, error) {
+	content, err := os.ReadFile(filepath.Join(s.signersFolder(), filepath.Base(publicKey)))
+	if os.IsNotExist(err) {
+		return core.SigningIdentity{}, ErrSigningIdentityNotFound
+	}
+	if err != nil {
+		return core.SigningIdentity{}, err
+	}
+	return core.UnmarshalSigningIdentity(content)
+}
+
+// Save writes the signing identity.
+func (s *SignerRepositoryFile) Save(identity core.SigningIdentity) error {
+	serialized, err := identity.Marshal()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(s.signersFolder(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.signersFolder(), identity.PublicKey), serialized, 0644)
+}
+
+// signersFolder returns the folder of the signing identities, in the root of the repository.
+func (s *SignerRepositoryFile) signersFolder() string {
+	return filepath.Join(s.rootPath, ".meta", ".signers")
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// UserRepository is an interface for persisting the users having several devices
+type UserRepository interface {
+	Get(userId string) (core.User, error)
+	Save(user core.User) error
+	List() ([]core.User, error)
+}
+
+type UserRepositoryFile struct {
+	rootPath string
+}
+
+var _ UserRepository = &UserRepositoryFile{}
+
+func NewUserRepositoryFile(rootPath string) *UserRepositoryFile {
+	return &UserRepositoryFile{
+		rootPath: rootPath,
+	}
+}
+
+// Get returns the user with the specified id.
+// It returns ErrUserNotFound if the user has no user record, i.e. the user has a single device.
+func (u *UserRepositoryFile) Get(userId string) (core.User, error) {
+	content, err := os.ReadFile(filepath.Join(u.usersFolder(), userId))
+	if os.IsNotExist(err) {
+		return core.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return core.User{}, err
+	}
+	return core.UnmarshalUser(content)
+}
+
+// Save writes the user record.
+func (u *UserRepositoryFile) Save(user core.User) error {
+	serialized, err := user.Marshal()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(u.usersFolder(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(u.usersFolder(), user.Id), serialized, 0644)
+}
+
+// List returns the users having a user record.
+func (u *UserRepositoryFile) List() ([]core.User, error) {
+	list := make([]core.User, 0)
+	entries, err := os.ReadDir(u.usersFolder())
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		user, err := u.Get(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, user)
+	}
+	return list, nil
+}
+
+// usersFolder returns the folder of the user records, in the root of the repository.
+func (u *UserRepositoryFile) usersFolder() string {
+	return filepath.Join(u.rootPath, ".meta", ".users")
+}
@@ -2,32 +2,42 @@ package repositories
 
 import (
 	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // VaultRepository KeyStorePersist is an interface for persisting keys
 type VaultRepository interface {
 	GetVault(vaultId string, vaultPath string) (core.Vault, error)
+	GetVaultSignature(path string) (*core.Signature, string, error)
 	SaveVault(vault core.Vault, vaultPath string) (err error)
 	InsertVault(vault core.Vault, vaultPath string) error
 	AddKeyToVault(vault *core.Vault, vaultPath string, keyId string, serialized string) error
 	GetKey(keyId string, vaultId string, vaultPath string) (string, bool)
+	ListKeys(vaultId string, vaultPath string) ([]string, error)
 	RemoveKey(keyId string, vaultId string, vaultPath string) error
 	GetVaultParent(vaultPath string) (string, core.Vault, error)
 	GetVaultByPath(path string) (core.Vault, error)
 	RemoveVaultLink(path string) error
 	GetFileVault(path string) (core.Vault, string, error)
+	GetVaultMetadata(path string) (string, error)
+	SetVaultMetadata(path string, sealed string) error
 }
 
 type VaultRepositoryFile struct {
 	rootPath string
+	resolver *PathResolver
+	signer   core.RecordSigner
 }
 
 type vaultLink struct {
 	VaultId string `json:"vaultId"`
+	// Metadata is the metadata of the directory, sealed with the vault key of the directory
+	Metadata string `json:"metadata,omitempty"`
 }
 
 func NewVaultLink(vaultId string, keyId string) vaultLink {
@@ -38,19 +48,81 @@ func NewVaultLink(vaultId string, keyId string) vaultLink {
 
 var _ VaultRepository = &VaultRepositoryFile{}
 
-func NewVaultRepositoryFile(rootPath string) *VaultRepositoryFile {
+func NewVaultRepositoryFile(rootPath string, resolver *PathResolver) *VaultRepositoryFile {
 	return &VaultRepositoryFile{
 		rootPath: rootPath,
+		resolver: resolver,
 	}
 }
 
+// SetRecordSigner sets the signer of the vaults and of the keys sealed in the vaults.
+// The vaults and the keys are signed when they are saved, and their signatures are verified when they are read.
+func (k *VaultRepositoryFile) SetRecordSigner(signer core.RecordSigner) {
+	k.signer = signer
+}
+
+// GetVault reads the vault and verifies its signature.
+// It returns the error of the signer if the vault must be refused, such as core.ErrInvalidSignature
+// if the signature of the vault is not valid.
 func (k *VaultRepositoryFile) GetVault(vaultId string, vaultPath string) (core.Vault, error) {
-	p := k.vaultFile(vaultId, vaultPath)
-	content, err := os.ReadFile(p)
+	vault, payload, err := k.readVaultFile(vaultId, vaultPath)
 	if err != nil {
 		return core.Vault{}, err
 	}
-	return core.UnmarshalVault(content)
+	if k.signer == nil {
+		return vault, nil
+	}
+	if err := k.signer.CheckRecord(vault.Signature, sign_crypto.VaultV1Info, payload); err != nil {
+		return core.Vault{}, err
+	}
+	return vault, nil
+}
+
+// GetVaultSignature returns the signature of the vault of the directory located at the path,
+// with the status of the signature.
+func (k *VaultRepositoryFile) GetVaultSignature(path string) (*core.Signature, string, error) {
+	link, err := k.getVaultLinkByPath(path)
+	if err != nil {
+		return nil, "", err
+	}
+	vault, status, err := k.readVault(link.VaultId, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return vault.Signature, status, nil
+}
+
+// readVault reads the vault and returns the status of its signature.
+func (k *VaultRepositoryFile) readVault(vaultId string, vaultPath string) (core.Vault, string, error) {
+	vault, payload, err := k.readVaultFile(vaultId, vaultPath)
+	if err != nil {
+		return core.Vault{}, "", err
+	}
+	if k.signer == nil {
+		return vault, core.SignatureUnsigned, nil
+	}
+	return vault, k.signer.VerifyRecord(vault.Signature, sign_crypto.VaultV1Info, payload), nil
+}
+
+// readVaultFile reads the vault and returns it with the payload of its signature.
+func (k *VaultRepositoryFile) readVaultFile(vaultId string, vaultPath string) (core.Vault, []byte, error) {
+	p, err := k.vaultFile(vaultId, vaultPath)
+	if err != nil {
+		return core.Vault{}, nil, err
+	}
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return core.Vault{}, nil, err
+	}
+	vault, err := core.UnmarshalVault(content)
+	if err != nil {
+		return core.Vault{}, nil, err
+	}
+	payload, err := vault.SignedPayload()
+	if err != nil {
+		return core.Vault{}, nil, err
+	}
+	return vault, payload, nil
 }
 
 func (k *VaultRepositoryFile) InsertVault(vault core.Vault, vaultPath string) error {
@@ -58,7 +130,10 @@ func (k *VaultRepositoryFile) InsertVault(vault core.Vault, vaultPath string) er
 	if err != nil {
 		return err
 	}
-	insidePath := k.vaultKeyFolder(vault.Id, vaultPath)
+	insidePath, err := k.vaultKeyFolder(vault.Id, vaultPath)
+	if err != nil {
+		return err
+	}
 	err = os.MkdirAll(insidePath, os.ModePerm)
 	if err != nil {
 		return err
@@ -75,12 +150,26 @@ func (k *VaultRepositoryFile) InsertVault(vault core.Vault, vaultPath string) er
 }
 
 func (k *VaultRepositoryFile) SaveVault(vault core.Vault, vaultPath string) (err error) {
-	p := k.vaultFile(vault.Id, vaultPath)
+	p, err := k.vaultFile(vault.Id, vaultPath)
+	if err != nil {
+		return err
+	}
 	file, err := os.Create(p)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
+	vault.Signature = nil
+	if k.signer != nil {
+		payload, err := vault.SignedPayload()
+		if err != nil {
+			return err
+		}
+		vault.Signature, err = k.signer.SignRecord(sign_crypto.VaultV1Info, payload)
+		if err != nil {
+			return err
+		}
+	}
 	serialized, err := vault.Marshal()
 	if err != nil {
 		return fmt.Errorf("error serializing vault")
@@ -89,7 +178,10 @@ func (k *VaultRepositoryFile) SaveVault(vault core.Vault, vaultPath string) (err
 	if err != nil {
 		return err
 	}
-	insidePath := k.vaultKeyFolder(vault.Id, vaultPath)
+	insidePath, err := k.vaultKeyFolder(vault.Id, vaultPath)
+	if err != nil {
+		return err
+	}
 	err = os.MkdirAll(insidePath, os.ModePerm)
 	if err != nil {
 		return err
@@ -98,17 +190,49 @@ func (k *VaultRepositoryFile) SaveVault(vault core.Vault, vaultPath string) (err
 }
 
 func (k *VaultRepositoryFile) GetKey(keyId string, vaultId string, vaultPath string) (string, bool) {
-	path := filepath.Join(k.vaultKeyFolder(vaultId, vaultPath), keyId)
-	b, err := os.ReadFile(path)
+	folder, err := k.vaultKeyFolder(vaultId, vaultPath)
+	if err != nil {
+		return "", false
+	}
+	p := filepath.Join(folder, keyId)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", false
+	}
+	// A key with an invalid signature is not used
+	err = verifySignature(p, sign_crypto.VaultKeyV1Info, recordPayload(vaultId, keyId, string(b)), k.signer)
 	if err != nil {
 		return "", false
 	}
 	return string(b), true
 }
 
+// ListKeys returns the IDs of the keys sealed in the vault.
+func (k *VaultRepositoryFile) ListKeys(vaultId string, vaultPath string) ([]string, error) {
+	folder, err := k.vaultKeyFolder(vaultId, vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	keyIds := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasSuffix(entry.Name(), signatureSuffix) {
+			keyIds = append(keyIds, entry.Name())
+		}
+	}
+	return keyIds, nil
+}
+
 func (k *VaultRepositoryFile) AddKeyToVault(vault *core.Vault, vaultPath string, keyId string, serialized string) error {
-	path := filepath.Join(k.vaultKeyFolder(vault.Id, vaultPath), keyId)
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	folder, err := k.vaultKeyFolder(vault.Id, vaultPath)
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(folder, keyId)
+	file, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
@@ -117,12 +241,20 @@ func (k *VaultRepositoryFile) AddKeyToVault(vault *core.Vault, vaultPath string,
 	if err != nil {
 		return err
 	}
-	return nil
+	return writeSignature(p, sign_crypto.VaultKeyV1Info, recordPayload(vault.Id, keyId, serialized), k.signer)
 }
 
 func (k *VaultRepositoryFile) RemoveKey(keyId string, vaultId string, vaultPath string) error {
-	path := filepath.Join(k.vaultKeyFolder(vaultId, vaultPath), keyId)
-	return os.Remove(path)
+	folder, err := k.vaultKeyFolder(vaultId, vaultPath)
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(folder, keyId)
+	err = os.Remove(p)
+	if err != nil {
+		return err
+	}
+	return removeSignature(p)
 }
 
 func (k *VaultRepositoryFile) GetVaultParent(vaultPath string) (string, core.Vault, error) {
@@ -159,7 +291,10 @@ func (k *VaultRepositoryFile) GetVaultByPath(path string) (core.Vault, error) {
 // If an error occurs during file reading or unmarshaling, it returns an empty VaultLink object and the error.
 func (k *VaultRepositoryFile) getVaultLinkByPath(path string) (vaultLink, error) {
 	// Read the vault link file
-	p := k.getVaultLinkPath(path)
+	p, err := k.getVaultLinkPath(path)
+	if err != nil {
+		return vaultLink{}, err
+	}
 	js, err := os.ReadFile(p)
 	if err != nil {
 		return vaultLink{}, fmt.Errorf("error reading vault link file: %v", err)
@@ -172,11 +307,42 @@ func (k *VaultRepositoryFile) getVaultLinkByPath(path string) (vaultLink, error)
 	return link, nil
 }
 
+// GetVaultMetadata returns the sealed metadata of the directory at the specified path,
+// stored in its vault link. It returns an empty string if the directory has no metadata.
+func (k *VaultRepositoryFile) GetVaultMetadata(path string) (string, error) {
+	link, err := k.getVaultLinkByPath(path)
+	if err != nil {
+		return "", err
+	}
+	return link.Metadata, nil
+}
+
+// SetVaultMetadata stores the sealed metadata of the directory at the specified path in its vault link.
+func (k *VaultRepositoryFile) SetVaultMetadata(path string, sealed string) error {
+	link, err := k.getVaultLinkByPath(path)
+	if err != nil {
+		return err
+	}
+	link.Metadata = sealed
+	p, err := k.getVaultLinkPath(path)
+	if err != nil {
+		return err
+	}
+	js, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, js, 0666)
+}
+
 // RemoveVaultLink removes the vault link file for the specified path.
 // It takes the path of the link file as input and returns an error if any.
 func (k *VaultRepositoryFile) RemoveVaultLink(path string) error {
-	absPath := k.getVaultLinkPath(path)
-	err := os.Remove(absPath)
+	absPath, err := k.getVaultLinkPath(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(absPath)
 	if err != nil {
 		return ErrRemovingVaultLinkFile
 	}
@@ -196,8 +362,11 @@ func (k *VaultRepositoryFile) GetFileVault(path string) (core.Vault, string, err
 // The link data is serialized as JSON before writing to the file.
 // If any error occurs during the process, it is returned.
 func (k *VaultRepositoryFile) insertVaultLink(path string, link vaultLink) error {
-	absPath := k.getVaultLinkPath(path)
-	err := os.MkdirAll(filepath.Dir(absPath), os.ModePerm)
+	absPath, err := k.getVaultLinkPath(path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(absPath), os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -212,21 +381,37 @@ func (k *VaultRepositoryFile) insertVaultLink(path string, link vaultLink) error
 }
 
 // vaultFolder returns the path to the vault folder for the specified path.
-func (k *VaultRepositoryFile) vaultFolder(vaultPath string) string {
-	return filepath.Join(k.rootPath, vaultPath, ".meta", ".vault")
+func (k *VaultRepositoryFile) vaultFolder(vaultPath string) (string, error) {
+	absPath, err := k.resolver.Abs(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(absPath, ".meta", ".vault"), nil
 }
 
 // getVaultLinkPath returns the path to the vault link file for the specified path.
-func (k *VaultRepositoryFile) getVaultLinkPath(path string) string {
-	return filepath.Join(k.vaultFolder(path), ".link")
+func (k *VaultRepositoryFile) getVaultLinkPath(path string) (string, error) {
+	folder, err := k.vaultFolder(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, ".link"), nil
 }
 
 // vaultKeyFolder returns the path to the key folder for the specified vault ID and path.
-func (k *VaultRepositoryFile) vaultKeyFolder(vaultId string, vaultPath string) string {
-	return filepath.Join(k.vaultFolder(vaultPath), "."+vaultId)
+func (k *VaultRepositoryFile) vaultKeyFolder(vaultId string, vaultPath string) (string, error) {
+	folder, err := k.vaultFolder(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, "."+vaultId), nil
 }
 
 // vaultFile returns the path to the vault file for the specified vault ID and path.
-func (k *VaultRepositoryFile) vaultFile(vaultId string, vaultPath string) string {
-	return filepath.Join(k.vaultFolder(vaultPath), vaultId)
+func (k *VaultRepositoryFile) vaultFile(vaultId string, vaultPath string) (string, error) {
+	folder, err := k.vaultFolder(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, vaultId), nil
 }
@@ -1,6 +1,9 @@
 package config_service
 
 import (
+	"ctb-cli/config"
+	"ctb-cli/crypto/stream"
+	"ctb-cli/repositories"
 	"path/filepath"
 
 	"github.com/spf13/viper"
@@ -9,23 +12,28 @@ import (
 // Config represents the configuration of the application
 type ConfigService struct {
 	rootPath string
+	resolver *repositories.PathResolver
 }
 
 // New returns a new Config
-func New(rootPath string) *ConfigService {
+func New(rootPath string, resolver *repositories.PathResolver) *ConfigService {
 	return &ConfigService{
 		rootPath: rootPath,
+		resolver: resolver,
 	}
 }
 
 // InitConfig generates the configuration file for the repository.
 func (c *ConfigService) InitConfig(path string) error {
-	configPath := c.getConfigPath(path)
+	configPath, err := c.getConfigPath(path)
+	if err != nil {
+		return err
+	}
 	cfg := viper.New()
 	// Set the default values for the configuration
 	cfg.SetConfigFile(filepath.Join(configPath, "config.yaml"))
 	cfg.Set("version", 1)
-	err := cfg.WriteConfig()
+	err = cfg.WriteConfig()
 	if err != nil {
 		return err
 	}
@@ -34,18 +42,165 @@ func (c *ConfigService) InitConfig(path string) error {
 
 // IsRepositoryConfigExists checks if the repository configuration exists.
 func (c *ConfigService) IsRepositoryConfigExists(path string) bool {
-	err := c.getConfig(path).ReadInConfig()
+	cfg, err := c.getConfig(path)
+	if err != nil {
+		return false
+	}
+	err = cfg.ReadInConfig()
 	return err == nil
 }
 
 // GetRepoVersion returns the version of the repository.
 func (c *ConfigService) GetRepoVersion(path string) string {
-	return c.getConfig(path).GetString("version")
+	cfg, err := c.getConfig(path)
+	if err != nil {
+		return ""
+	}
+	return cfg.GetString("version")
+}
+
+// IsNameEncryptionEnabled returns true if the names of the repository files and directories are encrypted.
+// It is read from the configuration of the repository root.
+func (c *ConfigService) IsNameEncryptionEnabled() bool {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return false
+	}
+	return cfg.GetBool("encrypt_names")
+}
+
+// SetNameEncryption enables or disables name encryption in the configuration of the repository root.
+func (c *ConfigService) SetNameEncryption(enabled bool) error {
+	return c.setRootValue("encrypt_names", enabled)
+}
+
+// IsLinkEncryptionEnabled returns true if the file links of the repository are encrypted.
+// It is read from the configuration of the repository root, and link encryption stays enabled once the links are migrated.
+func (c *ConfigService) IsLinkEncryptionEnabled() bool {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return false
+	}
+	return cfg.GetBool("encrypt_links") || cfg.GetBool("links_migrated")
+}
+
+// SetLinkEncryption enables or disables link encryption in the configuration of the repository root.
+func (c *ConfigService) SetLinkEncryption(enabled bool) error {
+	return c.setRootValue("encrypt_links", enabled)
+}
+
+// IsLinksMigrated returns true if all the file links of the repository are sealed bound to their full path,
+// since the repository was created with link encryption or its links were migrated. The plaintext links and the links
+// bound to their name only are then refused. It is read from the configuration of the repository root.
+func (c *ConfigService) IsLinksMigrated() bool {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return false
+	}
+	return cfg.GetBool("links_migrated")
+}
+
+// SetLinksMigrated records in the configuration of the repository root whether all the file links are sealed
+// bound to their full path.
+func (c *ConfigService) SetLinksMigrated(migrated bool) error {
+	return c.setRootValue("links_migrated", migrated)
+}
+
+// IsSizePaddingEnabled returns true if the objects of the repository are padded to hide the exact file sizes.
+// It is read from the configuration of the repository root.
+func (c *ConfigService) IsSizePaddingEnabled() bool {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return false
+	}
+	return cfg.GetBool("pad_sizes")
+}
+
+// SetSizePadding enables or disables size padding in the configuration of the repository root.
+func (c *ConfigService) SetSizePadding(enabled bool) error {
+	return c.setRootValue("pad_sizes", enabled)
+}
+
+// GetEncryptionAlgorithm returns the AEAD algorithm used to encrypt the new objects of the repository.
+// It is read from the configuration of the repository root, and is ChaCha20-Poly1305 if it is not set or not supported.
+func (c *ConfigService) GetEncryptionAlgorithm() string {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return stream.ChaCha20Poly1305
+	}
+	alg, err := stream.ParseAlgorithm(cfg.GetString("encryption_algorithm"))
+	if err != nil {
+		return stream.ChaCha20Poly1305
+	}
+	return alg
+}
+
+// SetEncryptionAlgorithm sets the AEAD algorithm used to encrypt the new objects in the configuration of the repository root.
+// The name is "chacha20-poly1305" or "aes-256-gcm". The existing objects are decrypted with the algorithm of their header.
+func (c *ConfigService) SetEncryptionAlgorithm(name string) error {
+	if _, err := stream.ParseAlgorithm(name); err != nil {
+		return err
+	}
+	return c.setRootValue("encryption_algorithm", name)
+}
+
+// IsHybridKeyWrappingRequired returns true if the data keys of the repository are sealed with the hybrid
+// X25519 + ML-KEM-768 scheme only. It is read from the configuration of the repository root, which is not signed:
+// it can require the hybrid key wrapping, but the requirement of the user configuration cannot be removed by it.
+func (c *ConfigService) IsHybridKeyWrappingRequired() bool {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return false
+	}
+	return cfg.GetBool("hybrid_key_wrapping")
+}
+
+// SetHybridKeyWrapping sets in the configuration of the repository root whether the data keys are sealed
+// with the hybrid X25519 + ML-KEM-768 scheme only.
+func (c *ConfigService) SetHybridKeyWrapping(required bool) error {
+	return c.setRootValue("hybrid_key_wrapping", required)
+}
+
+// GetRecoveryPublicKey returns the encoded recovery public key recorded in the configuration of the repository root
+// by the previous versions, or an empty string. It is not used since the configuration is not signed: the recovery
+// key is recorded in the policy of the repository.
+func (c *ConfigService) GetRecoveryPublicKey() string {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return ""
+	}
+	return cfg.GetString("recovery_public_key")
+}
+
+// GetStorage returns the storage section of the configuration of the repository root.
+// It overrides the storage configuration of the user.
+func (c *ConfigService) GetStorage() config.Storage {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return config.Storage{}
+	}
+	return config.ReadStorage(cfg)
+}
+
+// setRootValue sets a value in the configuration of the repository root and writes it.
+func (c *ConfigService) setRootValue(key string, value interface{}) error {
+	cfg, err := c.getConfig("")
+	if err != nil {
+		return err
+	}
+	if err := cfg.ReadInConfig(); err != nil {
+		return err
+	}
+	cfg.Set(key, value)
+	return cfg.WriteConfig()
 }
 
 // GetRepoConfig returns the configuration of the path.
-func (c *ConfigService) getConfig(path string) *viper.Viper {
-	configPath := c.getConfigPath(path)
+func (c *ConfigService) getConfig(path string) (*viper.Viper, error) {
+	configPath, err := c.getConfigPath(path)
+	if err != nil {
+		return nil, err
+	}
 	cfg := viper.New()
 	cfg.SetConfigName("config")
 	cfg.SetConfigType("yaml")
@@ -53,9 +208,13 @@ func (c *ConfigService) getConfig(path string) *viper.Viper {
 
 	_ = cfg.ReadInConfig()
 
-	return cfg
+	return cfg, nil
 }
 
-func (c *ConfigService) getConfigPath(path string) string {
-	return filepath.Join(c.rootPath, path, ".meta")
+func (c *ConfigService) getConfigPath(path string) (string, error) {
+	absPath, err := c.resolver.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(absPath, ".meta"), nil
 }
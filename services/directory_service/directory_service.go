@@ -0,0 +1,270 @@
+package directory_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/repositories"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidAlias     = errors.New("invalid alias: an alias must not be empty, contain spaces or be a public key")
+	ErrAliasAlreadyUsed = errors.New("alias is already used by another user")
+	ErrUnknownRecipient = errors.New("recipient is neither a public key nor an alias of the user directory")
+	ErrAmbiguousAlias   = errors.New("several users of the user directory have the alias; use the public key of the user")
+	ErrUntrustedEntry   = errors.New("the entry of the user directory is not signed by the owner of the repository or by you")
+	ErrNotEntrySigner   = errors.New("only the user who added the entry or the owner of the repository can remove it")
+)
+
+// StatusUntrusted is the status of an entry whose signature is valid, but whose signer is neither
+// the owner of the repository nor the current user.
+const StatusUntrusted = "untrusted"
+
+// VerifiedEntry is an entry of the user directory with the status of its signature.
+type VerifiedEntry struct {
+	core.DirectoryEntry
+	Status string
+}
+
+// Service manages the user directory of the repository: the aliases, names and emails of the users,
+// signed by the users who added them.
+// The directory is writable by every user of the repository, so an entry is only trusted if it is signed
+// by a trust anchor: the owner of the repository, who wrote the root vault, or the current user.
+type Service struct {
+	directoryRepository repositories.DirectoryRepository
+	vaultRepository     repositories.VaultRepository
+	keyService          core.KeyService
+}
+
+// NewService creates a new instance of the directory service
+func NewService(directoryRepository repositories.DirectoryRepository, vaultRepository repositories.VaultRepository, keyService core.KeyService) *Service {
+	return &Service{
+		directoryRepository: directoryRepository,
+		vaultRepository:     vaultRepository,
+		keyService:          keyService,
+	}
+}
+
+// Add adds the user with the public key to the directory, or replaces its entry, and signs the entry
+// with the signing key of the current user.
+// It returns ErrAliasAlreadyUsed if the alias is the alias of another user.
+func (s *Service) Add(publicKey core.PublicKey, alias string, name string, email string) (core.DirectoryEntry, error) {
+	if !IsValidAlias(alias) {
+		return core.DirectoryEntry{}, ErrInvalidAlias
+	}
+	existing, err := s.findByAlias(alias)
+	if err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	for _, entry := range existing {
+		if entry.PublicKey != publicKey.String() {
+			return core.DirectoryEntry{}, ErrAliasAlreadyUsed
+		}
+	}
+	addedBy, err := s.keyService.GetPublicKey()
+	if err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	signerKey, err := s.keyService.GetSigningPublicKey()
+	if err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	entry := core.DirectoryEntry{
+		PublicKey: publicKey.String(),
+		Alias:     alias,
+		Name:      name,
+		Email:     email,
+		AddedBy:   addedBy.String(),
+		SignerKey: signerKey,
+		AddedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	payload, err := entry.SignedPayload()
+	if err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	// The entry is signed as a record, so that the signing identity of the user, which binds its signing key
+	// to its public key, is published with it
+	signature, err := s.keyService.SignRecord(sign_crypto.DirectoryV1Info, payload)
+	if err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	entry.Signature = signature.Value
+	if err := s.directoryRepository.Save(entry); err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	return entry, nil
+}
+
+// List returns the entries of the directory, sorted by alias, with the status of their signatures.
+func (s *Service) List() ([]VerifiedEntry, error) {
+	entries, err := s.directoryRepository.List()
+	if err != nil {
+		return nil, err
+	}
+	anchors, err := s.trustAnchors()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]VerifiedEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, VerifiedEntry{
+			DirectoryEntry: entry,
+			Status:         s.verify(entry, anchors),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Alias < list[j].Alias })
+	return list, nil
+}
+
+// Get returns the entry of the user with the public key.
+// It returns repositories.ErrDirectoryEntryNotFound if the user is not in the directory,
+// and ErrUntrustedEntry if the entry is not verified under a trust anchor.
+func (s *Service) Get(publicKey string) (core.DirectoryEntry, error) {
+	entry, err := s.directoryRepository.Get(publicKey)
+	if err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	anchors, err := s.trustAnchors()
+	if err != nil {
+		return core.DirectoryEntry{}, err
+	}
+	if s.verify(entry, anchors) != core.SignatureVerified {
+		return core.DirectoryEntry{}, ErrUntrustedEntry
+	}
+	return entry, nil
+}
+
+// Remove removes the user with the alias or public key from the directory.
+// Only the user who added the entry or the owner of the repository can remove it.
+func (s *Service) Remove(aliasOrPublicKey string) error {
+	var entry core.DirectoryEntry
+	if publicKey, err := core.NewPublicKeyFromEncoded(aliasOrPublicKey); err == nil {
+		if entry, err = s.directoryRepository.Get(publicKey.String()); err != nil {
+			return err
+		}
+	} else {
+		entries, err := s.findByAlias(aliasOrPublicKey)
+		if err != nil {
+			return err
+		}
+		switch len(entries) {
+		case 0:
+			return ErrUnknownRecipient
+		case 1:
+			entry = entries[0]
+		default:
+			return ErrAmbiguousAlias
+		}
+	}
+	remover, err := s.keyService.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	if entry.AddedBy != remover.String() && remover.String() != s.owner() {
+		return ErrNotEntrySigner
+	}
+	return s.directoryRepository.Delete(entry.PublicKey)
+}
+
+// Resolve returns the public key of a recipient given as a public key or as an alias of the directory.
+// A public key is returned in its base58 encoding, so that an SSH public key is resolved to the X25519 key it converts to.
+// An alias is only resolved if a single entry has it and the entry is verified under a trust anchor.
+// It returns ErrUnknownRecipient if the recipient is neither, ErrAmbiguousAlias if several entries have the alias,
+// and ErrUntrustedEntry if the entry with the alias is not verified.
+func (s *Service) Resolve(recipient string) (string, error) {
+	if publicKey, err := core.NewPublicKeyFromEncoded(recipient); err == nil {
+		return publicKey.String(), nil
+	}
+	entries, err := s.findByAlias(recipient)
+	if err != nil {
+		return "", err
+	}
+	switch len(entries) {
+	case 0:
+		return "", ErrUnknownRecipient
+	case 1:
+	default:
+		return "", ErrAmbiguousAlias
+	}
+	anchors, err := s.trustAnchors()
+	if err != nil {
+		return "", err
+	}
+	if s.verify(entries[0], anchors) != core.SignatureVerified {
+		return "", ErrUntrustedEntry
+	}
+	return entries[0].PublicKey, nil
+}
+
+// findByAlias returns the entries with the alias, whatever the status of their signatures.
+func (s *Service) findByAlias(alias string) ([]core.DirectoryEntry, error) {
+	entries, err := s.directoryRepository.List()
+	if err != nil {
+		return nil, err
+	}
+	var found []core.DirectoryEntry
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Alias, alias) {
+			found = append(found, entry)
+		}
+	}
+	return found, nil
+}
+
+// trustAnchors returns the public keys of the users whose entries are trusted: the current user and the owner
+// of the repository.
+func (s *Service) trustAnchors() (map[string]bool, error) {
+	publicKey, err := s.keyService.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	anchors := map[string]bool{publicKey.String(): true}
+	if owner := s.owner(); owner != "" {
+		anchors[owner] = true
+	}
+	return anchors, nil
+}
+
+// owner returns the public key of the owner of the repository: the writer of the root vault, if its signature
+// is verified. It returns an empty string if the owner is unknown, such as in a repository without a root vault.
+func (s *Service) owner() string {
+	signature, status, err := s.vaultRepository.GetVaultSignature("/")
+	if err != nil || signature == nil || status != core.SignatureVerified {
+		return ""
+	}
+	return signature.Writer
+}
+
+// verify returns the status of the signature of the entry.
+// An entry is verified if it is signed with the signing key bound to the public key of the user who added it,
+// and if this user is a trust anchor. A self signed entry of another user is only untrusted: anyone can sign
+// an entry for its own key with any alias.
+func (s *Service) verify(entry core.DirectoryEntry, anchors map[string]bool) string {
+	payload, err := entry.SignedPayload()
+	if err != nil {
+		return core.SignatureInvalid
+	}
+	signature := &core.Signature{
+		Writer:    entry.AddedBy,
+		SignerKey: entry.SignerKey,
+		Value:     entry.Signature,
+	}
+	status := s.keyService.VerifyRecord(signature, sign_crypto.DirectoryV1Info, payload)
+	if status == core.SignatureVerified && !anchors[entry.AddedBy] {
+		return StatusUntrusted
+	}
+	return status
+}
+
+// IsValidAlias returns true if the alias is not empty, has no spaces and cannot be taken for a public key.
+func IsValidAlias(alias string) bool {
+	if alias == "" || strings.IndexFunc(alias, unicode.IsSpace) >= 0 {
+		return false
+	}
+	_, err := core.NewPublicKeyFromEncoded(alias)
+	return err != nil
+}
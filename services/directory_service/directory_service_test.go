@@ -0,0 +1,198 @@
+package directory_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/repositories"
+	"ctb-cli/services/key_service"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testUser is a user of a repository in a temporary directory, with its key store and directory service.
+type testUser struct {
+	publicKey  core.PublicKey
+	keyStore   *key_service.KeyStoreDefault
+	directory  *Service
+	repository repositories.DirectoryRepository
+}
+
+// newTestRepo creates an empty repository in a temporary directory.
+func newTestRepo(t *testing.T) string {
+	root := t.TempDir()
+	for _, folder := range core.GetRepoSystemFolderNames() {
+		if err := os.MkdirAll(filepath.Join(root, ".meta", folder), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// newTestUser opens the repository with the key of a new user.
+func newTestUser(t *testing.T, root string) *testUser {
+	t.Helper()
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := repositories.NewPathResolver(root)
+	keyRepository := repositories.NewKeyRepositoryFile(root, resolver)
+	vaultRepository := repositories.NewVaultRepositoryFile(root, resolver)
+	directoryRepository := repositories.NewDirectoryRepositoryFile(root)
+	ks := key_service.NewKeyStore(keyRepository, vaultRepository, repositories.NewUserRepositoryFile(root),
+		repositories.NewGroupRepositoryFile(root), repositories.NewSignerRepositoryFile(root))
+	keyRepository.SetRecordSigner(ks)
+	vaultRepository.SetRecordSigner(ks)
+	ks.SetPrivateKey(privateKey)
+	return &testUser{
+		publicKey:  publicKey,
+		keyStore:   ks,
+		directory:  NewService(directoryRepository, vaultRepository, ks),
+		repository: directoryRepository,
+	}
+}
+
+// selfSign writes an entry of the user for its own key, bypassing the checks of Add, as any writer of the repository can.
+func (u *testUser) selfSign(t *testing.T, alias string) {
+	t.Helper()
+	signingKey, err := u.keyStore.GetSigningPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := core.DirectoryEntry{
+		PublicKey: u.publicKey.String(),
+		Alias:     alias,
+		AddedBy:   u.publicKey.String(),
+		SignerKey: signingKey,
+		AddedAt:   time.Now().UTC(),
+	}
+	payload, err := entry.SignedPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := u.keyStore.SignRecord(sign_crypto.DirectoryV1Info, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Signature = signature.Value
+	if err := u.repository.Save(entry); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveTrustAnchors(t *testing.T) {
+	root := newTestRepo(t)
+	owner := newTestUser(t, root)
+	if _, err := owner.keyStore.CreateVault("", "/"); err != nil {
+		t.Fatal(err)
+	}
+	alice := newTestUser(t, root)
+	bob := newTestUser(t, root)
+	mallory := newTestUser(t, root)
+
+	// The entries added by the owner are trusted by every user
+	if _, err := owner.directory.Add(alice.publicKey, "alice", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if publicKey, err := bob.directory.Resolve("alice"); err != nil || publicKey != alice.publicKey.String() {
+		t.Errorf("Expected alice to be resolved, got %s, %v", publicKey, err)
+	}
+
+	// The entries added by a user are trusted by this user only
+	if _, err := bob.directory.Add(mallory.publicKey, "mallory", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.directory.Resolve("mallory"); err != nil {
+		t.Errorf("Expected the entry added by bob to be resolved by bob, got %v", err)
+	}
+	if _, err := alice.directory.Resolve("mallory"); err != ErrUntrustedEntry {
+		t.Errorf("Expected the entry added by bob to be untrusted by alice, got %v", err)
+	}
+
+	// A self signed entry is not trusted
+	mallory.selfSign(t, "carol")
+	if _, err := bob.directory.Resolve("carol"); err != ErrUntrustedEntry {
+		t.Errorf("Expected a self signed entry to be untrusted, got %v", err)
+	}
+	list, err := bob.directory.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range list {
+		if entry.Alias == "carol" && entry.Status != StatusUntrusted {
+			t.Errorf("Expected the self signed entry to be listed as untrusted, got %s", entry.Status)
+		}
+	}
+
+	// An alias claimed by another entry is ambiguous
+	mallory.selfSign(t, "ALICE")
+	if _, err := bob.directory.Resolve("alice"); err != ErrAmbiguousAlias {
+		t.Errorf("Expected the alias to be ambiguous, got %v", err)
+	}
+}
+
+func TestResolveTamperedEntry(t *testing.T) {
+	root := newTestRepo(t)
+	owner := newTestUser(t, root)
+	if _, err := owner.keyStore.CreateVault("", "/"); err != nil {
+		t.Fatal(err)
+	}
+	alice := newTestUser(t, root)
+	mallory := newTestUser(t, root)
+	if _, err := owner.directory.Add(alice.publicKey, "alice", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key of the entry is replaced by the key of mallory
+	entry, err := owner.repository.Get(alice.publicKey.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.repository.Delete(entry.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	entry.PublicKey = mallory.publicKey.String()
+	if err := owner.repository.Save(entry); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := owner.directory.Resolve("alice"); err != ErrUntrustedEntry {
+		t.Errorf("Expected the tampered entry to be refused, got %v", err)
+	}
+}
+
+func TestRemoveAuthorization(t *testing.T) {
+	root := newTestRepo(t)
+	owner := newTestUser(t, root)
+	if _, err := owner.keyStore.CreateVault("", "/"); err != nil {
+		t.Fatal(err)
+	}
+	alice := newTestUser(t, root)
+	bob := newTestUser(t, root)
+	if _, err := owner.directory.Add(alice.publicKey, "alice", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.directory.Add(bob.publicKey, "bob", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// A user cannot remove the entries added by another user
+	if err := bob.directory.Remove("alice"); err != ErrNotEntrySigner {
+		t.Errorf("Expected bob not to remove the entry of alice, got %v", err)
+	}
+	// The signer of the entry and the owner can
+	if err := alice.directory.Remove(bob.publicKey.String()); err != nil {
+		t.Errorf("Expected alice to remove the entry they added, got %v", err)
+	}
+	if err := owner.directory.Remove("alice"); err != nil {
+		t.Errorf("Expected the owner to remove the entry, got %v", err)
+	}
+	if _, err := owner.directory.Resolve("alice"); err != ErrUnknownRecipient {
+		t.Errorf("Expected the entry to be removed, got %v", err)
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"ctb-cli/services/config_service"
 	"ctb-cli/services/object_service"
 	"fmt"
+	"io"
 	"io/fs"
 	"path/filepath"
+	"time"
 )
 
 // FileSystem implements the FileSystem interface
@@ -89,15 +91,29 @@ func (f *FileSystem) CreateVaultInPath(path string) error {
 	return nil
 }
 
-// RemovePath removes the file or directory at the specified path.
+// RemovePath removes the file at the specified path, with its object and the chunks of the object.
+// The chunks are only found with the key of the file, so they are kept if the user has no access to it.
 func (f *FileSystem) RemovePath(path string) (err error) {
-	return f.linkRepo.Remove(path)
+	link, linkErr := f.linkRepo.GetByPath(path)
+	err = f.linkRepo.Remove(path)
+	if err != nil || linkErr != nil {
+		return err
+	}
+	vault, vaultPath, err := f.vaultRepo.GetFileVault(path)
+	if err != nil {
+		return err
+	}
+	return f.objectService.Remove(link.ObjectId, filepath.Dir(path), func(keyId string) (*core.KeyInfo, error) {
+		return f.keyService.Get(keyId, vault.Id, vaultPath)
+	})
 }
 
 // GetSubFiles returns a list of sub files in the specified path.
 // It ignores ".vault" files and creates file or directory info based on the sub file type.
 // For directories, it checks the user's access to the directory and sets the mode accordingly.
 // For files, it retrieves the file link and sets the size and user access mode.
+// If the file metadata can be opened, the modification time and mode bits are taken from it,
+// and the metadata is returned by the Sys method of the file info.
 // The function returns the list of file info and any error encountered during the process.
 func (f *FileSystem) GetSubFiles(path string) (res []fs.FileInfo, err error) {
 	//Get sub files in link repo
@@ -105,6 +121,8 @@ func (f *FileSystem) GetSubFiles(path string) (res []fs.FileInfo, err error) {
 	if err != nil {
 		return nil, err
 	}
+	//Get the vault key of the directory to open the file metadata (not available if the user has no access to the directory)
+	vaultKey, vaultKeyErr := f.keyService.GetVaultKeyByPath(path)
 	//Create list of file info
 	var infos []fs.FileInfo
 	//Iterate through sub files
@@ -115,11 +133,18 @@ func (f *FileSystem) GetSubFiles(path string) (res []fs.FileInfo, err error) {
 		}
 		if subFile.IsDir() {
 			//If sub file is a directory, create directory info
-			var info fs.FileInfo = FileInfo{
+			p := filepath.Join(path, subFile.Name())
+			info := FileInfo{
 				isDir: true,
 				name:  subFile.Name(),
 				//Check user access to directory (Read only if user has access to at least one file in the directory)
-				mode: f.GetUserFileAccess(filepath.Join(path, subFile.Name()), true),
+				mode: f.GetUserFileAccess(p, true),
+			}
+			//Open the directory metadata if available, with the vault key of the directory
+			if metadata, err := f.getDirMetadata(p); err == nil {
+				info.metadata = metadata
+				info.modTime = metadata.ModTime
+				info.mode &= fs.FileMode(metadata.Mode) & fs.ModePerm
 			}
 			//Add directory info to list
 			infos = append(infos, info)
@@ -129,16 +154,29 @@ func (f *FileSystem) GetSubFiles(path string) (res []fs.FileInfo, err error) {
 			p := filepath.Join(path, subFile.Name())
 			//Get file link
 			link, err := f.linkRepo.GetByPath(p)
+			if err != nil && f.linkRepo.IsEncryptLinks() {
+				//The encrypted link cannot be opened without the vault key of the directory
+				infos = append(infos, FileInfo{isDir: false, name: subFile.Name(), mode: 0000})
+				continue
+			}
 			if err != nil {
 				return nil, fmt.Errorf("error reading file size: %v", err)
 			}
-			var info fs.FileInfo = FileInfo{
+			info := FileInfo{
 				isDir: false,
 				name:  subFile.Name(),
 				size:  link.Size,
 				//Check user access to file
 				mode: f.GetUserFileAccess(filepath.Join(path, subFile.Name()), false),
 			}
+			//Open the file metadata if available
+			if link.Metadata != "" && vaultKeyErr == nil {
+				if metadata, err := openMetadata(link.Metadata, vaultKey.Key); err == nil {
+					info.metadata = metadata
+					info.modTime = metadata.ModTime
+					info.mode &= fs.FileMode(metadata.Mode) & fs.ModePerm
+				}
+			}
 			//Add file info to list
 			infos = append(infos, info)
 		}
@@ -170,10 +208,21 @@ func (f *FileSystem) CreateFile(path string) (err error) {
 	if err != nil {
 		return err
 	}
+	//Create the file metadata sealed with the vault key of the parent directory
+	vaultKey, err := f.keyService.GetVaultKeyByPath(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	metadata := core.NewFileMetadata(0777)
+	sealedMetadata, err := sealMetadata(&metadata, vaultKey.Key)
+	if err != nil {
+		return err
+	}
 	//Create file link
 	_ = f.linkRepo.Create(path, core.Link{
 		ObjectId: id,
 		Size:     0,
+		Metadata: sealedMetadata,
 	})
 	//Create file in object service
 	err = f.objectService.Create(id)
@@ -212,8 +261,10 @@ func (f *FileSystem) Write(path string, buff []byte, ofst int64) (n int, err err
 }
 
 // changeFileId changes the ID of a file identified by the given path.
-// It retrieves the file link from the link repository, updates the ID in the link repository,
-// and moves the file in the object service to the new ID.
+// If the file is marked to be encrypted again, none of the chunks of the previous version are reused.
+// It retrieves the file link from the link repository, decrypts the file to the object cache if needed,
+// updates the ID in the link repository, and moves the file in the object service to the new ID.
+// The object in the cache is truncated to the file size, since the stored object may be padded.
 // If any error occurs during the process, it is returned along with an empty string for the new ID.
 // Otherwise, the new ID is returned along with a nil error.
 func (f *FileSystem) changeFileId(path string) (newId string, err error) {
@@ -222,10 +273,21 @@ func (f *FileSystem) changeFileId(path string) (newId string, err error) {
 	if err != nil {
 		return "", err
 	}
-	//Change file id in link repo
+	//Make sure the whole file is decrypted in the object cache before writing to it
+	key, err := f.getFileKey(path, link)
+	if err != nil {
+		return "", err
+	}
+	err = f.objectService.LoadToCache(link.ObjectId, filepath.Dir(path), key)
+	if err != nil {
+		return "", err
+	}
+	//Change file id in link repo (the file is encrypted again entirely if requested by a key rotation)
 	oldId := link.ObjectId
 	newId, _ = core.NewUid()
 	link.ObjectId = newId
+	reencrypt := link.Reencrypt
+	link.Reencrypt = false
 	err = f.linkRepo.Update(path, link)
 	if err != nil {
 		return "", err
@@ -235,10 +297,20 @@ func (f *FileSystem) changeFileId(path string) (newId string, err error) {
 	if err != nil {
 		return "", err
 	}
+	if reencrypt {
+		f.objectService.ReencryptAll(newId)
+	}
+	//Remove the padding of the object from the cache
+	err = f.objectService.Truncate(newId, link.Size)
+	if err != nil {
+		return "", err
+	}
 	return newId, nil
 }
 
 // Read reads data from a file at the specified path into the provided buffer starting from the given offset.
+// Files that are not open for writing are read without decrypting the whole file to the cache.
+// The read is limited to the file size in the link, since the stored object may be padded.
 // It returns the number of bytes read and any error encountered.
 func (f *FileSystem) Read(path string, buff []byte, ofst int64) (n int, err error) {
 	dir := filepath.Dir(path)
@@ -247,23 +319,37 @@ func (f *FileSystem) Read(path string, buff []byte, ofst int64) (n int, err erro
 	if err != nil {
 		return 0, err
 	}
+	//Limit the read to the file size
+	if ofst >= link.Size {
+		return 0, io.EOF
+	}
+	if rest := link.Size - ofst; int64(len(buff)) > rest {
+		buff = buff[:rest]
+	}
+	//Get file key
+	key, err := f.getFileKey(path, link)
+	if err != nil {
+		return 0, err
+	}
+	//Read file (only the needed chunks are decrypted if the file is not in the cache)
+	return f.objectService.ReadAt(link.ObjectId, dir, buff, ofst, key)
+}
+
+// getFileKey returns the key of the file located at the specified path with the given link.
+// It returns an error if the user does not have access to the file key.
+func (f *FileSystem) getFileKey(path string, link core.Link) (*core.KeyInfo, error) {
 	//Get file vault
 	vault, vaultPath, err := f.vaultRepo.GetFileVault(path)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	//Get file key id
-	keyId, err := f.objectService.GetKeyIdByObjectId(link.ObjectId, dir)
+	keyId, err := f.objectService.GetKeyIdByObjectId(link.ObjectId, filepath.Dir(path))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	//Get file key
-	key, err := f.keyService.Get(keyId, vault.Id, vaultPath)
-	if err != nil {
-		return 0, err
-	}
-	//Read file
-	return f.objectService.Read(link.ObjectId, dir, buff, ofst, key)
+	return f.keyService.Get(keyId, vault.Id, vaultPath)
 }
 
 // Resize resizes a file to the specified size.
@@ -298,8 +384,13 @@ func (f *FileSystem) Resize(path string, size int64) (err error) {
 // If the oldPath and newPath are in different directories, the file or directory is moved to the new location.
 // If the path is a directory, the vault is moved to the new parent vault.
 // If the path is a file, the file key is moved to the new vault.
+// The rename of a directory is refused if the links under it cannot be sealed again with the new path.
 // Returns an error if any operation fails.
 func (f *FileSystem) Rename(oldPath string, newPath string) (err error) {
+	//Complete the interrupted rename of a directory, if any
+	if err := f.linkRepo.ResumeRename(); err != nil {
+		return err
+	}
 	//Check if the path is a directory
 	isDir := f.linkRepo.IsDir(oldPath)
 	//Get the vault links for the oldPath and newPath
@@ -314,6 +405,10 @@ func (f *FileSystem) Rename(oldPath string, newPath string) (err error) {
 		return err
 	}
 	if isDir {
+		//Check the links under the directory before moving its vault, so that a refused rename changes nothing
+		if err := f.linkRepo.CheckRename(oldPath); err != nil {
+			return err
+		}
 		//If the path is a directory, move the vault to the new parent vault
 		vault, err := f.vaultRepo.GetVaultByPath(oldPath)
 		if err != nil {
@@ -347,6 +442,11 @@ func (f *FileSystem) Rename(oldPath string, newPath string) (err error) {
 			if err != nil {
 				return err
 			}
+			//Seal the file metadata with the vault key of the new directory
+			if err := f.linkRepo.Rename(oldPath, newPath); err != nil {
+				return err
+			}
+			return f.resealMetadata(newPath, obj, oldDir, newDir)
 		}
 
 	}
@@ -356,11 +456,17 @@ func (f *FileSystem) Rename(oldPath string, newPath string) (err error) {
 // Commit commits changes made to a file at the specified path.
 // If the file is open for writing, it removes it from the list of open files,
 // retrieves the link associated with the path, generates a key in the vault,
-// and commits the changes using the object service.
+// sets the modification time in the file metadata, and commits the changes using the object service.
 // Returns an error if there was an issue retrieving the vault link or generating the key.
 // Returns nil if the file is not open for writing.
 // If the file is not open for writing, it removes the file from the object cache.
 func (f *FileSystem) Commit(path string) error {
+	return f.commit(path, true)
+}
+
+// commit commits changes made to a file at the specified path.
+// The modification time of the file is only updated if touch is true.
+func (f *FileSystem) commit(path string, touch bool) error {
 	_, ex := f.openToWrite[path]
 	// If the file is open for writing
 	if ex {
@@ -377,9 +483,20 @@ func (f *FileSystem) Commit(path string) error {
 		if err != nil {
 			return err
 		}
-		//Commit changes
+		//Set the modification time of the file
+		if touch {
+			err = f.UpdateMetadata(path, func(metadata *core.FileMetadata) {
+				now := time.Now()
+				metadata.ModTime = now
+				metadata.ChangeTime = now
+			})
+			if err != nil {
+				return err
+			}
+		}
+		//Commit changes (padding the object if enabled in the repository)
 		dir := filepath.Dir(path)
-		return f.objectService.Commit(link, dir, keyInfo)
+		return f.objectService.Commit(link, dir, keyInfo, f.configService.IsSizePaddingEnabled(), f.configService.GetEncryptionAlgorithm())
 	}
 	//Remove file from object cache if it is not open for writing
 	link, err := f.linkRepo.GetByPath(path)
@@ -0,0 +1,184 @@
+package filesystem_service
+
+import (
+	"ctb-cli/repositories"
+	"ctb-cli/services/config_service"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+// writeFile creates a file with the content at the path and commits it.
+func writeFile(t *testing.T, fs *FileSystem, path string, content string) {
+	t.Helper()
+	if err := fs.CreateFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Write(path, []byte(content), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Commit(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkFile checks that the file at the path is read with the content.
+func checkFile(t *testing.T, fs *FileSystem, path string, content string) {
+	t.Helper()
+	buff := make([]byte, 64)
+	n, err := fs.Read(path, buff, 0)
+	if err != nil {
+		t.Fatalf("Expected %s to be read, got %v", path, err)
+	}
+	if string(buff[:n]) != content {
+		t.Errorf("Expected the content of %s, got %q", path, buff[:n])
+	}
+}
+
+func TestRenameDirResealsLinks(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newKeyPair(t)
+	fs, _ := repo.open(t, owner)
+	if err := fs.CreateVaultInPath("/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"/docs", "/docs/sub", "/archive"} {
+		if err := fs.CreateDir(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, fs, "/docs/sub/a.txt", "a")
+	fs.Wait()
+
+	// The links bound to the old path are sealed again with the new path
+	if err := fs.Rename("/docs", "/archive/docs"); err != nil {
+		t.Fatal(err)
+	}
+	fs, _ = repo.open(t, owner)
+	checkFile(t, fs, "/archive/docs/sub/a.txt", "a")
+}
+
+func TestSealExistingLinks(t *testing.T) {
+	repo := newTestRepo(t)
+	config := config_service.New(repo.root, repositories.NewPathResolver(repo.root))
+	if err := config.SetLinkEncryption(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SetLinksMigrated(false); err != nil {
+		t.Fatal(err)
+	}
+	owner, _ := newKeyPair(t)
+	fs, _ := repo.open(t, owner)
+	if err := fs.CreateVaultInPath("/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.CreateDir("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, fs, "/a.txt", "a")
+	writeFile(t, fs, "/docs/b.txt", "b")
+	fs.Wait()
+
+	// The plaintext links are sealed, and the links already sealed are skipped
+	fs, _ = repo.open(t, owner)
+	fs.linkRepo.SetEncryptLinks(true)
+	if sealed, err := fs.linkRepo.SealExistingLinks("/"); err != nil || sealed != 2 {
+		t.Fatalf("Expected the 2 links to be sealed, got %d, %v", sealed, err)
+	}
+	if sealed, err := fs.linkRepo.SealExistingLinks("/"); err != nil || sealed != 0 {
+		t.Fatalf("Expected no link to be sealed again, got %d, %v", sealed, err)
+	}
+	if err := config.SetLinkEncryption(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SetLinksMigrated(true); err != nil {
+		t.Fatal(err)
+	}
+	fs, _ = repo.open(t, owner)
+	checkFile(t, fs, "/a.txt", "a")
+	checkFile(t, fs, "/docs/b.txt", "b")
+}
+
+func TestForgedPlaintextLinkRefused(t *testing.T) {
+	repo := newTestRepo(t)
+	// The names are plain, so that the link file of a.txt is found by its name
+	config := config_service.New(repo.root, repositories.NewPathResolver(repo.root))
+	if err := config.SetNameEncryption(false); err != nil {
+		t.Fatal(err)
+	}
+	owner, _ := newKeyPair(t)
+	fs, _ := repo.open(t, owner)
+	if err := fs.CreateVaultInPath("/"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, fs, "/a.txt", "a")
+	writeFile(t, fs, "/b.txt", "b")
+	fs.Wait()
+
+	// A writer of the repository replaces the link of a.txt with the plaintext link of b.txt
+	link, err := fs.linkRepo.GetByPath("/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := json.Marshal(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := repositories.NewPathResolver(repo.root).Abs("/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, plaintext, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The plaintext link is refused since all the links of the repository are sealed
+	fs, _ = repo.open(t, owner)
+	if _, err := fs.linkRepo.GetByPath("/a.txt"); !errors.Is(err, repositories.ErrUnsealedLink) {
+		t.Errorf("Expected the forged plaintext link to be refused, got %v", err)
+	}
+}
+
+func TestRenameDirWithUnopenableLinkRefused(t *testing.T) {
+	repo := newTestRepo(t)
+	// The names are plain, so that the link file of b.txt is found by its name
+	config := config_service.New(repo.root, repositories.NewPathResolver(repo.root))
+	if err := config.SetNameEncryption(false); err != nil {
+		t.Fatal(err)
+	}
+	owner, _ := newKeyPair(t)
+	fs, _ := repo.open(t, owner)
+	if err := fs.CreateVaultInPath("/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"/docs", "/docs/sub"} {
+		if err := fs.CreateDir(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, fs, "/docs/a.txt", "a")
+	writeFile(t, fs, "/docs/sub/b.txt", "b")
+	fs.Wait()
+
+	// The link of b.txt cannot be opened by the renamer
+	resolver := repositories.NewPathResolver(repo.root)
+	path, err := resolver.Abs("/docs/sub/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("unopenable"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rename is refused, rather than leaving the link bound to the old path
+	fs, _ = repo.open(t, owner)
+	if err := fs.Rename("/docs", "/archive"); !errors.Is(err, repositories.ErrCannotResealLink) {
+		t.Fatalf("Expected the rename to be refused, got %v", err)
+	}
+	if fs.linkRepo.IsDir("/archive") {
+		t.Error("Expected the directory not to be renamed")
+	}
+	fs, _ = repo.open(t, owner)
+	checkFile(t, fs, "/docs/a.txt", "a")
+}
@@ -0,0 +1,198 @@
+package filesystem_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/record_crypto"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+)
+
+var (
+	ErrMetadataNotFound = errors.New("file metadata not found")
+)
+
+// GetMetadata returns the metadata of the file or directory located at the specified path.
+// It returns ErrMetadataNotFound if the file has no metadata (e.g. created by an older version).
+func (f *FileSystem) GetMetadata(path string) (*core.FileMetadata, error) {
+	if f.linkRepo.IsDir(path) {
+		return f.getDirMetadata(path)
+	}
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if link.Metadata == "" {
+		return nil, ErrMetadataNotFound
+	}
+	vaultKey, err := f.keyService.GetVaultKeyByPath(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	return openMetadata(link.Metadata, vaultKey.Key)
+}
+
+// UpdateMetadata applies the update function to the metadata of the file located at the specified path,
+// then seals the metadata with the vault key of the parent directory and stores it in the file link.
+// The metadata of a directory is sealed with the vault key of the directory and stored in its vault link.
+// If the file has no metadata yet, the update function is applied to a new metadata.
+func (f *FileSystem) UpdateMetadata(path string, update func(metadata *core.FileMetadata)) error {
+	if f.linkRepo.IsDir(path) {
+		return f.updateDirMetadata(path, update)
+	}
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return err
+	}
+	vaultKey, err := f.keyService.GetVaultKeyByPath(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	// Get the current metadata or create a new one
+	var metadata *core.FileMetadata
+	if link.Metadata != "" {
+		metadata, err = openMetadata(link.Metadata, vaultKey.Key)
+		if err != nil {
+			return err
+		}
+	} else {
+		newMetadata := core.NewFileMetadata(0777)
+		metadata = &newMetadata
+	}
+	update(metadata)
+	// Seal the metadata and store it in the link
+	link.Metadata, err = sealMetadata(metadata, vaultKey.Key)
+	if err != nil {
+		return err
+	}
+	return f.linkRepo.Update(path, link)
+}
+
+// getDirMetadata returns the metadata of the directory located at the specified path, stored in its vault link.
+// It returns ErrMetadataNotFound if the directory has no metadata.
+func (f *FileSystem) getDirMetadata(path string) (*core.FileMetadata, error) {
+	sealed, err := f.vaultRepo.GetVaultMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	if sealed == "" {
+		return nil, ErrMetadataNotFound
+	}
+	vaultKey, err := f.keyService.GetVaultKeyByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return openDirMetadata(sealed, vaultKey.Key)
+}
+
+// updateDirMetadata applies the update function to the metadata of the directory located at the specified path,
+// then seals the metadata with the vault key of the directory and stores it in its vault link.
+func (f *FileSystem) updateDirMetadata(path string, update func(metadata *core.FileMetadata)) error {
+	sealed, err := f.vaultRepo.GetVaultMetadata(path)
+	if err != nil {
+		return err
+	}
+	vaultKey, err := f.keyService.GetVaultKeyByPath(path)
+	if err != nil {
+		return err
+	}
+	// Get the current metadata or create a new one
+	var metadata *core.FileMetadata
+	if sealed != "" {
+		metadata, err = openDirMetadata(sealed, vaultKey.Key)
+		if err != nil {
+			return err
+		}
+	} else {
+		newMetadata := core.NewFileMetadata(0777)
+		metadata = &newMetadata
+	}
+	update(metadata)
+	sealed, err = sealDirMetadata(metadata, vaultKey.Key)
+	if err != nil {
+		return err
+	}
+	return f.vaultRepo.SetVaultMetadata(path, sealed)
+}
+
+// resealMetadata opens the metadata of the link with the vault key of the old directory
+// and seals it with the vault key of the new directory, then updates the link at the specified path.
+// It is used when a file is moved to another directory.
+func (f *FileSystem) resealMetadata(path string, link core.Link, oldDir string, newDir string) error {
+	if link.Metadata == "" {
+		return nil
+	}
+	oldVaultKey, err := f.keyService.GetVaultKeyByPath(oldDir)
+	if err != nil {
+		return err
+	}
+	newVaultKey, err := f.keyService.GetVaultKeyByPath(newDir)
+	if err != nil {
+		return err
+	}
+	metadata, err := openMetadata(link.Metadata, oldVaultKey.Key)
+	if err != nil {
+		return err
+	}
+	link.Metadata, err = sealMetadata(metadata, newVaultKey.Key)
+	if err != nil {
+		return err
+	}
+	return f.linkRepo.Update(path, link)
+}
+
+// sealMetadata serializes the metadata of a file and seals it with the vault key of its directory.
+func sealMetadata(metadata *core.FileMetadata, vaultKey core.Key) (string, error) {
+	return sealRecord(metadata, vaultKey, record_crypto.FileMetadataV1Info)
+}
+
+// openMetadata opens the sealed metadata of a file with the vault key of its directory and deserializes it.
+func openMetadata(sealed string, vaultKey core.Key) (*core.FileMetadata, error) {
+	return openRecord(sealed, vaultKey, record_crypto.FileMetadataV1Info)
+}
+
+// sealDirMetadata serializes the metadata of a directory and seals it with the vault key of the directory.
+// It is sealed with its own info, so that it cannot be swapped with the metadata of a file of the directory.
+func sealDirMetadata(metadata *core.FileMetadata, vaultKey core.Key) (string, error) {
+	return sealRecord(metadata, vaultKey, record_crypto.DirMetadataV1Info)
+}
+
+// openDirMetadata opens the sealed metadata of a directory with the vault key of the directory and deserializes it.
+func openDirMetadata(sealed string, vaultKey core.Key) (*core.FileMetadata, error) {
+	return openRecord(sealed, vaultKey, record_crypto.DirMetadataV1Info)
+}
+
+// sealRecord serializes the metadata and seals it with the vault key and the info.
+func sealRecord(metadata *core.FileMetadata, vaultKey core.Key, info string) (string, error) {
+	js, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return record_crypto.Seal(vaultKey, info, js, nil)
+}
+
+// openRecord opens the sealed metadata with the vault key and the info and deserializes it.
+func openRecord(sealed string, vaultKey core.Key, info string) (*core.FileMetadata, error) {
+	js, err := record_crypto.Open(vaultKey, info, sealed, nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata core.FileMetadata
+	if err := json.Unmarshal(js, &metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// resealRecord seals the sealed metadata with the new vault key and the info,
+// if it is still sealed with the old vault key. It is used when a vault key is rotated.
+func resealRecord(sealed string, oldVaultKey core.Key, newVaultKey core.Key, info string) (string, error) {
+	if _, err := openRecord(sealed, newVaultKey, info); err == nil {
+		return sealed, nil
+	}
+	metadata, err := openRecord(sealed, oldVaultKey, info)
+	if err != nil {
+		return "", err
+	}
+	return sealRecord(metadata, newVaultKey, info)
+}
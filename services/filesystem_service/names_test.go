@@ -0,0 +1,86 @@
+package filesystem_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/name_crypto"
+	"ctb-cli/repositories"
+	"ctb-cli/services/config_service"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptExistingNamesResumed(t *testing.T) {
+	repo := newTestRepo(t)
+	config := config_service.New(repo.root, repositories.NewPathResolver(repo.root))
+	if err := config.SetNameEncryption(false); err != nil {
+		t.Fatal(err)
+	}
+	owner, _ := newKeyPair(t)
+	fs, ks := repo.open(t, owner)
+	if err := fs.CreateVaultInPath("/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.CreateDir("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/docs/a.txt", "/docs/b.txt"} {
+		if err := fs.CreateFile(path); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Write(path, []byte(path), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Commit(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs.Wait()
+
+	// The migration is interrupted after the first entry of the directory is renamed
+	vaultKey, err := ks.GetVaultKeyByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nameKey, err := name_crypto.DeriveNameKey(vaultKey.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := name_crypto.EncryptName(nameKey, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(repo.root, "docs", "a.txt"), filepath.Join(repo.root, "docs", encrypted)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The migration skips the encrypted entry and renames the others
+	resolver := repositories.NewPathResolver(repo.root)
+	resolver.SetDirKeyProvider(func(dirPath string) (*core.Key, error) {
+		key, err := ks.GetVaultKeyByPath(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		return &key.Key, nil
+	})
+	if err := resolver.EncryptExistingNames(); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SetNameEncryption(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolver.CompleteNameMigration(); err != nil {
+		t.Fatal(err)
+	}
+	fs, _ = repo.open(t, owner)
+	for _, path := range []string{"/docs/a.txt", "/docs/b.txt"} {
+		buff := make([]byte, 20)
+		n, err := fs.Read(path, buff, 0)
+		if err != nil {
+			t.Fatalf("Expected %s to be read after the migration, got %v", path, err)
+		}
+		if string(buff[:n]) != path {
+			t.Errorf("Expected the content of %s, got %q", path, buff[:n])
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package filesystem_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/objectstorage/local"
+	"ctb-cli/repositories"
+	"ctb-cli/services/config_service"
+	"ctb-cli/services/key_service"
+	"ctb-cli/services/object_service"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testRepo is a repository in a temporary directory, storing the objects in a local storage.
+type testRepo struct {
+	root    string
+	storage *local.Client
+}
+
+// newTestRepo creates an empty repository with encrypted names and links.
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	r := &testRepo{root: t.TempDir(), storage: local.NewClient(t.TempDir())}
+	for _, folder := range core.GetRepoSystemFolderNames() {
+		if err := os.MkdirAll(filepath.Join(r.root, ".meta", folder), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := config_service.New(r.root, repositories.NewPathResolver(r.root))
+	if err := config.InitConfig(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SetNameEncryption(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SetLinkEncryption(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SetLinksMigrated(true); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// open returns the file system and the key store of the repository opened with the private key,
+// as a new process of the user would.
+func (r *testRepo) open(t *testing.T, privateKey core.PrivateKey) (*FileSystem, *key_service.KeyStoreDefault) {
+	t.Helper()
+	resolver := repositories.NewPathResolver(r.root)
+	config := config_service.New(r.root, resolver)
+	resolver.SetEncryptNames(config.IsNameEncryptionEnabled())
+	keyRepository := repositories.NewKeyRepositoryFile(r.root, resolver)
+	vaultRepository := repositories.NewVaultRepositoryFile(r.root, resolver)
+	linkRepository := repositories.NewLinkRepository(r.root, resolver)
+	linkRepository.SetEncryptLinks(config.IsLinkEncryptionEnabled())
+	linkRepository.SetLinksMigrated(config.IsLinksMigrated())
+	cache := repositories.NewObjectCacheRepository(t.TempDir())
+	objectRepository := repositories.NewObjectRepository(r.root, resolver)
+	ks := key_service.NewKeyStore(keyRepository, vaultRepository, repositories.NewUserRepositoryFile(r.root),
+		repositories.NewGroupRepositoryFile(r.root), repositories.NewSignerRepositoryFile(r.root))
+	keyRepository.SetRecordSigner(ks)
+	vaultRepository.SetRecordSigner(ks)
+	objectService := object_service.NewService(&cache, &objectRepository, r.storage, ks)
+	resolver.SetDirKeyProvider(func(dirPath string) (*core.Key, error) {
+		key, err := ks.GetVaultKeyByPath(dirPath)
+		if err != nil {
+			return nil, err
+		}
+		return &key.Key, nil
+	})
+	ks.SetPrivateKey(privateKey)
+	return NewFileSystem(ks, objectService, linkRepository, vaultRepository, *config), ks
+}
+
+// newKeyPair returns a new private key and its public key.
+func newKeyPair(t *testing.T) (core.PrivateKey, core.PublicKey) {
+	t.Helper()
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, publicKey
+}
+
+func TestRecoverAndRead(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newKeyPair(t)
+	newOwner, newOwnerPublicKey := newKeyPair(t)
+	recoveryKey, recoveryPublicKey := newKeyPair(t)
+
+	// The repository is initialized with the recovery key, and a file is written in a top-level directory
+	fs, ks := repo.open(t, owner)
+	ks.SetRecoveryKey(&recoveryPublicKey)
+	if err := fs.CreateVaultInPath("/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.CreateDir("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.CreateFile("/docs/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Write("/docs/a.txt", []byte("hello"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Commit("/docs/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	fs.Wait()
+
+	// The keys are recovered to a new owner, who reads the file
+	_, recovery := repo.open(t, recoveryKey)
+	recovery.SetRecoveryKey(&recoveryPublicKey)
+	if _, err := recovery.Recover(newOwnerPublicKey); err != nil {
+		t.Fatal(err)
+	}
+	fs, _ = repo.open(t, newOwner)
+	buff := make([]byte, 10)
+	n, err := fs.Read("/docs/a.txt", buff, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buff[:n]) != "hello" {
+		t.Errorf("Expected the new owner to read the file, got %q", buff[:n])
+	}
+}
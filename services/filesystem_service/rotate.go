@@ -0,0 +1,292 @@
+package filesystem_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/record_crypto"
+	"ctb-cli/repositories"
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrInvalidReencryptMode = errors.New("invalid re-encryption mode")
+)
+
+// ReencryptMode defines when the files are encrypted again after a vault key rotation.
+type ReencryptMode string
+
+const (
+	// ReencryptNone does not encrypt the files again, only the keys, names, links and metadata are sealed with the new keys.
+	ReencryptNone ReencryptMode = "none"
+	// ReencryptLazy marks the files to be encrypted again entirely on their next write.
+	ReencryptLazy ReencryptMode = "lazy"
+	// ReencryptEager encrypts all the files again during the rotation.
+	ReencryptEager ReencryptMode = "eager"
+)
+
+// ParseReencryptMode returns the re-encryption mode of the given name.
+// It returns ErrInvalidReencryptMode if the name is not a valid mode.
+func ParseReencryptMode(name string) (ReencryptMode, error) {
+	switch mode := ReencryptMode(name); mode {
+	case ReencryptNone, ReencryptLazy, ReencryptEager:
+		return mode, nil
+	}
+	return "", ErrInvalidReencryptMode
+}
+
+// RotateVault replaces the vault key of the directory located at the specified path and of all its sub directories.
+// The rotation of each directory is recorded in its vault until the directory is sealed with the new vault key,
+// so if the rotation of the directory, of a parent directory or of some of its sub directories was interrupted,
+// it is resumed instead. The files are encrypted again according to the re-encryption mode.
+// The shares with a passphrase cannot be renewed with the new vault keys: it returns the paths of the directories
+// whose share with a passphrase was removed.
+func (f *FileSystem) RotateVault(path string, mode ReencryptMode) (droppedPassphraseShares []string, err error) {
+	droppedPassphraseShares, resumed, err := f.ResumeRotations(path, mode)
+	if err != nil || resumed {
+		return droppedPassphraseShares, err
+	}
+	err = f.rotateVault(path, mode, &droppedPassphraseShares)
+	return droppedPassphraseShares, err
+}
+
+// ResumeRotations resumes the interrupted vault key rotations of the directory located at the specified path,
+// of its parent directories and of its sub directories. It returns true if a rotation was resumed.
+// The rotation of a parent directory rotates the directory as well.
+// Like RotateVault, it returns the paths of the directories whose share with a passphrase was removed.
+func (f *FileSystem) ResumeRotations(path string, mode ReencryptMode) (droppedPassphraseShares []string, resumed bool, err error) {
+	//Find the interrupted rotation of a parent directory
+	parent, err := f.findRotatedParent(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if parent != "" {
+		err = f.rotateVault(parent, mode, &droppedPassphraseShares)
+		return droppedPassphraseShares, true, err
+	}
+	//Find the interrupted rotations of the directory and its sub directories
+	pending, err := f.listRotatedVaults(path)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, dir := range pending {
+		err = f.rotateVault(dir, mode, &droppedPassphraseShares)
+		if err != nil {
+			return droppedPassphraseShares, true, err
+		}
+	}
+	return droppedPassphraseShares, len(pending) > 0, nil
+}
+
+// findRotatedParent returns the path of the topmost parent directory whose vault key rotation was interrupted,
+// or an empty string if there is none.
+func (f *FileSystem) findRotatedParent(path string) (string, error) {
+	parent := string(filepath.Separator)
+	components := strings.Split(strings.Trim(filepath.ToSlash(filepath.Clean(path)), "/"), "/")
+	for i := 0; i < len(components); i++ {
+		if components[i] == "" {
+			continue
+		}
+		vault, err := f.vaultRepo.GetVaultByPath(parent)
+		if err != nil {
+			return "", err
+		}
+		if vault.IsKeyRotated() {
+			return parent, nil
+		}
+		parent = filepath.Join(parent, components[i])
+	}
+	return "", nil
+}
+
+// listRotatedVaults returns the paths of the directory and of the sub directories whose vault key rotation was
+// interrupted. The sub directories of a directory being rotated are not listed, they are rotated with it.
+func (f *FileSystem) listRotatedVaults(path string) ([]string, error) {
+	vault, err := f.vaultRepo.GetVaultByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if vault.IsKeyRotated() {
+		return []string{path}, nil
+	}
+	subFiles, err := f.linkRepo.GetSubFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, subFile := range subFiles {
+		if !subFile.IsDir() || subFile.Name() == ".meta" {
+			continue
+		}
+		sub, err := f.listRotatedVaults(filepath.Join(path, subFile.Name()))
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, sub...)
+	}
+	return pending, nil
+}
+
+// rotateVault rotates the vault key of the directory located at the specified path, or resumes its rotation.
+// The names, the directory metadata, and the links and metadata of the files still sealed with the old vault key
+// are sealed with the new vault key, then the rotation of the sub directories is started before the rotation of
+// the directory is completed, so that an interruption is always recorded in a vault.
+// The paths of the directories whose share with a passphrase is removed are added to droppedPassphraseShares.
+func (f *FileSystem) rotateVault(path string, mode ReencryptMode, droppedPassphraseShares *[]string) error {
+	oldKey, newKey, err := f.startRotation(path, mode)
+	if err != nil {
+		return err
+	}
+	//Encrypt the names of the sub files with the new vault key
+	err = f.linkRepo.ReencryptNames(path, oldKey.Key, newKey.Key)
+	if err != nil {
+		return err
+	}
+	//Seal the directory metadata with the new vault key
+	sealed, err := f.vaultRepo.GetVaultMetadata(path)
+	if err != nil {
+		return err
+	}
+	if sealed != "" {
+		sealed, err = resealRecord(sealed, oldKey.Key, newKey.Key, record_crypto.DirMetadataV1Info)
+		if err != nil {
+			return err
+		}
+		if err := f.vaultRepo.SetVaultMetadata(path, sealed); err != nil {
+			return err
+		}
+	}
+	//Seal the links and the metadata of the files with the new vault key
+	subFiles, err := f.linkRepo.GetSubFiles(path)
+	if err != nil {
+		return err
+	}
+	var dirs []string
+	var files []string
+	for _, subFile := range subFiles {
+		if subFile.Name() == ".meta" {
+			continue
+		}
+		p := filepath.Join(path, subFile.Name())
+		if subFile.IsDir() {
+			dirs = append(dirs, p)
+			continue
+		}
+		err = f.resealLink(p, oldKey.Key, newKey.Key)
+		if err != nil {
+			return err
+		}
+		files = append(files, p)
+	}
+	//Encrypt again the files which are not encrypted again yet
+	if mode == ReencryptEager {
+		for _, p := range files {
+			link, err := f.linkRepo.GetByPath(p)
+			if err != nil {
+				return err
+			}
+			if !link.Reencrypt {
+				continue
+			}
+			err = f.Reencrypt(p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	//Start the rotation of the sub directories, then complete the rotation of the directory
+	for _, dir := range dirs {
+		_, _, err = f.startRotation(dir, mode)
+		if err != nil {
+			return err
+		}
+	}
+	passphraseShareRemoved, err := f.keyService.CompleteVaultKeyRotation(path)
+	if err != nil {
+		return err
+	}
+	if passphraseShareRemoved {
+		*droppedPassphraseShares = append(*droppedPassphraseShares, path)
+	}
+	//Rotate the vault keys of the sub directories
+	for _, dir := range dirs {
+		err = f.rotateVault(dir, mode, droppedPassphraseShares)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startRotation rotates the vault key of the directory located at the specified path, or resumes its rotation.
+// Unless the re-encryption mode is ReencryptNone, the files of the directory are first marked to be encrypted
+// again, so that the eager re-encryption of the files can be resumed as well.
+func (f *FileSystem) startRotation(path string, mode ReencryptMode) (oldKey *core.KeyInfo, newKey *core.KeyInfo, err error) {
+	vault, err := f.vaultRepo.GetVaultByPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !vault.IsKeyRotated() && mode != ReencryptNone {
+		subFiles, err := f.linkRepo.GetSubFiles(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, subFile := range subFiles {
+			if subFile.IsDir() || subFile.Name() == ".meta" {
+				continue
+			}
+			p := filepath.Join(path, subFile.Name())
+			link, err := f.linkRepo.GetByPath(p)
+			if err != nil {
+				return nil, nil, err
+			}
+			link.Reencrypt = true
+			err = f.linkRepo.Update(p, link)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return f.keyService.RotateVaultKey(path)
+}
+
+// resealLink seals the link and the metadata of the file located at the specified path with the new vault key,
+// if they are still sealed with the old vault key.
+func (f *FileSystem) resealLink(path string, oldKey core.Key, newKey core.Key) error {
+	link, err := f.linkRepo.GetByPath(path)
+	if errors.Is(err, repositories.ErrOpeningLink) {
+		link, err = f.linkRepo.GetByPathWithKey(path, oldKey)
+	}
+	if err != nil {
+		return err
+	}
+	if link.Metadata != "" {
+		link.Metadata, err = resealRecord(link.Metadata, oldKey, newKey, record_crypto.FileMetadataV1Info)
+		if err != nil {
+			return err
+		}
+	}
+	return f.linkRepo.Update(path, link)
+}
+
+// Reencrypt encrypts the file located at the specified path again entirely, with a new file key.
+// None of the chunks of the previous version of the file are reused, and the modification time is kept.
+func (f *FileSystem) Reencrypt(path string) error {
+	//Open file in write
+	if err := f.OpenInWrite(path); err != nil {
+		return err
+	}
+	//Get file link
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return err
+	}
+	//Do not reuse the chunks of the previous version
+	f.objectService.ReencryptAll(link.ObjectId)
+	return f.commit(path, false)
+}
+
+// Wait blocks until all the committed files are encrypted and uploaded.
+func (f *FileSystem) Wait() {
+	f.objectService.Wait()
+}
@@ -0,0 +1,50 @@
+package filesystem_service
+
+import (
+	"ctb-cli/repositories"
+	"ctb-cli/services/config_service"
+	"testing"
+	"time"
+)
+
+func TestSubfolderRecipientReadsByPath(t *testing.T) {
+	repo := newTestRepo(t)
+	// New repositories do not encrypt the names
+	config := config_service.New(repo.root, repositories.NewPathResolver(repo.root))
+	if err := config.SetNameEncryption(false); err != nil {
+		t.Fatal(err)
+	}
+	owner, _ := newKeyPair(t)
+	recipient, recipientPublicKey := newKeyPair(t)
+	fs, ks := repo.open(t, owner)
+	if err := fs.CreateVaultInPath("/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"/docs", "/docs/sub"} {
+		if err := fs.CreateDir(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, fs, "/docs/sub/a.txt", "a")
+	writeFile(t, fs, "/docs/b.txt", "b")
+	fs.Wait()
+
+	// Only the sub folder is shared with the recipient
+	sub, err := fs.vaultRepo.GetVaultByPath("/docs/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, docs, err := fs.vaultRepo.GetVaultParent("/docs/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Share(sub.KeyId, docs.Id, "/docs", recipientPublicKey, recipientPublicKey.String(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, _ = repo.open(t, recipient)
+	checkFile(t, fs, "/docs/sub/a.txt", "a")
+	if _, err := fs.Read("/docs/b.txt", make([]byte, 1), 0); err == nil {
+		t.Error("Expected the recipient not to read outside of the sub folder")
+	}
+}
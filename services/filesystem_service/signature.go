@@ -0,0 +1,19 @@
+package filesystem_service
+
+import (
+	"ctb-cli/core"
+	"path/filepath"
+)
+
+// GetSignature returns the signature of the last writer of the file or directory located at the specified path,
+// with the status of the signature. The signature of a directory is the signature of its vault.
+func (f *FileSystem) GetSignature(path string) (*core.Signature, string, error) {
+	if f.linkRepo.IsDir(path) {
+		return f.vaultRepo.GetVaultSignature(path)
+	}
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return f.objectService.GetSignature(link.ObjectId, filepath.Dir(path))
+}
@@ -1,15 +1,18 @@
 package filesystem_service
 
 import (
+	"ctb-cli/core"
 	"io/fs"
 	"time"
 )
 
 type FileInfo struct {
-	name  string
-	size  int64
-	isDir bool
-	mode  fs.FileMode
+	name     string
+	size     int64
+	isDir    bool
+	mode     fs.FileMode
+	modTime  time.Time
+	metadata *core.FileMetadata
 }
 
 var _ fs.FileInfo = FileInfo{}
@@ -27,13 +30,14 @@ func (f FileInfo) Mode() fs.FileMode {
 }
 
 func (f FileInfo) ModTime() time.Time {
-	return time.Time{}
+	return f.modTime
 }
 
 func (f FileInfo) IsDir() bool {
 	return f.isDir
 }
 
+// Sys returns the *core.FileMetadata of the file, or nil if the file has no metadata.
 func (f FileInfo) Sys() any {
-	return nil
+	return f.metadata
 }
@@ -0,0 +1,92 @@
+package identity_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/repositories"
+	"errors"
+)
+
+var (
+	ErrIdentityAlreadyExists = errors.New("identity already exists")
+	ErrInvalidIdentity       = errors.New("invalid identity file")
+)
+
+// Service manages the identity of the user: the private key sealed with a passphrase in the identity file.
+type Service struct {
+	identityRepository repositories.IdentityRepository
+}
+
+// NewService creates a new instance of the identity service
+func NewService(identityRepository repositories.IdentityRepository) *Service {
+	return &Service{
+		identityRepository: identityRepository,
+	}
+}
+
+// Exists returns true if the identity file exists.
+func (s *Service) Exists() bool {
+	return s.identityRepository.Exists()
+}
+
+// GetPath returns the path of the identity file.
+func (s *Service) GetPath() string {
+	return s.identityRepository.GetPath()
+}
+
+// Create seals the private key with the passphrase and saves it in the identity file.
+// It returns ErrIdentityAlreadyExists if the identity file exists and overwrite is false.
+func (s *Service) Create(privateKey core.PrivateKey, passphrase []byte, overwrite bool) error {
+	if !overwrite && s.identityRepository.Exists() {
+		return ErrIdentityAlreadyExists
+	}
+	return s.save(privateKey, passphrase)
+}
+
+// Unlock opens the private key of the identity file with the passphrase.
+// It returns key_crypto.ErrInvalidPassphrase if the passphrase is wrong.
+func (s *Service) Unlock(passphrase []byte) (core.PrivateKey, error) {
+	identity, err := s.identityRepository.Get()
+	if err != nil {
+		return core.EmptyPrivateKey(), err
+	}
+	if identity.Version != core.IdentityV1 {
+		return core.EmptyPrivateKey(), ErrInvalidIdentity
+	}
+	privateKey, err := key_crypto.OpenPrivateKey(identity.SealedKey, passphrase)
+	if err != nil {
+		return core.EmptyPrivateKey(), err
+	}
+	// Make sure the private key matches the public key of the identity
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil || publicKey.String() != identity.PublicKey {
+		return core.EmptyPrivateKey(), ErrInvalidIdentity
+	}
+	return privateKey, nil
+}
+
+// ChangePassphrase unlocks the identity with the old passphrase and seals it again with the new one.
+func (s *Service) ChangePassphrase(oldPassphrase []byte, newPassphrase []byte) error {
+	privateKey, err := s.Unlock(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	return s.save(privateKey, newPassphrase)
+}
+
+// save seals the private key with the passphrase and writes the identity file.
+func (s *Service) save(privateKey core.PrivateKey, passphrase []byte) error {
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return err
+	}
+	sealedKey, err := key_crypto.SealPrivateKey(privateKey, passphrase)
+	if err != nil {
+		return err
+	}
+	return s.identityRepository.Save(core.Identity{
+		Version:   core.IdentityV1,
+		PublicKey: publicKey.String(),
+		SealedKey: sealedKey,
+	})
+}
@@ -0,0 +1,196 @@
+package invitation_service
+
+import (
+	"crypto/ed25519"
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/repositories"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrInvalidInvitationFile  = errors.New("invalid invitation file")
+	ErrInvitationAlreadyUsed  = errors.New("invitation already used")
+	ErrInvitationNotAnswered  = errors.New("the invitee has not joined with the invitation yet")
+	ErrInvalidInvitationReply = errors.New("the answer to the invitation is not signed with the key of the invitation file")
+	ErrNotInviter             = errors.New("the invitation was not created by you")
+)
+
+// Service manages the invitations of users to the repository.
+type Service struct {
+	invitationRepository repositories.InvitationRepository
+	keyService           core.KeyService
+}
+
+// NewService creates a new instance of the invitation service
+func NewService(invitationRepository repositories.InvitationRepository, keyService core.KeyService) *Service {
+	return &Service{
+		invitationRepository: invitationRepository,
+		keyService:           keyService,
+	}
+}
+
+// Create creates an invitation to the paths, for the user to be added to the user directory with the alias.
+// It returns the invitation and the invitation file to be given to the invitee.
+func (s *Service) Create(paths []string, alias string) (core.Invitation, core.InvitationFile, error) {
+	invitedBy, err := s.keyService.GetPublicKey()
+	if err != nil {
+		return core.Invitation{}, core.InvitationFile{}, err
+	}
+	id, err := core.NewUid()
+	if err != nil {
+		return core.Invitation{}, core.InvitationFile{}, err
+	}
+	// Generate the ephemeral key of the invitation
+	key, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		return core.Invitation{}, core.InvitationFile{}, err
+	}
+	signingKey, err := sign_crypto.DeriveSigningKey(key)
+	if err != nil {
+		return core.Invitation{}, core.InvitationFile{}, err
+	}
+	invitation := core.Invitation{
+		Id:        id,
+		InvitedBy: invitedBy.String(),
+		Alias:     alias,
+		Paths:     paths,
+		Key:       sign_crypto.EncodeSigningPublicKey(signingKey.Public().(ed25519.PublicKey)),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	payload, err := invitation.SignedPayload()
+	if err != nil {
+		return core.Invitation{}, core.InvitationFile{}, err
+	}
+	invitation.Record, err = s.keyService.SignRecord(sign_crypto.InvitationRecordV1Info, payload)
+	if err != nil {
+		return core.Invitation{}, core.InvitationFile{}, err
+	}
+	if err := s.invitationRepository.Save(invitation); err != nil {
+		return core.Invitation{}, core.InvitationFile{}, err
+	}
+	file := core.InvitationFile{
+		Id:  id,
+		Key: key.Unsafe().String(),
+	}
+	return invitation, file, nil
+}
+
+// Answer answers the invitation of the file with the public key of the user of the key service.
+// It returns ErrInvitationAlreadyUsed if the invitation has already been answered.
+func (s *Service) Answer(file core.InvitationFile) (core.Invitation, error) {
+	key, err := core.NewPrivateKeyFromEncoded(file.Key)
+	if err != nil {
+		return core.Invitation{}, ErrInvalidInvitationFile
+	}
+	invitation, err := s.invitationRepository.Get(file.Id)
+	if err != nil {
+		return core.Invitation{}, err
+	}
+	if invitation.IsAnswered() {
+		return core.Invitation{}, ErrInvitationAlreadyUsed
+	}
+	if err := s.checkRecord(invitation); err != nil {
+		return core.Invitation{}, err
+	}
+	signingKey, err := sign_crypto.DeriveSigningKey(key)
+	if err != nil {
+		return core.Invitation{}, err
+	}
+	if sign_crypto.EncodeSigningPublicKey(signingKey.Public().(ed25519.PublicKey)) != invitation.Key {
+		return core.Invitation{}, ErrInvalidInvitationFile
+	}
+	publicKey, err := s.keyService.GetPublicKey()
+	if err != nil {
+		return core.Invitation{}, err
+	}
+	// The encapsulation key of the invitee is published, so that the inviter can seal the data keys with the hybrid scheme
+	if err := s.keyService.PublishIdentity(); err != nil {
+		return core.Invitation{}, err
+	}
+	invitation.PublicKey = publicKey.String()
+	invitation.JoinedAt = time.Now().UTC().Truncate(time.Second)
+	invitation.Signature = sign_crypto.Sign(signingKey, sign_crypto.InvitationV1Info, invitation.AnswerPayload())
+	if err := s.invitationRepository.Save(invitation); err != nil {
+		return core.Invitation{}, err
+	}
+	return invitation, nil
+}
+
+// Accept accepts the answered invitation with the id: it checks the signature of the invitation by the user
+// of the key service and the signature of the answer, grants the invitation and deletes it so that it cannot be used again.
+// It returns ErrInvitationNotAnswered if the invitee has not answered the invitation yet,
+// and ErrNotInviter if the invitation was not created by the user of the key service.
+func (s *Service) Accept(id string, grant func(invitation core.Invitation) error) (core.Invitation, error) {
+	invitation, err := s.invitationRepository.Get(id)
+	if err != nil {
+		return core.Invitation{}, err
+	}
+	if !invitation.IsAnswered() {
+		return core.Invitation{}, ErrInvitationNotAnswered
+	}
+	if err := s.checkRecord(invitation); err != nil {
+		return core.Invitation{}, err
+	}
+	// The paths of the invitation are shared by the user of the key service, so only the invitations
+	// of the user are accepted, even if another writer of the repository signed a valid invitation
+	publicKey, err := s.keyService.GetPublicKey()
+	if err != nil {
+		return core.Invitation{}, err
+	}
+	if invitation.InvitedBy != publicKey.String() {
+		return core.Invitation{}, ErrNotInviter
+	}
+	if err := Verify(invitation); err != nil {
+		return core.Invitation{}, err
+	}
+	if err := grant(invitation); err != nil {
+		return core.Invitation{}, err
+	}
+	if err := s.invitationRepository.Delete(id); err != nil {
+		return core.Invitation{}, err
+	}
+	return invitation, nil
+}
+
+// List returns the pending invitations, oldest first.
+func (s *Service) List() ([]core.Invitation, error) {
+	invitations, err := s.invitationRepository.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].CreatedAt.Before(invitations[j].CreatedAt) })
+	return invitations, nil
+}
+
+// Revoke deletes the invitation with the id.
+func (s *Service) Revoke(id string) error {
+	return s.invitationRepository.Delete(id)
+}
+
+// checkRecord checks that the invitation is signed by the user who created it, so that its paths, alias
+// and key are the ones chosen by the inviter.
+func (s *Service) checkRecord(invitation core.Invitation) error {
+	payload, err := invitation.SignedPayload()
+	if err != nil {
+		return err
+	}
+	if invitation.Record != nil && invitation.Record.Writer != invitation.InvitedBy {
+		return core.ErrInvalidSignature
+	}
+	return s.keyService.CheckRecord(invitation.Record, sign_crypto.InvitationRecordV1Info, payload)
+}
+
+// Verify checks that the answer of the invitation is signed with the ephemeral key of the invitation file.
+func Verify(invitation core.Invitation) error {
+	if _, err := core.NewPublicKeyFromEncoded(invitation.PublicKey); err != nil {
+		return ErrInvalidInvitationReply
+	}
+	err := sign_crypto.Verify(invitation.Key, sign_crypto.InvitationV1Info, invitation.AnswerPayload(), invitation.Signature)
+	if err != nil {
+		return ErrInvalidInvitationReply
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package invitation_service
+
+import (
+	"crypto/ed25519"
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/repositories"
+	"ctb-cli/services/key_service"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testUser is a user of a repository in a temporary directory, with its invitation service.
+type testUser struct {
+	invitations *Service
+	repository  repositories.InvitationRepository
+}
+
+// newTestRepo creates an empty repository in a temporary directory.
+func newTestRepo(t *testing.T) string {
+	root := t.TempDir()
+	for _, folder := range core.GetRepoSystemFolderNames() {
+		if err := os.MkdirAll(filepath.Join(root, ".meta", folder), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// newTestUser opens the repository with the key of a new user.
+func newTestUser(t *testing.T, root string) *testUser {
+	t.Helper()
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := repositories.NewPathResolver(root)
+	ks := key_service.NewKeyStore(repositories.NewKeyRepositoryFile(root, resolver), repositories.NewVaultRepositoryFile(root, resolver),
+		repositories.NewUserRepositoryFile(root), repositories.NewGroupRepositoryFile(root), repositories.NewSignerRepositoryFile(root))
+	ks.SetPrivateKey(privateKey)
+	invitationRepository := repositories.NewInvitationRepositoryFile(root)
+	return &testUser{
+		invitations: NewService(invitationRepository, ks),
+		repository:  invitationRepository,
+	}
+}
+
+// acceptAll is a grant accepting every invitation.
+func acceptAll(core.Invitation) error {
+	return nil
+}
+
+func TestAccept(t *testing.T) {
+	root := newTestRepo(t)
+	inviter := newTestUser(t, root)
+	invitee := newTestUser(t, root)
+	invitation, file, err := inviter.invitations.Create([]string{"/docs"}, "invitee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invitee.invitations.Answer(file); err != nil {
+		t.Fatal(err)
+	}
+	granted := false
+	_, err = inviter.invitations.Accept(invitation.Id, func(invitation core.Invitation) error {
+		granted = len(invitation.Paths) == 1 && invitation.Paths[0] == "/docs"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !granted {
+		t.Error("Expected the paths of the invitation to be granted")
+	}
+}
+
+func TestAcceptTamperedInvitation(t *testing.T) {
+	root := newTestRepo(t)
+	inviter := newTestUser(t, root)
+	invitee := newTestUser(t, root)
+	invitation, file, err := inviter.invitations.Create([]string{"/docs"}, "invitee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invitee.invitations.Answer(file); err != nil {
+		t.Fatal(err)
+	}
+
+	// A writer of the repository adds a path to the answered invitation
+	tampered, err := inviter.repository.Get(invitation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered.Paths = append(tampered.Paths, "/")
+	if err := inviter.repository.Save(tampered); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inviter.invitations.Accept(invitation.Id, acceptAll); err != core.ErrInvalidSignature {
+		t.Errorf("Expected the tampered invitation to be refused, got %v", err)
+	}
+}
+
+func TestAcceptReplacedKey(t *testing.T) {
+	root := newTestRepo(t)
+	inviter := newTestUser(t, root)
+	attacker := newTestUser(t, root)
+	invitation, _, err := inviter.invitations.Create([]string{"/docs"}, "invitee")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The attacker replaces the key of the invitation by a key of its own, and answers the invitation
+	key, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey, err := sign_crypto.DeriveSigningKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := inviter.repository.Get(invitation.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered.Key = sign_crypto.EncodeSigningPublicKey(signingKey.Public().(ed25519.PublicKey))
+	if err := inviter.repository.Save(tampered); err != nil {
+		t.Fatal(err)
+	}
+	file := core.InvitationFile{Id: invitation.Id, Key: key.Unsafe().String()}
+	if _, err := attacker.invitations.Answer(file); err != core.ErrInvalidSignature {
+		t.Errorf("Expected the answer to the tampered invitation to be refused, got %v", err)
+	}
+	// The answer is written without the checks of the service
+	tampered.PublicKey = "attacker"
+	tampered.Signature = sign_crypto.Sign(signingKey, sign_crypto.InvitationV1Info, tampered.AnswerPayload())
+	if err := inviter.repository.Save(tampered); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inviter.invitations.Accept(invitation.Id, acceptAll); err != core.ErrInvalidSignature {
+		t.Errorf("Expected the invitation with a replaced key to be refused, got %v", err)
+	}
+}
+
+func TestAcceptInvitationOfAnotherUser(t *testing.T) {
+	root := newTestRepo(t)
+	inviter := newTestUser(t, root)
+	other := newTestUser(t, root)
+	invitee := newTestUser(t, root)
+	invitation, file, err := other.invitations.Create([]string{"/"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invitee.invitations.Answer(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inviter.invitations.Accept(invitation.Id, acceptAll); err != ErrNotInviter {
+		t.Errorf("Expected the invitation of another user to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package key_service
+
+import (
+	"crypto/ed25519"
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/crypto/sign_crypto"
+)
+
+// LocalDecrypter performs the private key operations with a private key held in memory.
+type LocalDecrypter struct {
+	privateKey core.PrivateKey
+}
+
+// Ensure LocalDecrypter implements Decrypter
+var _ core.Decrypter = &LocalDecrypter{}
+
+// NewLocalDecrypter creates a new instance of LocalDecrypter
+func NewLocalDecrypter(privateKey core.PrivateKey) *LocalDecrypter {
+	return &LocalDecrypter{
+		privateKey: privateKey,
+	}
+}
+
+// PublicKey returns the public key corresponding to the private key.
+func (d *LocalDecrypter) PublicKey() (core.PublicKey, error) {
+	return d.privateKey.ToPublicKey()
+}
+
+// OpenDataKey decrypts a data key sealed with the public key.
+func (d *LocalDecrypter) OpenDataKey(serialized string) (*core.Key, error) {
+	return key_crypto.OpenDataKey(serialized, d.privateKey)
+}
+
+// SigningPublicKey returns the public key of the signing key derived from the private key.
+func (d *LocalDecrypter) SigningPublicKey() (ed25519.PublicKey, error) {
+	signingKey, err := sign_crypto.DeriveSigningKey(d.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(signingKey)
+	return signingKey.Public().(ed25519.PublicKey), nil
+}
+
+// Sign signs the message with the signing key derived from the private key.
+func (d *LocalDecrypter) Sign(message []byte) ([]byte, error) {
+	signingKey, err := sign_crypto.DeriveSigningKey(d.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(signingKey)
+	return ed25519.Sign(signingKey, message), nil
+}
+
+// ProveIdentity signs the message with the X25519 private key itself, to bind the signing identity to the public key.
+func (d *LocalDecrypter) ProveIdentity(message []byte) ([]byte, error) {
+	return sign_crypto.ProveIdentity(d.privateKey, message)
+}
+
+// EncapsulationKey returns the ML-KEM-768 encapsulation key derived from the private key.
+func (d *LocalDecrypter) EncapsulationKey() ([]byte, error) {
+	return key_crypto.EncapsulationKey(d.privateKey)
+}
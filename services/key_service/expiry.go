@@ -0,0 +1,120 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"errors"
+	"time"
+)
+
+var (
+	ErrShareExpired = errors.New("the share of the data key is expired")
+)
+
+// setShareExpiry signs and saves the expiry of the share of the data key with the user.
+func (ks *KeyStoreDefault) setShareExpiry(keyId string, userId string, path string, expiresAt time.Time) error {
+	sharedBy, err := ks.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	signerKey, err := ks.GetSigningPublicKey()
+	if err != nil {
+		return err
+	}
+	expiry := core.ShareExpiry{
+		KeyId:     keyId,
+		Recipient: userId,
+		ExpiresAt: expiresAt.UTC(),
+		SharedBy:  sharedBy.String(),
+		SignerKey: signerKey,
+	}
+	payload, err := expiry.SignedPayload()
+	if err != nil {
+		return err
+	}
+	// The expiry is signed as a record, so that the signing key is bound to the public key of the user who shared the key
+	signature, err := ks.SignRecord(sign_crypto.ShareExpiryV1Info, payload)
+	if err != nil {
+		return err
+	}
+	expiry.Signature = signature.Value
+	return ks.keyRepository.SaveDataKeyExpiry(expiry, path)
+}
+
+// checkShareExpiry returns ErrShareExpired if the share of the data key with the user is expired.
+// The expiries which are not honored by ExpireShares are ignored.
+func (ks *KeyStoreDefault) checkShareExpiry(keyId string, userId string, startVaultId string, startVaultPath string) error {
+	expiry, err := ks.keyRepository.GetDataKeyExpiry(keyId, userId, startVaultPath)
+	if err != nil {
+		return err
+	}
+	if expiry != nil && expiry.IsExpired(time.Now()) && ks.isShareExpiryHonored(*expiry, keyId, startVaultId, startVaultPath) {
+		return ErrShareExpired
+	}
+	return nil
+}
+
+// ExpireShares deletes the shares of the data key in the path which are expired at the given time.
+// Only the expiries signed by a verified user who has access to the data key are honored: any writer of the
+// repository can write an expiry file, and an invalid or forged expiry must not revoke the share of another user.
+// The shares with the user of the key store are left to the other users, who can rotate the keys.
+// It returns the expiries of the deleted shares.
+func (ks *KeyStoreDefault) ExpireShares(keyId string, startVaultId string, startVaultPath string, now time.Time) ([]core.ShareExpiry, error) {
+	userId, err := ks.GetUserId()
+	if err != nil {
+		return nil, err
+	}
+	expiries, err := ks.keyRepository.ListDataKeyExpiries(keyId, startVaultPath)
+	if err != nil {
+		return nil, err
+	}
+	expired := make([]core.ShareExpiry, 0)
+	for _, expiry := range expiries {
+		if expiry.Recipient == userId || !expiry.IsExpired(now) {
+			continue
+		}
+		if !ks.isShareExpiryHonored(expiry, keyId, startVaultId, startVaultPath) {
+			continue
+		}
+		err = ks.keyRepository.DeleteDataKey(keyId, expiry.Recipient, startVaultPath)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, expiry)
+	}
+	return expired, nil
+}
+
+// isShareExpiryHonored returns true if the expiry is signed by a verified user who has access to the data key.
+func (ks *KeyStoreDefault) isShareExpiryHonored(expiry core.ShareExpiry, keyId string, startVaultId string, startVaultPath string) bool {
+	return ks.isShareExpirySigned(expiry, keyId) && ks.hasSignerAccess(expiry, keyId, startVaultId, startVaultPath)
+}
+
+// isShareExpirySigned returns true if the expiry is the expiry of the data key and is signed with the signing key
+// bound to the public key of the user who shared the data key.
+func (ks *KeyStoreDefault) isShareExpirySigned(expiry core.ShareExpiry, keyId string) bool {
+	if expiry.KeyId != keyId {
+		return false
+	}
+	payload, err := expiry.SignedPayload()
+	if err != nil {
+		return false
+	}
+	signature := &core.Signature{
+		Writer:    expiry.SharedBy,
+		SignerKey: expiry.SignerKey,
+		Value:     expiry.Signature,
+	}
+	return ks.VerifyRecord(signature, sign_crypto.ShareExpiryV1Info, payload) == core.SignatureVerified
+}
+
+// hasSignerAccess returns true if the user who signed the expiry has access to the data key,
+// other than by the share which expires.
+func (ks *KeyStoreDefault) hasSignerAccess(expiry core.ShareExpiry, keyId string, startVaultId string, startVaultPath string) bool {
+	signerId, err := ks.resolveUserId(expiry.SharedBy)
+	if err != nil || signerId == expiry.Recipient {
+		return false
+	}
+	hasAccess, _ := ks.GetHasAccessToKey(keyId, startVaultId, startVaultPath, signerId)
+	return hasAccess
+}
@@ -0,0 +1,122 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"testing"
+	"time"
+)
+
+// shareDocs creates the vaults of the root and of /docs with the key store of the owner, and shares the vault key
+// of /docs with the recipient. It returns the id of the vault key of /docs and the id of the root vault.
+func (r *testRepo) shareDocs(t *testing.T, owner *KeyStoreDefault, recipient string, expiresAt time.Time) (string, string) {
+	t.Helper()
+	r.createVaults(t, owner, "/docs")
+	root, err := owner.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := owner.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := core.NewPublicKeyFromEncoded(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.Share(docs.KeyId, root.Id, "/", publicKey, recipient, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	return docs.KeyId, root.Id
+}
+
+// writeExpiry signs the expiry of the share with the key store and writes it, as any writer of the repository can.
+func writeExpiry(t *testing.T, ks *KeyStoreDefault, expiry core.ShareExpiry) {
+	t.Helper()
+	publicKey, err := ks.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry.SharedBy = publicKey.String()
+	expiry.SignerKey, err = ks.GetSigningPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := expiry.SignedPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ks.SignRecord(sign_crypto.ShareExpiryV1Info, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry.Signature = signature.Value
+	if err := ks.keyRepository.SaveDataKeyExpiry(expiry, "/"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpireShares(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	recipient, recipientId := newUser(t)
+	ks := repo.open(owner)
+	keyId, rootId := repo.shareDocs(t, ks, recipientId, time.Now().Add(time.Hour))
+
+	// The share is kept until it expires
+	expired, err := ks.ExpireShares(keyId, rootId, "/", time.Now())
+	if err != nil || len(expired) != 0 {
+		t.Fatalf("Expected no expired share, got %v, %v", expired, err)
+	}
+	if _, err := repo.open(recipient).Get(keyId, "", "/"); err != nil {
+		t.Fatalf("Expected the recipient to access the key, got %v", err)
+	}
+	expired, err = ks.ExpireShares(keyId, rootId, "/", time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].Recipient != recipientId {
+		t.Fatalf("Expected the share of the recipient to expire, got %v", expired)
+	}
+	if ks.keyRepository.DataKeyExist(keyId, recipientId, "/") {
+		t.Error("Expected the expired share to be deleted")
+	}
+}
+
+func TestExpireSharesForgedExpiry(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	recipient, recipientId := newUser(t)
+	attacker, _ := newUser(t)
+	ks := repo.open(owner)
+	keyId, rootId := repo.shareDocs(t, ks, recipientId, time.Time{})
+
+	// A user without access to the key writes an expiry of the share, with a valid signature
+	past := core.ShareExpiry{KeyId: keyId, Recipient: recipientId, ExpiresAt: time.Now().Add(-time.Hour).UTC()}
+	writeExpiry(t, repo.open(attacker), past)
+	if _, err := repo.open(recipient).Get(keyId, "", "/"); err != nil {
+		t.Errorf("Expected the forged expiry to be ignored on read, got %v", err)
+	}
+	expired, err := ks.ExpireShares(keyId, rootId, "/", time.Now())
+	if err != nil || len(expired) != 0 {
+		t.Errorf("Expected the forged expiry to be ignored, got %v, %v", expired, err)
+	}
+
+	// The expiry of the owner with a changed time is not valid
+	writeExpiry(t, ks, core.ShareExpiry{KeyId: keyId, Recipient: recipientId, ExpiresAt: time.Now().Add(time.Hour).UTC()})
+	tampered, err := ks.keyRepository.GetDataKeyExpiry(keyId, recipientId, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered.ExpiresAt = past.ExpiresAt
+	if err := ks.keyRepository.SaveDataKeyExpiry(*tampered, "/"); err != nil {
+		t.Fatal(err)
+	}
+	expired, err = ks.ExpireShares(keyId, rootId, "/", time.Now())
+	if err != nil || len(expired) != 0 {
+		t.Errorf("Expected the invalid expiry to be ignored, got %v, %v", expired, err)
+	}
+	if !ks.keyRepository.DataKeyExist(keyId, recipientId, "/") {
+		t.Error("Expected the share to be kept")
+	}
+}
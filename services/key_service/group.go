@@ -0,0 +1,374 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/repositories"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrGroupAlreadyExists     = errors.New("group already exists")
+	ErrEmptyGroupName         = errors.New("group name is empty")
+	ErrNotGroupMember         = errors.New("not a member of the group")
+	ErrAlreadyGroupMember     = errors.New("user is already a member of the group")
+	ErrCannotRemoveLastMember = errors.New("cannot remove the last member of the group")
+)
+
+// CreateGroup creates a group with a new key pair. The user of the key store is the first member of the group.
+func (ks *KeyStoreDefault) CreateGroup(name string) (*core.Group, error) {
+	if name == "" {
+		return nil, ErrEmptyGroupName
+	}
+	if _, err := ks.groupRepository.GetByName(name); err == nil {
+		return nil, ErrGroupAlreadyExists
+	} else if !errors.Is(err, repositories.ErrGroupNotFound) {
+		return nil, err
+	}
+	userId, err := ks.GetUserId()
+	if err != nil {
+		return nil, err
+	}
+	// Generate the group id, derived from the user as the creator, and the key pair
+	id, nonce, err := core.NewGroupId(userId)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		return nil, err
+	}
+	group := core.Group{
+		Id:      id,
+		Name:    name,
+		Members: make(map[string]string),
+		Creator: userId,
+		Nonce:   nonce,
+	}
+	// Seal the group private key for the user
+	if err := ks.setGroupKey(&group, privateKey, []string{userId}); err != nil {
+		return nil, err
+	}
+	if err := ks.groupRepository.Save(group); err != nil {
+		return nil, err
+	}
+	ks.memberGroups = nil
+	return &group, nil
+}
+
+// VerifyGroup checks the signature of the group and of its previous versions. The first version must be written
+// by the creator of the group, whose id the group id is derived from, and every other version by a member of the
+// version it replaces, so that a user who is not a member cannot replace the key pair of the group.
+// The groups created before the versions were recorded have no creator and are refused, unless the key store
+// accepts the records written before the records were signed.
+func (ks *KeyStoreDefault) VerifyGroup(group core.Group) error {
+	payload, err := group.SignedPayload()
+	if err != nil {
+		return err
+	}
+	if err := ks.CheckRecord(group.Signature, sign_crypto.GroupV1Info, payload); err != nil {
+		return err
+	}
+	if group.Creator == "" && ks.acceptUnsigned {
+		return nil
+	}
+	if group.Signature == nil || group.Creator == "" {
+		return core.ErrUnauthorizedGroupWriter
+	}
+	writerId, err := ks.resolveUserId(group.Signature.Writer)
+	if err != nil {
+		return err
+	}
+	if group.Previous == nil {
+		if !group.IsCreatedBy(writerId) {
+			return core.ErrUnauthorizedGroupWriter
+		}
+		return nil
+	}
+	previous := group.Previous
+	if previous.Id != group.Id || previous.Creator != group.Creator || previous.Nonce != group.Nonce || !previous.HasMember(writerId) {
+		return core.ErrUnauthorizedGroupWriter
+	}
+	return ks.VerifyGroup(*previous)
+}
+
+// GetGroup returns the group with the specified id.
+func (ks *KeyStoreDefault) GetGroup(groupId string) (core.Group, error) {
+	if ks.groupRepository == nil {
+		return core.Group{}, repositories.ErrGroupNotFound
+	}
+	return ks.groupRepository.Get(groupId)
+}
+
+// GetGroupByName returns the group with the specified name.
+func (ks *KeyStoreDefault) GetGroupByName(name string) (core.Group, error) {
+	return ks.groupRepository.GetByName(name)
+}
+
+// ListGroups returns the groups of the repository.
+func (ks *KeyStoreDefault) ListGroups() ([]core.Group, error) {
+	return ks.groupRepository.List()
+}
+
+// AddGroupMember adds the user having the public key to the group.
+// The private key of the group is sealed for the new member.
+// It must be called by a member of the group.
+func (ks *KeyStoreDefault) AddGroupMember(name string, publicKey core.PublicKey) error {
+	group, err := ks.groupRepository.GetByName(name)
+	if err != nil {
+		return err
+	}
+	if err := ks.completeGroupKeyReplacement(&group); err != nil {
+		return err
+	}
+	privateKey, err := ks.openGroupKey(group)
+	if err != nil {
+		return err
+	}
+	memberId, err := ks.resolveUserId(publicKey.String())
+	if err != nil {
+		return err
+	}
+	if group.HasMember(memberId) {
+		return ErrAlreadyGroupMember
+	}
+	key, err := core.KeyFromBytes(privateKey.Bytes())
+	if err != nil {
+		return err
+	}
+	group.Members[memberId], err = ks.sealForUser(key, memberId)
+	if err != nil {
+		return err
+	}
+	return ks.groupRepository.Save(group)
+}
+
+// RemoveGroupMember removes the user having the public key from the group.
+// Since the removed member knows the private key of the group, the key pair of the group is replaced:
+// the data keys shared with the group are sealed with the new public key, and the new private key is sealed
+// for the remaining members. It must be called by a member of the group.
+// The new key pair is saved next to the previous one before the data keys are sealed again, and the previous
+// key pair is dropped last, so that an interrupted replacement is resumed by the next change of the group.
+func (ks *KeyStoreDefault) RemoveGroupMember(name string, publicKey core.PublicKey) error {
+	group, err := ks.groupRepository.GetByName(name)
+	if err != nil {
+		return err
+	}
+	if err := ks.completeGroupKeyReplacement(&group); err != nil {
+		return err
+	}
+	memberId, err := ks.resolveUserId(publicKey.String())
+	if err != nil {
+		return err
+	}
+	if !group.HasMember(memberId) {
+		return ErrNotGroupMember
+	}
+	if len(group.Members) == 1 {
+		return ErrCannotRemoveLastMember
+	}
+	if err := ks.replaceGroupKey(&group, memberId); err != nil {
+		return err
+	}
+	return ks.completeGroupKeyReplacement(&group)
+}
+
+// replaceGroupKey removes the member from the group and saves a new key pair of the group, sealed for the remaining
+// members, next to the previous key pair, which stays sealed for the remaining members only.
+func (ks *KeyStoreDefault) replaceGroupKey(group *core.Group, memberId string) error {
+	// The user of the key store must be able to open the previous key to seal the data keys again
+	if _, err := ks.openGroupKey(*group); err != nil {
+		return err
+	}
+	delete(group.Members, memberId)
+	newPrivateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		return err
+	}
+	members := make([]string, 0, len(group.Members))
+	for userId := range group.Members {
+		members = append(members, userId)
+	}
+	previousPublicKey, previousMembers := group.PublicKey, group.Members
+	if err := ks.setGroupKey(group, newPrivateKey, members); err != nil {
+		return err
+	}
+	group.PreviousPublicKey = previousPublicKey
+	group.PreviousMembers = previousMembers
+	if err := ks.groupRepository.Save(*group); err != nil {
+		return err
+	}
+	ks.memberGroups = nil
+	return nil
+}
+
+// completeGroupKeyReplacement seals the data keys shared with the group with the new public key of the group,
+// and then drops the previous key pair. It does nothing if the key pair of the group is not being replaced.
+// The data keys already sealed with the new public key are kept, so it can be run again after an interruption.
+func (ks *KeyStoreDefault) completeGroupKeyReplacement(group *core.Group) error {
+	if !group.IsKeyReplaced() {
+		return nil
+	}
+	privateKey, err := ks.openGroupKey(*group)
+	if err != nil {
+		return err
+	}
+	previousPrivateKey, err := ks.openGroupKeyPair(group.PreviousPublicKey, group.PreviousMembers)
+	if err != nil {
+		return err
+	}
+	publicKey, err := core.NewPublicKeyFromEncoded(group.PublicKey)
+	if err != nil {
+		return err
+	}
+	// Seal the data keys shared with the group with the new public key
+	err = ks.keyRepository.UpdateDataKeys(group.Id, func(keyId string, sealed string) (string, error) {
+		if _, err := key_crypto.OpenDataKey(sealed, privateKey); err == nil {
+			return sealed, nil
+		}
+		key, err := key_crypto.OpenDataKey(sealed, previousPrivateKey)
+		if err != nil {
+			return "", err
+		}
+		return ks.sealForGroupKey(*key, publicKey, group.EncapsulationKey)
+	})
+	if err != nil {
+		return err
+	}
+	// Drop the previous key pair
+	group.PreviousPublicKey = ""
+	group.PreviousMembers = nil
+	if err := ks.groupRepository.Save(*group); err != nil {
+		return err
+	}
+	ks.memberGroups = nil
+	return nil
+}
+
+// setGroupKey sets the key pair of the group, and seals the private key for the members.
+func (ks *KeyStoreDefault) setGroupKey(group *core.Group, privateKey core.PrivateKey, members []string) error {
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return err
+	}
+	key, err := core.KeyFromBytes(privateKey.Bytes())
+	if err != nil {
+		return err
+	}
+	encapsulationKey, err := key_crypto.EncapsulationKey(privateKey)
+	if err != nil {
+		return err
+	}
+	group.PublicKey = publicKey.String()
+	group.EncapsulationKey = base64.RawStdEncoding.EncodeToString(encapsulationKey)
+	group.Members = make(map[string]string)
+	for _, userId := range members {
+		group.Members[userId], err = ks.sealForUser(key, userId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openGroupKey opens the private key of the group sealed for the user of the key store.
+func (ks *KeyStoreDefault) openGroupKey(group core.Group) (core.PrivateKey, error) {
+	return ks.openGroupKeyPair(group.PublicKey, group.Members)
+}
+
+// openGroupKeyPair opens the private key of the group public key, sealed for the user of the key store
+// in the members. The keys are cached by public key, since the key pair of a group is replaced when
+// a member is removed.
+func (ks *KeyStoreDefault) openGroupKeyPair(publicKey string, members map[string]string) (core.PrivateKey, error) {
+	if privateKey, found := ks.groupKeys[publicKey]; found {
+		return privateKey, nil
+	}
+	userId, err := ks.GetUserId()
+	if err != nil {
+		return core.EmptyPrivateKey(), err
+	}
+	sealed, found := members[userId]
+	if !found {
+		return core.EmptyPrivateKey(), ErrNotGroupMember
+	}
+	key, err := ks.openShare(sealed)
+	if err != nil {
+		return core.EmptyPrivateKey(), err
+	}
+	privateKey := core.NewPrivateKeyFromBytes(key.Bytes())
+	if ks.groupKeys == nil {
+		ks.groupKeys = make(map[string]core.PrivateKey)
+	}
+	ks.groupKeys[publicKey] = privateKey
+	return privateKey, nil
+}
+
+// getMemberGroups returns the groups the user of the key store is a member of.
+func (ks *KeyStoreDefault) getMemberGroups() ([]core.Group, error) {
+	if ks.memberGroups != nil {
+		return ks.memberGroups, nil
+	}
+	userId, err := ks.GetUserId()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := ks.getGroupsOfUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	ks.memberGroups = groups
+	return groups, nil
+}
+
+// getGroupsOfUser returns the groups the user is a member of.
+func (ks *KeyStoreDefault) getGroupsOfUser(userId string) ([]core.Group, error) {
+	groups := make([]core.Group, 0)
+	if ks.groupRepository == nil {
+		return groups, nil
+	}
+	all, err := ks.groupRepository.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range all {
+		if group.HasMember(userId) {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// getGroupDataKey opens a data key shared with a group the user of the key store is a member of.
+// It returns false if the data key is not shared with any group of the user.
+func (ks *KeyStoreDefault) getGroupDataKey(keyId string, path string) (*core.Key, bool, error) {
+	groups, err := ks.getMemberGroups()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, group := range groups {
+		if !ks.keyRepository.DataKeyExist(keyId, group.Id, path) {
+			continue
+		}
+		sealed, err := ks.keyRepository.GetDataKey(keyId, group.Id, path)
+		if err != nil {
+			return nil, true, err
+		}
+		privateKey, err := ks.openGroupKey(group)
+		if err != nil {
+			return nil, true, err
+		}
+		key, err := key_crypto.OpenDataKey(sealed, privateKey)
+		// While the key pair of the group is replaced, the data key may still be sealed with the previous key
+		if err != nil && group.IsKeyReplaced() {
+			previousPrivateKey, previousErr := ks.openGroupKeyPair(group.PreviousPublicKey, group.PreviousMembers)
+			if previousErr != nil {
+				return nil, true, err
+			}
+			key, err = key_crypto.OpenDataKey(sealed, previousPrivateKey)
+		}
+		return key, true, err
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,221 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/repositories"
+	"os"
+	"testing"
+	"time"
+)
+
+// groupRepo is a repository with the vaults of the root and of /docs, and a group of three members
+// the vault key of /docs is shared with.
+type groupRepo struct {
+	*testRepo
+	owner, alice, bob core.PrivateKey
+	aliceId, bobId    string
+	group             core.Group
+	keyId, rootId     string
+}
+
+// newGroupRepo creates the repository of the group "team" of the owner, alice and bob.
+func newGroupRepo(t *testing.T) *groupRepo {
+	t.Helper()
+	r := &groupRepo{testRepo: newTestRepo(t)}
+	r.owner, _ = newUser(t)
+	r.alice, r.aliceId = newUser(t)
+	r.bob, r.bobId = newUser(t)
+	ks := r.open(r.owner)
+	r.createVaults(t, ks, "/docs")
+	group, err := ks.CreateGroup("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range []string{r.aliceId, r.bobId} {
+		publicKey, err := core.NewPublicKeyFromEncoded(member)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ks.AddGroupMember("team", publicKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, err := ks.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupPublicKey, err := core.NewPublicKeyFromEncoded(group.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Share(docs.KeyId, root.Id, "/", groupPublicKey, group.Id, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	r.group, err = ks.GetGroup(group.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.keyId, r.rootId = docs.KeyId, root.Id
+	return r
+}
+
+// checkAccess checks whether the user can get the vault key of /docs through the group.
+func (r *groupRepo) checkAccess(t *testing.T, user core.PrivateKey, userId string, expected bool) {
+	t.Helper()
+	ks := r.open(user)
+	_, err := ks.Get(r.keyId, r.rootId, "/")
+	if expected && err != nil {
+		t.Errorf("Expected %s to get the key, got %v", userId, err)
+	}
+	if !expected && err == nil {
+		t.Errorf("Expected %s not to get the key", userId)
+	}
+	if hasAccess, _ := ks.GetHasAccessToKey(r.keyId, r.rootId, "/", userId); hasAccess != expected {
+		t.Errorf("Expected the access of %s to be %v", userId, expected)
+	}
+}
+
+func TestRemoveGroupMember(t *testing.T) {
+	r := newGroupRepo(t)
+	r.checkAccess(t, r.alice, r.aliceId, true)
+	r.checkAccess(t, r.bob, r.bobId, true)
+	// The removed member knows the previous private key of the group
+	previousKey, err := r.open(r.bob).openGroupKey(r.group)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bobPublicKey, err := core.NewPublicKeyFromEncoded(r.bobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.open(r.owner).RemoveGroupMember("team", bobPublicKey); err != nil {
+		t.Fatal(err)
+	}
+	r.checkAccess(t, r.alice, r.aliceId, true)
+	r.checkAccess(t, r.bob, r.bobId, false)
+
+	group, err := r.open(r.alice).GetGroup(r.group.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.IsKeyReplaced() || group.PublicKey == r.group.PublicKey {
+		t.Errorf("Expected the key pair of the group to be replaced")
+	}
+	sealed, err := r.open(r.owner).keyRepository.GetDataKey(r.keyId, r.group.Id, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key_crypto.OpenDataKey(sealed, previousKey); err == nil {
+		t.Errorf("Expected the data key not to be sealed with the previous key of the group")
+	}
+}
+
+func TestRemoveGroupMemberResumed(t *testing.T) {
+	r := newGroupRepo(t)
+	// The removal is interrupted once the new key pair is saved, before the data keys are sealed again
+	ks := r.open(r.owner)
+	group := r.group
+	if err := ks.replaceGroupKey(&group, r.bobId); err != nil {
+		t.Fatal(err)
+	}
+	r.checkAccess(t, r.alice, r.aliceId, true)
+	r.checkAccess(t, r.bob, r.bobId, false)
+
+	// The next change of the group completes the replacement
+	carol, carolId := newUser(t)
+	carolPublicKey, err := core.NewPublicKeyFromEncoded(carolId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.open(r.alice).AddGroupMember("team", carolPublicKey); err != nil {
+		t.Fatal(err)
+	}
+	group, err = r.open(r.owner).GetGroup(r.group.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.IsKeyReplaced() {
+		t.Errorf("Expected the replacement of the key pair to be completed")
+	}
+	r.checkAccess(t, r.alice, r.aliceId, true)
+	r.checkAccess(t, carol, carolId, true)
+	r.checkAccess(t, r.bob, r.bobId, false)
+}
+
+func TestTamperedGroupRefused(t *testing.T) {
+	r := newGroupRepo(t)
+	// A writer of the repository replaces the key pair of the group with its own
+	group := r.group
+	attacker, attackerId := newUser(t)
+	if err := r.open(attacker).setGroupKey(&group, attacker, []string{r.aliceId, attackerId}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := group.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(r.path(".meta", ".groups", group.Id), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.open(r.owner).GetGroup(group.Id); err != core.ErrInvalidSignature {
+		t.Errorf("Expected the tampered group to be refused, got %v", err)
+	}
+	if _, err := r.open(r.owner).GetGroupByName("team"); err != repositories.ErrGroupNotFound {
+		t.Errorf("Expected the tampered group to be skipped, got %v", err)
+	}
+}
+
+func TestGroupRewrittenByNonMemberRefused(t *testing.T) {
+	r := newGroupRepo(t)
+	// A joined user who is not a member signs a new key pair of the group, sealed for the members and itself
+	attacker, attackerId := newUser(t)
+	ks := r.open(attacker)
+	group := r.group
+	if err := ks.setGroupKey(&group, attacker, []string{r.aliceId, r.bobId, attackerId}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.groupRepository.Save(group); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.open(r.owner).GetGroup(group.Id); err != core.ErrUnauthorizedGroupWriter {
+		t.Errorf("Expected the group written by a non member to be refused, got %v", err)
+	}
+	if _, err := r.open(r.owner).GetGroupByName("team"); err != repositories.ErrGroupNotFound {
+		t.Errorf("Expected the rewritten group to be skipped, got %v", err)
+	}
+}
+
+func TestDuplicateGroupNameRefused(t *testing.T) {
+	r := newGroupRepo(t)
+	// Another user creates a group with the name of an existing group
+	attacker, _ := newUser(t)
+	if _, err := r.open(attacker).CreateGroup("team"); err != ErrGroupAlreadyExists {
+		t.Errorf("Expected ErrGroupAlreadyExists, got %v", err)
+	}
+	ks := r.open(attacker)
+	userId, err := ks.GetUserId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, nonce, err := core.NewGroupId(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicate := core.Group{Id: id, Name: "team", Creator: userId, Nonce: nonce}
+	if err := ks.setGroupKey(&duplicate, attacker, []string{userId}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.groupRepository.Save(duplicate); err != nil {
+		t.Fatal(err)
+	}
+	// The name no longer resolves to a group, so that the duplicate does not receive the shares of the group
+	if _, err := r.open(r.owner).GetGroupByName("team"); err != repositories.ErrDuplicateGroupName {
+		t.Errorf("Expected ErrDuplicateGroupName, got %v", err)
+	}
+}
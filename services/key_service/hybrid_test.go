@@ -0,0 +1,119 @@
+package key_service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// isHybrid returns true if the sealed data key is sealed with the hybrid X25519 + ML-KEM-768 scheme.
+func isHybrid(sealed string) bool {
+	return strings.HasPrefix(sealed, "x25519-mlkem768-v1\n")
+}
+
+func TestHybridKeyWrappingRequired(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	recipient, recipientId := newUser(t)
+	ks := repo.open(owner)
+	ks.SetRequireHybridKeyWrapping(true)
+	repo.createVaults(t, ks, "/docs")
+	root, err := ks.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The recipient has not published an encapsulation key, so the share fails instead of using X25519 only
+	err = ks.Share(docs.KeyId, root.Id, "/", publicKeyOf(t, recipientId), recipientId, time.Time{})
+	if err != ErrNoEncapsulationKey {
+		t.Fatalf("Expected ErrNoEncapsulationKey, got %v", err)
+	}
+	if err := repo.open(recipient).PublishIdentity(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Share(docs.KeyId, root.Id, "/", publicKeyOf(t, recipientId), recipientId, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := ks.keyRepository.GetDataKey(docs.KeyId, recipientId, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isHybrid(sealed) {
+		t.Error("Expected the data key to be sealed with the hybrid scheme")
+	}
+	if _, err := repo.open(recipient).Get(docs.KeyId, root.Id, "/"); err != nil {
+		t.Errorf("Expected the recipient to open the data key, got %v", err)
+	}
+
+	// The data keys shared with a group are sealed with the encapsulation key of the signed group record
+	group, err := ks.CreateGroup("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.EncapsulationKey == "" {
+		t.Fatal("Expected the group to have an encapsulation key")
+	}
+	if err := ks.Share(docs.KeyId, root.Id, "/", publicKeyOf(t, group.PublicKey), group.Id, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err = ks.keyRepository.GetDataKey(docs.KeyId, group.Id, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isHybrid(sealed) {
+		t.Error("Expected the data key shared with the group to be sealed with the hybrid scheme")
+	}
+}
+
+func TestX25519KeyWrappingByDefault(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	recipient, recipientId := newUser(t)
+	if err := repo.open(recipient).PublishIdentity(); err != nil {
+		t.Fatal(err)
+	}
+	ks := repo.open(owner)
+	keyId, _ := repo.shareDocs(t, ks, recipientId, time.Time{})
+	sealed, err := ks.keyRepository.GetDataKey(keyId, recipientId, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isHybrid(sealed) {
+		t.Error("Expected the data key to be sealed with X25519 when the repository does not require the hybrid scheme")
+	}
+}
+
+func TestHybridKeyWrappingTurnedOff(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	recipient, recipientId := newUser(t)
+	if err := repo.open(recipient).PublishIdentity(); err != nil {
+		t.Fatal(err)
+	}
+	ks := repo.open(owner)
+	ks.SetRequireHybridKeyWrapping(true)
+	keyId, rootId := repo.shareDocs(t, ks, recipientId, time.Time{})
+
+	// The requirement is kept by the user configuration, so the key store opens the hybrid share silently
+	required := repo.open(recipient)
+	required.SetRequireHybridKeyWrapping(true)
+	if _, err := required.Get(keyId, rootId, "/"); err != nil {
+		t.Fatal(err)
+	}
+	if required.hybridDowngrade {
+		t.Error("Expected no warning while the hybrid key wrapping is required")
+	}
+
+	// The requirement is turned off in the repository configuration: the key store warns when it opens the hybrid share
+	turnedOff := repo.open(recipient)
+	if _, err := turnedOff.Get(keyId, rootId, "/"); err != nil {
+		t.Fatal(err)
+	}
+	if !turnedOff.hybridDowngrade {
+		t.Error("Expected a warning when a hybrid share is opened while the hybrid key wrapping is not required")
+	}
+}
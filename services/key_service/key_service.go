@@ -3,10 +3,14 @@ package key_service
 import (
 	"ctb-cli/core"
 	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/crypto/sign_crypto"
 	"ctb-cli/repositories"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"time"
 
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/curve25519"
 )
 
@@ -16,63 +20,270 @@ var (
 	ErrUserAlreadyJoined                = errors.New("user already joined")
 	ErrGeneratingVaultId                = errors.New("error generating vault id")
 	ErrGeneratingKey                    = errors.New("error generating key")
+	ErrPrivateKeyNotSet                 = errors.New("private key not set")
+	ErrDeviceNotAuthorized              = errors.New("the data key is not shared with this device")
+	ErrDeviceAlreadyAdded               = errors.New("device already added")
+	ErrDeviceOfAnotherUser              = errors.New("device belongs to another user")
+	ErrDeviceNotFound                   = errors.New("device not found")
+	ErrCannotRemoveCurrentDevice        = errors.New("cannot remove the current device")
+	ErrNoEncapsulationKey               = errors.New("the recipient has not published an ML-KEM encapsulation key, required by the hybrid key wrapping of the repository; the recipient publishes it by opening the repository once")
 )
 
 // KeyStoreDefault represents a key store
 type KeyStoreDefault struct {
-	privateKey      core.PrivateKey
-	keyRepository   repositories.KeyRepository
-	vaultRepository repositories.VaultRepository
+	decrypter        core.Decrypter
+	userId           string // userId caches the id of the user of the decrypter
+	keyRepository    repositories.KeyRepository
+	vaultRepository  repositories.VaultRepository
+	userRepository   repositories.UserRepository
+	groupRepository  repositories.GroupRepository
+	signerRepository repositories.SignerRepository
+	policyRepository repositories.PolicyRepository
+
+	memberGroups []core.Group                    // memberGroups caches the groups of the user of the decrypter
+	groupKeys    map[string]core.PrivateKey      // groupKeys caches the opened private keys of the groups, by public key of the group
+	signer       *core.Signature                 // signer caches the writer and the signing key of the user of the decrypter
+	signers      map[string]core.SigningIdentity // signers caches the signing identities of the users, by public key
+
+	acceptUnsigned bool // acceptUnsigned accepts the records written before the records were signed
+	requireHybrid  bool // requireHybrid seals the data keys with the hybrid X25519 + ML-KEM-768 scheme only
+	// hybridDowngrade is true once a data key sealed with the hybrid scheme is opened while it is not required
+	hybridDowngrade bool
+
+	passphrase     []byte              // passphrase opens the data keys shared with a passphrase
+	passphraseKeys map[string]core.Key // passphraseKeys caches the data keys opened with the passphrase, by sealed data key
+
+	recoveryKey       *core.PublicKey // recoveryKey is the recovery public key of the repository, if any
+	recoveryKeyLoaded bool            // recoveryKeyLoaded is true once the recovery key is read from the policy or set
 }
 
 // Ensure KeyStoreDefault implements KeyService
 var _ core.KeyService = &KeyStoreDefault{}
 
 // NewKeyStore creates a new instance of KeyStoreDefault
-func NewKeyStore(keyRepository repositories.KeyRepository, vaultRepository repositories.VaultRepository) *KeyStoreDefault {
+func NewKeyStore(keyRepository repositories.KeyRepository, vaultRepository repositories.VaultRepository, userRepository repositories.UserRepository, groupRepository repositories.GroupRepository, signerRepository repositories.SignerRepository) *KeyStoreDefault {
 	return &KeyStoreDefault{
-		keyRepository:   keyRepository,
-		vaultRepository: vaultRepository,
+		keyRepository:    keyRepository,
+		vaultRepository:  vaultRepository,
+		userRepository:   userRepository,
+		groupRepository:  groupRepository,
+		signerRepository: signerRepository,
+		signers:          make(map[string]core.SigningIdentity),
 	}
 }
 
 // SetPrivateKey sets the private key in the KeyStoreDefault instance.
 func (ks *KeyStoreDefault) SetPrivateKey(privateKey core.PrivateKey) {
-	ks.privateKey = privateKey
+	ks.SetDecrypter(NewLocalDecrypter(privateKey))
+}
+
+// SetDecrypter sets the decrypter performing the private key operations, e.g. a client of the key agent.
+func (ks *KeyStoreDefault) SetDecrypter(decrypter core.Decrypter) {
+	ks.decrypter = decrypter
+	ks.userId = ""
+	ks.memberGroups = nil
+	ks.groupKeys = nil
+	ks.signer = nil
 }
 
 // GetUserId returns the user ID associated with the key store.
-// It retrieves the user's public key and encodes it to obtain the user ID.
+// It retrieves the user's public key, which is the public key of the device, and returns the id
+// of the user having the device. If no user has the device, the encoded public key is the user ID.
 func (ks *KeyStoreDefault) GetUserId() (string, error) {
+	if ks.userId != "" {
+		return ks.userId, nil
+	}
 	// Get user public key
 	publicKey, err := ks.GetPublicKey()
 	if err != nil {
 		return "", err
 	}
-	// Encode public key to get user id
-	userId := publicKey.String()
+	// Get the user having the device
+	userId, err := ks.resolveUserId(publicKey.String())
+	if err != nil {
+		return "", err
+	}
+	ks.userId = userId
 	return userId, nil
 }
 
+// resolveUserId returns the id of the user having the device with the encoded public key.
+// Only the verified user records, whose device accepted to be a device of the user, are considered.
+// If no user has the device, the public key is the id of a single device user and is returned.
+// It returns ErrDeviceOfSeveralUsers if several users have the device.
+func (ks *KeyStoreDefault) resolveUserId(publicKey string) (string, error) {
+	if ks.userRepository == nil {
+		return publicKey, nil
+	}
+	users, err := ks.userRepository.List()
+	if err != nil {
+		return "", err
+	}
+	userId, found := publicKey, false
+	for _, user := range users {
+		device, ok := user.GetDevice(publicKey)
+		if !ok || ks.verifyUser(user) != nil || !ks.isDeviceAccepted(user, device) {
+			continue
+		}
+		if found && user.Id != userId {
+			return "", ErrDeviceOfSeveralUsers
+		}
+		userId, found = user.Id, true
+	}
+	return userId, nil
+}
+
+// sealForUser seals the data key for the user or the group with the specified id.
+// If the user has several devices, the data key is sealed with the public key of every device.
+// Otherwise, it is sealed with the public key of the user or the group.
+func (ks *KeyStoreDefault) sealForUser(key core.Key, userId string) (string, error) {
+	if ks.groupRepository != nil {
+		group, err := ks.groupRepository.Get(userId)
+		if err == nil {
+			publicKey, err := core.NewPublicKeyFromEncoded(group.PublicKey)
+			if err != nil {
+				return "", err
+			}
+			return ks.sealForGroupKey(key, publicKey, group.EncapsulationKey)
+		}
+		if !errors.Is(err, repositories.ErrGroupNotFound) {
+			return "", err
+		}
+	}
+	if ks.userRepository != nil {
+		user, err := ks.getUserRecord(userId)
+		if err == nil {
+			return ks.sealForDevices(key, user.DevicePublicKeys())
+		}
+		if !errors.Is(err, repositories.ErrUserNotFound) {
+			return "", err
+		}
+	}
+	publicKey, err := core.NewPublicKeyFromEncoded(userId)
+	if err != nil {
+		return "", err
+	}
+	return ks.sealDataKey(key, publicKey)
+}
+
+// sealDataKey seals the data key with the public key of a user or a device. If the repository requires the hybrid
+// key wrapping, the data key is sealed with the hybrid X25519 + ML-KEM-768 scheme and the published encapsulation
+// key of the recipient, and ErrNoEncapsulationKey is returned if the recipient has not published one.
+// Otherwise, it is sealed with X25519.
+func (ks *KeyStoreDefault) sealDataKey(key core.Key, publicKey core.PublicKey) (string, error) {
+	if !ks.requireHybrid {
+		return key_crypto.SealDataKey(key, publicKey)
+	}
+	// Publish the identity of the user first, so that the user can seal the data keys for itself
+	if ks.decrypter != nil && ks.signerRepository != nil {
+		if _, err := ks.getSigner(); err != nil {
+			return "", err
+		}
+	}
+	encapsulationKey, found, err := ks.getEncapsulationKey(publicKey.String())
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", ErrNoEncapsulationKey
+	}
+	return key_crypto.SealDataKeyHybrid(key, publicKey, encapsulationKey)
+}
+
+// sealForGroupKey seals the data key with the public key of a group and its encapsulation key, which is part of
+// the signed group record. The policy of sealDataKey applies: a group without an encapsulation key, written before
+// the groups had one, is refused if the repository requires the hybrid key wrapping.
+func (ks *KeyStoreDefault) sealForGroupKey(key core.Key, publicKey core.PublicKey, encodedEncapsulationKey string) (string, error) {
+	if !ks.requireHybrid {
+		return key_crypto.SealDataKey(key, publicKey)
+	}
+	if encodedEncapsulationKey == "" {
+		return "", ErrNoEncapsulationKey
+	}
+	encapsulationKey, err := base64.RawStdEncoding.DecodeString(encodedEncapsulationKey)
+	if err != nil {
+		return "", err
+	}
+	return key_crypto.SealDataKeyHybrid(key, publicKey, encapsulationKey)
+}
+
+// SetRequireHybridKeyWrapping sets whether the data keys are sealed with the hybrid X25519 + ML-KEM-768 scheme only.
+// It is a policy of the repository: the sealing fails rather than falling back to X25519 for the recipients
+// without an encapsulation key. The data keys are sealed with X25519 if it is not set.
+func (ks *KeyStoreDefault) SetRequireHybridKeyWrapping(require bool) {
+	ks.requireHybrid = require
+}
+
+// PublishIdentity publishes the signing identity and the encapsulation key of the user of the key store,
+// if they are not published yet, so that the data keys can be sealed for the user with the hybrid scheme.
+func (ks *KeyStoreDefault) PublishIdentity() error {
+	if ks.decrypter == nil {
+		return ErrPrivateKeyNotSet
+	}
+	_, err := ks.getSigner()
+	return err
+}
+
+// sealForDevices seals the data key with the public key of every device.
+func (ks *KeyStoreDefault) sealForDevices(key core.Key, devices []string) (string, error) {
+	shares := make(core.DeviceKeyShares)
+	for _, device := range devices {
+		publicKey, err := core.NewPublicKeyFromEncoded(device)
+		if err != nil {
+			return "", err
+		}
+		shares[device], err = ks.sealDataKey(key, publicKey)
+		if err != nil {
+			return "", err
+		}
+	}
+	return shares.Marshal()
+}
+
+// openShare opens a data key shared with the user.
+// If the data key is sealed for several devices, the data key sealed with the public key of the device is opened.
+func (ks *KeyStoreDefault) openShare(serialized string) (*core.Key, error) {
+	if ks.decrypter == nil {
+		return nil, ErrPrivateKeyNotSet
+	}
+	if core.IsDeviceKeyShares(serialized) {
+		shares, err := core.UnmarshalDeviceKeyShares(serialized)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err := ks.GetPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		share, found := shares[publicKey.String()]
+		if !found {
+			return nil, ErrDeviceNotAuthorized
+		}
+		serialized = share
+	}
+	// The data keys of the user are sealed with the hybrid scheme, so the requirement may have been turned off
+	// by a writer of the repository
+	if !ks.requireHybrid && !ks.hybridDowngrade && key_crypto.IsHybridSealed(serialized) {
+		ks.hybridDowngrade = true
+		log.Warn("The data keys shared with you are sealed with the hybrid X25519 + ML-KEM-768 scheme, but the hybrid key wrapping is not required: ",
+			"the new shares are sealed with X25519 only. Set require_hybrid_key_wrapping in your user configuration to require it.")
+	}
+	return ks.decrypter.OpenDataKey(serialized)
+}
+
 // Insert inserts a new key into the key store.
-// It first retrieves the user's public key using the GetPublicKey method.
-// Then, it seals the key with the user's public key using the SealDataKey method.
-// Next, it retrieves the user's ID using the GetUserId method.
+// It first retrieves the user's ID using the GetUserId method.
+// Then, it seals the key for the user (with the public key of every device of the user).
 // Finally, it saves the key in the user's data keys using the SaveDataKey method.
 // If any error occurs during the process, it is returned.
 func (ks *KeyStoreDefault) Insert(key *core.KeyInfo, path string) error {
-	// Get user public key
-	pk, err := ks.GetPublicKey()
-	if err != nil {
-		return err
-	}
-	// Seal key with user public key
-	keyHashed, err := key_crypto.SealDataKey(key.Key, pk)
+	// Get user id
+	userId, err := ks.GetUserId()
 	if err != nil {
 		return err
 	}
-	// Get user id
-	userId, err := ks.GetUserId()
+	// Seal key for the user
+	keyHashed, err := ks.sealForUser(key.Key, userId)
 	if err != nil {
 		return err
 	}
@@ -82,32 +293,60 @@ func (ks *KeyStoreDefault) Insert(key *core.KeyInfo, path string) error {
 
 // Get retrieves a key from the KeyStoreDefault.
 // It takes a keyId and a startVaultId as parameters.
+// If the key is shared with the passphrase of the key store, it returns the key opened with the passphrase.
 // If the key exists in the user's data keys, it returns the key in KeyInfo format.
 // If the key does not exist in the user's data keys, it checks if it exists in the provided vault.
 // If the key is found in the vault, it recursively calls the Get method to retrieve the vault key.
 // It then retrieves the encrypted data key from the vault and unseals it using the vault key.
 // Finally, it returns the key in KeyInfo format.
 func (ks *KeyStoreDefault) Get(keyId string, startVaultId string, startVaultPath string) (*core.KeyInfo, error) {
-	// Get user id
-	userId, err := ks.GetUserId()
-	if err != nil {
+	// Check if key is shared with the passphrase of the key store
+	if key, found, err := ks.getPassphraseDataKey(keyId, startVaultId, startVaultPath); found {
+		if err != nil {
+			return nil, err
+		}
+		keyInfo := core.NewKeyInfo(keyId, *key)
+		return &keyInfo, nil
+	} else if err != nil {
 		return nil, err
 	}
-	// Check if key directly exists in user's data keys
-	if ks.keyRepository.DataKeyExist(keyId, userId, startVaultPath) {
-		// Get key from user's data keys
-		sk, err := ks.keyRepository.GetDataKey(keyId, userId, startVaultPath)
+	// A key store opened with a passphrase only has no user
+	if ks.decrypter != nil || ks.passphrase == nil {
+		// Get user id
+		userId, err := ks.GetUserId()
 		if err != nil {
 			return nil, err
 		}
-		// Unseal key
-		key, err := key_crypto.OpenDataKey(sk, ks.privateKey)
-		if err != nil {
+		// Check if key directly exists in user's data keys
+		if ks.keyRepository.DataKeyExist(keyId, userId, startVaultPath) {
+			// Check that the share is not expired
+			if err := ks.checkShareExpiry(keyId, userId, startVaultId, startVaultPath); err != nil {
+				return nil, err
+			}
+			// Get key from user's data keys
+			sk, err := ks.keyRepository.GetDataKey(keyId, userId, startVaultPath)
+			if err != nil {
+				return nil, err
+			}
+			// Unseal key
+			key, err := ks.openShare(sk)
+			if err != nil {
+				return nil, err
+			}
+			// Return key in KeyInfo format
+			keyInfo := core.NewKeyInfo(keyId, *key)
+			return &keyInfo, nil
+		}
+		// Check if key is shared with a group of the user
+		if key, found, err := ks.getGroupDataKey(keyId, startVaultPath); found {
+			if err != nil {
+				return nil, err
+			}
+			keyInfo := core.NewKeyInfo(keyId, *key)
+			return &keyInfo, nil
+		} else if err != nil {
 			return nil, err
 		}
-		// Return key in KeyInfo format
-		keyInfo := core.NewKeyInfo(keyId, *key)
-		return &keyInfo, nil
 	}
 	// If key does not exist in user's data keys, check if it exists in a vault
 	// If startVaultId is not provided, return key not found
@@ -144,6 +383,20 @@ func (ks *KeyStoreDefault) Get(keyId string, startVaultId string, startVaultPath
 	return &keyInfo, nil
 }
 
+// GetVaultKeyByPath retrieves the vault key of the directory located at the specified path.
+// It finds the vault of the directory and its parent vault, then retrieves the vault key using the Get method.
+func (ks *KeyStoreDefault) GetVaultKeyByPath(path string) (*core.KeyInfo, error) {
+	vault, err := ks.vaultRepository.GetVaultByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	parentPath, parentVault, err := ks.vaultRepository.GetVaultParent(path)
+	if err != nil {
+		return nil, err
+	}
+	return ks.Get(vault.KeyId, parentVault.Id, parentPath)
+}
+
 // GetHasAccessToKey checks if a user has access to a specific key.
 // It also checks if the access is inherited from a vault or directly from the user's data keys.
 // It first checks if the key directly exists in the user's data keys.
@@ -160,6 +413,14 @@ func (ks *KeyStoreDefault) GetHasAccessToKey(keyId string, startVaultId string,
 			return true, false
 		}
 	}
+	// Check if key is shared with a group of the user
+	if groups, err := ks.getGroupsOfUser(userId); err == nil {
+		for _, group := range groups {
+			if ks.keyRepository.DataKeyExist(keyId, group.Id, startVaultPath) {
+				return true, false
+			}
+		}
+	}
 	// If key does not exist in user's data keys, check if it exists in a vault
 	// If startVaultId is not provided, return false
 	if startVaultId == "" {
@@ -180,30 +441,68 @@ func (ks *KeyStoreDefault) GetHasAccessToKey(keyId string, startVaultId string,
 	return px, true
 }
 
-func (ks *KeyStoreDefault) Share(keyId string, startVaultId string, startVaultPath string, recipient core.PublicKey, recipientUserId string) error {
+// Share shares a data key with the recipient.
+// If the recipient is a device of a user having several devices, the data key is shared with the user,
+// sealed with the public key of every device of the user.
+// If expiresAt is not zero, the share expires at this time, otherwise it does not expire.
+func (ks *KeyStoreDefault) Share(keyId string, startVaultId string, startVaultPath string, recipient core.PublicKey, recipientUserId string, expiresAt time.Time) error {
 	key, err := ks.Get(keyId, startVaultId, startVaultPath)
 	if err != nil {
 		return fmt.Errorf("cannot load key: %v", err)
 	}
 
-	keyHashed, err := key_crypto.SealDataKey(key.Key, recipient)
+	recipientUserId, err = ks.resolveUserId(recipientUserId)
+	if err != nil {
+		return err
+	}
+	keyHashed, err := ks.sealForUser(key.Key, recipientUserId)
 	if err != nil {
 		return err
 	}
 
-	return ks.keyRepository.SaveDataKey(keyId, keyHashed, recipientUserId, startVaultPath)
+	err = ks.keyRepository.SaveDataKey(keyId, keyHashed, recipientUserId, startVaultPath)
+	if err != nil {
+		return err
+	}
+	if expiresAt.IsZero() {
+		return ks.keyRepository.DeleteDataKeyExpiry(keyId, recipientUserId, startVaultPath)
+	}
+	return ks.setShareExpiry(keyId, recipientUserId, startVaultPath, expiresAt)
 }
 
-// GetPublicKey returns the public key corresponding to the private key stored in the KeyStore.
-// It uses the X25519 function from the curve25519 package to perform the scalar multiplication
-// of the private key with the base point, resulting in the public key.
+// GetPublicKey returns the public key corresponding to the private key of the KeyStore.
+// The public key is provided by the decrypter holding the private key.
 // If any error occurs during the process, it returns the error.
 func (ks *KeyStoreDefault) GetPublicKey() (core.PublicKey, error) {
-	res, err := curve25519.X25519(ks.privateKey.Bytes(), curve25519.Basepoint)
+	if ks.decrypter == nil {
+		return core.EmptyPublicKey(), ErrPrivateKeyNotSet
+	}
+	return ks.decrypter.PublicKey()
+}
+
+// GetSigningPublicKey returns the encoded signing public key of the user, derived from the private key.
+func (ks *KeyStoreDefault) GetSigningPublicKey() (string, error) {
+	if ks.decrypter == nil {
+		return "", ErrPrivateKeyNotSet
+	}
+	publicKey, err := ks.decrypter.SigningPublicKey()
 	if err != nil {
-		return core.EmptyPublicKey(), err
+		return "", err
+	}
+	return sign_crypto.EncodeSigningPublicKey(publicKey), nil
+}
+
+// Sign signs the payload with the signing key of the user, for the use described by the info string.
+// It returns the encoded signature.
+func (ks *KeyStoreDefault) Sign(info string, payload []byte) (string, error) {
+	if ks.decrypter == nil {
+		return "", ErrPrivateKeyNotSet
+	}
+	signature, err := ks.decrypter.Sign(sign_crypto.SignedMessage(info, payload))
+	if err != nil {
+		return "", err
 	}
-	return core.NewPublicKeyFromBytes(res), nil
+	return sign_crypto.EncodeSignature(signature), nil
 }
 
 // GetPublicKeyByPrivateKey returns the public key as a string.
@@ -220,6 +519,7 @@ func (ks *KeyStoreDefault) GetPublicKeyByPrivateKey(privateKey core.PrivateKey)
 // CreateVault generates a new vault and inserts it into the vault repository.
 // If parentId is provided, it generates a key in the parent vault and associates it with the new vault.
 // If parentId is not provided, it generates a key without a parent and inserts it into the keystore.
+// The keys of the root vault and of the top-level vaults are also sealed with the recovery key of the repository, if any.
 // The generated vault and associated key are returned on success.
 // If any error occurs during the process, an error is returned.
 func (ks *KeyStoreDefault) CreateVault(parentId string, path string) (*core.Vault, error) {
@@ -240,6 +540,12 @@ func (ks *KeyStoreDefault) CreateVault(parentId string, path string) (*core.Vaul
 		if err != nil {
 			return nil, ErrGeneratingKey
 		}
+		// Seal the key of a top-level vault with the recovery key
+		if isRootPath(parentPath) {
+			if err := ks.shareWithRecoveryKey(*key, parentPath); err != nil {
+				return nil, err
+			}
+		}
 	} else {
 		// If parentId is empty, generate key without parent
 		key, err = core.GenerateKey()
@@ -251,6 +557,10 @@ func (ks *KeyStoreDefault) CreateVault(parentId string, path string) (*core.Vaul
 		if err != nil {
 			return nil, err
 		}
+		// Seal the key of the root vault with the recovery key
+		if err := ks.shareWithRecoveryKey(*key, ""); err != nil {
+			return nil, err
+		}
 	}
 	// Insert vault into vault repository
 	vault := core.Vault{
@@ -382,7 +692,35 @@ func (ks *KeyStoreDefault) IsUserJoined() bool {
 	if err != nil {
 		return false
 	}
-	return ks.keyRepository.IsUserJoined(userId)
+	if ks.keyRepository.IsUserJoined(userId) {
+		return true
+	}
+	// A member of a group has joined
+	groups, err := ks.getMemberGroups()
+	return err == nil && len(groups) > 0
+}
+
+// Join joins the user of the private key in the repository.
+// It returns ErrUserAlreadyJoined if the user has already joined.
+func (ks *KeyStoreDefault) Join() error {
+	userId, err := ks.GetUserId()
+	if err != nil {
+		return err
+	}
+	return ks.JoinByUserId(userId)
+}
+
+// JoinByUserId joins the user with the specified id in the repository, so that keys can be shared with the user.
+// It returns ErrUserAlreadyJoined if the user has already joined.
+func (ks *KeyStoreDefault) JoinByUserId(userId string) error {
+	userId, err := ks.resolveUserId(userId)
+	if err != nil {
+		return err
+	}
+	if ks.keyRepository.IsUserJoined(userId) {
+		return ErrUserAlreadyJoined
+	}
+	return ks.keyRepository.JoinUser(userId)
 }
 
 // GetKeyAccessList retrieves the key access list for a given key ID and starting vault ID.
@@ -395,12 +733,71 @@ func (ks *KeyStoreDefault) GetKeyAccessList(keyId string, startVaultId string, s
 		return nil, err
 	}
 	accessList := make(core.KeyAccessList, 0)
+	added := make(map[string]bool)
 	for _, user := range usersList {
 		if hasAccess, inherited := ks.GetHasAccessToKey(keyId, startVaultId, startVaultPath, user); hasAccess {
-			accessList = append(accessList, core.KeyAccess{
-				PublicKey: user,
+			// Show the share with a passphrase
+			if user == core.PassphraseRecipient {
+				if !added[user] {
+					added[user] = true
+					accessList = append(accessList, core.KeyAccess{
+						PublicKey:  user,
+						Inherited:  inherited,
+						Passphrase: true,
+					})
+				}
+				continue
+			}
+			// Show the share with the recovery key
+			if user == core.RecoveryRecipient {
+				if !added[user] {
+					added[user] = true
+					accessList = append(accessList, core.KeyAccess{
+						PublicKey: user,
+						Inherited: inherited,
+						Recovery:  true,
+					})
+				}
+				continue
+			}
+			// Show the name of a group
+			if group, err := ks.GetGroup(user); err == nil {
+				if !added[group.Id] {
+					added[group.Id] = true
+					accessList = append(accessList, core.KeyAccess{
+						PublicKey: group.PublicKey,
+						Inherited: inherited,
+						Group:     group.Name,
+					})
+				}
+				continue
+			}
+			// Show the user having the device instead of the device
+			userId, err := ks.resolveUserId(user)
+			if err != nil {
+				return nil, err
+			}
+			if added[userId] {
+				continue
+			}
+			added[userId] = true
+			access := core.KeyAccess{
+				PublicKey: userId,
 				Inherited: inherited,
-			})
+			}
+			if devices, err := ks.getUserDevices(userId); err == nil {
+				access.Devices = devices
+			}
+			if !inherited {
+				expiry, err := ks.keyRepository.GetDataKeyExpiry(keyId, user, startVaultPath)
+				if err != nil {
+					return nil, err
+				}
+				if expiry != nil {
+					access.Expires = &expiry.ExpiresAt
+				}
+			}
+			accessList = append(accessList, access)
 		}
 	}
 	return accessList, nil
@@ -410,5 +807,304 @@ func (ks *KeyStoreDefault) GetKeyAccessList(keyId string, startVaultId string, s
 // It takes the key ID and the recipient user ID as parameters.
 // Returns an error if there was a problem deleting the data key.
 func (ks *KeyStoreDefault) Unshare(keyId string, recipientUserId string, path string) error {
+	recipientUserId, err := ks.resolveUserId(recipientUserId)
+	if err != nil {
+		return err
+	}
 	return ks.keyRepository.DeleteDataKey(keyId, recipientUserId, path)
 }
+
+// RotateVaultKey replaces the key of the vault located at the specified path with a new key,
+// or resumes the rotation if the rotation of the vault was interrupted.
+// The new vault key is shared with the users having a direct share of the old one and with the recovery key,
+// and added to the parent vault, before the vault is saved with the ids of the new and the old vault keys.
+// The policy of the repository is proved again with the new key of the root vault.
+// Then the keys sealed in the vault (file keys and sub vault keys) are sealed again with the new vault key.
+// The keys already sealed with the new vault key are kept, so it can be run again after an interruption.
+// The old vault key stays available until CompleteVaultKeyRotation is called.
+// It returns the old and the new vault keys.
+func (ks *KeyStoreDefault) RotateVaultKey(vaultPath string) (oldKey *core.KeyInfo, newKey *core.KeyInfo, err error) {
+	// Get the vault and the old vault key
+	vault, err := ks.vaultRepository.GetVaultByPath(vaultPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	parentPath, parentVault, err := ks.vaultRepository.GetVaultParent(vaultPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if vault.IsKeyRotated() {
+		// Resume the interrupted rotation with the keys recorded in the vault
+		oldKey, err = ks.Get(vault.PreviousKeyId, parentVault.Id, parentPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		newKey, err = ks.Get(vault.KeyId, parentVault.Id, parentPath)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		oldKey, err = ks.Get(vault.KeyId, parentVault.Id, parentPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Generate the new vault key
+		newKey, err = core.GenerateKey()
+		if err != nil {
+			return nil, nil, ErrGeneratingKey
+		}
+	}
+	// Share the new vault key before it is recorded in the vault, so that it can be opened to resume the rotation
+	err = ks.shareRotatedVaultKey(*oldKey, *newKey, parentVault, parentPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Record the rotation in the vault
+	if !vault.IsKeyRotated() {
+		vault.KeyId = newKey.Id
+		vault.PreviousKeyId = oldKey.Id
+		err = ks.vaultRepository.SaveVault(vault, vaultPath)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	// The policy of the repository is proved with the root vault key, so it is proved again with the new one
+	if isRootPath(vaultPath) {
+		if err := ks.reprovePolicy(newKey.Id); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Seal the keys of the vault with the new vault key
+	keyIds, err := ks.vaultRepository.ListKeys(vault.Id, vaultPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, keyId := range keyIds {
+		encKey, found := ks.vaultRepository.GetKey(keyId, vault.Id, vaultPath)
+		if !found {
+			return nil, nil, ErrDataKeyNotFound
+		}
+		// Keep the keys already sealed with the new vault key
+		if _, err := key_crypto.OpenVaultDataKey(encKey, newKey.Key); err == nil {
+			continue
+		}
+		key, err := key_crypto.OpenVaultDataKey(encKey, oldKey.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		sealedKey, err := key_crypto.SealVaultDataKey(*key, newKey.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = ks.vaultRepository.AddKeyToVault(&vault, vaultPath, keyId, sealedKey)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return oldKey, newKey, nil
+}
+
+// shareRotatedVaultKey shares the new vault key with the users having a direct share of the old one,
+// with the same expiry, and with the recovery key of the repository if the old one is shared with it.
+// The shares of the old vault key with a passphrase are not renewed since the passphrase is not known.
+// The new vault key is also added to the parent vault.
+func (ks *KeyStoreDefault) shareRotatedVaultKey(oldKey core.KeyInfo, newKey core.KeyInfo, parentVault core.Vault, parentPath string) error {
+	users, err := ks.keyRepository.ListUsers()
+	if err != nil {
+		return err
+	}
+	for _, userId := range users {
+		if userId == core.PassphraseRecipient || !ks.keyRepository.DataKeyExist(oldKey.Id, userId, parentPath) {
+			continue
+		}
+		// The share with the recovery key is renewed with the recovery key of the repository, if it is still set
+		if userId == core.RecoveryRecipient {
+			err = ks.shareWithRecoveryKey(newKey, parentPath)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		sealedKey, err := ks.sealForUser(newKey.Key, userId)
+		if err != nil {
+			return err
+		}
+		err = ks.keyRepository.SaveDataKey(newKey.Id, sealedKey, userId, parentPath)
+		if err != nil {
+			return err
+		}
+		// The share of the new vault key expires with the share of the old one
+		expiry, err := ks.keyRepository.GetDataKeyExpiry(oldKey.Id, userId, parentPath)
+		if err != nil {
+			return err
+		}
+		if expiry != nil {
+			err = ks.setShareExpiry(newKey.Id, userId, parentPath, expiry.ExpiresAt)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if parentVault.Id != "" {
+		return ks.AddKeyToVault(&parentVault, parentPath, newKey)
+	}
+	return nil
+}
+
+// CompleteVaultKeyRotation removes the shares of the old vault key and the old vault key from the parent vault,
+// once the vault and its content are sealed with the new vault key, and clears the rotation recorded in the vault.
+// The share of the old vault key with a passphrase is removed without being renewed, since the passphrase is not
+// known: it returns true if such a share was removed. It does nothing if the vault key is not being rotated.
+func (ks *KeyStoreDefault) CompleteVaultKeyRotation(vaultPath string) (passphraseShareRemoved bool, err error) {
+	vault, err := ks.vaultRepository.GetVaultByPath(vaultPath)
+	if err != nil {
+		return false, err
+	}
+	if !vault.IsKeyRotated() {
+		return false, nil
+	}
+	parentPath, parentVault, err := ks.vaultRepository.GetVaultParent(vaultPath)
+	if err != nil {
+		return false, err
+	}
+	// Remove the shares of the old vault key, including the share with a passphrase
+	users, err := ks.keyRepository.ListUsers()
+	if err != nil {
+		return false, err
+	}
+	for _, userId := range users {
+		if !ks.keyRepository.DataKeyExist(vault.PreviousKeyId, userId, parentPath) {
+			continue
+		}
+		err = ks.keyRepository.DeleteDataKey(vault.PreviousKeyId, userId, parentPath)
+		if err != nil {
+			return false, err
+		}
+		if userId == core.PassphraseRecipient {
+			passphraseShareRemoved = true
+		}
+	}
+	// Remove the old vault key from the parent vault
+	if parentVault.Id != "" {
+		if _, found := ks.vaultRepository.GetKey(vault.PreviousKeyId, parentVault.Id, parentPath); found {
+			err = ks.vaultRepository.RemoveKey(vault.PreviousKeyId, parentVault.Id, parentPath)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	vault.PreviousKeyId = ""
+	return passphraseShareRemoved, ks.vaultRepository.SaveVault(vault, vaultPath)
+}
+
+// GetUser returns the user of the key store with its devices.
+// A user without user record has a single device, whose public key is the user ID.
+func (ks *KeyStoreDefault) GetUser() (core.User, error) {
+	userId, err := ks.GetUserId()
+	if err != nil {
+		return core.User{}, err
+	}
+	user, err := ks.getUserRecord(userId)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		return core.NewUser(userId, ""), nil
+	}
+	return user, err
+}
+
+// getUserDevices returns the public keys of the devices of a user having a user record.
+func (ks *KeyStoreDefault) getUserDevices(userId string) ([]string, error) {
+	user, err := ks.getUserRecord(userId)
+	if err != nil {
+		return nil, err
+	}
+	return user.DevicePublicKeys(), nil
+}
+
+// AddDevice adds a device to the user of the key store.
+// The data keys shared with the user are sealed again with the public key of every device of the user,
+// including the new device. It must be called with the private key of a device of the user.
+// The new device is endorsed by the current device, and opens the data keys of the user once it accepts
+// to be a device of the user with AcceptDevice.
+func (ks *KeyStoreDefault) AddDevice(publicKey core.PublicKey, name string) error {
+	user, err := ks.GetUser()
+	if err != nil {
+		return err
+	}
+	// Check that the device does not belong to a user
+	if user.HasDevice(publicKey.String()) {
+		return ErrDeviceAlreadyAdded
+	}
+	if otherUserId, err := ks.resolveUserId(publicKey.String()); err != nil {
+		return err
+	} else if otherUserId != publicKey.String() {
+		return ErrDeviceOfAnotherUser
+	}
+	if _, err := ks.getUserRecord(publicKey.String()); err == nil {
+		return ErrDeviceOfAnotherUser
+	} else if !errors.Is(err, repositories.ErrUserNotFound) {
+		return err
+	}
+	user.Devices = append(user.Devices, core.Device{PublicKey: publicKey.String(), Name: name})
+	return ks.updateUserDevices(user)
+}
+
+// RemoveDevice removes a device from the user of the key store.
+// The data keys shared with the user are sealed again with the public keys of the remaining devices.
+// The current device and the first device of the user, whose public key is the id of the user, cannot be removed.
+// The removed device is not able to open the data keys shared with the user anymore, but the data keys
+// it opened before are not changed. Rotate the vault keys to revoke its access to the files.
+func (ks *KeyStoreDefault) RemoveDevice(publicKey core.PublicKey) error {
+	user, err := ks.GetUser()
+	if err != nil {
+		return err
+	}
+	if !user.HasDevice(publicKey.String()) {
+		return ErrDeviceNotFound
+	}
+	current, err := ks.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	if current.Equals(publicKey) {
+		return ErrCannotRemoveCurrentDevice
+	}
+	if publicKey.String() == user.Id {
+		return ErrCannotRemoveFirstDevice
+	}
+	user.RemoveDevice(publicKey.String())
+	return ks.updateUserDevices(user)
+}
+
+// updateUserDevices seals again the data keys shared with the user and the private keys of the groups
+// of the user for the devices of the user, then signs and saves the user record.
+func (ks *KeyStoreDefault) updateUserDevices(user core.User) error {
+	devices := user.DevicePublicKeys()
+	reseal := func(sealed string) (string, error) {
+		key, err := ks.openShare(sealed)
+		if err != nil {
+			return "", err
+		}
+		return ks.sealForDevices(*key, devices)
+	}
+	err := ks.keyRepository.UpdateDataKeys(user.Id, func(keyId string, sealed string) (string, error) {
+		return reseal(sealed)
+	})
+	if err != nil {
+		return err
+	}
+	// Seal the private keys of the groups of the user
+	groups, err := ks.getGroupsOfUser(user.Id)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		group.Members[user.Id], err = reseal(group.Members[user.Id])
+		if err != nil {
+			return err
+		}
+		if err := ks.groupRepository.Save(group); err != nil {
+			return err
+		}
+	}
+	return ks.saveUser(user)
+}
@@ -0,0 +1,86 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testRepo is a repository in a temporary directory, opened by the key stores of several users.
+type testRepo struct {
+	root string
+}
+
+// newTestRepo creates an empty repository in a temporary directory.
+func newTestRepo(t *testing.T) *testRepo {
+	r := &testRepo{root: t.TempDir()}
+	r.mkdir(t, "/")
+	return r
+}
+
+// mkdir creates the directory at the path of the repository, with its system folders.
+func (r *testRepo) mkdir(t *testing.T, path string) {
+	t.Helper()
+	for _, folder := range core.GetRepoSystemFolderNames() {
+		if err := os.MkdirAll(filepath.Join(r.root, path, ".meta", folder), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// path returns the absolute path of the path of the repository.
+func (r *testRepo) path(elem ...string) string {
+	return filepath.Join(append([]string{r.root}, elem...)...)
+}
+
+// open returns a new key store of the repository opened with the private key, as a new process of the user would.
+func (r *testRepo) open(privateKey core.PrivateKey) *KeyStoreDefault {
+	resolver := repositories.NewPathResolver(r.root)
+	keyRepository := repositories.NewKeyRepositoryFile(r.root, resolver)
+	vaultRepository := repositories.NewVaultRepositoryFile(r.root, resolver)
+	groupRepository := repositories.NewGroupRepositoryFile(r.root)
+	ks := NewKeyStore(keyRepository, vaultRepository, repositories.NewUserRepositoryFile(r.root),
+		groupRepository, repositories.NewSignerRepositoryFile(r.root))
+	policyRepository := repositories.NewPolicyRepositoryFile(r.root)
+	ks.SetPolicyRepository(policyRepository)
+	keyRepository.SetRecordSigner(ks)
+	vaultRepository.SetRecordSigner(ks)
+	groupRepository.SetRecordSigner(ks)
+	policyRepository.SetRecordSigner(ks)
+	ks.SetPrivateKey(privateKey)
+	return ks
+}
+
+// newUser returns the private key and the encoded public key of a new user.
+func newUser(t *testing.T) (core.PrivateKey, string) {
+	t.Helper()
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, publicKey.String()
+}
+
+// createVaults creates the root vault and a vault for each path, in order, with the key store.
+func (r *testRepo) createVaults(t *testing.T, ks *KeyStoreDefault, paths ...string) {
+	t.Helper()
+	if _, err := ks.CreateVault("", "/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		r.mkdir(t, path)
+		parent, err := ks.vaultRepository.GetVaultByPath(filepath.Dir(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ks.CreateVault(parent.Id, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
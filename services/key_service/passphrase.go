@@ -0,0 +1,87 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrPassphraseShareExists = errors.New("the data key is already shared with another passphrase: remove the share with a passphrase first")
+)
+
+// SetPassphrase sets the passphrase opening the data keys shared with a passphrase.
+// A key store with a passphrase and no private key opens only the data keys shared with the passphrase
+// and the keys of their vaults.
+func (ks *KeyStoreDefault) SetPassphrase(passphrase []byte) {
+	ks.passphrase = passphrase
+	ks.passphraseKeys = make(map[string]core.Key)
+}
+
+// ShareWithPassphrase shares the data key with the people knowing the passphrase, who have no key pair.
+// The data key is sealed with a key derived from the passphrase and saved for core.PassphraseRecipient.
+// If expiresAt is not zero, the share expires at this time.
+// A data key has a single share with a passphrase: sharing it again with the same passphrase updates the share,
+// and ErrPassphraseShareExists is returned if it is already shared with another passphrase.
+func (ks *KeyStoreDefault) ShareWithPassphrase(keyId string, startVaultId string, startVaultPath string, passphrase []byte, expiresAt time.Time) error {
+	key, err := ks.Get(keyId, startVaultId, startVaultPath)
+	if err != nil {
+		return fmt.Errorf("cannot load key: %v", err)
+	}
+	if ks.keyRepository.DataKeyExist(keyId, core.PassphraseRecipient, startVaultPath) {
+		existing, err := ks.keyRepository.GetDataKey(keyId, core.PassphraseRecipient, startVaultPath)
+		if err != nil {
+			return err
+		}
+		_, err = key_crypto.OpenDataKeyWithPassphrase(existing, passphrase)
+		if errors.Is(err, key_crypto.ErrInvalidPassphrase) {
+			return ErrPassphraseShareExists
+		}
+		if err != nil {
+			return err
+		}
+	}
+	sealed, err := key_crypto.SealDataKeyWithPassphrase(key.Key, passphrase)
+	if err != nil {
+		return err
+	}
+	err = ks.keyRepository.SaveDataKey(keyId, sealed, core.PassphraseRecipient, startVaultPath)
+	if err != nil {
+		return err
+	}
+	if expiresAt.IsZero() {
+		return ks.keyRepository.DeleteDataKeyExpiry(keyId, core.PassphraseRecipient, startVaultPath)
+	}
+	return ks.setShareExpiry(keyId, core.PassphraseRecipient, startVaultPath, expiresAt)
+}
+
+// getPassphraseDataKey returns the data key shared with the passphrase of the key store, if any.
+// A data key shared with another passphrase is not found.
+func (ks *KeyStoreDefault) getPassphraseDataKey(keyId string, startVaultId string, path string) (*core.Key, bool, error) {
+	if ks.passphrase == nil || !ks.keyRepository.DataKeyExist(keyId, core.PassphraseRecipient, path) {
+		return nil, false, nil
+	}
+	// Check that the share is not expired
+	if err := ks.checkShareExpiry(keyId, core.PassphraseRecipient, startVaultId, path); err != nil {
+		return nil, true, err
+	}
+	sealed, err := ks.keyRepository.GetDataKey(keyId, core.PassphraseRecipient, path)
+	if err != nil {
+		return nil, true, err
+	}
+	// The keys are cached by sealed key, since deriving the key from the passphrase is slow by design
+	if key, ok := ks.passphraseKeys[sealed]; ok {
+		return &key, true, nil
+	}
+	key, err := key_crypto.OpenDataKeyWithPassphrase(sealed, ks.passphrase)
+	if errors.Is(err, key_crypto.ErrInvalidPassphrase) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	ks.passphraseKeys[sealed] = *key
+	return key, true, nil
+}
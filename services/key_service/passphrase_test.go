@@ -0,0 +1,58 @@
+package key_service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPassphraseShareNotOverwritten(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	ks := repo.open(owner)
+	repo.createVaults(t, ks, "/docs")
+	root, err := ks.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.ShareWithPassphrase(docs.KeyId, root.Id, "/", []byte("first"), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another passphrase does not replace the share, the same passphrase updates it
+	err = ks.ShareWithPassphrase(docs.KeyId, root.Id, "/", []byte("second"), time.Time{})
+	if err != ErrPassphraseShareExists {
+		t.Errorf("Expected ErrPassphraseShareExists, got %v", err)
+	}
+	if err := ks.ShareWithPassphrase(docs.KeyId, root.Id, "/", []byte("first"), time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("Expected the share to be updated, got %v", err)
+	}
+	auditor := repo.open(owner)
+	auditor.SetDecrypter(nil)
+	auditor.SetPassphrase([]byte("first"))
+	if _, err := auditor.Get(docs.KeyId, root.Id, "/"); err != nil {
+		t.Errorf("Expected the first passphrase to open the key, got %v", err)
+	}
+
+	// The share with the passphrase is not renewed by a rotation, and its removal is reported
+	if _, _, err := ks.RotateVaultKey("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := ks.CompleteVaultKeyRotation("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !removed {
+		t.Error("Expected the removal of the share with a passphrase to be reported")
+	}
+	docs, err = ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auditor.Get(docs.KeyId, root.Id, "/"); err == nil {
+		t.Error("Expected the passphrase not to open the new vault key")
+	}
+}
@@ -0,0 +1,103 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/record_crypto"
+	"ctb-cli/repositories"
+	"errors"
+)
+
+var (
+	ErrUntrustedPolicy = errors.New("the policy of the repository is not signed by a user with access to the root directory; set it again with the private key of an owner")
+)
+
+// SetPolicyRepository sets the repository of the policy of the repository, signed by the key store.
+func (ks *KeyStoreDefault) SetPolicyRepository(policyRepository repositories.PolicyRepository) {
+	ks.policyRepository = policyRepository
+}
+
+// GetPolicy returns the policy of the repository, or an empty policy if the repository has no policy.
+// The policy must be signed by a verified user and proved with the root vault key, or with the previous
+// root vault key while the root vault key is rotated, so that only the users with access to the root
+// directory can write it: it returns ErrUntrustedPolicy otherwise.
+func (ks *KeyStoreDefault) GetPolicy() (core.RepositoryPolicy, error) {
+	if ks.policyRepository == nil {
+		return core.RepositoryPolicy{}, nil
+	}
+	policy, err := ks.policyRepository.Get()
+	if errors.Is(err, repositories.ErrPolicyNotFound) {
+		return core.RepositoryPolicy{}, nil
+	}
+	if err != nil {
+		if isRefusedSignature(err) {
+			return core.RepositoryPolicy{}, ErrUntrustedPolicy
+		}
+		return core.RepositoryPolicy{}, err
+	}
+	// The proof must be sealed with the current or the previous root vault key
+	root, err := ks.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		return core.RepositoryPolicy{}, err
+	}
+	if policy.RootKeyId != root.KeyId && (policy.RootKeyId != root.PreviousKeyId || policy.RootKeyId == "") {
+		return core.RepositoryPolicy{}, ErrUntrustedPolicy
+	}
+	rootKey, err := ks.getRootKey(policy.RootKeyId)
+	if err != nil {
+		return core.RepositoryPolicy{}, err
+	}
+	payload, err := policy.ProvedPayload()
+	if err != nil {
+		return core.RepositoryPolicy{}, err
+	}
+	if _, err := record_crypto.Open(rootKey.Key, record_crypto.PolicyV1Info, policy.Proof, payload); err != nil {
+		return core.RepositoryPolicy{}, ErrUntrustedPolicy
+	}
+	return policy, nil
+}
+
+// SavePolicy proves the policy with the root vault key and saves it signed by the user of the key store,
+// who must have access to the root directory.
+func (ks *KeyStoreDefault) SavePolicy(policy core.RepositoryPolicy) error {
+	root, err := ks.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		return err
+	}
+	rootKey, err := ks.getRootKey(root.KeyId)
+	if err != nil {
+		return err
+	}
+	policy.RootKeyId = rootKey.Id
+	payload, err := policy.ProvedPayload()
+	if err != nil {
+		return err
+	}
+	policy.Proof, err = record_crypto.Seal(rootKey.Key, record_crypto.PolicyV1Info, nil, payload)
+	if err != nil {
+		return err
+	}
+	return ks.policyRepository.Save(policy)
+}
+
+// getRootKey returns the root vault key, or the previous root vault key, with the id.
+func (ks *KeyStoreDefault) getRootKey(keyId string) (*core.KeyInfo, error) {
+	return ks.Get(keyId, "", "")
+}
+
+// reprovePolicy proves the policy of the repository again with the new root vault key, when the root vault key
+// is rotated. Nothing is done if the repository has no policy or if it is already proved with the new key.
+func (ks *KeyStoreDefault) reprovePolicy(newKeyId string) error {
+	policy, err := ks.GetPolicy()
+	if err != nil {
+		return err
+	}
+	if policy.RootKeyId == "" || policy.RootKeyId == newKeyId {
+		return nil
+	}
+	return ks.SavePolicy(policy)
+}
+
+// isRefusedSignature returns true if the error is the error of a record whose signature is refused.
+func isRefusedSignature(err error) bool {
+	return errors.Is(err, core.ErrInvalidSignature) || errors.Is(err, core.ErrUnknownSigner) || errors.Is(err, core.ErrUnsignedRecord)
+}
@@ -0,0 +1,177 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"errors"
+	"path/filepath"
+)
+
+var (
+	ErrNoRecoveryKey       = errors.New("the repository has no recovery key")
+	ErrRecoveryKeyMismatch = errors.New("the private key is not the recovery key of the repository")
+	ErrNothingToRecover    = errors.New("no key is sealed with the recovery key")
+)
+
+// SetRecoveryKey sets the recovery public key of the repository, instead of the recovery key of the policy of
+// the repository. The root and top-level vault keys created afterwards are sealed with it for core.RecoveryRecipient.
+// A nil public key disables the recovery key.
+func (ks *KeyStoreDefault) SetRecoveryKey(publicKey *core.PublicKey) {
+	ks.recoveryKey = publicKey
+	ks.recoveryKeyLoaded = true
+}
+
+// SaveRecoveryKey records the recovery public key in the policy of the repository and sets it in the key store.
+// The user of the key store must have access to the root directory. A policy that is not trusted is replaced.
+func (ks *KeyStoreDefault) SaveRecoveryKey(publicKey core.PublicKey) error {
+	policy, err := ks.GetPolicy()
+	if errors.Is(err, ErrUntrustedPolicy) {
+		policy = core.RepositoryPolicy{}
+	} else if err != nil {
+		return err
+	}
+	policy.RecoveryPublicKey = publicKey.String()
+	if err := ks.SavePolicy(policy); err != nil {
+		return err
+	}
+	ks.SetRecoveryKey(&publicKey)
+	return nil
+}
+
+// GetRecoveryKey returns the recovery public key of the repository, or nil if the repository has no recovery key.
+// It is read from the policy of the repository, and ErrUntrustedPolicy is returned if the policy is not written
+// by a user with access to the root directory, so that no key is sealed with a recovery key set by another writer.
+func (ks *KeyStoreDefault) GetRecoveryKey() (*core.PublicKey, error) {
+	if ks.recoveryKeyLoaded {
+		return ks.recoveryKey, nil
+	}
+	policy, err := ks.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.setRecoveryKeyOfPolicy(policy); err != nil {
+		return nil, err
+	}
+	return ks.recoveryKey, nil
+}
+
+// ShareVaultWithRecoveryKey seals the key of the vault located at the path with the recovery key,
+// next to the shares of the vault key with the users. It is used for the vaults created before the
+// recovery key was set.
+func (ks *KeyStoreDefault) ShareVaultWithRecoveryKey(vaultPath string) error {
+	recoveryKey, err := ks.GetRecoveryKey()
+	if err != nil {
+		return err
+	}
+	if recoveryKey == nil {
+		return ErrNoRecoveryKey
+	}
+	key, err := ks.GetVaultKeyByPath(vaultPath)
+	if err != nil {
+		return err
+	}
+	parentPath, _, err := ks.vaultRepository.GetVaultParent(vaultPath)
+	if err != nil {
+		return err
+	}
+	return ks.shareWithRecoveryKey(*key, parentPath)
+}
+
+// Recover shares the keys sealed with the recovery key with a new owner, and joins the new owner.
+// The key store must be opened with the recovery private key, which also signs the new shares.
+// The recovery key has no access to the root vault key, so the proof of the policy is not checked: only the
+// keys sealed with the recovery private key are opened anyway.
+// It returns the number of keys shared with the new owner.
+func (ks *KeyStoreDefault) Recover(owner core.PublicKey) (int, error) {
+	if !ks.recoveryKeyLoaded && ks.policyRepository != nil {
+		policy, err := ks.policyRepository.Get()
+		if err != nil && !errors.Is(err, repositories.ErrPolicyNotFound) {
+			return 0, err
+		}
+		if err := ks.setRecoveryKeyOfPolicy(policy); err != nil {
+			return 0, err
+		}
+	}
+	if ks.recoveryKey == nil {
+		return 0, ErrNoRecoveryKey
+	}
+	publicKey, err := ks.GetPublicKey()
+	if err != nil {
+		return 0, err
+	}
+	if !publicKey.Equals(*ks.recoveryKey) {
+		return 0, ErrRecoveryKeyMismatch
+	}
+	// The recovery shares are all in the root directory: the root vault key and the top-level vault keys
+	keyIds, err := ks.keyRepository.ListDataKeys(core.RecoveryRecipient, "")
+	if err != nil {
+		return 0, err
+	}
+	if len(keyIds) == 0 {
+		return 0, ErrNothingToRecover
+	}
+	ownerId, err := ks.resolveUserId(owner.String())
+	if err != nil {
+		return 0, err
+	}
+	if !ks.keyRepository.IsUserJoined(ownerId) {
+		if err := ks.keyRepository.JoinUser(ownerId); err != nil {
+			return 0, err
+		}
+	}
+	for _, keyId := range keyIds {
+		sealed, err := ks.keyRepository.GetDataKey(keyId, core.RecoveryRecipient, "")
+		if err != nil {
+			return 0, err
+		}
+		key, err := ks.decrypter.OpenDataKey(sealed)
+		if err != nil {
+			return 0, err
+		}
+		sealedForOwner, err := ks.sealForUser(*key, ownerId)
+		if err != nil {
+			return 0, err
+		}
+		if err := ks.keyRepository.SaveDataKey(keyId, sealedForOwner, ownerId, ""); err != nil {
+			return 0, err
+		}
+	}
+	return len(keyIds), nil
+}
+
+// shareWithRecoveryKey seals the vault key with the recovery key and saves it in the path for core.RecoveryRecipient.
+// Nothing is done if the repository has no recovery key, and the key is not sealed if the policy of the repository is not trusted.
+func (ks *KeyStoreDefault) shareWithRecoveryKey(key core.KeyInfo, path string) error {
+	recoveryKey, err := ks.GetRecoveryKey()
+	if err != nil {
+		return err
+	}
+	if recoveryKey == nil {
+		return nil
+	}
+	sealed, err := ks.sealDataKey(key.Key, *recoveryKey)
+	if err != nil {
+		return err
+	}
+	return ks.keyRepository.SaveDataKey(key.Id, sealed, core.RecoveryRecipient, path)
+}
+
+// setRecoveryKeyOfPolicy sets the recovery public key of the policy in the key store, or no recovery key if the policy has none.
+func (ks *KeyStoreDefault) setRecoveryKeyOfPolicy(policy core.RepositoryPolicy) error {
+	if policy.RecoveryPublicKey == "" {
+		ks.SetRecoveryKey(nil)
+		return nil
+	}
+	publicKey, err := core.NewPublicKeyFromEncoded(policy.RecoveryPublicKey)
+	if err != nil {
+		return err
+	}
+	ks.SetRecoveryKey(&publicKey)
+	return nil
+}
+
+// isRootPath returns true if the path is the root directory of the repository,
+// whose vault is the parent of the top-level vaults.
+func isRootPath(path string) bool {
+	return path == "" || filepath.Clean(path) == string(filepath.Separator)
+}
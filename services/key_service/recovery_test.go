@@ -0,0 +1,222 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"testing"
+)
+
+// newRecoveryKey returns the private key and the public key of a new recovery key.
+func newRecoveryKey(t *testing.T) (core.PrivateKey, core.PublicKey) {
+	t.Helper()
+	privateKey, encoded := newUser(t)
+	return privateKey, publicKeyOf(t, encoded)
+}
+
+// recover shares the keys sealed with the recovery key with the new owner, and returns the number of keys shared.
+func (r *testRepo) recover(t *testing.T, recoveryKey core.PrivateKey, recoveryPublicKey core.PublicKey, ownerId string) int {
+	t.Helper()
+	ks := r.open(recoveryKey)
+	ks.SetRecoveryKey(&recoveryPublicKey)
+	count, err := ks.Recover(publicKeyOf(t, ownerId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// checkFileKey checks that the key store opens the file key of the vault at the path.
+func checkFileKey(t *testing.T, ks *KeyStoreDefault, fileKey *core.KeyInfo, path string) {
+	t.Helper()
+	vault, err := ks.vaultRepository.GetVaultByPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ks.Get(fileKey.Id, vault.Id, path)
+	if err != nil {
+		t.Fatalf("Expected the file key of %s to be opened, got %v", path, err)
+	}
+	if !key.Key.Equals(fileKey.Key) {
+		t.Errorf("Expected the file key of %s to be opened", path)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	newOwner, newOwnerId := newUser(t)
+	other, _ := newUser(t)
+	recoveryKey, recoveryPublicKey := newRecoveryKey(t)
+	ks := repo.open(owner)
+	ks.SetRecoveryKey(&recoveryPublicKey)
+	repo.createVaults(t, ks, "/docs", "/docs/deep")
+	deep, err := ks.vaultRepository.GetVaultByPath("/docs/deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileKey, err := ks.GenerateKeyInVault(deep.Id, "/docs/deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the recovery private key recovers the keys
+	notRecovery := repo.open(other)
+	notRecovery.SetRecoveryKey(&recoveryPublicKey)
+	if _, err := notRecovery.Recover(publicKeyOf(t, newOwnerId)); err != ErrRecoveryKeyMismatch {
+		t.Errorf("Expected ErrRecoveryKeyMismatch, got %v", err)
+	}
+	if _, err := repo.open(recoveryKey).Recover(publicKeyOf(t, newOwnerId)); err != ErrNoRecoveryKey {
+		t.Errorf("Expected ErrNoRecoveryKey, got %v", err)
+	}
+
+	// The root and top-level vault keys are shared with the new owner, who opens the keys of the deeper vaults
+	if count := repo.recover(t, recoveryKey, recoveryPublicKey, newOwnerId); count != 2 {
+		t.Errorf("Expected the root and top-level vault keys to be recovered, got %d", count)
+	}
+	recovered := repo.open(newOwner)
+	if !recovered.IsUserJoined() {
+		t.Error("Expected the new owner to be joined")
+	}
+	checkFileKey(t, recovered, fileKey, "/docs/deep")
+}
+
+func TestShareVaultWithRecoveryKey(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	newOwner, newOwnerId := newUser(t)
+	recoveryKey, recoveryPublicKey := newRecoveryKey(t)
+	ks := repo.open(owner)
+	repo.createVaults(t, ks, "/docs")
+	docs, err := ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileKey, err := ks.GenerateKeyInVault(docs.Id, "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.ShareVaultWithRecoveryKey("/docs"); err != ErrNoRecoveryKey {
+		t.Errorf("Expected ErrNoRecoveryKey, got %v", err)
+	}
+
+	// The vaults created before the recovery key was set are not recovered until they are shared with it
+	recovery := repo.open(recoveryKey)
+	recovery.SetRecoveryKey(&recoveryPublicKey)
+	if _, err := recovery.Recover(publicKeyOf(t, newOwnerId)); err != ErrNothingToRecover {
+		t.Errorf("Expected ErrNothingToRecover, got %v", err)
+	}
+	ks.SetRecoveryKey(&recoveryPublicKey)
+	if err := ks.ShareVaultWithRecoveryKey("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	if count := repo.recover(t, recoveryKey, recoveryPublicKey, newOwnerId); count != 1 {
+		t.Errorf("Expected the top-level vault key to be recovered, got %d", count)
+	}
+	checkFileKey(t, repo.open(newOwner), fileKey, "/docs")
+}
+
+func TestRecoveryShareRenewedOnRotation(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	newOwner, newOwnerId := newUser(t)
+	recoveryKey, recoveryPublicKey := newRecoveryKey(t)
+	ks := repo.open(owner)
+	ks.SetRecoveryKey(&recoveryPublicKey)
+	repo.createVaults(t, ks, "/docs")
+	docs, err := ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileKey, err := ks.GenerateKeyInVault(docs.Id, "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldKey, newKey, err := ks.RotateVaultKey("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.CompleteVaultKeyRotation("/docs"); err != nil {
+		t.Fatal(err)
+	}
+	if ks.keyRepository.DataKeyExist(oldKey.Id, core.RecoveryRecipient, "/") {
+		t.Error("Expected the recovery share of the old vault key to be removed")
+	}
+	if !ks.keyRepository.DataKeyExist(newKey.Id, core.RecoveryRecipient, "/") {
+		t.Error("Expected the recovery share to be renewed with the new vault key")
+	}
+	if count := repo.recover(t, recoveryKey, recoveryPublicKey, newOwnerId); count != 2 {
+		t.Errorf("Expected the root and the rotated top-level vault keys to be recovered, got %d", count)
+	}
+	checkFileKey(t, repo.open(newOwner), fileKey, "/docs")
+}
+
+func TestRecoveryKeyOfUntrustedPolicy(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	writer, _ := newUser(t)
+	_, recoveryPublicKey := newRecoveryKey(t)
+	_, otherPublicKey := newRecoveryKey(t)
+	ks := repo.open(owner)
+	repo.createVaults(t, ks, "/docs")
+	if err := ks.SaveRecoveryKey(recoveryPublicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// A writer without access to the root directory replaces the recovery key of the policy
+	forger := repo.open(writer)
+	policy, err := forger.policyRepository.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.RecoveryPublicKey = otherPublicKey.String()
+	if err := forger.policyRepository.Save(policy); err != nil {
+		t.Fatal(err)
+	}
+
+	// The forged policy is refused, and no vault key is sealed with its recovery key
+	ks = repo.open(owner)
+	if _, err := ks.GetRecoveryKey(); err != ErrUntrustedPolicy {
+		t.Errorf("Expected ErrUntrustedPolicy, got %v", err)
+	}
+	repo.mkdir(t, "/other")
+	root, err := ks.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.CreateVault(root.Id, "/other"); err != ErrUntrustedPolicy {
+		t.Errorf("Expected the vault not to be sealed with the forged recovery key, got %v", err)
+	}
+
+	// The owner sets the recovery key again
+	if err := ks.SaveRecoveryKey(recoveryPublicKey); err != nil {
+		t.Fatal(err)
+	}
+	recoveryKey, err := repo.open(owner).GetRecoveryKey()
+	if err != nil || recoveryKey == nil || !recoveryKey.Equals(recoveryPublicKey) {
+		t.Errorf("Expected the recovery key of the owner, got %v, %v", recoveryKey, err)
+	}
+}
+
+func TestRecoveryKeyAfterRootRotation(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	_, recoveryPublicKey := newRecoveryKey(t)
+	ks := repo.open(owner)
+	repo.createVaults(t, ks)
+	if err := ks.SaveRecoveryKey(recoveryPublicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// The policy is proved again with the new root vault key
+	ks = repo.open(owner)
+	if _, _, err := ks.RotateVaultKey("/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.CompleteVaultKeyRotation("/"); err != nil {
+		t.Fatal(err)
+	}
+	recoveryKey, err := repo.open(owner).GetRecoveryKey()
+	if err != nil || recoveryKey == nil || !recoveryKey.Equals(recoveryPublicKey) {
+		t.Errorf("Expected the recovery key after the rotation of the root vault key, got %v, %v", recoveryKey, err)
+	}
+}
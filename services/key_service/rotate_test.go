@@ -0,0 +1,90 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"testing"
+	"time"
+)
+
+func TestRotateVaultKeyResumed(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	recipient, recipientId := newUser(t)
+	ks := repo.open(owner)
+	repo.createVaults(t, ks, "/docs", "/docs/sub")
+	root, err := ks.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileKey, err := ks.GenerateKeyInVault(docs.Id, "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Share(docs.KeyId, root.Id, "/", publicKeyOf(t, recipientId), recipientId, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	sealedFileKey, _ := ks.vaultRepository.GetKey(fileKey.Id, docs.Id, "/docs")
+
+	oldKey, newKey, err := ks.RotateVaultKey("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rotation is interrupted before the file key is sealed with the new vault key
+	if err := ks.vaultRepository.AddKeyToVault(&docs, "/docs", fileKey.Id, sealedFileKey); err != nil {
+		t.Fatal(err)
+	}
+	docs, err = ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !docs.IsKeyRotated() || docs.KeyId != newKey.Id || docs.PreviousKeyId != oldKey.Id {
+		t.Fatalf("Expected the rotation to be recorded in the vault, got %+v", docs)
+	}
+
+	// The rotation is resumed with the same keys
+	resumedOld, resumedNew, err := repo.open(owner).RotateVaultKey("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumedOld.Id != oldKey.Id || resumedNew.Id != newKey.Id {
+		t.Fatalf("Expected the rotation to be resumed, got %s -> %s", resumedOld.Id, resumedNew.Id)
+	}
+	for name, device := range map[string]core.PrivateKey{"owner": owner, "recipient": recipient} {
+		key, err := repo.open(device).Get(fileKey.Id, docs.Id, "/docs")
+		if err != nil || !key.Key.Equals(fileKey.Key) {
+			t.Errorf("Expected the %s to open the file key with the new vault key, got %v", name, err)
+		}
+	}
+	if _, err := ks.GetVaultKeyByPath("/docs/sub"); err != nil {
+		t.Errorf("Expected the sub vault key to be sealed with the new vault key, got %v", err)
+	}
+	// The old vault key stays available until the rotation is completed
+	if _, err := repo.open(recipient).Get(oldKey.Id, root.Id, "/"); err != nil {
+		t.Errorf("Expected the old vault key to be available during the rotation, got %v", err)
+	}
+
+	if removed, err := ks.CompleteVaultKeyRotation("/docs"); err != nil || removed {
+		t.Fatalf("Expected the rotation to be completed without removing a share with a passphrase, got %v, %v", removed, err)
+	}
+	docs, err = ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docs.IsKeyRotated() {
+		t.Error("Expected the rotation to be completed")
+	}
+	if _, err := repo.open(recipient).Get(oldKey.Id, root.Id, "/"); err == nil {
+		t.Error("Expected the old vault key to be removed")
+	}
+	if _, err := repo.open(recipient).Get(fileKey.Id, docs.Id, "/docs"); err != nil {
+		t.Errorf("Expected the recipient to open the file key after the rotation, got %v", err)
+	}
+	// Completing again does nothing
+	if _, err := ks.CompleteVaultKeyRotation("/docs"); err != nil {
+		t.Error(err)
+	}
+}
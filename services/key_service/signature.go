@@ -0,0 +1,177 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"ctb-cli/repositories"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrSigningIdentityMismatch = errors.New("the signing identity published for the user does not match the signing key of the user")
+)
+
+// SignRecord signs the record written by the user of the key store, for the use described by the info string.
+// The signing identity of the user is published in the repository with the first signature.
+func (ks *KeyStoreDefault) SignRecord(info string, payload []byte) (*core.Signature, error) {
+	signer, err := ks.getSigner()
+	if err != nil {
+		return nil, err
+	}
+	value, err := ks.Sign(info, sign_crypto.RecordPayload(signer.Writer, signer.SignerKey, payload))
+	if err != nil {
+		return nil, err
+	}
+	return &core.Signature{
+		Writer:    signer.Writer,
+		SignerKey: signer.SignerKey,
+		Value:     value,
+	}, nil
+}
+
+// VerifyRecord returns the status of the signature of the record:
+// core.SignatureVerified if the signature is valid and the signing key is the published signing key of the writer,
+// core.SignatureUnknownSigner if the signature is valid but the writer has not published a signing key,
+// core.SignatureInvalid if the signature is not valid or the signing key is not the signing key of the writer,
+// and core.SignatureUnsigned if the record is not signed.
+func (ks *KeyStoreDefault) VerifyRecord(signature *core.Signature, info string, payload []byte) string {
+	if signature == nil {
+		return core.SignatureUnsigned
+	}
+	err := sign_crypto.Verify(signature.SignerKey, info, sign_crypto.RecordPayload(signature.Writer, signature.SignerKey, payload), signature.Value)
+	if err != nil {
+		return core.SignatureInvalid
+	}
+	signingKey, found, err := ks.getSigningKey(signature.Writer)
+	if err != nil || !found {
+		return core.SignatureUnknownSigner
+	}
+	if signingKey != signature.SignerKey {
+		return core.SignatureInvalid
+	}
+	return core.SignatureVerified
+}
+
+// CheckRecord verifies the signature of the record and returns an error if the record must be refused:
+// only the records signed by a user whose signing identity is bound to the public key of the user are accepted,
+// and the unsigned records if the key store accepts them.
+func (ks *KeyStoreDefault) CheckRecord(signature *core.Signature, info string, payload []byte) error {
+	return core.SignatureStatusError(ks.VerifyRecord(signature, info, payload), ks.acceptUnsigned)
+}
+
+// SetAcceptUnsignedRecords sets whether the unsigned records, written before the records were signed, are accepted.
+// They are refused by default, so that a record cannot be replaced by removing its signature.
+func (ks *KeyStoreDefault) SetAcceptUnsignedRecords(accept bool) {
+	ks.acceptUnsigned = accept
+}
+
+// getSigner returns the writer and the signing key of the user of the key store,
+// and publishes the signing identity of the user if it is not published yet.
+// The identities published without the encapsulation key of the user or without a valid proof are published again.
+func (ks *KeyStoreDefault) getSigner() (*core.Signature, error) {
+	if ks.signer != nil {
+		return ks.signer, nil
+	}
+	publicKey, err := ks.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := ks.GetSigningPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	published, found, err := ks.getIdentity(publicKey.String())
+	if err != nil {
+		return nil, err
+	}
+	if found && published.SigningKey != signingKey {
+		return nil, ErrSigningIdentityMismatch
+	}
+	if (!found || published.EncapsulationKey == "") && ks.signerRepository != nil {
+		encapsulationKey, err := ks.decrypter.EncapsulationKey()
+		if err != nil {
+			return nil, err
+		}
+		identity := core.SigningIdentity{
+			PublicKey:        publicKey.String(),
+			SigningKey:       signingKey,
+			EncapsulationKey: base64.RawStdEncoding.EncodeToString(encapsulationKey),
+		}
+		proof, err := ks.decrypter.ProveIdentity(identityMessage(identity))
+		if err != nil {
+			return nil, err
+		}
+		identity.Proof = sign_crypto.EncodeSignature(proof)
+		if err := ks.signerRepository.Save(identity); err != nil {
+			return nil, err
+		}
+		ks.signers[identity.PublicKey] = identity
+	}
+	ks.signer = &core.Signature{Writer: publicKey.String(), SignerKey: signingKey}
+	return ks.signer, nil
+}
+
+// getSigningKey returns the published signing key of the user with the public key.
+func (ks *KeyStoreDefault) getSigningKey(publicKey string) (signingKey string, found bool, err error) {
+	identity, found, err := ks.getIdentity(publicKey)
+	return identity.SigningKey, found, err
+}
+
+// getEncapsulationKey returns the published ML-KEM encapsulation key of the user with the public key.
+// It is not found if the user has not published a signing identity, or published it before the
+// encapsulation keys were published.
+func (ks *KeyStoreDefault) getEncapsulationKey(publicKey string) (encapsulationKey []byte, found bool, err error) {
+	identity, found, err := ks.getIdentity(publicKey)
+	if err != nil || !found || identity.EncapsulationKey == "" {
+		return nil, false, err
+	}
+	encapsulationKey, err = base64.RawStdEncoding.DecodeString(identity.EncapsulationKey)
+	if err != nil {
+		return nil, false, err
+	}
+	return encapsulationKey, true, nil
+}
+
+// getIdentity returns the published signing identity of the user with the public key.
+// An identity is only found if its proof shows that it was published by the owner of the public key,
+// so that no other writer of the repository can publish a signing key in the name of the user.
+func (ks *KeyStoreDefault) getIdentity(publicKey string) (identity core.SigningIdentity, found bool, err error) {
+	if identity, ok := ks.signers[publicKey]; ok {
+		return identity, true, nil
+	}
+	if ks.signerRepository == nil {
+		return core.SigningIdentity{}, false, nil
+	}
+	identity, err = ks.signerRepository.Get(publicKey)
+	if errors.Is(err, repositories.ErrSigningIdentityNotFound) {
+		return core.SigningIdentity{}, false, nil
+	}
+	if err != nil {
+		return core.SigningIdentity{}, false, err
+	}
+	if identity.PublicKey != publicKey || verifyIdentity(identity) != nil {
+		return core.SigningIdentity{}, false, nil
+	}
+	ks.signers[publicKey] = identity
+	return identity, true, nil
+}
+
+// identityMessage returns the message signed by the proof of the signing identity.
+func identityMessage(identity core.SigningIdentity) []byte {
+	return sign_crypto.SignedMessage(sign_crypto.IdentityV1Info,
+		sign_crypto.IdentityPayload(identity.PublicKey, identity.SigningKey, identity.EncapsulationKey))
+}
+
+// verifyIdentity checks the proof of the signing identity against the public key of the user.
+func verifyIdentity(identity core.SigningIdentity) error {
+	publicKey, err := core.NewPublicKeyFromEncoded(identity.PublicKey)
+	if err != nil {
+		return sign_crypto.ErrInvalidIdentityProof
+	}
+	proof, err := base64.RawStdEncoding.DecodeString(identity.Proof)
+	if err != nil {
+		return sign_crypto.ErrInvalidIdentityProof
+	}
+	return sign_crypto.VerifyIdentity(publicKey, identityMessage(identity), proof)
+}
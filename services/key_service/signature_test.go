@@ -0,0 +1,79 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"os"
+	"testing"
+)
+
+func TestUnsignedRecordRefused(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, ownerId := newUser(t)
+	repo.createVaults(t, repo.open(owner))
+
+	vaultKey, err := repo.open(owner).GetVaultKeyByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Removing the signature of the share does not make it acceptable
+	if err := os.Remove(repo.path(".meta", ".key-share", ownerId, vaultKey.Id+".sig")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.open(owner).GetVaultKeyByPath("/"); err != core.ErrUnsignedRecord {
+		t.Errorf("Expected ErrUnsignedRecord, got %v", err)
+	}
+	// The legacy repositories accept the unsigned records explicitly
+	ks := repo.open(owner)
+	ks.SetAcceptUnsignedRecords(true)
+	if _, err := ks.GetVaultKeyByPath("/"); err != nil {
+		t.Errorf("Unsigned record refused with allow_unsigned_records: %v", err)
+	}
+}
+
+func TestForgedSigningIdentity(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, ownerId := newUser(t)
+	attacker, _ := newUser(t)
+	repo.createVaults(t, repo.open(owner))
+
+	payload := []byte("record")
+	signature, err := repo.open(owner).SignRecord("info", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := repo.open(attacker).VerifyRecord(signature, "info", payload); status != core.SignatureVerified {
+		t.Fatalf("Expected the record of the owner to be verified, got %s", status)
+	}
+
+	// The attacker publishes its own signing key in the name of the owner, with a proof of its own key
+	attackerStore := repo.open(attacker)
+	attackerSigningKey, _ := sign_crypto.DeriveSigningKey(attacker)
+	signingKey, _ := attackerStore.GetSigningPublicKey()
+	forged := core.SigningIdentity{PublicKey: ownerId, SigningKey: signingKey}
+	proof, _ := attackerStore.decrypter.ProveIdentity(identityMessage(forged))
+	forged.Proof = sign_crypto.EncodeSignature(proof)
+	if err := attackerStore.signerRepository.Save(forged); err != nil {
+		t.Fatal(err)
+	}
+	// and signs a record as the owner with it
+	forgedSignature := &core.Signature{
+		Writer:    ownerId,
+		SignerKey: signingKey,
+		Value:     sign_crypto.Sign(attackerSigningKey, "info", sign_crypto.RecordPayload(ownerId, signingKey, payload)),
+	}
+	ks := repo.open(attacker)
+	if status := ks.VerifyRecord(forgedSignature, "info", payload); status == core.SignatureVerified {
+		t.Error("Record signed with a forged signing identity verified")
+	}
+	if err := ks.CheckRecord(forgedSignature, "info", payload); err != core.ErrUnknownSigner {
+		t.Errorf("Expected ErrUnknownSigner, got %v", err)
+	}
+	// The owner publishes its identity again when it signs
+	if _, err := repo.open(owner).SignRecord("info", payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.open(attacker).CheckRecord(signature, "info", payload); err != nil {
+		t.Errorf("Record of the owner refused after the identity is published again: %v", err)
+	}
+}
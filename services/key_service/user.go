@@ -0,0 +1,161 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"errors"
+)
+
+var (
+	ErrUntrustedUserRecord     = errors.New("the user record is not signed by a device of the user")
+	ErrDeviceOfSeveralUsers    = errors.New("the device is a device of several users")
+	ErrCannotRemoveFirstDevice = errors.New("cannot remove the first device of the user, whose public key is the id of the user")
+)
+
+// getUserRecord returns the user record of the user with the id, once verified.
+// It returns repositories.ErrUserNotFound if the user has no user record, and an error if the record must be refused.
+func (ks *KeyStoreDefault) getUserRecord(userId string) (core.User, error) {
+	user, err := ks.userRepository.Get(userId)
+	if err != nil {
+		return core.User{}, err
+	}
+	if user.Id != userId {
+		return core.User{}, ErrUntrustedUserRecord
+	}
+	if err := ks.verifyUser(user); err != nil {
+		return core.User{}, err
+	}
+	return user, nil
+}
+
+// verifyUser checks that the user record is signed by a trusted device of the user, and that every device
+// of the user is trusted. Any writer of the repository can write a user record, so a record listing a device
+// which was not added by a device of the user is refused.
+func (ks *KeyStoreDefault) verifyUser(user core.User) error {
+	if user.Signature == nil && ks.acceptUnsigned {
+		return nil
+	}
+	payload, err := user.SignedPayload()
+	if err != nil {
+		return err
+	}
+	if err := ks.CheckRecord(user.Signature, sign_crypto.UserV1Info, payload); err != nil {
+		return err
+	}
+	trusted := ks.trustedDevices(user)
+	if !trusted[user.Signature.Writer] {
+		return ErrUntrustedUserRecord
+	}
+	for _, device := range user.Devices {
+		if !trusted[device.PublicKey] {
+			return ErrUntrustedUserRecord
+		}
+	}
+	return nil
+}
+
+// trustedDevices returns the public keys of the devices added by a device of the user: starting from the first
+// device, whose public key is the id of the user, a device is trusted if it is endorsed by a trusted device.
+func (ks *KeyStoreDefault) trustedDevices(user core.User) map[string]bool {
+	trusted := map[string]bool{user.Id: true}
+	for added := true; added; {
+		added = false
+		for _, device := range user.Devices {
+			if trusted[device.PublicKey] || device.Endorsement == nil || !trusted[device.Endorsement.Writer] {
+				continue
+			}
+			status := ks.VerifyRecord(device.Endorsement, sign_crypto.DeviceV1Info, user.DevicePayload(device.PublicKey))
+			if status == core.SignatureVerified {
+				trusted[device.PublicKey] = true
+				added = true
+			}
+		}
+	}
+	return trusted
+}
+
+// isDeviceAccepted returns true if the device accepted to be a device of the verified user: the first device,
+// and the devices which signed their consent. A device is only resolved to the user once it has accepted,
+// so that a user cannot receive the data keys shared with a device of another user by adding it.
+func (ks *KeyStoreDefault) isDeviceAccepted(user core.User, device core.Device) bool {
+	if device.PublicKey == user.Id || user.Signature == nil {
+		return true
+	}
+	if device.Consent == nil || device.Consent.Writer != device.PublicKey {
+		return false
+	}
+	status := ks.VerifyRecord(device.Consent, sign_crypto.DeviceConsentV1Info, user.DevicePayload(device.PublicKey))
+	return status == core.SignatureVerified
+}
+
+// saveUser endorses the devices of the user which are not trusted yet with the current device,
+// signs the user record and saves it. The current device must be a trusted device of the user.
+func (ks *KeyStoreDefault) saveUser(user core.User) error {
+	current, err := ks.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	trusted := ks.trustedDevices(user)
+	if !trusted[current.String()] {
+		return ErrUntrustedUserRecord
+	}
+	for i, device := range user.Devices {
+		if trusted[device.PublicKey] {
+			continue
+		}
+		user.Devices[i].Endorsement, err = ks.SignRecord(sign_crypto.DeviceV1Info, user.DevicePayload(device.PublicKey))
+		if err != nil {
+			return err
+		}
+	}
+	user.Signature = nil
+	payload, err := user.SignedPayload()
+	if err != nil {
+		return err
+	}
+	user.Signature, err = ks.SignRecord(sign_crypto.UserV1Info, payload)
+	if err != nil {
+		return err
+	}
+	return ks.userRepository.Save(user)
+}
+
+// AcceptDevice accepts that the device of the key store is a device of the user with the id, once another device
+// of the user has added it. The device then opens the data keys shared with the user.
+func (ks *KeyStoreDefault) AcceptDevice(userId string) error {
+	user, err := ks.getUserRecord(userId)
+	if err != nil {
+		return err
+	}
+	current, err := ks.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	device, found := user.GetDevice(current.String())
+	if !found {
+		return ErrDeviceNotFound
+	}
+	if ks.isDeviceAccepted(user, device) {
+		return nil
+	}
+	if otherUserId, err := ks.resolveUserId(current.String()); err != nil {
+		return err
+	} else if otherUserId != current.String() {
+		return ErrDeviceOfAnotherUser
+	}
+	for i := range user.Devices {
+		if user.Devices[i].PublicKey == current.String() {
+			user.Devices[i].Consent, err = ks.SignRecord(sign_crypto.DeviceConsentV1Info, user.DevicePayload(current.String()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := ks.saveUser(user); err != nil {
+		return err
+	}
+	// The device is now resolved to the user
+	ks.userId = ""
+	ks.memberGroups = nil
+	return nil
+}
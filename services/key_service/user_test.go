@@ -0,0 +1,144 @@
+package key_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/sign_crypto"
+	"testing"
+	"time"
+)
+
+// publicKeyOf returns the public key of the encoded public key.
+func publicKeyOf(t *testing.T, encoded string) core.PublicKey {
+	t.Helper()
+	publicKey, err := core.NewPublicKeyFromEncoded(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicKey
+}
+
+// checkRootAccess checks whether the device opens the key of the root vault.
+func (r *testRepo) checkRootAccess(t *testing.T, device core.PrivateKey, name string, expected bool) {
+	t.Helper()
+	_, err := r.open(device).GetVaultKeyByPath("/")
+	if expected && err != nil {
+		t.Errorf("Expected the %s to open the root vault key, got %v", name, err)
+	}
+	if !expected && err == nil {
+		t.Errorf("Expected the %s not to open the root vault key", name)
+	}
+}
+
+func TestAddRemoveDevice(t *testing.T) {
+	repo := newTestRepo(t)
+	laptop, userId := newUser(t)
+	phone, phoneId := newUser(t)
+	tablet, tabletId := newUser(t)
+	repo.createVaults(t, repo.open(laptop))
+
+	// The added device opens the keys of the user once it accepts to be a device of the user
+	if err := repo.open(laptop).AddDevice(publicKeyOf(t, phoneId), "phone"); err != nil {
+		t.Fatal(err)
+	}
+	repo.checkRootAccess(t, phone, "phone before accepting", false)
+	if err := repo.open(phone).AcceptDevice(userId); err != nil {
+		t.Fatal(err)
+	}
+	repo.checkRootAccess(t, phone, "phone", true)
+	repo.checkRootAccess(t, laptop, "laptop", true)
+
+	// A device added by the phone is trusted through the phone, and stays trusted once the phone is removed
+	if err := repo.open(phone).AddDevice(publicKeyOf(t, tabletId), "tablet"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.open(tablet).AcceptDevice(userId); err != nil {
+		t.Fatal(err)
+	}
+	repo.checkRootAccess(t, tablet, "tablet", true)
+	if err := repo.open(laptop).RemoveDevice(publicKeyOf(t, phoneId)); err != nil {
+		t.Fatal(err)
+	}
+	repo.checkRootAccess(t, phone, "removed phone", false)
+	repo.checkRootAccess(t, tablet, "tablet after the removal of the phone", true)
+	repo.checkRootAccess(t, laptop, "laptop after the removal of the phone", true)
+
+	user, err := repo.open(tablet).GetUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != userId || len(user.Devices) != 2 {
+		t.Errorf("Expected the laptop and the tablet, got %+v", user)
+	}
+	if err := repo.open(tablet).RemoveDevice(publicKeyOf(t, userId)); err != ErrCannotRemoveFirstDevice {
+		t.Errorf("Expected ErrCannotRemoveFirstDevice, got %v", err)
+	}
+}
+
+func TestDeviceOfAnotherUserNotResolved(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	victim, victimId := newUser(t)
+	attacker, attackerId := newUser(t)
+	keyId, rootId := repo.shareDocs(t, repo.open(owner), attackerId, time.Time{})
+
+	// The attacker adds the key of the victim as a device of its own user
+	if err := repo.open(attacker).AddDevice(publicKeyOf(t, victimId), "victim"); err != nil {
+		t.Fatal(err)
+	}
+	// The victim has not accepted, so the data keys shared with the victim are not shared with the attacker
+	if err := repo.open(owner).Share(keyId, rootId, "/", publicKeyOf(t, victimId), victimId, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.open(owner).keyRepository.DataKeyExist(keyId, victimId, "/") {
+		t.Error("Expected the data key to be shared with the victim itself")
+	}
+	if _, err := repo.open(victim).Get(keyId, rootId, "/"); err != nil {
+		t.Errorf("Expected the victim to get the key, got %v", err)
+	}
+}
+
+func TestForgedUserRecordRefused(t *testing.T) {
+	repo := newTestRepo(t)
+	owner, _ := newUser(t)
+	_, victimId := newUser(t)
+	attacker, attackerId := newUser(t)
+	ks := repo.open(owner)
+	repo.createVaults(t, ks, "/docs")
+
+	// The attacker writes a user record of the victim listing its own device, signed with its own key
+	forged := core.User{
+		Id: victimId,
+		Devices: []core.Device{
+			{PublicKey: victimId},
+			{PublicKey: attackerId},
+		},
+	}
+	attackerKs := repo.open(attacker)
+	payload, err := forged.SignedPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Signature, err = attackerKs.SignRecord(sign_crypto.UserV1Info, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := attackerKs.userRepository.Save(forged); err != nil {
+		t.Fatal(err)
+	}
+
+	if userId, err := ks.resolveUserId(attackerId); err != nil || userId != attackerId {
+		t.Errorf("Expected the device of the attacker not to be resolved to the victim, got %s, %v", userId, err)
+	}
+	docs, err := ks.vaultRepository.GetVaultByPath("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ks.vaultRepository.GetVaultByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ks.Share(docs.KeyId, root.Id, "/", publicKeyOf(t, victimId), victimId, time.Time{})
+	if err != ErrUntrustedUserRecord {
+		t.Errorf("Expected the forged user record to be refused, got %v", err)
+	}
+}
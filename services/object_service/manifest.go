@@ -0,0 +1,352 @@
+package object_service
+
+import (
+	"crypto/sha256"
+	"ctb-cli/core"
+	"ctb-cli/crypto/file_crypto"
+	"ctb-cli/crypto/stream"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	ErrInvalidManifest = errors.New("invalid object manifest")
+)
+
+// manifest is the content of a chunked object.
+// It lists the chunks of the object, each one encrypted with its own key and stored separately.
+// The manifest itself is encrypted with the file key.
+type manifest struct {
+	Size   int64           `json:"size"`
+	Chunks []manifestChunk `json:"chunks"`
+}
+
+// manifestChunk is a chunk of a chunked object.
+// The hash of the content of the chunk is covered by the signature of the manifest.
+type manifestChunk struct {
+	Id   string `json:"id"`
+	Key  []byte `json:"key"`
+	Size int64  `json:"size"`
+	Hash []byte `json:"hash,omitempty"`
+}
+
+// keyInfo returns the key info used to encrypt the chunk.
+func (c manifestChunk) keyInfo() (*core.KeyInfo, error) {
+	key, err := core.KeyFromBytes(c.Key)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := core.NewKeyInfo(c.Id, key)
+	return &keyInfo, nil
+}
+
+// manifestStore keeps the opened manifests by object ID, and the manifests
+// of the previous versions of the objects in the write cache.
+type manifestStore struct {
+	sync.Mutex
+	manifests map[string]*manifest
+	bases     map[string]*manifest
+	reencrypt map[string]bool // objects whose chunks must all be encrypted again, instead of reusing the base
+}
+
+// newManifestStore creates a new empty manifestStore.
+func newManifestStore() *manifestStore {
+	return &manifestStore{
+		manifests: make(map[string]*manifest),
+		bases:     make(map[string]*manifest),
+		reencrypt: make(map[string]bool),
+	}
+}
+
+// get returns the manifest of the object with the specified ID, or nil if it is unknown.
+func (s *manifestStore) get(id string) *manifest {
+	s.Lock()
+	defer s.Unlock()
+	return s.manifests[id]
+}
+
+// set stores the manifest of the object with the specified ID.
+func (s *manifestStore) set(id string, m *manifest) {
+	s.Lock()
+	defer s.Unlock()
+	s.manifests[id] = m
+}
+
+// setBase sets the manifest of the object with the old ID as the base of the object with the new ID.
+// The chunks of the base which are not modified are reused when the new object is encrypted.
+func (s *manifestStore) setBase(oldId string, newId string) {
+	s.Lock()
+	defer s.Unlock()
+	if m, ok := s.manifests[oldId]; ok {
+		s.bases[newId] = m
+	}
+}
+
+// setReencrypt makes the chunks of the base of the object with the specified ID not reused.
+// The base is kept, so that its chunks are removed once the object is encrypted again.
+func (s *manifestStore) setReencrypt(id string) {
+	s.Lock()
+	defer s.Unlock()
+	s.reencrypt[id] = true
+}
+
+// takeBase returns and removes the base manifest of the object with the specified ID,
+// and whether its chunks may be reused.
+func (s *manifestStore) takeBase(id string) (*manifest, bool) {
+	s.Lock()
+	defer s.Unlock()
+	m := s.bases[id]
+	reuse := !s.reencrypt[id]
+	delete(s.bases, id)
+	delete(s.reencrypt, id)
+	return m, reuse
+}
+
+// remove forgets the manifest of the removed object with the specified ID.
+func (s *manifestStore) remove(id string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.manifests, id)
+	delete(s.bases, id)
+	delete(s.reencrypt, id)
+}
+
+// readManifest reads and deserializes the manifest from the decrypted reader.
+func readManifest(reader io.Reader) (*manifest, error) {
+	js, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(js, &m); err != nil {
+		return nil, ErrInvalidManifest
+	}
+	return &m, nil
+}
+
+// openManifest returns the manifest of the chunked object with the specified ID, reading it from the repository if needed.
+func (o *Service) openManifest(id string, dir string, key *core.KeyInfo) (*manifest, error) {
+	if m := o.manifests.get(id); m != nil {
+		return m, nil
+	}
+	reader, err := o.objectRepo.OpenObject(id, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	header, enc, err := file_crypto.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+	if header.ContentType != file_crypto.ContentTypeManifest {
+		return nil, ErrInvalidManifest
+	}
+	if err := o.verifyHeader(id, header); err != nil {
+		return nil, err
+	}
+	decrypted, err := enc.Decrypt(key)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	m, err := readManifest(io.TeeReader(decrypted, hash))
+	if err != nil {
+		return nil, err
+	}
+	if err := header.VerifyContent(hash.Sum(nil)); err != nil {
+		return nil, err
+	}
+	o.manifests.set(id, m)
+	return m, nil
+}
+
+// objectChunks returns the chunks of the object with the specified ID, downloading the object if needed.
+// The manifest of the object is opened with the key returned by getKey for the key ID of the object.
+// It returns no chunk if the object is not chunked, if it was never uploaded or if its key cannot be got.
+func (o *Service) objectChunks(id string, dir string, getKey func(keyId string) (*core.KeyInfo, error)) ([]manifestChunk, error) {
+	if m := o.manifests.get(id); m != nil {
+		return m.Chunks, nil
+	}
+	if !o.objectRepo.IsInRepo(id, dir) {
+		if err := o.downloadToObject(id, dir); err != nil {
+			return nil, nil
+		}
+	}
+	reader, err := o.objectRepo.OpenObject(id, dir)
+	if err != nil {
+		return nil, err
+	}
+	header, _, err := file_crypto.Parse(reader)
+	reader.Close()
+	if err != nil || header.ContentType != file_crypto.ContentTypeManifest {
+		return nil, nil
+	}
+	key, err := getKey(header.KeyId)
+	if err != nil {
+		//Without access to the object, the chunks cannot be found
+		return nil, nil
+	}
+	m, err := o.openManifest(id, dir, key)
+	if err != nil {
+		return nil, err
+	}
+	return m.Chunks, nil
+}
+
+// unreferencedChunks returns the chunks of the base manifest which are not listed in the manifest m.
+// m is nil if the new version of the object is not chunked, so none of the chunks of the base are referenced.
+func unreferencedChunks(base *manifest, m *manifest) []manifestChunk {
+	if base == nil {
+		return nil
+	}
+	referenced := make(map[string]bool)
+	if m != nil {
+		for _, chunk := range m.Chunks {
+			referenced[chunk.Id] = true
+		}
+	}
+	var chunks []manifestChunk
+	for _, chunk := range base.Chunks {
+		if !referenced[chunk.Id] {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// queueRemoval queues the removal of the chunks from the repository and from the cloud storage.
+// The removals go through the upload queue, so that they happen after the uploads queued before.
+func (o *Service) queueRemoval(chunks []manifestChunk) {
+	for _, chunk := range chunks {
+		o.pending.Add(1)
+		o.uploadChan <- uploadChanItem{id: chunk.Id, isChunk: true, remove: true}
+	}
+}
+
+// availableChunk makes sure that the chunk is stored in the repository, downloading it if needed.
+func (o *Service) availableChunk(chunk manifestChunk) error {
+	if o.objectRepo.IsChunkInRepo(chunk.Id) {
+		return nil
+	}
+	file, err := o.objectRepo.CreateChunkFile(chunk.Id)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return o.downloader.Download(chunk.Id, file)
+}
+
+// readChunkAt reads the plaintext of the chunk starting at the specified offset in the chunk.
+// Only the parts of the chunk covering the requested range are decrypted.
+func (o *Service) readChunkAt(chunk manifestChunk, buff []byte, ofst int64) (int, error) {
+	file, reader, err := o.openChunk(chunk)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return reader.ReadAt(buff, ofst)
+}
+
+// openChunk opens the chunk for random access, downloading it if needed, and verifies it.
+// The returned file must be closed once the chunk is read.
+func (o *Service) openChunk(chunk manifestChunk) (*os.File, *stream.ReaderAt, error) {
+	if err := o.availableChunk(chunk); err != nil {
+		return nil, nil, err
+	}
+	file, err := o.objectRepo.OpenChunkFile(chunk.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := o.chunkReader(chunk, file)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return file, reader, nil
+}
+
+// chunkReader returns the random access reader of the chunk read from the file, once verified.
+func (o *Service) chunkReader(chunk manifestChunk, file *os.File) (*stream.ReaderAt, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := chunk.keyInfo()
+	if err != nil {
+		return nil, err
+	}
+	header, reader, err := file_crypto.NewReaderAt(file, stat.Size(), keyInfo)
+	if err != nil {
+		return nil, err
+	}
+	// The chunks are not signed: their keys and hashes are in the signed manifest
+	if header.FileID != chunk.Id {
+		return nil, ErrObjectIdMismatch
+	}
+	//Verify the whole chunk against the hash of the manifest before the first partial read
+	err = o.verifyContent(chunk.Id, chunk.Hash, io.NewSectionReader(reader, 0, reader.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// readManifestAt reads the chunked object described by the manifest starting at the specified offset,
+// reading the chunks with readChunkAt.
+// It returns io.EOF if fewer than len(buff) bytes are available.
+func (o *Service) readManifestAt(m *manifest, buff []byte, ofst int64, readChunkAt func(chunk manifestChunk, buff []byte, ofst int64) (int, error)) (n int, err error) {
+	chunkStart := int64(0)
+	for _, chunk := range m.Chunks {
+		if n == len(buff) {
+			break
+		}
+		chunkEnd := chunkStart + chunk.Size
+		if ofst < chunkEnd {
+			nn, err := readChunkAt(chunk, buff[n:], ofst-chunkStart)
+			n += nn
+			ofst += int64(nn)
+			if err != nil && err != io.EOF {
+				return n, err
+			}
+		}
+		chunkStart = chunkEnd
+	}
+	if n < len(buff) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// writeChunk encrypts the chunk content read from the reader with a new chunk key and the algorithm,
+// stores it in the repository and queues its upload. It returns the chunk written in the manifest.
+func (o *Service) writeChunk(reader io.Reader, alg string) (manifestChunk, error) {
+	id, err := core.NewUid()
+	if err != nil {
+		return manifestChunk{}, err
+	}
+	keyInfo := core.NewKeyInfo(id, core.NewKeyFromRand())
+	file, err := o.objectRepo.CreateChunkFile(id)
+	if err != nil {
+		return manifestChunk{}, err
+	}
+	defer file.Close()
+	writer, err := file_crypto.NewWriterWithAlgorithm(file, &keyInfo, id, file_crypto.ContentTypeChunk, alg)
+	if err != nil {
+		return manifestChunk{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(writer, io.TeeReader(reader, hash))
+	if err != nil {
+		return manifestChunk{}, err
+	}
+	if err := writer.Close(); err != nil {
+		return manifestChunk{}, err
+	}
+	o.pending.Add(1)
+	o.uploadChan <- uploadChanItem{id: id, isChunk: true}
+	o.verified.set(id)
+	return manifestChunk{Id: id, Key: keyInfo.Key.Bytes(), Size: size, Hash: hash.Sum(nil)}, nil
+}
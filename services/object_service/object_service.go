@@ -1,10 +1,12 @@
 package object_service
 
 import (
+	"crypto/sha256"
 	"ctb-cli/core"
 	"ctb-cli/crypto/file_crypto"
 	"ctb-cli/repositories"
 	"io"
+	"sync"
 )
 
 // Service represents the object service.
@@ -12,6 +14,11 @@ type Service struct {
 	objectCacheRepo *repositories.ObjectCacheRepository
 	objectRepo      *repositories.ObjectRepository
 	downloader      core.CloudStorage
+	manifests       *manifestStore
+	readers         *readerStore      // objects kept open between the reads of ReadAt
+	pending         *sync.WaitGroup   // commits and uploads not processed yet
+	signer          core.RecordSigner // signs the headers of the written objects and verifies the read ones
+	verified        *verifiedStore    // objects and chunks whose content is verified
 
 	// internal queues and channels
 	encryptChan chan encryptChanItem
@@ -22,15 +29,21 @@ type Service struct {
 var _ core.ObjectService = (*Service)(nil)
 
 // NewService creates a new instance of the object service.
-// It takes in a cache repository, an object repository, and a cloud storage instance.
+// It takes in a cache repository, an object repository, a cloud storage instance and the signer of the objects.
+// If the signer is nil, the objects are neither signed nor verified.
 // It initializes the service with the provided repositories and channels for encryption and upload routines.
 // It starts the encryption and upload routines in separate goroutines.
 // It returns the initialized service.
-func NewService(cache *repositories.ObjectCacheRepository, objectRepo *repositories.ObjectRepository, dn core.CloudStorage) Service {
+func NewService(cache *repositories.ObjectCacheRepository, objectRepo *repositories.ObjectRepository, dn core.CloudStorage, signer core.RecordSigner) Service {
 	service := Service{
 		downloader:      dn,
 		objectCacheRepo: cache,
 		objectRepo:      objectRepo,
+		manifests:       newManifestStore(),
+		readers:         newReaderStore(),
+		pending:         &sync.WaitGroup{},
+		signer:          signer,
+		verified:        newVerifiedStore(),
 		encryptChan:     make(chan encryptChanItem, 10),
 		uploadChan:      make(chan uploadChanItem, 10),
 	}
@@ -53,6 +66,29 @@ func (o *Service) Read(id string, dir string, buff []byte, ofst int64, key *core
 	return o.objectCacheRepo.Read(id, buff, ofst)
 }
 
+// ReadAt reads the object with the specified ID without decrypting it to the cache.
+// Only the chunks of the object covering the requested range are decrypted.
+// The object is kept open between the reads, until it is committed or removed from the cache.
+// If the object is already in the cache (e.g. open for writing), it is read from the cache.
+// Returns the number of bytes read and any error encountered.
+func (o *Service) ReadAt(id string, dir string, buff []byte, ofst int64, key *core.KeyInfo) (n int, err error) {
+	//if the object is in the cache, read it from the cache
+	if o.objectCacheRepo.IsInCache(id) {
+		return o.objectCacheRepo.Read(id, buff, ofst)
+	}
+	object, err := o.openObject(id, dir, key)
+	if err != nil {
+		return 0, err
+	}
+	return o.readOpenObjectAt(object, buff, ofst)
+}
+
+// LoadToCache decrypts the object with the specified ID to the cache if it is not already there.
+// It returns an error if the object cannot be downloaded or decrypted.
+func (o *Service) LoadToCache(id string, dir string, key *core.KeyInfo) error {
+	return o.availableInCache(id, dir, key)
+}
+
 // Write writes the given byte slice to the object cache repository at the specified offset.
 // It returns the number of bytes written and any error encountered.
 func (o *Service) Write(id string, buff []byte, ofst int64) (n int, err error) {
@@ -71,12 +107,23 @@ func (o *Service) Create(id string) (err error) {
 }
 
 // Move moves an object from the oldId to the newId.
+// If the object is chunked, its manifest is used as the base of the new object,
+// so that only the modified chunks are encrypted again.
 // It returns an error if the move operation fails.
 func (o *Service) Move(oldId string, newId string) (err error) {
-	return o.objectCacheRepo.Move(oldId, newId)
+	o.readers.remove(oldId)
+	err = o.objectCacheRepo.Move(oldId, newId)
+	if err != nil {
+		return err
+	}
+	o.manifests.setBase(oldId, newId)
+	return nil
 }
 
+// ChangeDir moves the object with the specified ID to the object folder of the new directory.
+// The object is closed first, since an open file cannot be moved on every platform.
 func (o *Service) ChangeDir(id string, oldDir string, newDir string) (err error) {
+	o.readers.remove(id)
 	return o.objectRepo.ChangeDir(id, oldDir, newDir)
 }
 
@@ -114,6 +161,7 @@ func (o *Service) availableInCache(id string, dir string, key *core.KeyInfo) err
 }
 
 // decryptToCache decrypts an object with the given ID using the provided key and writes the decrypted object to the cache.
+// Chunked objects are decrypted chunk by chunk.
 // It opens the object from the repository, creates an unencrypted reader from the encrypted file and the key,
 // and writes the decrypted object to the cache using the created writer and reader.
 // The decrypted object is written to the cache using the object's ID as the cache key.
@@ -122,19 +170,78 @@ func (o *Service) decryptToCache(id string, objectPath string, key *core.KeyInfo
 	//open object from repo
 	openObject, _ := o.objectRepo.OpenObject(id, objectPath)
 	defer openObject.Close()
+	//Parse the encrypted file
+	header, enc, err := file_crypto.Parse(openObject)
+	if err != nil {
+		return err
+	}
+	if err := o.verifyHeader(id, header); err != nil {
+		return err
+	}
 	//Create an unencrypted reader from encrypted file (reader interface) and the key
-	decryptedReader, _ := o.decryptReader(openObject, key)
+	decryptedReader, err := enc.Decrypt(key)
+	if err != nil {
+		return err
+	}
+	//Hash the decrypted content to check it against the signed header
+	hash := sha256.New()
+	decryptedReader = io.TeeReader(decryptedReader, hash)
 	//Create a writer to write the decrypted object to the cache
 	writer, err := o.objectCacheRepo.CacheObjectWriter(id)
 	if err != nil {
 		return err
 	}
 	defer writer.Close()
+	//If the object is chunked, write the chunks to the cache
+	if header.ContentType == file_crypto.ContentTypeManifest {
+		m, err := readManifest(decryptedReader)
+		if err != nil {
+			return err
+		}
+		if err := header.VerifyContent(hash.Sum(nil)); err != nil {
+			return o.discardFromCache(id, writer, err)
+		}
+		o.manifests.set(id, m)
+		err = o.writeChunksTo(writer, m)
+		if err != nil {
+			return o.discardFromCache(id, writer, err)
+		}
+		return nil
+	}
 	//Write the decrypted object to the cache using the created writer and reader
 	_, err = io.Copy(writer, decryptedReader)
+	if err != nil {
+		return err
+	}
+	if err := header.VerifyContent(hash.Sum(nil)); err != nil {
+		return o.discardFromCache(id, writer, err)
+	}
+	return nil
+}
+
+// discardFromCache closes the writer and removes the object with the specified ID from the cache
+// because its content is not valid, and returns the error.
+func (o *Service) discardFromCache(id string, writer io.Closer, err error) error {
+	_ = writer.Close()
+	_ = o.objectCacheRepo.RemoveFromCache(id)
 	return err
 }
 
+// writeChunksTo decrypts the chunks listed in the manifest and writes them to the writer.
+func (o *Service) writeChunksTo(writer io.Writer, m *manifest) error {
+	for _, chunk := range m.Chunks {
+		buff := make([]byte, chunk.Size)
+		n, err := o.readChunkAt(chunk, buff, 0)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if _, err := writer.Write(buff[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (o *Service) downloadToObject(id string, objectPath string) error {
 	//create the file in the repository
 	file, _ := o.objectRepo.CreateFile(id, objectPath)
@@ -147,25 +254,23 @@ func (o *Service) downloadToObject(id string, objectPath string) error {
 	return nil
 }
 
-// encryptWriter encrypts the data written to the provided writer using the specified key and file ID.
+// encryptWriter encrypts the data written to the provided writer using the specified key, file ID, content type and algorithm.
+// The header is signed with the hash of the content if the service has a signer.
 // It returns a new io.WriteCloser that wraps the original writer and performs encryption.
 // The returned writer should be closed after the writing process is done to flush the remaining data and finalize the encryption.
 // If any error occurs during the process, it returns an error.
-func (o *Service) encryptWriter(writer io.Writer, fileId string, key *core.KeyInfo) (write io.WriteCloser, err error) {
-	return file_crypto.NewWriter(writer, key, fileId)
-}
-
-// decryptReader decrypts the data from the given reader using the provided key.
-// It returns a new reader with the decrypted data and any error encountered.
-func (o *Service) decryptReader(reader io.Reader, key *core.KeyInfo) (read io.Reader, err error) {
-	// Parse the encrypted file and create an encrypted stream
-	_, enc, err := file_crypto.Parse(reader)
+func (o *Service) encryptWriter(writer io.Writer, fileId string, key *core.KeyInfo, contentType string, alg string, contentHash []byte) (write io.WriteCloser, err error) {
+	encryptedWriter, err := file_crypto.NewWriterWithAlgorithm(writer, key, fileId, contentType, alg)
 	if err != nil {
 		return nil, err
 	}
-	// Decrypt the encrypted stream using the key
-	read, err = enc.Decrypt(key)
-	return read, err
+	if o.signer != nil {
+		err = encryptedWriter.Sign(contentHash, o.signer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return encryptedWriter, nil
 }
 
 // GetKeyIdByObjectId retrieves the key ID associated with the given object ID.
@@ -189,15 +294,53 @@ func (o *Service) GetKeyIdByObjectId(id string, dir string) (string, error) {
 
 // Commit adds the object to the encrypt channel queue.
 // It takes a link and a key as parameters and returns an error if any.
-func (o *Service) Commit(link core.Link, dir string, key *core.KeyInfo) error {
+// If pad is true, the object is padded with zero bytes before encryption to hide its size;
+// the actual size is kept in the link.
+// The object is encrypted with the AEAD algorithm alg (see stream.ParseAlgorithm).
+func (o *Service) Commit(link core.Link, dir string, key *core.KeyInfo, pad bool, alg string) error {
+	// The object is written again, so it must not be read from the previous open file
+	o.readers.remove(link.ObjectId)
 	// Add the object to the encrypt channel queue
-	o.encryptChan <- encryptChanItem{id: link.ObjectId, dir: dir, key: key}
+	o.pending.Add(1)
+	o.encryptChan <- encryptChanItem{id: link.ObjectId, dir: dir, key: key, pad: pad, alg: alg}
+	return nil
+}
+
+// ReencryptAll makes the next commit of the object with the specified ID encrypt all its chunks again,
+// instead of reusing the chunks which are not modified.
+func (o *Service) ReencryptAll(id string) {
+	o.manifests.setReencrypt(id)
+}
+
+// Wait blocks until all the committed objects are encrypted and uploaded.
+func (o *Service) Wait() {
+	o.pending.Wait()
+}
+
+// Remove removes the object with the specified ID of a removed file from the cache, the repository and
+// the cloud storage. If the object is chunked, its chunks are removed as well: they are listed in the
+// manifest of the object, which is opened with the key returned by getKey for the key ID of the object.
+func (o *Service) Remove(id string, dir string, getKey func(keyId string) (*core.KeyInfo, error)) error {
+	o.readers.remove(id)
+	if err := o.objectCacheRepo.RemoveFromCache(id); err != nil {
+		return err
+	}
+	chunks, err := o.objectChunks(id, dir, getKey)
+	if err != nil {
+		return err
+	}
+	o.manifests.remove(id)
+	o.queueRemoval(chunks)
+	o.pending.Add(1)
+	o.uploadChan <- uploadChanItem{id: id, dir: dir, remove: true}
 	return nil
 }
 
-// RemoveFromCache removes the object with the specified ID from the cache.
+// RemoveFromCache removes the object with the specified ID from the cache, and closes it if it is open
+// for the reads of ReadAt, since the file is closed.
 // It returns an error if the removal operation fails.
 // If the object is not in the cache, it returns nil (no error).
 func (o *Service) RemoveFromCache(id string) error {
+	o.readers.remove(id)
 	return o.objectCacheRepo.RemoveFromCache(id)
 }
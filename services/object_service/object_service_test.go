@@ -0,0 +1,141 @@
+package object_service
+
+import (
+	"bytes"
+	"ctb-cli/core"
+	"ctb-cli/crypto/stream"
+	"ctb-cli/objectstorage/local"
+	"ctb-cli/repositories"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testService is an object service of a repository in a temporary directory, storing the objects in a local storage.
+type testService struct {
+	*Service
+	objectRepo *repositories.ObjectRepository
+	storage    *local.Client
+	key        *core.KeyInfo
+}
+
+// newTestService creates the object service of an empty repository.
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".meta", ".object"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	cache := repositories.NewObjectCacheRepository(t.TempDir())
+	objectRepo := repositories.NewObjectRepository(root, repositories.NewPathResolver(root))
+	storage := local.NewClient(t.TempDir())
+	service := NewService(&cache, &objectRepo, storage, nil)
+	key := core.NewKeyInfo("key", core.NewKeyFromRand())
+	return &testService{Service: &service, objectRepo: &objectRepo, storage: storage, key: &key}
+}
+
+// commit writes the content at the offset of the object in the write cache, and commits it.
+func (s *testService) commit(t *testing.T, id string, content []byte, ofst int64) {
+	t.Helper()
+	if _, err := s.Write(id, content, ofst); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit(core.Link{ObjectId: id}, "/", s.key, false, stream.ChaCha20Poly1305); err != nil {
+		t.Fatal(err)
+	}
+	s.Wait()
+}
+
+// checkChunks checks whether the chunks are stored in the repository and in the storage.
+func (s *testService) checkChunks(t *testing.T, chunks []manifestChunk, expected bool) {
+	t.Helper()
+	for _, chunk := range chunks {
+		if s.objectRepo.IsChunkInRepo(chunk.Id) != expected {
+			t.Errorf("Expected the chunk %s to be in the repository: %v", chunk.Id, expected)
+		}
+		file, err := os.Create(filepath.Join(t.TempDir(), chunk.Id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.storage.Download(chunk.Id, file)
+		file.Close()
+		if (err == nil) != expected {
+			t.Errorf("Expected the chunk %s to be in the storage: %v, got %v", chunk.Id, expected, err)
+		}
+	}
+}
+
+func TestUnreferencedChunksRemoved(t *testing.T) {
+	s := newTestService(t)
+	if err := s.Create("first"); err != nil {
+		t.Fatal(err)
+	}
+	s.commit(t, "first", bytes.Repeat([]byte{1}, 3*core.ObjectChunkSize), 0)
+	first := s.manifests.get("first")
+	if first == nil || len(first.Chunks) != 3 {
+		t.Fatalf("Expected a manifest of 3 chunks, got %+v", first)
+	}
+
+	// Only the modified chunk is replaced, and removed
+	if err := s.Move("first", "second"); err != nil {
+		t.Fatal(err)
+	}
+	s.commit(t, "second", []byte{2}, core.ObjectChunkSize)
+	second := s.manifests.get("second")
+	if second == nil || second.Chunks[1].Id == first.Chunks[1].Id {
+		t.Fatalf("Expected the modified chunk to be replaced, got %+v", second)
+	}
+	s.checkChunks(t, first.Chunks[1:2], false)
+	s.checkChunks(t, second.Chunks, true)
+
+	// All the chunks are removed with the object
+	err := s.Remove("second", "/", func(keyId string) (*core.KeyInfo, error) {
+		return s.key, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Wait()
+	s.checkChunks(t, second.Chunks, false)
+	if s.objectRepo.IsInRepo("second", "/") {
+		t.Error("Expected the removed object not to be in the repository")
+	}
+}
+
+func TestReadAtKeepsObjectOpen(t *testing.T) {
+	s := newTestService(t)
+	content := make([]byte, 2*core.ObjectChunkSize+10)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	for _, c := range []struct {
+		id      string
+		content []byte
+	}{{"small", content[:100]}, {"chunked", content}} {
+		if err := s.Create(c.id); err != nil {
+			t.Fatal(err)
+		}
+		s.commit(t, c.id, c.content, 0)
+		if err := s.RemoveFromCache(c.id); err != nil {
+			t.Fatal(err)
+		}
+
+		// The reads across the chunks reuse the open object
+		for _, ofst := range []int64{0, int64(len(c.content)) - 20, 10} {
+			buff := make([]byte, 20)
+			n, err := s.ReadAt(c.id, "/", buff, ofst, s.key)
+			if err != nil || !bytes.Equal(buff[:n], c.content[ofst:ofst+20]) {
+				t.Errorf("Unexpected read of %s at %d: %d, %v", c.id, ofst, n, err)
+			}
+			if s.readers.get(c.id) == nil {
+				t.Errorf("Expected %s to be kept open", c.id)
+			}
+		}
+		if err := s.RemoveFromCache(c.id); err != nil {
+			t.Fatal(err)
+		}
+		if s.readers.get(c.id) != nil {
+			t.Errorf("Expected %s to be closed", c.id)
+		}
+	}
+}
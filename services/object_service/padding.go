@@ -0,0 +1,27 @@
+package object_service
+
+import "math/bits"
+
+// paddedSize returns the size of the padded object for an object of the given size.
+// It uses the Padmé scheme: the size is rounded up so that only O(log log n) bits of it are exposed,
+// with a storage overhead of at most 12%.
+func paddedSize(size int64) int64 {
+	if size < 2 {
+		return size
+	}
+	// e is floor(log2(size)) and s is floor(log2(e)) + 1
+	e := bits.Len64(uint64(size)) - 1
+	s := bits.Len64(uint64(e))
+	// Clear the last e-s bits of the size, rounding up
+	mask := int64(1)<<(e-s) - 1
+	return (size + mask) &^ mask
+}
+
+// zeroReader is an io.Reader that returns an infinite stream of zero bytes.
+type zeroReader struct{}
+
+// Read fills the buffer with zero bytes.
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
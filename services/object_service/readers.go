@@ -0,0 +1,169 @@
+package object_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/file_crypto"
+	"ctb-cli/crypto/stream"
+	"io"
+	"os"
+	"sync"
+)
+
+// openObject is an object of the repository opened for random access, kept open between the reads
+// until the object is committed or closed. Its header and content are verified once, when it is opened.
+type openObject struct {
+	mu       sync.Mutex
+	file     *os.File         // file of the object, nil if the object is chunked
+	reader   *stream.ReaderAt // reader of the content, nil if the object is chunked
+	manifest *manifest        // manifest of the object, nil if the object is not chunked
+	chunk    *openChunk       // last chunk read, kept open for the sequential reads
+}
+
+// openChunk is a chunk of a chunked object opened for random access.
+type openChunk struct {
+	id     string
+	file   *os.File
+	reader *stream.ReaderAt
+}
+
+// close closes the files of the object and of its last chunk read.
+func (object *openObject) close() {
+	object.mu.Lock()
+	defer object.mu.Unlock()
+	if object.file != nil {
+		_ = object.file.Close()
+	}
+	if object.chunk != nil {
+		_ = object.chunk.file.Close()
+		object.chunk = nil
+	}
+}
+
+// readerStore keeps the open objects by object ID.
+type readerStore struct {
+	sync.Mutex
+	objects map[string]*openObject
+}
+
+// newReaderStore creates a new empty readerStore.
+func newReaderStore() *readerStore {
+	return &readerStore{
+		objects: make(map[string]*openObject),
+	}
+}
+
+// get returns the open object with the specified ID, or nil if it is not open.
+func (s *readerStore) get(id string) *openObject {
+	s.Lock()
+	defer s.Unlock()
+	return s.objects[id]
+}
+
+// add stores the open object with the specified ID and returns it. If the object was opened
+// concurrently, the object already stored is returned and the new one is closed.
+func (s *readerStore) add(id string, object *openObject) *openObject {
+	s.Lock()
+	defer s.Unlock()
+	if existing, ok := s.objects[id]; ok {
+		object.close()
+		return existing
+	}
+	s.objects[id] = object
+	return object
+}
+
+// remove closes and forgets the open object with the specified ID, if it is open.
+func (s *readerStore) remove(id string) {
+	s.Lock()
+	object, ok := s.objects[id]
+	delete(s.objects, id)
+	s.Unlock()
+	if ok {
+		object.close()
+	}
+}
+
+// openObject returns the object with the specified ID opened for random access, opening it if needed.
+// The object is downloaded if it is not in the repository.
+func (o *Service) openObject(id string, dir string, key *core.KeyInfo) (*openObject, error) {
+	if object := o.readers.get(id); object != nil {
+		return object, nil
+	}
+	//if the object is not in the repo, download it
+	if !o.objectRepo.IsInRepo(id, dir) {
+		err := o.downloadToObject(id, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	//open object from repo
+	file, err := o.objectRepo.OpenObjectFile(id, dir)
+	if err != nil {
+		return nil, err
+	}
+	object, err := o.parseObject(id, dir, file, key)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return o.readers.add(id, object), nil
+}
+
+// parseObject parses and verifies the object read from the file.
+// The file is closed once the manifest is read if the object is chunked, and kept open otherwise.
+func (o *Service) parseObject(id string, dir string, file *os.File, key *core.KeyInfo) (*openObject, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	//Create a random access reader from the encrypted file and the key
+	header, reader, err := file_crypto.NewReaderAt(file, stat.Size(), key)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.verifyHeader(id, header); err != nil {
+		return nil, err
+	}
+	//If the object is chunked, only its manifest is kept
+	if header.ContentType == file_crypto.ContentTypeManifest {
+		m, err := o.openManifest(id, dir, key)
+		if err != nil {
+			return nil, err
+		}
+		_ = file.Close()
+		return &openObject{manifest: m}, nil
+	}
+	//Verify the whole content before the first partial read
+	err = o.verifyContent(id, header.ContentHash, io.NewSectionReader(reader, 0, reader.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return &openObject{file: file, reader: reader}, nil
+}
+
+// readOpenObjectAt reads the open object starting at the specified offset.
+func (o *Service) readOpenObjectAt(object *openObject, buff []byte, ofst int64) (int, error) {
+	if object.manifest == nil {
+		return object.reader.ReadAt(buff, ofst)
+	}
+	return o.readManifestAt(object.manifest, buff, ofst, object.readChunkAt(o))
+}
+
+// readChunkAt returns a function reading the chunks of the open object, which keeps the last chunk read open.
+func (object *openObject) readChunkAt(o *Service) func(chunk manifestChunk, buff []byte, ofst int64) (int, error) {
+	return func(chunk manifestChunk, buff []byte, ofst int64) (int, error) {
+		object.mu.Lock()
+		defer object.mu.Unlock()
+		if object.chunk == nil || object.chunk.id != chunk.Id {
+			file, reader, err := o.openChunk(chunk)
+			if err != nil {
+				return 0, err
+			}
+			if object.chunk != nil {
+				_ = object.chunk.file.Close()
+			}
+			object.chunk = &openChunk{id: chunk.Id, file: file, reader: reader}
+		}
+		return object.chunk.reader.ReadAt(buff, ofst)
+	}
+}
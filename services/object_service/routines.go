@@ -1,6 +1,10 @@
 package object_service
 
 import (
+	"crypto/sha256"
+	"ctb-cli/core"
+	"ctb-cli/crypto/file_crypto"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -12,6 +16,7 @@ func (o *Service) StartEncryptRoutine() {
 	for {
 		item := <-o.encryptChan
 		err := o.encrypt(item)
+		o.pending.Done()
 		if err != nil {
 			continue
 		}
@@ -20,15 +25,24 @@ func (o *Service) StartEncryptRoutine() {
 
 // encrypt encrypts the object identified by the given ID using the provided encryption key.
 // It opens the object file, creates an output file, and copies the encrypted content from the input file to the output file.
+// Objects larger than a chunk are stored as a manifest of chunks: only the modified chunks are encrypted and uploaded,
+// and the manifest is encrypted with the provided key.
+// If padding is requested, zero bytes are appended to the content before encryption.
 // After encrypting the file, it flushes the object from the cache and triggers an upload of the encrypted file.
+// The chunks of the previous version of the object which are no longer referenced are then removed.
 // The function returns an error if any operation fails.
 func (o *Service) encrypt(e encryptChanItem) (err error) {
+	base, reuse := o.manifests.takeBase(e.id)
 	//Open object file
 	inputFile, err := o.objectCacheRepo.AsFile(e.id)
 	defer closeFile(inputFile)
 	if err != nil {
 		return fmt.Errorf("failed to open input file: %w", err)
 	}
+	stat, err := inputFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat input file: %w", err)
+	}
 
 	//Create output file
 	file, err := o.objectRepo.CreateFile(e.id, e.dir)
@@ -37,39 +51,145 @@ func (o *Service) encrypt(e encryptChanItem) (err error) {
 	}
 	defer file.Close()
 
-	//Create encrypted writer
-	encryptedWriter, err := o.encryptWriter(file, e.id, e.key)
-
-	//Copy to output
-	_, err = io.Copy(encryptedWriter, inputFile)
-	if err != nil {
-		return
-	}
-	//Close encrypted writer
-	err = encryptedWriter.Close()
-	if err != nil {
-		return
+	var m *manifest
+	if stat.Size() > core.ObjectChunkSize {
+		//Encrypt the modified chunks and write the manifest
+		m, err = o.encryptChunks(e, inputFile, stat.Size(), base, reuse)
+		if err != nil {
+			return
+		}
+		err = o.writeManifest(file, e, m)
+		if err != nil {
+			return
+		}
+	} else {
+		//Encrypt the whole object
+		err = o.encryptWhole(file, e, inputFile, stat.Size())
+		if err != nil {
+			return
+		}
 	}
 	//Flush the object from the cache
 	err = o.objectCacheRepo.Flush(e.id)
 	if err != nil {
 		return
 	}
+	if m != nil {
+		o.manifests.set(e.id, m)
+	}
 	fmt.Printf("File Encrypted: %s \n", e.id)
 
 	//Trigger upload
+	o.pending.Add(1)
 	o.uploadChan <- uploadChanItem{id: e.id, dir: e.dir}
 
+	//Remove the chunks of the previous version, once the new version is uploaded
+	o.queueRemoval(unreferencedChunks(base, m))
+	return nil
+}
+
+// encryptWhole encrypts the whole content of the input file of the given size to the output writer.
+// The content is read twice: once to sign its hash in the header, then to encrypt it.
+func (o *Service) encryptWhole(output io.Writer, e encryptChanItem, inputFile io.ReaderAt, size int64) error {
+	//The content is the input file, padded with zero bytes if requested
+	content := func() io.Reader {
+		var reader io.Reader = io.NewSectionReader(inputFile, 0, size)
+		if e.pad {
+			reader = io.MultiReader(reader, io.LimitReader(zeroReader{}, paddedSize(size)-size))
+		}
+		return reader
+	}
+	hash, err := hashContent(content())
+	if err != nil {
+		return err
+	}
+	//Create encrypted writer
+	encryptedWriter, err := o.encryptWriter(output, e.id, e.key, file_crypto.ContentTypeData, e.alg, hash)
+	if err != nil {
+		return err
+	}
+	//Copy to output
+	_, err = io.Copy(encryptedWriter, content())
+	if err != nil {
+		return err
+	}
+	//Close encrypted writer
+	err = encryptedWriter.Close()
+	if err != nil {
+		return err
+	}
+	o.verified.set(e.id)
 	return nil
 }
 
+// encryptChunks encrypts the chunks of the input file of the given size and returns the manifest of the object.
+// If reuse is true, the chunks which are not modified since the object was moved to the write cache are reused
+// from the base manifest.
+// If padding is requested, the last chunk is padded, without exceeding the chunk size.
+func (o *Service) encryptChunks(e encryptChanItem, inputFile io.ReaderAt, size int64, base *manifest, reuse bool) (*manifest, error) {
+	//Compute the padding of the last chunk
+	padding := int64(0)
+	if e.pad {
+		chunksEnd := (size + core.ObjectChunkSize - 1) / core.ObjectChunkSize * core.ObjectChunkSize
+		padding = min(paddedSize(size), chunksEnd) - size
+	}
+	m := &manifest{}
+	for idx, start := int64(0), int64(0); start < size; idx, start = idx+1, start+core.ObjectChunkSize {
+		chunkSize := min(core.ObjectChunkSize, size-start)
+		isLast := start+chunkSize == size
+		//Reuse the chunk of the base if it is not modified
+		if reuse && base != nil && idx < int64(len(base.Chunks)) && base.Chunks[idx].Size == chunkSize &&
+			!o.objectCacheRepo.IsChunkDirty(e.id, idx) && !(isLast && padding > 0) {
+			m.Chunks = append(m.Chunks, base.Chunks[idx])
+			m.Size += chunkSize
+			continue
+		}
+		//Encrypt the chunk
+		var reader io.Reader = io.NewSectionReader(inputFile, start, chunkSize)
+		if isLast && padding > 0 {
+			reader = io.MultiReader(reader, io.LimitReader(zeroReader{}, padding))
+		}
+		chunk, err := o.writeChunk(reader, e.alg)
+		if err != nil {
+			return nil, err
+		}
+		m.Chunks = append(m.Chunks, chunk)
+		m.Size += chunk.Size
+	}
+	return m, nil
+}
+
+// writeManifest serializes the manifest and encrypts it to the output writer with the object key.
+func (o *Service) writeManifest(output io.Writer, e encryptChanItem, m *manifest) error {
+	js, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(js)
+	encryptedWriter, err := o.encryptWriter(output, e.id, e.key, file_crypto.ContentTypeManifest, e.alg, hash[:])
+	if err != nil {
+		return err
+	}
+	if _, err := encryptedWriter.Write(js); err != nil {
+		return err
+	}
+	return encryptedWriter.Close()
+}
+
 // StartUploadRoutine starts a routine that listens to the upload channel and processes the items.
 // It continuously receives items from the upload channel and calls the upload method to handle each item.
+// Items which are no longer referenced are removed instead.
 // If an error occurs during the upload process, it will continue to the next item.
 func (o *Service) StartUploadRoutine() {
 	for {
 		item := <-o.uploadChan
-		err := o.upload(item.id, item.dir)
+		var err error
+		if item.remove {
+			err = o.remove(item.id, item.dir, item.isChunk)
+		} else {
+			err = o.upload(item.id, item.dir, item.isChunk)
+		}
+		o.pending.Done()
 		if err != nil {
 			continue
 		}
@@ -77,9 +197,17 @@ func (o *Service) StartUploadRoutine() {
 }
 
 // upload uploads the file with the specified ID.
-func (o *Service) upload(id string, objectPath string) error {
+// Chunks are read from the chunks folder of the repository, other objects from the object folder of their directory.
+func (o *Service) upload(id string, objectPath string, isChunk bool) error {
 	// Get the dir of the object using the object repository
-	path := o.objectRepo.GetPath(id, objectPath)
+	path := o.objectRepo.GetChunkPath(id)
+	if !isChunk {
+		var err error
+		path, err = o.objectRepo.GetPath(id, objectPath)
+		if err != nil {
+			return err
+		}
+	}
 	// Open the file
 	file, err := os.Open(path)
 	if err != nil {
@@ -95,3 +223,22 @@ func (o *Service) upload(id string, objectPath string) error {
 	fmt.Printf("File Uploaded: %s \n", path)
 	return nil
 }
+
+// remove removes the file with the specified ID from the repository, and from the cloud storage if it supports it.
+func (o *Service) remove(id string, objectPath string, isChunk bool) error {
+	path := o.objectRepo.GetChunkPath(id)
+	if !isChunk {
+		var err error
+		path, err = o.objectRepo.GetPath(id, objectPath)
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if remover, ok := o.downloader.(core.CloudRemover); ok {
+		return remover.Remove(id)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package object_service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"ctb-cli/crypto/file_crypto"
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	ErrObjectIdMismatch = errors.New("the object does not have the expected id")
+)
+
+// verifiedStore keeps the IDs of the objects and chunks whose content is verified against their signed hash.
+type verifiedStore struct {
+	sync.Mutex
+	ids map[string]bool
+}
+
+// newVerifiedStore creates a new empty verifiedStore.
+func newVerifiedStore() *verifiedStore {
+	return &verifiedStore{
+		ids: make(map[string]bool),
+	}
+}
+
+// has returns true if the content of the object with the specified ID is verified.
+func (s *verifiedStore) has(id string) bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.ids[id]
+}
+
+// set marks the content of the object with the specified ID as verified.
+func (s *verifiedStore) set(id string) {
+	s.Lock()
+	defer s.Unlock()
+	s.ids[id] = true
+}
+
+// GetSignature returns the signature of the header of the object with the specified ID,
+// with the status of the signature. The content of the object is not verified.
+func (o *Service) GetSignature(id string, dir string) (*core.Signature, string, error) {
+	if !o.objectRepo.IsInRepo(id, dir) {
+		err := o.downloadToObject(id, dir)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	reader, err := o.objectRepo.OpenObject(id, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+	header, _, err := file_crypto.Parse(reader)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.signer == nil {
+		return header.Signature, core.SignatureUnsigned, nil
+	}
+	return header.Signature, header.Verify(o.signer), nil
+}
+
+// verifyHeader checks that the header belongs to the object with the specified ID and that its signature is valid.
+// Objects written before the objects were signed are only accepted if the signer accepts the unsigned records.
+func (o *Service) verifyHeader(id string, header *file_crypto.Header) error {
+	if header.FileID != id {
+		return ErrObjectIdMismatch
+	}
+	if o.signer != nil {
+		return header.Check(o.signer)
+	}
+	return nil
+}
+
+// verifyContent decrypts the whole content of the object or chunk with the specified ID once
+// and checks it against the expected hash, so that it can then be read partially.
+// The content is not checked if there is no expected hash.
+func (o *Service) verifyContent(id string, expected []byte, content io.Reader) error {
+	if expected == nil || o.verified.has(id) {
+		return nil
+	}
+	hash, err := hashContent(content)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, expected) {
+		return file_crypto.ErrContentMismatch
+	}
+	o.verified.set(id)
+	return nil
+}
+
+// hashContent returns the SHA-256 hash of the content read from the reader.
+func hashContent(reader io.Reader) ([]byte, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
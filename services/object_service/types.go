@@ -7,10 +7,14 @@ type encryptChanItem struct {
 	id  string
 	dir string
 	key *core.KeyInfo
+	pad bool   // pad is true if the object must be padded to hide its size
+	alg string // alg is the AEAD algorithm used to encrypt the object and its chunks
 }
 
 // uploadChanItem represents an item to be uploaded.
 type uploadChanItem struct {
-	id  string
-	dir string
+	id      string
+	dir     string
+	isChunk bool // isChunk is true if the item is a chunk of a chunked object
+	remove  bool // remove is true if the item is no longer referenced and must be removed instead of uploaded
 }
@@ -3,7 +3,16 @@ package share_service
 import (
 	"ctb-cli/core"
 	"ctb-cli/repositories"
+	"errors"
 	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrShareBelowRootWithEncryptedNames is returned when sharing a path below the root of a repository with
+	// encrypted names: the names are encrypted with the keys of the parent directories, which the recipient
+	// does not have, so the recipient could not resolve the path of the share.
+	ErrShareBelowRootWithEncryptedNames = errors.New("only the root can be shared when the names are encrypted")
 )
 
 type Service struct {
@@ -28,10 +37,11 @@ func NewService(
 }
 
 // ShareByPublicKey shares a file or directory located at the specified path with the given public key.
+// If expiresAt is not zero, the share expires at this time.
 // It retrieves the key ID associated with the path, decodes the provided public key, and then calls the Share method of the key service.
 // If any error occurs during the process, it is returned.
-func (s *Service) ShareByPublicKey(path string, publicKeyEncoded string) error {
-	keyId, startVaultId, startVaultPath, err := s.GetKeyIdByPath(path)
+func (s *Service) ShareByPublicKey(path string, publicKeyEncoded string, expiresAt time.Time) error {
+	keyId, startVaultId, startVaultPath, err := s.getSharedKeyIdByPath(path)
 	if err != nil {
 		return err
 	}
@@ -39,7 +49,7 @@ func (s *Service) ShareByPublicKey(path string, publicKeyEncoded string) error {
 	if err != nil {
 		return err
 	}
-	err = s.keyService.Share(keyId, startVaultId, startVaultPath, publicKeyBytes, publicKeyEncoded)
+	err = s.keyService.Share(keyId, startVaultId, startVaultPath, publicKeyBytes, publicKeyBytes.String(), expiresAt)
 	if err != nil {
 		return err
 	}
@@ -47,6 +57,47 @@ func (s *Service) ShareByPublicKey(path string, publicKeyEncoded string) error {
 	return nil
 }
 
+// ShareWithGroup shares a file or directory located at the specified path with the group with the given name.
+// The key is sealed with the public key of the group, so that every member of the group has access to it.
+// If expiresAt is not zero, the share expires at this time.
+// If any error occurs during the process, it is returned.
+func (s *Service) ShareWithGroup(path string, name string, expiresAt time.Time) error {
+	keyId, startVaultId, startVaultPath, err := s.getSharedKeyIdByPath(path)
+	if err != nil {
+		return err
+	}
+	group, err := s.keyService.GetGroupByName(name)
+	if err != nil {
+		return err
+	}
+	publicKey, err := core.NewPublicKeyFromEncoded(group.PublicKey)
+	if err != nil {
+		return err
+	}
+	return s.keyService.Share(keyId, startVaultId, startVaultPath, publicKey, group.Id, expiresAt)
+}
+
+// ShareWithPassphrase shares a file or directory located at the specified path with the people knowing the passphrase.
+// The key is sealed with a key derived from the passphrase, for the people who have no key pair.
+// If expiresAt is not zero, the share expires at this time.
+// If any error occurs during the process, it is returned.
+func (s *Service) ShareWithPassphrase(path string, passphrase []byte, expiresAt time.Time) error {
+	keyId, startVaultId, startVaultPath, err := s.getSharedKeyIdByPath(path)
+	if err != nil {
+		return err
+	}
+	return s.keyService.ShareWithPassphrase(keyId, startVaultId, startVaultPath, passphrase, expiresAt)
+}
+
+// getSharedKeyIdByPath retrieves the key ID associated with the path to share, like GetKeyIdByPath.
+// It returns ErrShareBelowRootWithEncryptedNames if the names are encrypted and the path is not the root.
+func (s *Service) getSharedKeyIdByPath(path string) (keyId string, startVaultId string, startVaultPath string, err error) {
+	if s.linkRepository.IsEncryptNames() && filepath.Clean(path) != string(filepath.Separator) {
+		return "", "", "", ErrShareBelowRootWithEncryptedNames
+	}
+	return s.GetKeyIdByPath(path)
+}
+
 // GetKeyIdByPath retrieves the key ID associated with the given path.
 // If the path represents a directory, it retrieves the key ID from the vault link associated with the path.
 // If the path represents a file, it retrieves the key ID from the object service using the object ID associated with the path.
@@ -105,16 +156,33 @@ func (s *Service) GetAccessList(path string) (core.KeyAccessList, error) {
 }
 
 // Unshare removes the sharing of a file or directory specified by the given path
-// with the public key provided. It returns an error if the operation fails.
+// with the public key provided. The share is removed from the vault path where it is stored
+// (the parent directory for a directory, the directory of the file for a file).
+// It returns an error if the operation fails.
 func (s *Service) Unshare(path string, publicKeyEncoded string) error {
-	keyId, _, _, err := s.GetKeyIdByPath(path)
+	keyId, _, startVaultPath, err := s.GetKeyIdByPath(path)
 	if err != nil {
 		return err
 	}
-	err = s.keyService.Unshare(keyId, publicKeyEncoded, path)
+	err = s.keyService.Unshare(keyId, publicKeyEncoded, startVaultPath)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// UnshareWithGroup removes the sharing of a file or directory specified by the given path
+// with the group with the given name.
+// It returns an error if the operation fails.
+func (s *Service) UnshareWithGroup(path string, name string) error {
+	group, err := s.keyService.GetGroupByName(name)
+	if err != nil {
+		return err
+	}
+	keyId, _, startVaultPath, err := s.GetKeyIdByPath(path)
+	if err != nil {
+		return err
+	}
+	return s.keyService.Unshare(keyId, group.Id, startVaultPath)
+}